@@ -3,11 +3,14 @@ package main
 import (
 	goflag "flag"
 	"fmt"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/cmd/server/auth"
 	gin_gonic_http_srv "github.com/redhat-ai-dev/model-catalog-bridge/pkg/cmd/server/location/server"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/config"
 	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/types"
 	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/util"
 	"k8s.io/klog/v2"
 	"os"
+	ctrl "sigs.k8s.io/controller-runtime"
 	"strings"
 )
 
@@ -34,8 +37,20 @@ func main() {
 	if len(nfstr) == 0 {
 		nf = types.JsonArrayForamt
 	}
-	server := gin_gonic_http_srv.NewImportLocationServer(st, address, nf)
-	stopCh := util.SetupSignalHandler()
-	server.Run(stopCh)
+	cfg, _ := util.GetK8sConfig(&config.Config{})
+	authz, err := auth.NewAuthorizerFromEnv(cfg)
+	if err != nil {
+		klog.Errorf("%s", err.Error())
+		klog.Flush()
+		os.Exit(1)
+	}
+	server := gin_gonic_http_srv.NewImportLocationServer(st, address, nf, authz)
+	// on SIGTERM the server drains, failing readiness and then finishing the requests in flight, before we exit
+	err = server.Run(ctrl.SetupSignalHandler())
+	if err != nil {
+		klog.Errorf("%s", err.Error())
+		klog.Flush()
+		os.Exit(1)
+	}
 
 }
@@ -0,0 +1,125 @@
+package main
+
+import (
+	goflag "flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/cmd/server/storage"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/cmd/server/storage/backup"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/types"
+	"k8s.io/klog/v2"
+)
+
+const usage = `usage:
+  storage-migrate export -from <storage type> -file <archive>
+  storage-migrate import -to <storage type> -file <archive> [-overwrite]
+
+The storage types are the STORAGE_TYPE values, and each is configured with the same env vars storage-rest uses.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	var storageType, file string
+	var overwrite bool
+	flagset := goflag.NewFlagSet("storage-migrate "+os.Args[1], goflag.ExitOnError)
+	klog.InitFlags(flagset)
+	flagset.StringVar(&file, "file", "", "The backup archive to write or read.")
+
+	var err error
+	switch os.Args[1] {
+	case "export":
+		flagset.StringVar(&storageType, "from", string(types.ConfigMapBridgeStorage), "The storage type to export.")
+		flagset.Parse(os.Args[2:])
+		err = export(types.BridgeStorageType(storageType), file)
+	case "import":
+		flagset.StringVar(&storageType, "to", "", "The storage type to import into.")
+		flagset.BoolVar(&overwrite, "overwrite", false, "Replace keys which are already in storage with different content.")
+		flagset.Parse(os.Args[2:])
+		err = importArchive(types.BridgeStorageType(storageType), file, overwrite)
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		klog.Errorf("%s", err.Error())
+		klog.Flush()
+		os.Exit(1)
+	}
+	klog.Flush()
+}
+
+// newStorage is stricter than storage.NewBridgeStorage, which falls back to ConfigMap storage for unknown types
+func newStorage(storageType types.BridgeStorageType) (types.BridgeStorage, error) {
+	switch storageType {
+	case types.ConfigMapBridgeStorage, types.GithubBridgeStorage, types.GitBridgeStorage, types.CustomResourceBridgeStorage, types.SQLiteBridgeStorage:
+	default:
+		return nil, fmt.Errorf("unknown storage type %q", storageType)
+	}
+	st := storage.NewBridgeStorage(storageType)
+	if st == nil {
+		return nil, fmt.Errorf("unable to initialize storage of type %s", storageType)
+	}
+	return st, nil
+}
+
+// closeStorage lets the backends which hold files open, like SQLite, flush them
+func closeStorage(st types.BridgeStorage) {
+	if c, ok := st.(io.Closer); ok {
+		if err := c.Close(); err != nil {
+			klog.Errorf("error closing storage: %s", err.Error())
+		}
+	}
+}
+
+func export(storageType types.BridgeStorageType, file string) error {
+	if len(file) == 0 {
+		return fmt.Errorf("the -file flag is required")
+	}
+	st, err := newStorage(storageType)
+	if err != nil {
+		return err
+	}
+	defer closeStorage(st)
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	manifest, err := backup.Export(st, storageType, f)
+	if err != nil {
+		f.Close()
+		os.Remove(file)
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	klog.Infof("exported %d keys from %s storage to %s", len(manifest.Entries), storageType, file)
+	return nil
+}
+
+func importArchive(storageType types.BridgeStorageType, file string, overwrite bool) error {
+	if len(file) == 0 {
+		return fmt.Errorf("the -file flag is required")
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	st, err := newStorage(storageType)
+	if err != nil {
+		return err
+	}
+	defer closeStorage(st)
+	result, err := backup.Import(f, st, backup.ImportOptions{Overwrite: overwrite})
+	if err != nil {
+		return err
+	}
+	klog.Infof("imported %s into %s storage: %d created, %d updated, %d unchanged", file, storageType, len(result.Created), len(result.Updated), len(result.Unchanged))
+	return nil
+}
@@ -3,11 +3,13 @@ package main
 import (
 	goflag "flag"
 	"fmt"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/cmd/server/auth"
 	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/cmd/server/storage"
 	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/types"
 	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/util"
 	"k8s.io/klog/v2"
 	"os"
+	ctrl "sigs.k8s.io/controller-runtime"
 	"strings"
 )
 
@@ -23,6 +25,11 @@ func main() {
 	storageType := types.BridgeStorageType(st)
 
 	bs := storage.NewBridgeStorage(storageType)
+	if bs == nil {
+		klog.Errorf("unable to initialize storage of type %s", storageType)
+		klog.Flush()
+		os.Exit(1)
+	}
 
 	// setup ca.crt for TLS, get k8s cfg to find bkstg route
 	restConfig, err := storage.GetRESTConfig()
@@ -54,8 +61,20 @@ func main() {
 	nfstr := os.Getenv(types.FormatEnvVar)
 	nf := types.NormalizerFormat(nfstr)
 
-	server := storage.NewStorageRESTServer(bs, address, bridgeURL, bridgeToken, bkstgToken, nf)
-	stopCh := util.SetupSignalHandler()
-	server.Run(stopCh)
+	authz, err := auth.NewAuthorizerFromEnv(restConfig)
+	if err != nil {
+		klog.Errorf("%s", err.Error())
+		klog.Flush()
+		os.Exit(1)
+	}
+
+	server := storage.NewStorageRESTServer(bs, address, bridgeURL, bridgeToken, bkstgToken, nf, authz)
+	// on SIGTERM the server drains, failing readiness and then finishing the requests in flight, before we exit
+	err = server.Run(ctrl.SetupSignalHandler())
+	if err != nil {
+		klog.Errorf("%s", err.Error())
+		klog.Flush()
+		os.Exit(1)
+	}
 
 }
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/rest"
+	nurl "net/url"
 )
 
 func (b *BackstageRESTClientWrapper) ListEntities() (string, error) {
@@ -17,3 +18,49 @@ func (b *BackstageRESTClientWrapper) ListEntities() (string, error) {
 	err = json.Indent(buffer, buf, "", "    ")
 	return buffer.String(), err
 }
+
+// Ping checks that Backstage answers catalog requests, by asking for at most one entity
+func (b *BackstageRESTClientWrapper) Ping() error {
+	_, err := b.getFromBackstage(b.RootURL + rest.ENTITIES_URI + "?limit=1")
+	return err
+}
+
+type entityQueryResponse struct {
+	Items    []map[string]any `json:"items"`
+	PageInfo struct {
+		NextCursor string `json:"nextCursor,omitempty"`
+	} `json:"pageInfo"`
+}
+
+// QueryEntityMaps returns the entities from the Backstage /entities/by-query endpoint matching any of the filters,
+// following the page cursors until the result set is exhausted
+func (b *BackstageRESTClientWrapper) QueryEntityMaps(filters ...string) ([]map[string]any, error) {
+	qparms := &nurl.Values{}
+	for _, filter := range filters {
+		qparms.Add("filter", filter)
+	}
+	items := []map[string]any{}
+	for {
+		url := b.RootURL + rest.QUERY_URI
+		resp, err := backstageRESTClient.RESTClient.R().SetAuthToken(b.Token).SetHeader("Accept", "application/json").SetQueryParamsFromValues(*qparms).Get(url)
+		if err != nil {
+			return nil, err
+		}
+		str, err := b.processFetch(resp, url, "get")
+		if err != nil {
+			return nil, err
+		}
+		page := entityQueryResponse{}
+		err = json.Unmarshal([]byte(str), &page)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, page.Items...)
+		if len(page.PageInfo.NextCursor) == 0 {
+			return items, nil
+		}
+		// the cursor encodes the original filters, so Backstage expects it on its own
+		qparms = &nurl.Values{}
+		qparms.Set("cursor", page.PageInfo.NextCursor)
+	}
+}
@@ -14,3 +14,33 @@ func TestListEntities(t *testing.T) {
 	common.AssertError(t, err)
 	common.AssertEqual(t, common.TestJSONStringIndented, str)
 }
+
+func TestQueryEntityMaps(t *testing.T) {
+	ts := backstage.CreateServer(t)
+	defer ts.Close()
+
+	items, err := (&BackstageRESTClientWrapper{RESTClient: common.DC(), RootURL: ts.URL}).QueryEntityMaps("kind=component")
+	common.AssertError(t, err)
+	if len(items) == 0 {
+		t.Fatal("expected component entities")
+	}
+	for _, item := range items {
+		if item["kind"] != "Component" {
+			t.Errorf("unexpected entity kind %v", item["kind"])
+		}
+	}
+}
+
+func TestPing(t *testing.T) {
+	ts := backstage.CreateServer(t)
+	defer ts.Close()
+
+	err := (&BackstageRESTClientWrapper{RESTClient: common.DC(), RootURL: ts.URL}).Ping()
+	common.AssertError(t, err)
+
+	ts.Close()
+	err = (&BackstageRESTClientWrapper{RESTClient: common.DC(), RootURL: ts.URL}).Ping()
+	if err == nil {
+		t.Error("expected an error once backstage is gone")
+	}
+}
@@ -99,13 +99,24 @@ type APIPopulator interface {
 	GetDependencyOf() []string
 }
 
+// AnnotationsPopulator is a populator with annotations of its own to add to those of the entity
+type AnnotationsPopulator interface {
+	GetAnnotations() map[string]string
+}
+
+// APITypePopulator is an APIPopulator which knows the type of its API, rather than it being worked out from the
+// definition
+type APITypePopulator interface {
+	GetAPIType() string
+}
+
 func PrintComponent(pop ComponentPopulator, writer io.Writer) error {
 	component := &ComponentEntityV1alpha1{
 		Kind:       "Component",
 		ApiVersion: VERSION,
 		Entity:     buildEntity("Component", pop),
 	}
-	component.Entity.Metadata.Annotations = map[string]string{TECHDOC_REFS: pop.GetTechdocRef()}
+	component.Entity.Metadata.Annotations = buildAnnotations(pop)
 	component.Metadata = component.Entity.Metadata
 	component.Spec = &ComponentEntityV1alpha1Spec{
 		Type:         COMPONENT_TYPE,
@@ -129,7 +140,7 @@ func PrintResource(pop ResourcePopulator, writer io.Writer) error {
 		ApiVersion: VERSION,
 		Entity:     buildEntity("Resource", pop),
 	}
-	resource.Entity.Metadata.Annotations = map[string]string{TECHDOC_REFS: pop.GetTechdocRef()}
+	resource.Entity.Metadata.Annotations = buildAnnotations(pop)
 	resource.Metadata = resource.Entity.Metadata
 	resource.Spec = &ResourceEntityV1alpha1Spec{
 		Type:         RESOURCE_TYPE,
@@ -153,7 +164,7 @@ func PrintAPI(pop APIPopulator, writer io.Writer) error {
 		ApiVersion: VERSION,
 		Entity:     buildEntity("API", pop),
 	}
-	api.Entity.Metadata.Annotations = map[string]string{TECHDOC_REFS: pop.GetTechdocRef()}
+	api.Entity.Metadata.Annotations = buildAnnotations(pop)
 	api.Metadata = api.Entity.Metadata
 	api.Spec = &ApiEntityV1alpha1Spec{
 		Type:         "",
@@ -163,7 +174,10 @@ func PrintAPI(pop APIPopulator, writer io.Writer) error {
 		DependencyOf: pop.GetDependencyOf(),
 		Profile:      Profile{DisplayName: pop.GetDisplayName()},
 	}
+	tpop, ok := pop.(APITypePopulator)
 	switch {
+	case ok && len(tpop.GetAPIType()) > 0:
+		api.Spec.Type = tpop.GetAPIType()
 	case strings.Contains(api.Spec.Definition, OPENAPI_API_TYPE):
 		api.Spec.Type = OPENAPI_API_TYPE
 	case strings.Contains(api.Spec.Definition, ASYNCAPI_API_TYPE):
@@ -186,6 +200,17 @@ func PrintAPI(pop APIPopulator, writer io.Writer) error {
 	return nil
 }
 
+func buildAnnotations(pop CommonPopulator) map[string]string {
+	annotations := map[string]string{}
+	if apop, ok := pop.(AnnotationsPopulator); ok {
+		for k, v := range apop.GetAnnotations() {
+			annotations[k] = v
+		}
+	}
+	annotations[TECHDOC_REFS] = pop.GetTechdocRef()
+	return annotations
+}
+
 func buildEntity(kind string, pop CommonPopulator) Entity {
 	entity := Entity{
 		Kind:       kind,
@@ -19,6 +19,30 @@ func (b *BackstageRESTClientWrapper) ListLocations() (string, error) {
 	return buffer.String(), err
 }
 
+// ListLocationMaps returns the location records from the Backstage /locations endpoint, unwrapping the "data"
+// envelope Backstage places around each of them
+func (b *BackstageRESTClientWrapper) ListLocationMaps() ([]map[string]any, error) {
+	str, err := b.getFromBackstage(b.RootURL + rest.LOCATION_URI)
+	if err != nil {
+		return nil, err
+	}
+	entries := []map[string]any{}
+	err = json.Unmarshal([]byte(str), &entries)
+	if err != nil {
+		return nil, err
+	}
+	locations := []map[string]any{}
+	for _, entry := range entries {
+		data, ok := entry["data"].(map[string]any)
+		if ok {
+			locations = append(locations, data)
+			continue
+		}
+		locations = append(locations, entry)
+	}
+	return locations, nil
+}
+
 func (b *BackstageRESTClientWrapper) GetLocations(args ...string) (string, error) {
 	if len(args) == 0 {
 		return b.ListLocations()
@@ -0,0 +1,254 @@
+package backstage
+
+// mapping of the model catalog json schema to catalog-info.yaml
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/redhat-ai-dev/model-catalog-bridge/schema/types/golang"
+)
+
+// PrintModelCatalogAsEntities prints the Backstage entities the model catalog becomes, as a multi document
+// catalog-info.yaml: a Component of type model-server and an API for the model server, and a Resource of type ai-model
+// for each model.  These are the entities EntityRefs returns for the model catalog JSON.
+func PrintModelCatalogAsEntities(mc *golang.ModelCatalog, writer io.Writer) error {
+	ms := mc.ModelServer
+	if ms != nil && len(ms.Name) > 0 {
+		err := PrintComponent(&modelServerPopulator{ms: ms, models: mc.Models}, writer)
+		if err != nil {
+			return err
+		}
+	} else {
+		ms = nil
+	}
+	for i := range mc.Models {
+		if len(mc.Models[i].Name) == 0 {
+			continue
+		}
+		err := PrintResource(&modelPopulator{m: &mc.Models[i], ms: ms}, writer)
+		if err != nil {
+			return err
+		}
+	}
+	if ms != nil && ms.API != nil {
+		return PrintAPI(&modelServerAPIPopulator{ms: ms}, writer)
+	}
+	return nil
+}
+
+// ModelCatalogToCatalogInfo converts the model catalog JSON to the catalog-info.yaml of its entities
+func ModelCatalogToCatalogInfo(body []byte) ([]byte, error) {
+	mc := golang.ModelCatalog{}
+	err := json.Unmarshal(body, &mc)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the model catalog: %s", err.Error())
+	}
+	buf := &bytes.Buffer{}
+	err = PrintModelCatalogAsEntities(&mc, buf)
+	if err != nil {
+		return nil, err
+	}
+	// the component and resource printers end with a divider, which is not wanted when there is no API after them
+	return bytes.TrimSuffix(buf.Bytes(), []byte("---\n")), nil
+}
+
+func modelCatalogOwner(owner string) string {
+	return strings.TrimPrefix(owner, "user:")
+}
+
+func modelCatalogLink(url *string, title string) []EntityLink {
+	if url == nil || len(*url) == 0 {
+		return []EntityLink{}
+	}
+	return []EntityLink{{
+		URL:   *url,
+		Title: title,
+		Icon:  LINK_ICON_WEBASSET,
+		Type:  LINK_TYPE_WEBSITE,
+	}}
+}
+
+func modelServerAPILink(ms *golang.ModelServer) []EntityLink {
+	if ms.API == nil {
+		return []EntityLink{}
+	}
+	return modelCatalogLink(&ms.API.URL, LINK_API_URL)
+}
+
+type modelServerPopulator struct {
+	ms     *golang.ModelServer
+	models []golang.Model
+}
+
+func (pop *modelServerPopulator) GetOwner() string {
+	return modelCatalogOwner(pop.ms.Owner)
+}
+
+func (pop *modelServerPopulator) GetLifecycle() string {
+	return pop.ms.Lifecycle
+}
+
+func (pop *modelServerPopulator) GetName() string {
+	return pop.ms.Name
+}
+
+func (pop *modelServerPopulator) GetDescription() string {
+	return pop.ms.Description
+}
+
+func (pop *modelServerPopulator) GetLinks() []EntityLink {
+	return append(modelServerAPILink(pop.ms), modelCatalogLink(pop.ms.HomepageURL, "Homepage")...)
+}
+
+func (pop *modelServerPopulator) GetTags() []string {
+	return pop.ms.Tags
+}
+
+func (pop *modelServerPopulator) GetProvidedAPIs() []string {
+	if pop.ms.API == nil {
+		return []string{}
+	}
+	return []string{pop.ms.Name}
+}
+
+func (pop *modelServerPopulator) GetTechdocRef() string {
+	return "./"
+}
+
+func (pop *modelServerPopulator) GetDisplayName() string {
+	return pop.GetName()
+}
+
+func (pop *modelServerPopulator) GetAnnotations() map[string]string {
+	return pop.ms.Annotations
+}
+
+func (pop *modelServerPopulator) GetDependsOn() []string {
+	depends := []string{}
+	for _, m := range pop.models {
+		if len(m.Name) > 0 {
+			depends = append(depends, "resource:"+m.Name)
+		}
+	}
+	if pop.ms.API != nil {
+		depends = append(depends, "api:"+pop.ms.Name)
+	}
+	return depends
+}
+
+type modelPopulator struct {
+	m *golang.Model
+	// ms is the model server of the model, if there is one
+	ms *golang.ModelServer
+}
+
+func (pop *modelPopulator) GetOwner() string {
+	return modelCatalogOwner(pop.m.Owner)
+}
+
+func (pop *modelPopulator) GetLifecycle() string {
+	return pop.m.Lifecycle
+}
+
+func (pop *modelPopulator) GetName() string {
+	return pop.m.Name
+}
+
+func (pop *modelPopulator) GetDescription() string {
+	return pop.m.Description
+}
+
+func (pop *modelPopulator) GetLinks() []EntityLink {
+	return append(modelCatalogLink(pop.m.ArtifactLocationURL, "Artifact Location"), modelCatalogLink(pop.m.HowToUseURL, "How To Use")...)
+}
+
+func (pop *modelPopulator) GetTags() []string {
+	return pop.m.Tags
+}
+
+func (pop *modelPopulator) GetProvidedAPIs() []string {
+	return []string{}
+}
+
+func (pop *modelPopulator) GetTechdocRef() string {
+	return "resource/"
+}
+
+func (pop *modelPopulator) GetDisplayName() string {
+	return pop.GetName()
+}
+
+func (pop *modelPopulator) GetAnnotations() map[string]string {
+	return pop.m.Annotations
+}
+
+func (pop *modelPopulator) GetDependencyOf() []string {
+	if pop.ms == nil {
+		return []string{}
+	}
+	return []string{"component:" + pop.ms.Name}
+}
+
+type modelServerAPIPopulator struct {
+	ms *golang.ModelServer
+}
+
+func (pop *modelServerAPIPopulator) GetOwner() string {
+	return modelCatalogOwner(pop.ms.Owner)
+}
+
+func (pop *modelServerAPIPopulator) GetLifecycle() string {
+	return pop.ms.Lifecycle
+}
+
+func (pop *modelServerAPIPopulator) GetName() string {
+	return pop.ms.Name
+}
+
+func (pop *modelServerAPIPopulator) GetDescription() string {
+	return pop.ms.Description
+}
+
+func (pop *modelServerAPIPopulator) GetLinks() []EntityLink {
+	return modelServerAPILink(pop.ms)
+}
+
+func (pop *modelServerAPIPopulator) GetTags() []string {
+	return pop.ms.API.Tags
+}
+
+func (pop *modelServerAPIPopulator) GetProvidedAPIs() []string {
+	return []string{}
+}
+
+func (pop *modelServerAPIPopulator) GetTechdocRef() string {
+	return "api/"
+}
+
+func (pop *modelServerAPIPopulator) GetDisplayName() string {
+	return pop.GetName()
+}
+
+func (pop *modelServerAPIPopulator) GetAnnotations() map[string]string {
+	return pop.ms.API.Annotations
+}
+
+func (pop *modelServerAPIPopulator) GetDefinition() string {
+	if len(pop.ms.API.Spec) == 0 {
+		// definition must be set to something to pass backstage validation
+		return "no-definition-yet"
+	}
+	return pop.ms.API.Spec
+}
+
+func (pop *modelServerAPIPopulator) GetAPIType() string {
+	return string(pop.ms.API.Type)
+}
+
+func (pop *modelServerAPIPopulator) GetDependencyOf() []string {
+	return []string{"component:" + pop.ms.Name}
+}
@@ -0,0 +1,102 @@
+package backstage
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/types"
+	"github.com/redhat-ai-dev/model-catalog-bridge/test/stub/common"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+func TestModelCatalogToCatalogInfo(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		body     string
+		expected map[string]func(t *testing.T, e *Entity)
+	}{
+		{
+			name: "model server with api",
+			body: `{"models":[{"name":"mnist-v1","owner":"kubeadmin","lifecycle":"production","description":"mnist","tags":["onnx"],"annotations":{"rhdh.modelcatalog.io/model-name":"mnist"},"artifactLocationURL":"s3://models/mnist"}],
+"modelServer":{"name":"mnist","owner":"user:kubeadmin","lifecycle":"production","description":"serves mnist","homepageURL":"https://mnist.example.com",
+"API":{"url":"https://mnist.example.com/v2","type":"openapi","spec":"","tags":["v2"]}}}`,
+			expected: map[string]func(t *testing.T, e *Entity){
+				"component:default/mnist": func(t *testing.T, e *Entity) {
+					common.AssertEqual(t, COMPONENT_TYPE, e.Spec["type"])
+					common.AssertEqual(t, "user:kubeadmin", e.Spec["owner"])
+					common.AssertEqual(t, []interface{}{"resource:mnist-v1", "api:mnist"}, e.Spec["dependsOn"])
+					common.AssertEqual(t, []interface{}{"mnist"}, e.Spec["providesApis"])
+					common.AssertEqual(t, 2, len(e.Metadata.Links))
+					common.AssertEqual(t, LINK_API_URL, e.Metadata.Links[0].Title)
+				},
+				"resource:default/mnist-v1": func(t *testing.T, e *Entity) {
+					common.AssertEqual(t, RESOURCE_TYPE, e.Spec["type"])
+					common.AssertEqual(t, []interface{}{"component:mnist"}, e.Spec["dependencyOf"])
+					common.AssertEqual(t, "mnist", e.Metadata.Annotations[MODEL_NAME])
+					common.AssertEqual(t, "resource/", e.Metadata.Annotations[TECHDOC_REFS])
+					common.AssertEqual(t, []string{"onnx"}, e.Metadata.Tags)
+					common.AssertEqual(t, "s3://models/mnist", e.Metadata.Links[0].URL)
+				},
+				"api:default/mnist": func(t *testing.T, e *Entity) {
+					common.AssertEqual(t, OPENAPI_API_TYPE, e.Spec["type"])
+					common.AssertEqual(t, "no-definition-yet", e.Spec["definition"])
+					common.AssertEqual(t, []string{"v2"}, e.Metadata.Tags)
+				},
+			},
+		},
+		{
+			name: "models without a model server",
+			body: `{"models":[{"name":"mnist-v1","owner":"kubeadmin","lifecycle":"production","description":"mnist"},{"name":"mnist-v2","owner":"kubeadmin","lifecycle":"production","description":"mnist"}]}`,
+			expected: map[string]func(t *testing.T, e *Entity){
+				"resource:default/mnist-v1": func(t *testing.T, e *Entity) {
+					common.AssertEqual(t, nil, e.Spec["dependencyOf"])
+				},
+				"resource:default/mnist-v2": func(t *testing.T, e *Entity) {},
+			},
+		},
+	} {
+		content, err := ModelCatalogToCatalogInfo([]byte(tc.body))
+		common.AssertError(t, err)
+		if strings.HasSuffix(string(content), "---\n") {
+			t.Errorf("%s: unexpected trailing divider in %s", tc.name, string(content))
+		}
+		dec := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(content), 4096)
+		seen := 0
+		for {
+			entity := Entity{}
+			err = dec.Decode(&entity)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			common.AssertError(t, err)
+			check, ok := tc.expected[entityRef(entity.Kind, entity.Metadata.Namespace, entity.Metadata.Name)]
+			if !ok {
+				t.Errorf("%s: unexpected entity %s %s", tc.name, entity.Kind, entity.Metadata.Name)
+				continue
+			}
+			check(t, &entity)
+			seen++
+		}
+		common.AssertEqual(t, len(tc.expected), seen)
+
+		// the entities are those the model catalog JSON is known as
+		jsonRefs, err := EntityRefs([]byte(tc.body), types.JsonArrayForamt)
+		common.AssertError(t, err)
+		yamlRefs, err := EntityRefs(content, types.CatalogInfoYamlFormat)
+		common.AssertError(t, err)
+		common.AssertEqual(t, len(jsonRefs), len(yamlRefs))
+		for _, ref := range jsonRefs {
+			if _, ok := tc.expected[ref]; !ok {
+				t.Errorf("%s: entity %s not in catalog-info.yaml", tc.name, ref)
+			}
+		}
+	}
+
+	_, err := ModelCatalogToCatalogInfo([]byte("apiVersion: backstage.io/v1alpha1"))
+	if err == nil {
+		t.Error("expected error")
+	}
+}
@@ -0,0 +1,68 @@
+package backstage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/types"
+	"github.com/redhat-ai-dev/model-catalog-bridge/schema/types/golang"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// OwnersAndLifecycles returns the owners and lifecycles set in the model catalog content, in the given format, each
+// sorted and without duplicates: for catalog-info.yaml those in the spec of its entities, and for the model catalog
+// JSON those of the model server and each model
+func OwnersAndLifecycles(body []byte, format types.NormalizerFormat) ([]string, []string, error) {
+	owners := map[string]struct{}{}
+	lifecycles := map[string]struct{}{}
+	add := func(owner, lifecycle string) {
+		if len(owner) > 0 {
+			owners[owner] = struct{}{}
+		}
+		if len(lifecycle) > 0 {
+			lifecycles[lifecycle] = struct{}{}
+		}
+	}
+	switch format {
+	case types.JsonArrayForamt:
+		mc := golang.ModelCatalog{}
+		err := json.Unmarshal(body, &mc)
+		if err != nil {
+			return nil, nil, err
+		}
+		if mc.ModelServer != nil {
+			add(mc.ModelServer.Owner, mc.ModelServer.Lifecycle)
+		}
+		for _, m := range mc.Models {
+			add(m.Owner, m.Lifecycle)
+		}
+	default:
+		dec := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(body), 4096)
+		for {
+			entity := Entity{}
+			err := dec.Decode(&entity)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+			owner, _ := entity.Spec["owner"].(string)
+			lifecycle, _ := entity.Spec["lifecycle"].(string)
+			add(owner, lifecycle)
+		}
+	}
+	return sortedSet(owners), sortedSet(lifecycles), nil
+}
+
+func sortedSet(set map[string]struct{}) []string {
+	values := make([]string, 0, len(set))
+	for v := range set {
+		values = append(values, v)
+	}
+	sort.Strings(values)
+	return values
+}
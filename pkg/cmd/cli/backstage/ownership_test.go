@@ -0,0 +1,40 @@
+package backstage
+
+import (
+	"testing"
+
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/types"
+	"github.com/redhat-ai-dev/model-catalog-bridge/test/stub/common"
+)
+
+func TestOwnersAndLifecycles(t *testing.T) {
+	owners, lifecycles, err := OwnersAndLifecycles([]byte(`apiVersion: backstage.io/v1alpha1
+kind: Component
+metadata:
+  name: mnist-v1
+spec:
+  owner: bob
+  lifecycle: production
+---
+apiVersion: backstage.io/v1alpha1
+kind: Resource
+metadata:
+  name: mnist-v1
+spec:
+  owner: alice
+  lifecycle: production
+`), types.CatalogInfoYamlFormat)
+	common.AssertError(t, err)
+	common.AssertEqual(t, []string{"alice", "bob"}, owners)
+	common.AssertEqual(t, []string{"production"}, lifecycles)
+
+	owners, lifecycles, err = OwnersAndLifecycles([]byte(`{"modelServer": {"name": "vllm", "owner": "bob", "lifecycle": "production"}, "models": [{"name": "mnist", "owner": "alice", "lifecycle": "experimental"}]}`), types.JsonArrayForamt)
+	common.AssertError(t, err)
+	common.AssertEqual(t, []string{"alice", "bob"}, owners)
+	common.AssertEqual(t, []string{"experimental", "production"}, lifecycles)
+
+	_, _, err = OwnersAndLifecycles([]byte("not json"), types.JsonArrayForamt)
+	if err == nil {
+		t.Error("expected an error")
+	}
+}
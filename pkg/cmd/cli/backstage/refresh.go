@@ -0,0 +1,79 @@
+package backstage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/rest"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/types"
+	"github.com/redhat-ai-dev/model-catalog-bridge/schema/types/golang"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// RefreshEntity has Backstage process the entity with the "kind:namespace/name" reference again
+func (b *BackstageRESTClientWrapper) RefreshEntity(entityRef string) error {
+	url := b.RootURL + rest.REFRESH_URI
+	resp, err := backstageRESTClient.RESTClient.R().SetAuthToken(b.Token).SetBody(map[string]interface{}{"entityRef": entityRef}).SetHeader("Accept", "application/json").Post(url)
+	if err != nil {
+		return err
+	}
+	rc := resp.StatusCode()
+	if rc != 200 && rc != 201 && rc != 204 {
+		return fmt.Errorf("refresh of %s for %s rc %d body %s\n", entityRef, url, rc, resp.String())
+	}
+	return nil
+}
+
+func entityRef(kind, namespace, name string) string {
+	if len(namespace) == 0 {
+		namespace = rest.DEFAULT_NS
+	}
+	return strings.ToLower(fmt.Sprintf("%s:%s/%s", kind, namespace, name))
+}
+
+// EntityRefs returns the references of the Backstage entities the model catalog content, in the given format, becomes:
+// for catalog-info.yaml the entities it lists, and for the model catalog JSON, a Component and API for the model
+// server and a Resource for each model
+func EntityRefs(body []byte, format types.NormalizerFormat) ([]string, error) {
+	refs := []string{}
+	switch format {
+	case types.JsonArrayForamt:
+		mc := golang.ModelCatalog{}
+		err := json.Unmarshal(body, &mc)
+		if err != nil {
+			return nil, err
+		}
+		if mc.ModelServer != nil && len(mc.ModelServer.Name) > 0 {
+			refs = append(refs, entityRef("component", "", mc.ModelServer.Name))
+			if mc.ModelServer.API != nil {
+				refs = append(refs, entityRef("api", "", mc.ModelServer.Name))
+			}
+		}
+		for _, m := range mc.Models {
+			if len(m.Name) > 0 {
+				refs = append(refs, entityRef("resource", "", m.Name))
+			}
+		}
+	default:
+		dec := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(body), 4096)
+		for {
+			entity := Entity{}
+			err := dec.Decode(&entity)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			if len(entity.Kind) == 0 || len(entity.Metadata.Name) == 0 {
+				continue
+			}
+			refs = append(refs, entityRef(entity.Kind, entity.Metadata.Namespace, entity.Metadata.Name))
+		}
+	}
+	return refs, nil
+}
@@ -0,0 +1,76 @@
+package backstage
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/types"
+	"github.com/redhat-ai-dev/model-catalog-bridge/test/stub/backstage"
+	"github.com/redhat-ai-dev/model-catalog-bridge/test/stub/common"
+)
+
+func TestRefreshEntity(t *testing.T) {
+	callback := sync.Map{}
+	ts := backstage.CreateBackstageServerWithCallbackMap(&callback, t)
+	defer ts.Close()
+
+	err := (&BackstageRESTClientWrapper{RESTClient: common.DC(), RootURL: ts.URL}).RefreshEntity("component:default/mnist-v1")
+	common.AssertError(t, err)
+	if _, ok := callback.Load("refresh/component:default/mnist-v1"); !ok {
+		t.Error("expected the entity to be refreshed")
+	}
+	err = (&BackstageRESTClientWrapper{RESTClient: common.DC(), RootURL: ts.URL}).RefreshEntity("")
+	if err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestEntityRefs(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		body     string
+		format   types.NormalizerFormat
+		expected string
+	}{
+		{
+			name: "catalog-info",
+			body: `apiVersion: backstage.io/v1alpha1
+kind: Component
+metadata:
+  name: mnist-v1
+spec:
+  type: model-server
+---
+apiVersion: backstage.io/v1alpha1
+kind: Resource
+metadata:
+  name: mnist-v1
+  namespace: models
+---
+apiVersion: backstage.io/v1alpha1
+kind: API
+metadata:
+  name: mnist-v1
+`,
+			format:   types.CatalogInfoYamlFormat,
+			expected: "[component:default/mnist-v1 resource:models/mnist-v1 api:default/mnist-v1]",
+		},
+		{
+			name:     "model catalog",
+			body:     `{"models":[{"name":"granite","description":"","lifecycle":"","owner":""}],"modelServer":{"name":"granite-server","description":"","lifecycle":"","owner":"","API":{"url":"http://granite","type":"openapi","spec":""}}}`,
+			format:   types.JsonArrayForamt,
+			expected: "[component:default/granite-server api:default/granite-server resource:default/granite]",
+		},
+	} {
+		refs, err := EntityRefs([]byte(tc.body), tc.format)
+		common.AssertError(t, err)
+		if fmt.Sprintf("%v", refs) != tc.expected {
+			t.Errorf("%s: expected %s, got %v", tc.name, tc.expected, refs)
+		}
+	}
+	_, err := EntityRefs([]byte("not json"), types.JsonArrayForamt)
+	if err == nil {
+		t.Error("expected error")
+	}
+}
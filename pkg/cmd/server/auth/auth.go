@@ -0,0 +1,324 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/types"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/util"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/cache"
+	"k8s.io/client-go/kubernetes"
+	k8srest "k8s.io/client-go/rest"
+	"k8s.io/klog/v2"
+)
+
+// Access is what a route lets its caller do
+type Access int
+
+const (
+	// Read routes list, fetch or watch catalog entries, and are for the Backstage entity provider and the location
+	// service
+	Read Access = iota
+	// Write routes change catalog entries, and are for the normalizers and storage-rest
+	Write
+	// Content routes serve catalog-info and model card content from the location service; they are Read routes
+	// unless anonymous content is allowed, since the Backstage URL reader that fetches them does not send a token
+	Content
+)
+
+const (
+	// DefaultGroup and DefaultResource make up the virtual resource SubjectAccessReviews are checked against, which
+	// RBAC rules can name even though no such API exists
+	DefaultGroup     = "modelcatalogbridge.rhdh.io"
+	DefaultResource  = "catalogs"
+	DefaultReadVerb  = "get"
+	DefaultWriteVerb = "update"
+	DefaultCacheTTL  = time.Minute
+
+	// UserContextKey is the gin context key holding the name of the authenticated caller
+	UserContextKey = "user"
+)
+
+type Config struct {
+	Mode types.AuthMode
+	// ReadTokens and WriteTokens are for AuthModeStatic; a write token can also be used to read
+	ReadTokens  []string
+	WriteTokens []string
+	// the SubjectAccessReview attributes for AuthModeKubernetes
+	Namespace string
+	Group     string
+	Resource  string
+	ReadVerb  string
+	WriteVerb string
+	// RouteVerbs overrides ReadVerb or WriteVerb for a route, keyed by the method and the path the route was
+	// registered with, for example "GET /list"
+	RouteVerbs       map[string]string
+	AnonymousContent bool
+	CacheTTL         time.Duration
+}
+
+// ConfigFromEnv reads the auth settings; when none are set callers are not checked, as before
+func ConfigFromEnv() (*Config, error) {
+	r := strings.NewReplacer("\r", "", "\n", "")
+	env := func(name, def string) string {
+		v := strings.TrimSpace(r.Replace(os.Getenv(name)))
+		if len(v) == 0 {
+			return def
+		}
+		return v
+	}
+	cfg := &Config{
+		Mode:        types.AuthMode(env(types.AuthModeEnvVar, string(types.AuthModeNone))),
+		ReadTokens:  splitList(env(types.AuthStaticReadTokensEnvVar, "")),
+		WriteTokens: splitList(env(types.AuthStaticWriteTokensEnvVar, "")),
+		Namespace:   env(types.AuthNamespaceEnvVar, env(util.PodNSEnvVar, "")),
+		Group:       env(types.AuthGroupEnvVar, DefaultGroup),
+		Resource:    env(types.AuthResourceEnvVar, DefaultResource),
+		ReadVerb:    env(types.AuthReadVerbEnvVar, DefaultReadVerb),
+		WriteVerb:   env(types.AuthWriteVerbEnvVar, DefaultWriteVerb),
+		RouteVerbs:  map[string]string{},
+		CacheTTL:    DefaultCacheTTL,
+	}
+	for _, setting := range splitList(env(types.AuthRouteVerbsEnvVar, "")) {
+		route, verb, ok := strings.Cut(setting, "=")
+		route = strings.Join(strings.Fields(route), " ")
+		verb = strings.TrimSpace(verb)
+		if !ok || len(verb) == 0 || len(strings.Fields(route)) != 2 {
+			return nil, fmt.Errorf("%s setting %q is not of the form \"METHOD /path=verb\"", types.AuthRouteVerbsEnvVar, setting)
+		}
+		cfg.RouteVerbs[route] = verb
+	}
+	if v := env(types.AuthAnonymousContentEnvVar, ""); len(v) > 0 {
+		anonymous, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a boolean: %s", types.AuthAnonymousContentEnvVar, err.Error())
+		}
+		cfg.AnonymousContent = anonymous
+	}
+	if v := env(types.AuthCacheTTLEnvVar, ""); len(v) > 0 {
+		ttl, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a duration: %s", types.AuthCacheTTLEnvVar, err.Error())
+		}
+		cfg.CacheTTL = ttl
+	}
+	return cfg, nil
+}
+
+// Authorizer is gin middleware checking that callers sent a bearer token which allows them the access a route needs
+type Authorizer struct {
+	cfg    Config
+	client kubernetes.Interface
+	cache  *cache.Expiring
+}
+
+// NewAuthorizer returns an Authorizer for cfg; client is only used, and so only needed, for AuthModeKubernetes
+func NewAuthorizer(cfg *Config, client kubernetes.Interface) (*Authorizer, error) {
+	switch cfg.Mode {
+	case types.AuthModeNone:
+	case types.AuthModeStatic:
+		if len(cfg.ReadTokens) == 0 && len(cfg.WriteTokens) == 0 {
+			return nil, fmt.Errorf("%s auth needs %s or %s set", cfg.Mode, types.AuthStaticReadTokensEnvVar, types.AuthStaticWriteTokensEnvVar)
+		}
+	case types.AuthModeKubernetes:
+		if client == nil {
+			return nil, fmt.Errorf("%s auth needs a kubernetes client", cfg.Mode)
+		}
+		if len(cfg.Namespace) == 0 {
+			return nil, fmt.Errorf("%s auth needs %s or %s set", cfg.Mode, types.AuthNamespaceEnvVar, util.PodNSEnvVar)
+		}
+	default:
+		return nil, fmt.Errorf("unknown auth mode %q", cfg.Mode)
+	}
+	return &Authorizer{cfg: *cfg, client: client, cache: cache.NewExpiring()}, nil
+}
+
+// NewAuthorizerFromEnv combines ConfigFromEnv and NewAuthorizer
+func NewAuthorizerFromEnv(restConfig *k8srest.Config) (*Authorizer, error) {
+	cfg, err := ConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	var client kubernetes.Interface
+	if cfg.Mode == types.AuthModeKubernetes {
+		if restConfig == nil {
+			return nil, fmt.Errorf("%s auth needs a kubernetes config", cfg.Mode)
+		}
+		client, err = kubernetes.NewForConfig(restConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+	klog.Infof("authenticating callers with %s auth", cfg.Mode)
+	if cfg.Mode == types.AuthModeNone {
+		klog.Warningf("every caller is allowed to read and change the catalog, including the audit log, history and outbox; set %s to %s or %s to check callers", types.AuthModeEnvVar, types.AuthModeKubernetes, types.AuthModeStatic)
+	}
+	return NewAuthorizer(cfg, client)
+}
+
+// Require returns the middleware for a route needing access; a nil Authorizer lets every caller through
+func (a *Authorizer) Require(access Access) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if a == nil || a.cfg.Mode == types.AuthModeNone || (access == Content && a.cfg.AnonymousContent) {
+			c.Next()
+			return
+		}
+		token, ok := bearerToken(c.Request)
+		if !ok {
+			c.Header("WWW-Authenticate", "Bearer")
+			a.abort(c, http.StatusUnauthorized, fmt.Errorf("a bearer token is required for %s %s", c.Request.Method, c.Request.URL.Path))
+			return
+		}
+
+		var user string
+		var code int
+		var err error
+		switch a.cfg.Mode {
+		case types.AuthModeStatic:
+			user, code, err = a.checkStatic(token, access)
+		case types.AuthModeKubernetes:
+			user, code, err = a.checkKubernetes(c.Request.Context(), token, a.verb(c, access))
+		}
+		if err != nil {
+			if code == http.StatusUnauthorized {
+				c.Header("WWW-Authenticate", "Bearer")
+			}
+			a.abort(c, code, err)
+			return
+		}
+		c.Set(UserContextKey, user)
+		c.Next()
+	}
+}
+
+func (a *Authorizer) abort(c *gin.Context, code int, err error) {
+	klog.Errorf("%s %s denied: %s", c.Request.Method, c.Request.URL.Path, err.Error())
+	c.AbortWithStatus(code)
+	c.Error(err)
+}
+
+// verb is the SubjectAccessReview verb for the route c was routed to
+func (a *Authorizer) verb(c *gin.Context, access Access) string {
+	if verb, ok := a.cfg.RouteVerbs[c.Request.Method+" "+c.FullPath()]; ok {
+		return verb
+	}
+	if access == Write {
+		return a.cfg.WriteVerb
+	}
+	return a.cfg.ReadVerb
+}
+
+func (a *Authorizer) checkStatic(token string, access Access) (string, int, error) {
+	if matches(token, a.cfg.WriteTokens) {
+		return "static-writer", http.StatusOK, nil
+	}
+	if matches(token, a.cfg.ReadTokens) {
+		if access == Write {
+			return "", http.StatusForbidden, fmt.Errorf("the token is read only")
+		}
+		return "static-reader", http.StatusOK, nil
+	}
+	return "", http.StatusUnauthorized, fmt.Errorf("the token is not valid")
+}
+
+// checkKubernetes validates the token with a TokenReview and then checks with a SubjectAccessReview that the user it
+// belongs to can do verb on the virtual resource; both results are cached, keyed by a hash of the token, so that a
+// busy caller does not mean an API server call per request
+func (a *Authorizer) checkKubernetes(ctx context.Context, token, verb string) (string, int, error) {
+	sum := sha256.Sum256([]byte(token))
+	tokenKey := hex.EncodeToString(sum[:])
+
+	var user *authenticationv1.UserInfo
+	if cached, ok := a.cache.Get("token/" + tokenKey); ok {
+		user = cached.(*authenticationv1.UserInfo)
+	} else {
+		review, err := a.client.AuthenticationV1().TokenReviews().Create(ctx, &authenticationv1.TokenReview{
+			Spec: authenticationv1.TokenReviewSpec{Token: token},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			return "", http.StatusInternalServerError, fmt.Errorf("error reviewing token: %s", err.Error())
+		}
+		if review.Status.Authenticated {
+			user = review.Status.User.DeepCopy()
+		}
+		a.cache.Set("token/"+tokenKey, user, a.cfg.CacheTTL)
+	}
+	if user == nil {
+		return "", http.StatusUnauthorized, fmt.Errorf("the token is not valid")
+	}
+
+	accessKey := "access/" + tokenKey + "/" + verb
+	var allowed bool
+	if cached, ok := a.cache.Get(accessKey); ok {
+		allowed = cached.(bool)
+	} else {
+		extra := map[string]authorizationv1.ExtraValue{}
+		for k, v := range user.Extra {
+			extra[k] = authorizationv1.ExtraValue(v)
+		}
+		review, err := a.client.AuthorizationV1().SubjectAccessReviews().Create(ctx, &authorizationv1.SubjectAccessReview{
+			Spec: authorizationv1.SubjectAccessReviewSpec{
+				User:   user.Username,
+				UID:    user.UID,
+				Groups: user.Groups,
+				Extra:  extra,
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Namespace: a.cfg.Namespace,
+					Verb:      verb,
+					Group:     a.cfg.Group,
+					Resource:  a.cfg.Resource,
+				},
+			},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			return "", http.StatusInternalServerError, fmt.Errorf("error reviewing access for %s: %s", user.Username, err.Error())
+		}
+		allowed = review.Status.Allowed
+		a.cache.Set(accessKey, allowed, a.cfg.CacheTTL)
+	}
+	if !allowed {
+		return "", http.StatusForbidden, fmt.Errorf("%s cannot %s %s.%s in namespace %s", user.Username, verb, a.cfg.Resource, a.cfg.Group, a.cfg.Namespace)
+	}
+	return user.Username, http.StatusOK, nil
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, len(token) > 0
+}
+
+func matches(token string, tokens []string) bool {
+	found := false
+	for _, t := range tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+			found = true
+		}
+	}
+	return found
+}
+
+func splitList(v string) []string {
+	list := []string{}
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); len(s) > 0 {
+			list = append(list, s)
+		}
+	}
+	return list
+}
@@ -0,0 +1,179 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/types"
+	"github.com/redhat-ai-dev/model-catalog-bridge/test/stub/common"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const (
+	readerToken = "reader-token"
+	writerToken = "writer-token"
+)
+
+type reviews struct {
+	tokens   int
+	accesses []authorizationv1.ResourceAttributes
+}
+
+// fakeClient authenticates the reader and writer tokens, and lets the writer do anything but the reader only get
+func fakeClient(r *reviews) *fake.Clientset {
+	client := fake.NewClientset()
+	client.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		r.tokens++
+		review := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+		switch review.Spec.Token {
+		case readerToken:
+			review.Status = authenticationv1.TokenReviewStatus{Authenticated: true, User: authenticationv1.UserInfo{Username: "system:serviceaccount:ai-rhdh:backstage"}}
+		case writerToken:
+			review.Status = authenticationv1.TokenReviewStatus{Authenticated: true, User: authenticationv1.UserInfo{Username: "system:serviceaccount:ai-rhdh:rhdh-rhoai-bridge"}}
+		}
+		return true, review, nil
+	})
+	client.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		r.accesses = append(r.accesses, *review.Spec.ResourceAttributes)
+		review.Status.Allowed = review.Spec.User == "system:serviceaccount:ai-rhdh:rhdh-rhoai-bridge" || review.Spec.ResourceAttributes.Verb == DefaultReadVerb
+		return true, review, nil
+	})
+	return client
+}
+
+func setupRouter(a *Authorizer) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	ok := func(c *gin.Context) { c.String(http.StatusOK, c.GetString(UserContextKey)) }
+	r.GET("/list", a.Require(Read), ok)
+	r.GET("/watch", a.Require(Read), ok)
+	r.POST("/upsert", a.Require(Write), ok)
+	r.GET("/:model/:version/:format", a.Require(Content), ok)
+	return r
+}
+
+func request(r *gin.Engine, method, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if len(token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestKubernetesAuth(t *testing.T) {
+	r := &reviews{}
+	cfg := &Config{
+		Mode:       types.AuthModeKubernetes,
+		Namespace:  "ai-rhdh",
+		Group:      DefaultGroup,
+		Resource:   DefaultResource,
+		ReadVerb:   DefaultReadVerb,
+		WriteVerb:  DefaultWriteVerb,
+		RouteVerbs: map[string]string{"GET /watch": "watch"},
+		CacheTTL:   time.Minute,
+	}
+	a, err := NewAuthorizer(cfg, fakeClient(r))
+	common.AssertError(t, err)
+	router := setupRouter(a)
+
+	for _, tc := range []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		code   int
+	}{
+		{name: "no token", method: http.MethodGet, path: "/list", code: http.StatusUnauthorized},
+		{name: "unknown token", method: http.MethodGet, path: "/list", token: "bogus", code: http.StatusUnauthorized},
+		{name: "reader lists", method: http.MethodGet, path: "/list", token: readerToken, code: http.StatusOK},
+		{name: "reader upserts", method: http.MethodPost, path: "/upsert", token: readerToken, code: http.StatusForbidden},
+		{name: "reader watches with the route verb", method: http.MethodGet, path: "/watch", token: readerToken, code: http.StatusForbidden},
+		{name: "reader reads content", method: http.MethodGet, path: "/mnist/v1/catalog-info.yaml", token: readerToken, code: http.StatusOK},
+		{name: "anonymous content", method: http.MethodGet, path: "/mnist/v1/catalog-info.yaml", code: http.StatusUnauthorized},
+		{name: "writer upserts", method: http.MethodPost, path: "/upsert", token: writerToken, code: http.StatusOK},
+		{name: "writer watches", method: http.MethodGet, path: "/watch", token: writerToken, code: http.StatusOK},
+	} {
+		w := request(router, tc.method, tc.path, tc.token)
+		if w.Code != tc.code {
+			t.Errorf("%s: expected %d but got %d", tc.name, tc.code, w.Code)
+		}
+		if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") != "Bearer" {
+			t.Errorf("%s: expected a WWW-Authenticate header", tc.name)
+		}
+	}
+	common.AssertEqual(t, "watch", r.accesses[2].Verb)
+	common.AssertEqual(t, DefaultResource, r.accesses[0].Resource)
+	common.AssertEqual(t, DefaultGroup, r.accesses[0].Group)
+	common.AssertEqual(t, "ai-rhdh", r.accesses[0].Namespace)
+
+	// the reviews are cached
+	tokens, accesses := r.tokens, len(r.accesses)
+	w := request(router, http.MethodGet, "/list", readerToken)
+	common.AssertEqual(t, http.StatusOK, w.Code)
+	common.AssertEqual(t, "system:serviceaccount:ai-rhdh:backstage", w.Body.String())
+	w = request(router, http.MethodGet, "/list", "bogus")
+	common.AssertEqual(t, http.StatusUnauthorized, w.Code)
+	common.AssertEqual(t, tokens, r.tokens)
+	common.AssertEqual(t, accesses, len(r.accesses))
+}
+
+func TestStaticAuth(t *testing.T) {
+	_, err := NewAuthorizer(&Config{Mode: types.AuthModeStatic}, nil)
+	if err == nil {
+		t.Error("expected an error for static auth without tokens")
+	}
+	a, err := NewAuthorizer(&Config{Mode: types.AuthModeStatic, ReadTokens: []string{readerToken}, WriteTokens: []string{writerToken}, AnonymousContent: true}, nil)
+	common.AssertError(t, err)
+	router := setupRouter(a)
+
+	common.AssertEqual(t, http.StatusUnauthorized, request(router, http.MethodGet, "/list", "").Code)
+	common.AssertEqual(t, http.StatusUnauthorized, request(router, http.MethodGet, "/list", "bogus").Code)
+	common.AssertEqual(t, http.StatusOK, request(router, http.MethodGet, "/list", readerToken).Code)
+	common.AssertEqual(t, http.StatusForbidden, request(router, http.MethodPost, "/upsert", readerToken).Code)
+	common.AssertEqual(t, http.StatusOK, request(router, http.MethodPost, "/upsert", writerToken).Code)
+	common.AssertEqual(t, http.StatusOK, request(router, http.MethodGet, "/list", writerToken).Code)
+	common.AssertEqual(t, http.StatusOK, request(router, http.MethodGet, "/mnist/v1/catalog-info.yaml", "").Code)
+}
+
+func TestNoAuth(t *testing.T) {
+	var nilAuthorizer *Authorizer
+	a, err := NewAuthorizer(&Config{Mode: types.AuthModeNone}, nil)
+	common.AssertError(t, err)
+	for _, a := range []*Authorizer{nilAuthorizer, a} {
+		router := setupRouter(a)
+		common.AssertEqual(t, http.StatusOK, request(router, http.MethodPost, "/upsert", "").Code)
+		common.AssertEqual(t, http.StatusOK, request(router, http.MethodGet, "/list", "").Code)
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv(types.AuthModeEnvVar, string(types.AuthModeKubernetes))
+	t.Setenv("POD_NAMESPACE", "ai-rhdh")
+	t.Setenv(types.AuthRouteVerbsEnvVar, "GET /watch=watch, POST  /upsert=create")
+	t.Setenv(types.AuthAnonymousContentEnvVar, "true")
+	t.Setenv(types.AuthCacheTTLEnvVar, "10s")
+	cfg, err := ConfigFromEnv()
+	common.AssertError(t, err)
+	common.AssertEqual(t, types.AuthModeKubernetes, cfg.Mode)
+	common.AssertEqual(t, "ai-rhdh", cfg.Namespace)
+	common.AssertEqual(t, DefaultGroup, cfg.Group)
+	common.AssertEqual(t, map[string]string{"GET /watch": "watch", "POST /upsert": "create"}, cfg.RouteVerbs)
+	common.AssertEqual(t, true, cfg.AnonymousContent)
+	common.AssertEqual(t, 10*time.Second, cfg.CacheTTL)
+
+	t.Setenv(types.AuthRouteVerbsEnvVar, "/watch=watch")
+	_, err = ConfigFromEnv()
+	if err == nil {
+		t.Error("expected an error for a route verb without a method")
+	}
+}
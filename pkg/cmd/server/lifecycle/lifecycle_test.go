@@ -0,0 +1,141 @@
+package lifecycle
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redhat-ai-dev/model-catalog-bridge/test/stub/common"
+)
+
+func TestProbes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	p := NewProbes(50 * time.Millisecond)
+	p.Register(r)
+	probe := func(uri string) (int, ProbeResponse) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, uri, nil))
+		resp := ProbeResponse{}
+		common.AssertError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return w.Code, resp
+	}
+
+	// with nothing to check, we are ready
+	sc, resp := probe("/readyz")
+	common.AssertEqual(t, http.StatusOK, sc)
+	common.AssertEqual(t, ProbeOK, resp.Status)
+
+	// readiness fails with any check, including one which takes too long, while liveness does not
+	var storageErr error
+	p.AddReadyzCheck("storage", func() error { return storageErr })
+	p.AddReadyzCheck("backstage", func() error {
+		time.Sleep(time.Second)
+		return nil
+	})
+	sc, resp = probe("/readyz")
+	common.AssertEqual(t, http.StatusServiceUnavailable, sc)
+	common.AssertEqual(t, map[string]string{"storage": ProbeOK, "backstage": "timed out after 50ms"}, resp.Checks)
+	storageErr = fmt.Errorf("storage is down")
+	p.AddReadyzCheck("backstage", func() error { return nil })
+	sc, resp = probe("/readyz")
+	common.AssertEqual(t, http.StatusServiceUnavailable, sc)
+	common.AssertEqual(t, map[string]string{"storage": "storage is down", "backstage": ProbeOK}, resp.Checks)
+	sc, _ = probe("/healthz")
+	common.AssertEqual(t, http.StatusOK, sc)
+
+	storageErr = nil
+	sc, _ = probe("/readyz")
+	common.AssertEqual(t, http.StatusOK, sc)
+
+	// and once we are shutting down, readiness fails regardless
+	p.Drain()
+	sc, resp = probe("/readyz")
+	common.AssertEqual(t, http.StatusServiceUnavailable, sc)
+	common.AssertEqual(t, ProbeFailed, resp.Status)
+	sc, _ = probe("/healthz")
+	common.AssertEqual(t, http.StatusOK, sc)
+}
+
+func TestServe(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	p := NewProbes(0)
+	p.Register(r)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	r.GET("/slow", func(c *gin.Context) {
+		close(started)
+		<-release
+		c.String(http.StatusOK, "done")
+	})
+	streaming := make(chan struct{})
+	streamStarted := make(chan struct{})
+	r.GET("/stream", func(c *gin.Context) {
+		close(streamStarted)
+		<-streaming
+		c.Status(http.StatusOK)
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	common.AssertError(t, err)
+	url := "http://" + ln.Addr().String()
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- serve(ctx, ln, r, Timeouts{DrainDelay: 100 * time.Millisecond, Shutdown: 5 * time.Second}, p, func() { close(streaming) })
+	}()
+
+	slow := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(url + "/slow")
+		if err != nil {
+			slow <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		slow <- string(body)
+	}()
+	stream := make(chan int, 1)
+	go func() {
+		resp, err := http.Get(url + "/stream")
+		if err != nil {
+			stream <- 0
+			return
+		}
+		resp.Body.Close()
+		stream <- resp.StatusCode
+	}()
+	<-started
+	<-streamStarted
+
+	// on shutdown, readiness fails while we still take requests, the stream is ended, and the request in flight
+	// finishes before we are done
+	cancel()
+	time.Sleep(20 * time.Millisecond)
+	resp, err := http.Get(url + "/readyz")
+	common.AssertError(t, err)
+	resp.Body.Close()
+	common.AssertEqual(t, http.StatusServiceUnavailable, resp.StatusCode)
+	select {
+	case err = <-served:
+		t.Fatalf("stopped serving with a request in flight: %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+	common.AssertEqual(t, http.StatusOK, <-stream)
+	close(release)
+	common.AssertEqual(t, "done", <-slow)
+	common.AssertError(t, <-served)
+	_, err = http.Get(url + "/healthz")
+	if err == nil {
+		t.Error("expected the server to no longer take requests")
+	}
+}
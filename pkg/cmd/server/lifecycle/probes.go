@@ -0,0 +1,131 @@
+package lifecycle
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/util"
+	"k8s.io/klog/v2"
+)
+
+const (
+	ProbeOK     = "ok"
+	ProbeFailed = "failed"
+)
+
+// Check returns why a dependency of the server cannot be used, or nil when it can
+type Check func() error
+
+// ProbeResponse is the body of the liveness and readiness probes; Checks has "ok" or the error of each readiness check
+type ProbeResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// Probes answers the liveness and readiness probes of a server.  The server is live as long as it answers, and ready
+// when every readiness check passes and it is not shutting down.
+type Probes struct {
+	// timeout of 0 means a readiness check is waited on for as long as it takes
+	timeout  time.Duration
+	mutex    sync.Mutex
+	checks   map[string]Check
+	draining atomic.Bool
+}
+
+func NewProbes(timeout time.Duration) *Probes {
+	return &Probes{timeout: timeout, checks: map[string]Check{}}
+}
+
+// AddReadyzCheck has readiness depend on the check
+func (p *Probes) AddReadyzCheck(name string, check Check) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.checks[name] = check
+}
+
+// Drain has readiness fail from now on, as the server is shutting down
+func (p *Probes) Drain() {
+	p.draining.Store(true)
+}
+
+// Register adds the probes to the router; as the kubelet has no token to present, they are not behind auth
+func (p *Probes) Register(r *gin.Engine) {
+	r.GET(util.HealthzURI, p.handleHealthzGet)
+	r.GET(util.ReadyzURI, p.handleReadyzGet)
+}
+
+func (p *Probes) handleHealthzGet(c *gin.Context) {
+	writeProbeResponse(c, http.StatusOK, &ProbeResponse{Status: ProbeOK})
+}
+
+// handleReadyzGet runs the readiness checks at the same time, answering with a 503 if any of them fails or takes
+// longer than the timeout
+func (p *Probes) handleReadyzGet(c *gin.Context) {
+	if p.draining.Load() {
+		writeProbeResponse(c, http.StatusServiceUnavailable, &ProbeResponse{Status: ProbeFailed, Checks: map[string]string{"shutdown": "shutting down"}})
+		return
+	}
+	p.mutex.Lock()
+	names := make([]string, 0, len(p.checks))
+	for name := range p.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	results := make([]chan error, len(names))
+	for i, name := range names {
+		// buffered, so a check which outlasts the timeout does not leak its goroutine once it does finish
+		results[i] = make(chan error, 1)
+		go func(check Check, result chan error) {
+			result <- check()
+		}(p.checks[name], results[i])
+	}
+	p.mutex.Unlock()
+	// expired is closed, rather than sent on, so that every check still waited on sees it
+	expired := make(chan struct{})
+	if p.timeout > 0 {
+		timer := time.AfterFunc(p.timeout, func() { close(expired) })
+		defer timer.Stop()
+	}
+	resp := &ProbeResponse{Status: ProbeOK, Checks: map[string]string{}}
+	for i, name := range names {
+		var err error
+		// a check which has finished counts, even when the timeout is up by the time we get to it
+		select {
+		case err = <-results[i]:
+		default:
+			select {
+			case err = <-results[i]:
+			case <-expired:
+				err = fmt.Errorf("timed out after %s", p.timeout)
+			}
+		}
+		if err != nil {
+			klog.Warningf("readiness check %s failed: %s", name, err.Error())
+			resp.Status = ProbeFailed
+			resp.Checks[name] = err.Error()
+			continue
+		}
+		resp.Checks[name] = ProbeOK
+	}
+	status := http.StatusOK
+	if resp.Status != ProbeOK {
+		status = http.StatusServiceUnavailable
+	}
+	writeProbeResponse(c, status, resp)
+}
+
+func writeProbeResponse(c *gin.Context, status int, resp *ProbeResponse) {
+	content, err := json.Marshal(resp)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		c.Error(err)
+		return
+	}
+	c.Data(status, "Content-Type: application/json", content)
+}
@@ -0,0 +1,128 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/types"
+	"k8s.io/klog/v2"
+)
+
+const (
+	defaultReadHeaderTimeout = 10 * time.Second
+	defaultReadTimeout       = time.Minute
+	defaultIdleTimeout       = 2 * time.Minute
+	defaultDrainDelay        = 5 * time.Second
+	// along with the drain delay, this stays within the default termination grace period of 30 seconds
+	defaultShutdownTimeout       = 20 * time.Second
+	defaultReadinessCheckTimeout = 5 * time.Second
+)
+
+// Timeouts are the http.Server timeouts, and how shutting down drains the server; 0 means no limit
+type Timeouts struct {
+	ReadHeader     time.Duration
+	Read           time.Duration
+	Write          time.Duration
+	Idle           time.Duration
+	DrainDelay     time.Duration
+	Shutdown       time.Duration
+	ReadinessCheck time.Duration
+}
+
+func TimeoutsFromEnv() Timeouts {
+	t := Timeouts{
+		ReadHeader:     defaultReadHeaderTimeout,
+		Read:           defaultReadTimeout,
+		Idle:           defaultIdleTimeout,
+		DrainDelay:     defaultDrainDelay,
+		Shutdown:       defaultShutdownTimeout,
+		ReadinessCheck: defaultReadinessCheckTimeout,
+	}
+	r := strings.NewReplacer("\r", "", "\n", "")
+	for envVar, d := range map[string]*time.Duration{
+		types.HTTPReadHeaderTimeoutEnvVar: &t.ReadHeader,
+		types.HTTPReadTimeoutEnvVar:       &t.Read,
+		types.HTTPWriteTimeoutEnvVar:      &t.Write,
+		types.HTTPIdleTimeoutEnvVar:       &t.Idle,
+		types.ShutdownDrainDelayEnvVar:    &t.DrainDelay,
+		types.ShutdownTimeoutEnvVar:       &t.Shutdown,
+		types.ReadinessCheckTimeoutEnvVar: &t.ReadinessCheck,
+	} {
+		v := r.Replace(os.Getenv(envVar))
+		if len(v) == 0 {
+			continue
+		}
+		parsed, err := time.ParseDuration(v)
+		if err != nil || parsed < 0 {
+			klog.Errorf("ignoring %s setting %q as it is not a duration", envVar, v)
+			continue
+		}
+		*d = parsed
+	}
+	return t
+}
+
+// Serve serves the handler on the port until ctx is done, and then drains the server: readiness fails for the drain
+// delay, so that Kubernetes stops sending requests, the server stops taking requests, and the requests in flight get
+// the shutdown timeout to finish, after which their connections are closed.  onShutdown, if set, is called as the
+// server stops taking requests, to end the streaming responses which would otherwise hold up the shutdown.  An error
+// is only returned if the server could not be started.
+func Serve(ctx context.Context, port string, handler http.Handler, t Timeouts, probes *Probes, onShutdown func()) error {
+	ln, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		return err
+	}
+	return serve(ctx, ln, handler, t, probes, onShutdown)
+}
+
+func serve(ctx context.Context, ln net.Listener, handler http.Handler, t Timeouts, probes *Probes, onShutdown func()) error {
+	srv := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: t.ReadHeader,
+		ReadTimeout:       t.Read,
+		WriteTimeout:      t.Write,
+		IdleTimeout:       t.Idle,
+	}
+	if onShutdown != nil {
+		srv.RegisterOnShutdown(onShutdown)
+	}
+	served := make(chan error, 1)
+	go func() {
+		served <- srv.Serve(ln)
+	}()
+	klog.Infof("serving on %s", ln.Addr().String())
+
+	select {
+	case err := <-served:
+		return err
+	case <-ctx.Done():
+	}
+	if probes != nil {
+		probes.Drain()
+	}
+	klog.Infof("shutting down, failing readiness for %s before no longer taking requests", t.DrainDelay)
+	time.Sleep(t.DrainDelay)
+
+	shutdownCtx := context.Background()
+	if t.Shutdown > 0 {
+		var cancel context.CancelFunc
+		shutdownCtx, cancel = context.WithTimeout(shutdownCtx, t.Shutdown)
+		defer cancel()
+	}
+	err := srv.Shutdown(shutdownCtx)
+	if err != nil {
+		klog.Warningf("closing the connections of the requests still in flight after %s: %s", t.Shutdown, err.Error())
+		srv.Close()
+	}
+	err = <-served
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	klog.Info("shut down")
+	return nil
+}
@@ -10,29 +10,37 @@ import (
 )
 
 type BridgeLocationRESTClient struct {
-	RESTClient *resty.Client
-	HostURL    string
-	UpsertURL  string
-	RemoveURL  string
-	Token      string
+	RESTClient     *resty.Client
+	HostURL        string
+	UpsertURL      string
+	UpsertBatchURL string
+	RemoveURL      string
+	Token          string
 }
 
 func SetupBridgeLocationRESTClient(hostURL, token string) *BridgeLocationRESTClient {
 	b := &BridgeLocationRESTClient{
-		RESTClient: resty.New(),
-		HostURL:    hostURL,
-		UpsertURL:  hostURL + util.UpsertURI,
-		RemoveURL:  hostURL + util.RemoveURI,
-		Token:      token,
+		RESTClient:     resty.New(),
+		HostURL:        hostURL,
+		UpsertURL:      hostURL + util.UpsertURI,
+		UpsertBatchURL: hostURL + util.UpsertBatchURI,
+		RemoveURL:      hostURL + util.RemoveURI,
+		Token:          token,
 	}
 	return b
 }
 
-func (b *BridgeLocationRESTClient) UpsertModel(importKey string, body *rest.PostBody) (int, string, error) {
+// UpsertModel upserts the model under the import key, which the location service serves under the source, see
+// util.BuildSourceURI
+func (b *BridgeLocationRESTClient) UpsertModel(importKey, source string, body *rest.PostBody) (int, string, error) {
 	var err error
 	var locationResp *resty.Response
 
-	locationResp, err = b.RESTClient.R().SetBody(body).SetAuthToken(b.Token).SetQueryParam(util.KeyQueryParam, importKey).SetHeader("Accept", "application/json").Post(b.UpsertURL)
+	req := b.RESTClient.R().SetBody(body).SetAuthToken(b.Token).SetQueryParam(util.KeyQueryParam, importKey).SetHeader("Accept", "application/json")
+	if len(source) > 0 {
+		req.SetQueryParam(util.SourceQueryParam, source)
+	}
+	locationResp, err = req.Post(b.UpsertURL)
 	msg := fmt.Sprintf("%#v", locationResp)
 	if err != nil {
 		return http.StatusInternalServerError, msg, err
@@ -40,6 +48,43 @@ func (b *BridgeLocationRESTClient) UpsertModel(importKey string, body *rest.Post
 	return locationResp.StatusCode(), msg, nil
 }
 
+// UpsertModels upserts the models in one request, returning the result for each; a location service which predates
+// batches gets one request per model instead
+func (b *BridgeLocationRESTClient) UpsertModels(entries []rest.BatchUpsertEntry) (*rest.BatchUpsertResponse, error) {
+	resp := &rest.BatchUpsertResponse{}
+	if len(b.UpsertBatchURL) > 0 {
+		locationResp, err := b.RESTClient.R().SetBody(&rest.BatchUpsertBody{Entries: entries}).SetAuthToken(b.Token).SetResult(resp).SetHeader("Accept", "application/json").Post(b.UpsertBatchURL)
+		if err != nil {
+			return nil, err
+		}
+		switch locationResp.StatusCode() {
+		case http.StatusOK:
+			if len(resp.Results) != len(entries) {
+				return nil, fmt.Errorf("location service returned %d results for a batch of %d", len(resp.Results), len(entries))
+			}
+			return resp, nil
+		case http.StatusNotFound, http.StatusMethodNotAllowed:
+		default:
+			return nil, fmt.Errorf("bad rc upserting batch to location service %d: %s", locationResp.StatusCode(), locationResp.String())
+		}
+	}
+	resp.Results = make([]rest.BatchUpsertResult, 0, len(entries))
+	for i := range entries {
+		result := rest.BatchUpsertResult{Key: entries[i].Key}
+		var msg string
+		var err error
+		result.Status, msg, err = b.UpsertModel(entries[i].Key, entries[i].Source, &entries[i].PostBody)
+		switch {
+		case err != nil:
+			result.Error = err.Error()
+		case result.Status != http.StatusCreated && result.Status != http.StatusOK:
+			result.Error = msg
+		}
+		resp.Results = append(resp.Results, result)
+	}
+	return resp, nil
+}
+
 func (b *BridgeLocationRESTClient) RemoveModel(key string) (int, string, error) {
 	resp, err := b.RESTClient.R().SetAuthToken(b.Token).SetQueryParam(util.KeyQueryParam, key).SetHeader("Accept", "application/json").Delete(b.RemoveURL)
 	msg := fmt.Sprintf("%#v", resp)
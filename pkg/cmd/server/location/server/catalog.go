@@ -0,0 +1,132 @@
+package server
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/types"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/util"
+)
+
+// Catalog is the content of every model we serve or, for a delta, what changed after a generation
+type Catalog struct {
+	// Generation is that of the last change to what we serve, which a consumer asks for the delta since next time
+	Generation int64                  `json:"generation"`
+	Format     types.NormalizerFormat `json:"format"`
+	// Full is set when Added has every model we serve, rather than what changed since a generation, which is also the
+	// answer to a delta from a generation we can no longer list the removals since; the consumer drops whatever it has
+	// that is not among them
+	Full    bool           `json:"full"`
+	Since   *int64         `json:"since,omitempty"`
+	Added   []CatalogEntry `json:"added"`
+	Changed []CatalogEntry `json:"changed"`
+	Removed []CatalogEntry `json:"removed"`
+}
+
+// CatalogEntry is the content of a model we serve, at the generation of its last change
+type CatalogEntry struct {
+	URI        string           `json:"uri"`
+	Generation int64            `json:"generation"`
+	Digest     string           `json:"digest,omitempty"`
+	Tombstone  *types.Tombstone `json:"tombstone,omitempty"`
+	// Content is not set for removed models, nor for those storage keeps a tombstone for, which we answer with a 410
+	Content string `json:"content,omitempty"`
+}
+
+// firstGeneration is where the generations start, which is the time we started in microseconds so that they keep
+// going up when we restart, and a delta from a generation from before then gets every model instead
+func firstGeneration(now time.Time) int64 {
+	return now.UnixMicro()
+}
+
+// changed gives the entry at the URI the next generation, as its content was added, updated or removed, and tells the
+// consumers of the event stream.  The lock must be held.
+func (i *ImportLocationServer) changed(uri string, il *ImportLocation, added bool) {
+	i.generation++
+	il.generation = i.generation
+	if added {
+		il.addedGeneration = i.generation
+	}
+	i.publish(il.event(uri))
+}
+
+// resumable says whether we can list what changed after the generation, which we cannot when its removals were pruned
+// or happened before we started, or it is not one of ours.  The lock must be held.
+func (i *ImportLocationServer) resumable(since *int64) bool {
+	return since != nil && *since >= i.compacted && *since <= i.generation
+}
+
+// catalog is every model we serve, or, with since, the models added, changed and removed after that generation.  The
+// lock must be held.
+func (i *ImportLocationServer) catalog(since *int64) *Catalog {
+	cat := &Catalog{
+		Generation: i.generation,
+		Format:     i.storedFormat(),
+		Added:      []CatalogEntry{},
+		Changed:    []CatalogEntry{},
+		Removed:    []CatalogEntry{},
+	}
+	cat.Full = !i.resumable(since)
+	if !cat.Full {
+		cat.Since = since
+	}
+	for uri, il := range i.content {
+		switch {
+		case cat.Full && il.content == nil:
+		case cat.Full:
+			cat.Added = append(cat.Added, il.catalogEntry(uri))
+		case il.generation <= *since:
+		case il.content == nil:
+			cat.Removed = append(cat.Removed, CatalogEntry{URI: uri, Generation: il.generation})
+		case il.addedGeneration > *since:
+			cat.Added = append(cat.Added, il.catalogEntry(uri))
+		default:
+			cat.Changed = append(cat.Changed, il.catalogEntry(uri))
+		}
+	}
+	for _, entries := range [][]CatalogEntry{cat.Added, cat.Changed, cat.Removed} {
+		slices.SortFunc(entries, func(a, b CatalogEntry) int {
+			return cmp.Or(cmp.Compare(a.Generation, b.Generation), cmp.Compare(a.URI, b.URI))
+		})
+	}
+	return cat
+}
+
+func (il *ImportLocation) catalogEntry(uri string) CatalogEntry {
+	ce := CatalogEntry{URI: uri, Generation: il.generation, Digest: il.contentDigest(), Tombstone: il.tombstone}
+	if il.tombstone == nil {
+		ce.Content = string(il.content)
+	}
+	return ce
+}
+
+// handleCatalogGet returns every model we serve, in one document, or with the 'since' parameter, what changed after
+// that generation
+func (i *ImportLocationServer) handleCatalogGet(c *gin.Context) {
+	var since *int64
+	if sinceStr := c.Query(util.SinceQueryParam); len(sinceStr) > 0 {
+		s, err := strconv.ParseInt(sinceStr, 10, 64)
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			c.Error(fmt.Errorf("bad '%s' parameter %s: %s", util.SinceQueryParam, sinceStr, err.Error()))
+			return
+		}
+		since = &s
+	}
+	i.lock.RLock()
+	cat := i.catalog(since)
+	i.lock.RUnlock()
+	content, err := json.Marshal(cat)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		c.Error(err)
+		return
+	}
+	c.Data(http.StatusOK, "Content-Type: application/json", content)
+}
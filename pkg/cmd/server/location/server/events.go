@@ -0,0 +1,194 @@
+package server
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/types"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/util"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// how many events a consumer can fall behind by before we end its stream, in which case it reconnects and resumes
+	eventSubscriberBuffer = 256
+	// how often an idle event stream gets a comment, so that proxies in between do not close it
+	eventKeepAlive = 30 * time.Second
+	// lastEventIDHeader is what an EventSource sends the id of the last event it got in when it reconnects
+	lastEventIDHeader = "Last-Event-ID"
+)
+
+type EventType string
+
+const (
+	EventUpsert EventType = "upsert"
+	EventRemove EventType = "remove"
+	// EventSynced follows the upsert events for every model we serve, which a consumer gets when it starts or when we
+	// cannot resume from its last event, so it knows it can drop any URI it has that it has not received since
+	EventSynced EventType = "synced"
+)
+
+// LocationEvent is a change to the content we serve.  The id of the event is its Generation, the catalog generation of
+// the change, see catalog.go, which is what a consumer resumes from.
+type LocationEvent struct {
+	Type       EventType        `json:"type"`
+	URI        string           `json:"uri,omitempty"`
+	Digest     string           `json:"digest,omitempty"`
+	Tombstone  *types.Tombstone `json:"tombstone,omitempty"`
+	Generation int64            `json:"generation"`
+}
+
+func (il *ImportLocation) event(uri string) LocationEvent {
+	if il.content == nil {
+		return LocationEvent{Type: EventRemove, URI: uri, Generation: il.generation}
+	}
+	return LocationEvent{Type: EventUpsert, URI: uri, Digest: il.contentDigest(), Tombstone: il.tombstone, Generation: il.generation}
+}
+
+// publish sends the event to the consumers of the event stream, ending the stream of any that fell too far behind.
+// The lock must be held, so that no event is published while a consumer subscribes.
+func (i *ImportLocationServer) publish(ev LocationEvent) {
+	i.eventsLock.Lock()
+	defer i.eventsLock.Unlock()
+	for ch := range i.subscribers {
+		select {
+		case ch <- ev:
+		default:
+			klog.Warningf("ending event stream for a consumer that fell more than %d events behind", eventSubscriberBuffer)
+			delete(i.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// subscribe returns the events a consumer that last saw the since generation missed, with only the last change to
+// each URI, along with a channel for the events from here on.  When we cannot resume from since, or there is no since,
+// the consumer gets an upsert event for every model we serve followed by a synced event instead, and full is set.
+func (i *ImportLocationServer) subscribe(since *int64) (bool, []LocationEvent, chan LocationEvent) {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	full := !i.resumable(since)
+	replay := []LocationEvent{}
+	for uri, il := range i.content {
+		switch {
+		case full && il.content == nil:
+		case !full && il.generation <= *since:
+		default:
+			replay = append(replay, il.event(uri))
+		}
+	}
+	slices.SortFunc(replay, func(a, b LocationEvent) int {
+		return cmp.Or(cmp.Compare(a.Generation, b.Generation), cmp.Compare(a.URI, b.URI))
+	})
+	if full {
+		replay = append(replay, LocationEvent{Type: EventSynced, Generation: i.generation})
+	}
+	ch := make(chan LocationEvent, eventSubscriberBuffer)
+	i.eventsLock.Lock()
+	defer i.eventsLock.Unlock()
+	if i.subscribers == nil {
+		i.subscribers = map[chan LocationEvent]struct{}{}
+	}
+	i.subscribers[ch] = struct{}{}
+	return full, replay, ch
+}
+
+func (i *ImportLocationServer) unsubscribe(ch chan LocationEvent) {
+	i.eventsLock.Lock()
+	defer i.eventsLock.Unlock()
+	if _, ok := i.subscribers[ch]; ok {
+		delete(i.subscribers, ch)
+		close(ch)
+	}
+}
+
+// drain ends the event streams, which would otherwise hold up shutting down
+func (i *ImportLocationServer) drain() {
+	i.drainingOnce.Do(func() {
+		if i.draining != nil {
+			close(i.draining)
+		}
+	})
+}
+
+// writeEvent writes the event in the server-sent events format; the upsert events of a full replay have no id, so
+// that a consumer which reconnects part way through it gets the full replay again
+func writeEvent(w io.Writer, ev *LocationEvent, withID bool) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	if withID {
+		_, err = fmt.Fprintf(w, "id: %d\n", ev.Generation)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, data)
+	return err
+}
+
+// handleEventsGet streams the upsert and remove events of the content we serve as server-sent events, starting with
+// the events after the Last-Event-ID header, or the 'since' parameter, if we can resume from there, or else with every
+// model followed by a synced event.  The stream ends if the consumer falls too far behind, and it should then
+// reconnect with the id of the last event it got.
+func (i *ImportLocationServer) handleEventsGet(c *gin.Context) {
+	var since *int64
+	sinceStr := c.GetHeader(lastEventIDHeader)
+	if len(sinceStr) == 0 {
+		sinceStr = c.Query(util.SinceQueryParam)
+	}
+	if len(sinceStr) > 0 {
+		s, err := strconv.ParseInt(sinceStr, 10, 64)
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			c.Error(fmt.Errorf("bad last event id %s: %s", sinceStr, err.Error()))
+			return
+		}
+		since = &s
+	}
+	full, replay, ch := i.subscribe(since)
+	defer i.unsubscribe(ch)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Status(http.StatusOK)
+	for _, ev := range replay {
+		if err := writeEvent(c.Writer, &ev, !full || ev.Type == EventSynced); err != nil {
+			return
+		}
+	}
+	c.Writer.Flush()
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-i.draining:
+			klog.Info("ending event stream as we are shutting down")
+			return
+		case <-keepAlive.C:
+			if _, err := io.WriteString(c.Writer, ": keepalive\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case ev, ok := <-ch:
+			if !ok {
+				return
+			}
+			if err := writeEvent(c.Writer, &ev, true); err != nil {
+				klog.Infof("ending event stream: %s", err.Error())
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
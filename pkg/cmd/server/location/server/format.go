@@ -0,0 +1,58 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/cmd/cli/backstage"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/types"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/util"
+)
+
+// formatMIMETypes are the media types an Accept header can ask for each format with, the first of which is the
+// Content-Type we answer with
+var formatMIMETypes = map[types.NormalizerFormat][]string{
+	types.CatalogInfoYamlFormat: {binding.MIMEYAML2, binding.MIMEYAML, "text/yaml"},
+	types.JsonArrayForamt:       {binding.MIMEJSON},
+}
+
+// storedFormat is the format the normalizers upsert content in, which is the format it is stored and indexed in
+func (i *ImportLocationServer) storedFormat() types.NormalizerFormat {
+	if i.format == types.JsonArrayForamt {
+		return types.JsonArrayForamt
+	}
+	return types.CatalogInfoYamlFormat
+}
+
+// requestedFormat is the format the request asks for: the one its Accept header asks for, when it asks for one of
+// them, or else the one of the file name its URI ends with
+func requestedFormat(c *gin.Context, fileFormat types.NormalizerFormat) types.NormalizerFormat {
+	other := types.JsonArrayForamt
+	if fileFormat == types.JsonArrayForamt {
+		other = types.CatalogInfoYamlFormat
+	}
+	offered := append(slices.Clone(formatMIMETypes[fileFormat]), formatMIMETypes[other]...)
+	if slices.Contains(formatMIMETypes[other], c.NegotiateFormat(offered...)) {
+		return other
+	}
+	return fileFormat
+}
+
+// convert returns the content stored in one format in another; the entities of a model catalog can be listed in a
+// catalog-info.yaml, but there is no model catalog for arbitrary entities
+func convert(content []byte, format, stored types.NormalizerFormat) ([]byte, int, error) {
+	switch {
+	case format == stored:
+		return content, http.StatusOK, nil
+	case format == types.CatalogInfoYamlFormat:
+		converted, err := backstage.ModelCatalogToCatalogInfo(content)
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		return converted, http.StatusOK, nil
+	}
+	return nil, http.StatusNotAcceptable, fmt.Errorf("content stored as %s cannot be served as %s", util.FormatFileName(stored), util.FormatFileName(format))
+}
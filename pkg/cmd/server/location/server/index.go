@@ -0,0 +1,185 @@
+package server
+
+import (
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/util"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// contentRoute serves the content of every model, under the source and, for a model registry, the registry which
+	// provides it; see util.BuildSourceURI
+	contentRoute = "/:source/:registry/:model/:version/:file"
+	// legacyContentRoute is for the URIs from before sources were part of them, /{model}/{version}/{file}, which
+	// Backstage may still have locations for; gin needs the parameters it shares with contentRoute to have the same
+	// names
+	legacyContentRoute = "/:source/:registry/:model"
+)
+
+// entry returns the entry for the model under the key at the URI, adding one if there is none.  As storage has one
+// model for each key, a URI the key had before, say for another source, is no longer served, and is redirected to the
+// new one instead.  An entry a key from before sources were part of keys had at the URI is taken over by the key, as
+// storage moves such keys to the key of their source.  The lock must be held.
+func (i *ImportLocationServer) entry(key, uri string, now time.Time) *ImportLocation {
+	if i.uris == nil {
+		i.uris = map[string]string{}
+	}
+	if old, ok := i.uris[key]; ok && old != uri {
+		if il, ok := i.content[old]; ok && il.content != nil && il.key == key {
+			klog.Infof("key %s moved from URI %s to %s", key, old, uri)
+			i.remove(old, il, now)
+		}
+	}
+	i.uris[key] = uri
+	if legacy := util.LegacyKey(key); legacy != key {
+		if i.legacy == nil {
+			i.legacy = map[string]map[string]struct{}{}
+		}
+		if _, ok := i.legacy[legacy]; !ok {
+			i.legacy[legacy] = map[string]struct{}{}
+		}
+		i.legacy[legacy][key] = struct{}{}
+	}
+	il, ok := i.content[uri]
+	if !ok {
+		il = &ImportLocation{key: key}
+		i.content[uri] = il
+	}
+	il.key = key
+	return il
+}
+
+// removeKey stops serving the model under the key, returning its URI, or "" if we do not have the model.  The lock
+// must be held.
+func (i *ImportLocationServer) removeKey(key string, now time.Time) string {
+	uri, ok := i.uris[key]
+	if !ok {
+		return ""
+	}
+	il, ok := i.content[uri]
+	if !ok || il.content == nil || il.key != key {
+		return ""
+	}
+	i.remove(uri, il, now)
+	return uri
+}
+
+// prune drops the entries removed before the time from the index; removed entries are kept until then so that a resync
+// which listed storage before they were removed does not bring them back, and so that deltas of the catalog list them.
+// The entries of URIs a model moved away from are kept for as long as the model is served, so that Backstage
+// locations with those URIs are redirected to it.  The lock must be held.
+func (i *ImportLocationServer) prune(before time.Time) {
+	for uri, il := range i.content {
+		if il.content != nil || !il.updatedAt.Before(before) {
+			continue
+		}
+		if _, ok := i.supersededBy(uri, il); ok {
+			continue
+		}
+		i.compacted = max(i.compacted, il.generation)
+		delete(i.content, uri)
+		if i.uris[il.key] == uri {
+			delete(i.uris, il.key)
+			legacy := util.LegacyKey(il.key)
+			delete(i.legacy[legacy], il.key)
+			if len(i.legacy[legacy]) == 0 {
+				delete(i.legacy, legacy)
+			}
+		}
+	}
+}
+
+// supersededBy returns the URI the model at the URI moved to, if it moved and is still served; a model under a key
+// from before sources were part of keys moved to the key of its source.  The lock must be held.
+func (i *ImportLocationServer) supersededBy(uri string, il *ImportLocation) (string, bool) {
+	target, ok := i.uris[il.key]
+	if ok && target != uri {
+		moved, ok := i.content[target]
+		if ok && moved.content != nil {
+			return target, true
+		}
+	}
+	target, ok = i.legacyTarget(il.key)
+	return target, ok && target != uri
+}
+
+// legacyTarget returns the URI of the model under the key from before sources were part of keys, which is the URI of
+// the key itself while it is still served, and otherwise that of the first of the keys of the sources with the
+// model which is served.  The lock must be held.
+func (i *ImportLocationServer) legacyTarget(legacy string) (string, bool) {
+	if uri, ok := i.uris[legacy]; ok {
+		if il, ok := i.content[uri]; ok && il.content != nil && il.key == legacy {
+			return uri, true
+		}
+	}
+	keys := make([]string, 0, len(i.legacy[legacy]))
+	for key := range i.legacy[legacy] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		uri := i.uris[key]
+		if il, ok := i.content[uri]; ok && il.content != nil && il.key == key {
+			return uri, true
+		}
+	}
+	return "", false
+}
+
+// remove has the entry at the URI answer with a 404.  The lock must be held.
+func (i *ImportLocationServer) remove(uri string, il *ImportLocation, now time.Time) {
+	il.content = nil
+	il.digest = ""
+	il.tombstone = nil
+	il.updatedAt = now
+	i.changed(uri, il, false)
+}
+
+// handleContentGet serves the content of the model at the URI of the request, which is what the index is keyed by,
+// and redirects a URI from before sources were part of them, or one the model has since moved away from, to the URI
+// of the model.  The file name the URI ends with
+// picks the format the content is served in, unless the Accept header asks for the other one; the index has the URIs
+// of the format the content is stored in.
+func (i *ImportLocationServer) handleContentGet(c *gin.Context) {
+	uri := c.Request.URL.Path
+	dir, file := path.Split(uri)
+	fileFormat, ok := util.FileNameFormat(file)
+	if !ok {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	stored := i.storedFormat()
+	storedURI := dir + util.FormatFileName(stored)
+	c.Header("Vary", "Accept")
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	il, ok := i.content[storedURI]
+	if ok && il.content != nil {
+		format := requestedFormat(c, fileFormat)
+		klog.Infof("returning content: uriString %s as %s with data of len %d", storedURI, format, len(il.content))
+		il.handleFormatGet(c, format, stored)
+		return
+	}
+	if ok {
+		// the model may move back, say when a registry it was in comes back, so the redirect is not a permanent one
+		if target, moved := i.supersededBy(storedURI, il); moved {
+			c.Redirect(http.StatusFound, path.Dir(target)+"/"+file)
+			return
+		}
+	}
+	segs := strings.Split(strings.Trim(uri, "/"), "/")
+	if len(segs) == 3 {
+		key, _ := util.BuildImportKeyAndURI(segs[0], segs[1], stored)
+		if target, ok := i.legacyTarget(key); ok && target != storedURI {
+			c.Redirect(http.StatusFound, path.Dir(target)+"/"+file)
+			return
+		}
+	}
+	c.Status(http.StatusNotFound)
+}
@@ -0,0 +1,323 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/cmd/server/storage"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/types"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/util"
+	"k8s.io/klog/v2"
+)
+
+const (
+	defaultResyncInterval = 5 * time.Minute
+	defaultResyncJitter   = 0.2
+	// how many models each page of the storage metadata listing has
+	resyncListLimit = "1000"
+	// pruneInterval is how often, with resync off, removed entries are dropped from the index, once they were removed
+	// that long ago
+	pruneInterval = defaultResyncInterval
+)
+
+// resyncSettings says how often we resync with storage; its zero value turns resync off
+type resyncSettings struct {
+	interval time.Duration
+	jitter   float64
+}
+
+func resyncSettingsFromEnv() resyncSettings {
+	r := strings.NewReplacer("\r", "", "\n", "")
+	rs := resyncSettings{interval: defaultResyncInterval, jitter: defaultResyncJitter}
+	if v := r.Replace(os.Getenv(types.LocationResyncIntervalEnvVar)); len(v) > 0 {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			klog.Errorf("ignoring %s setting %q as it is not a duration", types.LocationResyncIntervalEnvVar, v)
+		} else {
+			rs.interval = d
+		}
+	}
+	if v := r.Replace(os.Getenv(types.LocationResyncJitterEnvVar)); len(v) > 0 {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 {
+			klog.Errorf("ignoring %s setting %q as it is not a positive number", types.LocationResyncJitterEnvVar, v)
+		} else {
+			rs.jitter = f
+		}
+	}
+	return rs
+}
+
+// ResyncCounts are how many of the models and model cards we serve a resync found to differ from storage, and so
+// changed
+type ResyncCounts struct {
+	Added             int `json:"added"`
+	Updated           int `json:"updated"`
+	Removed           int `json:"removed"`
+	ModelCardsUpdated int `json:"modelCardsUpdated"`
+	ModelCardsRemoved int `json:"modelCardsRemoved"`
+}
+
+func (rc *ResyncCounts) add(o ResyncCounts) {
+	rc.Added += o.Added
+	rc.Updated += o.Updated
+	rc.Removed += o.Removed
+	rc.ModelCardsUpdated += o.ModelCardsUpdated
+	rc.ModelCardsRemoved += o.ModelCardsRemoved
+}
+
+func (rc *ResyncCounts) diverged() bool {
+	return *rc != ResyncCounts{}
+}
+
+// ResyncStatus is how resyncing with storage has gone
+type ResyncStatus struct {
+	// Interval is "0s" when resync is turned off
+	Interval string `json:"interval"`
+	// LastSync is when the last resync which got through every model listed storage, so what we serve is at least as
+	// recent as that
+	LastSync    *time.Time `json:"lastSync,omitempty"`
+	LastAttempt *time.Time `json:"lastAttempt,omitempty"`
+	// LastError is why the last resync failed, and is empty when it did not
+	LastError string `json:"lastError,omitempty"`
+	// Last is what the last resync changed, even if it failed part way, and Total what every resync since we started
+	// changed
+	Last  ResyncCounts `json:"last"`
+	Total ResyncCounts `json:"total"`
+}
+
+// resync brings what we serve in line with storage, for when storage-rest failed to push an upsert or remove to us
+// and the storage watch did not make up for it
+func (i *ImportLocationServer) resync(ctx context.Context) {
+	started := time.Now()
+	counts, err := i.resyncOnce(ctx, started)
+	i.resyncLock.Lock()
+	defer i.resyncLock.Unlock()
+	i.resyncStatus.LastAttempt = &started
+	i.resyncStatus.Last = counts
+	i.resyncStatus.Total.add(counts)
+	if err != nil {
+		i.resyncStatus.LastError = err.Error()
+		if ctx.Err() == nil {
+			klog.Errorf("error resyncing with storage: %s", err.Error())
+		}
+		return
+	}
+	i.resyncStatus.LastError = ""
+	i.resyncStatus.LastSync = &started
+	i.loaded.Store(true)
+	if counts.diverged() {
+		klog.Infof("resync with storage added %d, updated %d and removed %d models, and updated %d and removed %d model cards", counts.Added, counts.Updated, counts.Removed, counts.ModelCardsUpdated, counts.ModelCardsRemoved)
+	}
+}
+
+// pruneRemoved drops the entries removed more than pruneInterval ago from the index, which resync otherwise does as it
+// goes, so that they do not pile up, and deltas of the catalog from before then get every model instead
+func (i *ImportLocationServer) pruneRemoved(ctx context.Context) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.prune(time.Now().Add(-pruneInterval))
+}
+
+// resyncModel is a model we have to fetch from storage to resync
+type resyncModel struct {
+	key  string
+	segs []string
+	uri  string
+}
+
+// resyncOnce lists what storage has for each model, fetches the models whose digest, tombstone or model card we do not
+// have, and removes what storage no longer has.  The lock is only held while our content is compared or changed, not
+// while storage is called, and anything upserted or removed after started is left as it is, as it is at least as
+// recent as what we got from storage.
+func (i *ImportLocationServer) resyncOnce(ctx context.Context, started time.Time) (ResyncCounts, error) {
+	counts := ResyncCounts{}
+	metadata := map[string]storage.KeyMetadata{}
+	segments := map[string][]string{}
+	query := map[string]string{util.LimitQueryParam: resyncListLimit}
+	for {
+		rc, msg, page, err := i.storage.ListModelsMetadata(query)
+		if err != nil {
+			return counts, fmt.Errorf("error listing storage: %s: %s", err.Error(), msg)
+		}
+		if rc != http.StatusOK || page == nil {
+			return counts, fmt.Errorf("bad response code from storage list metadata %d, %s", rc, msg)
+		}
+		for _, m := range page.Items {
+			seg1, seg2, ok := util.KeySegments(m.Key)
+			if !ok {
+				klog.Errorf("bad format for key from storage list metadata when splitting with '_': %s", m.Key)
+				continue
+			}
+			uri := util.BuildSourceURI(types.EffectiveSource(m.Source, m.ReconcilerType), seg1, seg2, i.format)
+			metadata[uri] = m
+			segments[uri] = []string{seg1, seg2}
+		}
+		if len(page.Continue) == 0 {
+			break
+		}
+		if ctx.Err() != nil {
+			return counts, ctx.Err()
+		}
+		query[util.ContinueQueryParam] = page.Continue
+	}
+
+	fetch := []resyncModel{}
+	i.lock.Lock()
+	for uri, m := range metadata {
+		if !i.inSync(uri, &m) {
+			fetch = append(fetch, resyncModel{key: m.Key, segs: segments[uri], uri: uri})
+		}
+	}
+	i.lock.Unlock()
+
+	for _, f := range fetch {
+		if ctx.Err() != nil {
+			return counts, ctx.Err()
+		}
+		rc, msg, err, buf := i.storage.FetchModel(f.key)
+		if err != nil {
+			return counts, fmt.Errorf("error fetching key %s from storage: %s: %s", f.key, err.Error(), msg)
+		}
+		if rc == http.StatusNotFound {
+			klog.Infof("key %s was removed from storage since it was listed", f.key)
+			delete(metadata, f.uri)
+			continue
+		}
+		if rc != http.StatusOK {
+			return counts, fmt.Errorf("bad response code from storage fetch model %s is %d, %s", f.key, rc, msg)
+		}
+		sb := types.StorageBody{}
+		err = json.Unmarshal(buf, &sb)
+		if err != nil {
+			return counts, fmt.Errorf("error reading storage fetch model %s: %s", f.key, err.Error())
+		}
+		// the source may have changed since storage was listed
+		uri := util.BuildSourceURI(types.EffectiveSource(sb.Source, sb.ReconcilerType), f.segs[0], f.segs[1], i.format)
+		i.lock.Lock()
+		if il, ok := i.content[uri]; !ok || !il.updatedAt.After(started) {
+			added, updated, cardUpdated := i.setFromStorage(f.key, uri, &sb, time.Now())
+			switch {
+			case added:
+				klog.Infof("resync adding URI %s from storage", uri)
+				counts.Added++
+			case updated:
+				klog.Infof("resync updating URI %s from storage", uri)
+				counts.Updated++
+			}
+			if cardUpdated {
+				counts.ModelCardsUpdated++
+			}
+		}
+		i.lock.Unlock()
+	}
+
+	// with every model in storage listed, whatever else we serve is no longer in storage
+	cardKeys := map[string]struct{}{}
+	for _, m := range metadata {
+		cardKeys[m.ModelCardKey] = struct{}{}
+	}
+	now := time.Now()
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	for uri, il := range i.content {
+		if _, ok := metadata[uri]; ok || il.content == nil || il.updatedAt.After(started) {
+			continue
+		}
+		klog.Infof("resync removing URI %s as it is no longer in storage", uri)
+		i.remove(uri, il, now)
+		counts.Removed++
+	}
+	// what was removed before we listed storage will not be brought back by this resync, nor by any after it
+	i.prune(started)
+	for key, mcm := range i.modelcards {
+		if _, ok := cardKeys[key]; ok || len(key) == 0 || mcm.updatedAt.After(started) {
+			continue
+		}
+		klog.Infof("resync removing model card %s as no model in storage has it", key)
+		delete(i.modelcards, key)
+		counts.ModelCardsRemoved++
+	}
+	return counts, nil
+}
+
+// inSync says whether what we serve for the URI matches what storage has for it; content storage-rest stored before
+// it recorded digests is always fetched, to compare the content itself.  The lock must be held.
+func (i *ImportLocationServer) inSync(uri string, m *storage.KeyMetadata) bool {
+	il, ok := i.content[uri]
+	if !ok || il.content == nil || len(m.Digest) == 0 || il.digest != m.Digest || il.modelCardKey != m.ModelCardKey || !sameTombstone(il.tombstone, m.Tombstone) {
+		return false
+	}
+	if len(m.ModelCardKey) > 0 {
+		_, ok = i.modelcards[m.ModelCardKey]
+	}
+	return ok
+}
+
+// setFromStorage makes what we serve for the URI, along with its model card, what storage has, returning whether the
+// model was added or updated and whether its model card was updated.  The lock must be held.
+func (i *ImportLocationServer) setFromStorage(key, uri string, sb *types.StorageBody, now time.Time) (bool, bool, bool) {
+	il := i.entry(key, uri, now)
+	added := il.content == nil
+	updated := !added && (!bytes.Equal(il.content, sb.Body) || il.digest != sb.Digest || il.modelCardKey != sb.ModelCardKey || !sameTombstone(il.tombstone, sb.Tombstone))
+	if added || updated {
+		il.content = sb.Body
+		il.digest = sb.Digest
+		il.modelCardKey = sb.ModelCardKey
+		il.tombstone = sb.Tombstone
+		il.updatedAt = now
+		i.changed(uri, il, added)
+	}
+	return added, updated, i.setModelCard(sb.ModelCardKey, sb.ModelCard, sb.LastUpdateTimeSinceEpoch, now)
+}
+
+// setModelCard stores the model card from storage, unless we already have it, returning whether it was stored; storage
+// has no model card for entries stored before it kept them.  The lock must be held.
+func (i *ImportLocationServer) setModelCard(key, content, lastUpdateTimeSinceEpoch string, now time.Time) bool {
+	if len(key) == 0 || len(content) == 0 {
+		return false
+	}
+	if i.modelcards == nil {
+		i.modelcards = map[string]modelCardMetadata{}
+	}
+	if mcm, ok := i.modelcards[key]; ok && mcm.content == content {
+		return false
+	}
+	i.modelcards[key] = modelCardMetadata{
+		content:                  content,
+		lastUpdateTimeSinceEpoch: lastUpdateTimeSinceEpoch,
+		needToUpdate:             true,
+		updatedAt:                now,
+	}
+	return true
+}
+
+func sameTombstone(a, b *types.Tombstone) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.RemovedAt.Equal(b.RemovedAt) && a.ExpiresAt.Equal(b.ExpiresAt)
+}
+
+// handleResyncGet returns how resyncing with storage has gone
+func (i *ImportLocationServer) handleResyncGet(c *gin.Context) {
+	i.resyncLock.Lock()
+	status := i.resyncStatus
+	i.resyncLock.Unlock()
+	status.Interval = i.resyncSettings.interval.String()
+	content, err := json.Marshal(&status)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		c.Error(err)
+		return
+	}
+	c.Data(http.StatusOK, "Content-Type: application/json", content)
+}
@@ -1,30 +1,66 @@
 package server
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/cmd/server/auth"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/cmd/server/lifecycle"
 	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/cmd/server/storage"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/cmd/server/validation"
 	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/config"
 	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/rest"
 	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/types"
 	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/util"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/klog/v2"
 )
 
 type ImportLocationServer struct {
-	router     *gin.Engine
+	router *gin.Engine
+	// content is the index of the models we serve, by URI, uris the URI of the model under each storage key, and
+	// legacy the keys, one for each source, of the models under each key from before sources were part of keys, which
+	// is only kept to redirect to them; see util.LegacyKey
 	content    map[string]*ImportLocation
+	uris       map[string]string
+	legacy     map[string]map[string]struct{}
 	modelcards map[string]modelCardMetadata
 	storage    *storage.BridgeStorageRESTClient
 	format     types.NormalizerFormat
 	port       string
-	lock       sync.Mutex
+	lock       sync.RWMutex
+	auth       *auth.Authorizer
+	// validationMode of "" means upserted content is not validated
+	validationMode types.ValidationMode
+	timeouts       lifecycle.Timeouts
+	probes         *lifecycle.Probes
+	// loaded is set once we have every model storage had when we started, either from the one time load or the
+	// start of the storage watch
+	loaded atomic.Bool
+	// resyncSettings of the zero value means we do not resync with storage
+	resyncSettings resyncSettings
+	resyncLock     sync.Mutex
+	resyncStatus   ResyncStatus
+	// generation goes up by one with each change to the content we serve, see catalog.go, and compacted is the oldest
+	// generation a delta of the catalog can be from, as the removals before it are no longer in the index
+	generation int64
+	compacted  int64
+	// subscribers are the consumers of the event stream, see events.go, and draining is closed as the server shuts
+	// down, which ends the event streams; nil means they are never ended
+	eventsLock   sync.Mutex
+	subscribers  map[chan LocationEvent]struct{}
+	draining     chan struct{}
+	drainingOnce sync.Once
 }
 
 type modelCardMetadata struct {
@@ -32,57 +68,61 @@ type modelCardMetadata struct {
 	lastUpdateTimeSinceEpoch string
 	updateCount              int
 	needToUpdate             bool
+	// updatedAt is when the model card was last changed, by an upsert or from storage
+	updatedAt time.Time
 }
 
-func NewImportLocationServer(stURL, port string, nf types.NormalizerFormat) *ImportLocationServer {
+func NewImportLocationServer(stURL, port string, nf types.NormalizerFormat, authz *auth.Authorizer) *ImportLocationServer {
 	//var content map[string]*ImportLocation
 	gin.SetMode(gin.ReleaseMode)
 	cfg, _ := util.GetK8sConfig(&config.Config{})
 	r := gin.Default()
 	i := &ImportLocationServer{
-		router:     r,
-		content:    map[string]*ImportLocation{},
-		modelcards: map[string]modelCardMetadata{},
-		storage:    storage.SetupBridgeStorageRESTClient(stURL, util.GetCurrentToken(cfg)),
-		format:     nf,
-		port:       port,
-		lock:       sync.Mutex{},
+		router:         r,
+		content:        map[string]*ImportLocation{},
+		uris:           map[string]string{},
+		legacy:         map[string]map[string]struct{}{},
+		modelcards:     map[string]modelCardMetadata{},
+		storage:        storage.SetupBridgeStorageRESTClient(stURL, util.GetCurrentToken(cfg)),
+		format:         nf,
+		port:           port,
+		auth:           authz,
+		validationMode: validation.ModeFromEnv(),
+		timeouts:       lifecycle.TimeoutsFromEnv(),
+		resyncSettings: resyncSettingsFromEnv(),
 	}
+	i.generation = firstGeneration(time.Now())
+	i.compacted = i.generation
+	i.subscribers = map[chan LocationEvent]struct{}{}
+	i.draining = make(chan struct{})
 	r.SetTrustedProxies(nil)
 	r.TrustedPlatform = "X-Forwarded-For"
 	r.Use(addRequestId())
+	i.probes = lifecycle.NewProbes(i.timeouts.ReadinessCheck)
+	i.probes.AddReadyzCheck("storage", i.storage.Healthz)
+	i.probes.AddReadyzCheck("initialLoad", i.initialLoadReady)
+	i.probes.Register(r)
 
-	// approach for implementing background processing with gin gonic discovered via some AI interaction lead to some
-	// timing issues with the periodic reconcile of the normalizer/storage-rest loop; decided not to start including
-	// the synchronization needed to sort that out.
-	// So instead, just doing a one time load attempt before registering the upsert handler to speed up location service
-	// population before 2 minute poll interval the loading of reconciled models form storage
+	// a one time load attempt before registering the upsert handler, so that we have the models already in storage
+	// before we serve; the storage watch and the periodic resync started by Run pick up the rest
 
 	klog.Info("one time load attempt from storage instead of waiting for the reconciliation loop")
-	i.loadFromStorage()
+	if loaded, _ := i.loadFromStorage(); loaded {
+		i.loaded.Store(true)
+	}
 
 	klog.Infof("NewImportLocationServer content len %d", len(i.content))
-	r.GET(util.ListURI, i.handleCatalogDiscoveryGet)
-	r.POST(util.UpsertURI, i.handleCatalogUpsertPost)
-	r.DELETE(util.RemoveURI, i.handleCatalogDelete)
-	r.GET("/:model/:version/:format", func(c *gin.Context) {
-		var model ModelURI
-		if err := c.ShouldBindUri(&model); err != nil {
-			c.Status(http.StatusBadRequest)
-			return
-		}
-		_, uriString := util.BuildImportKeyAndURI(model.Model, model.Version, i.format)
-		i.lock.Lock()
-		defer i.lock.Unlock()
-		il, ok := i.content[uriString]
-		if !ok {
-			c.Status(http.StatusNotFound)
-			return
-		}
-		klog.Infof("returning content: uriString %s with data of len %d", uriString, len(il.content))
-		il.handleCatalogInfoGet(c)
-	})
-	r.GET(util.ModelCardURI, i.handleModelCardGet)
+	// storage-rest writes, the Backstage entity provider lists, and the Backstage URL reader fetches content
+	r.GET(util.ListURI, authz.Require(auth.Read), i.handleCatalogDiscoveryGet)
+	r.POST(util.UpsertURI, authz.Require(auth.Write), i.handleCatalogUpsertPost)
+	r.POST(util.UpsertBatchURI, authz.Require(auth.Write), i.handleCatalogUpsertBatchPost)
+	r.DELETE(util.RemoveURI, authz.Require(auth.Write), i.handleCatalogDelete)
+	r.GET(contentRoute, authz.Require(auth.Content), i.handleContentGet)
+	r.GET(legacyContentRoute, authz.Require(auth.Content), i.handleContentGet)
+	r.GET(util.ModelCardURI, authz.Require(auth.Content), i.handleModelCardGet)
+	r.GET(util.ResyncURI, authz.Require(auth.Read), i.handleResyncGet)
+	r.GET(util.CatalogURI, authz.Require(auth.Read), i.handleCatalogGet)
+	r.GET(util.EventsURI, authz.Require(auth.Read), i.handleEventsGet)
 	return i
 }
 
@@ -108,60 +148,200 @@ func (i *ImportLocationServer) loadFromStorage() (bool, error) {
 	}
 
 	for _, key := range keys {
-		segs := strings.Split(key, "_")
-		if len(segs) < 2 {
+		seg1, seg2, ok := util.KeySegments(key)
+		if !ok {
 			klog.Errorf("bad format for key from ListModelsKeys when splitting with '_': %s", key)
 			continue
 		}
-		il := &ImportLocation{}
-		rc, msg, err, il.content = i.storage.FetchModel(key)
+		var buf []byte
+		rc, msg, err, buf = i.storage.FetchModel(key)
 		if err != nil {
 			klog.Errorf("%s: %s", err.Error(), msg)
 			return false, nil
 		}
+		if rc == http.StatusNotFound {
+			klog.Infof("key %s was removed from storage since it was listed", key)
+			continue
+		}
 		if rc != http.StatusOK {
 			klog.Errorf("bad response code from storage fetch model %s is %d, %s", key, rc, msg)
 			return false, nil
 		}
-		_, uri := util.BuildImportKeyAndURI(segs[0], segs[1], i.format)
+		sb := types.StorageBody{}
+		err = json.Unmarshal(buf, &sb)
+		if err != nil {
+			klog.Errorf("error reading storage fetch model %s: %s", key, err.Error())
+			return false, nil
+		}
+		uri := util.BuildSourceURI(types.EffectiveSource(sb.Source, sb.ReconcilerType), seg1, seg2, i.format)
 		i.lock.Lock()
-		defer i.lock.Unlock()
-		i.content[uri] = il
-		i.router.GET(uri, il.handleCatalogInfoGet)
+		i.setFromStorage(key, uri, &sb, time.Now())
+		i.lock.Unlock()
 	}
 
 	return true, nil
 }
 
-func (i *ImportLocationServer) Run(stopCh <-chan struct{}) {
-	ch := make(chan int)
-	go func() {
-		for {
-			select {
-			case <-ch:
-				return
-			default:
-				err := i.router.Run(fmt.Sprintf(":%s", i.port))
-				if err != nil {
-					klog.Errorf("ERROR: gin-gonic run error %s", err.Error())
+// Run serves until ctx is done, and then drains the server, with the storage watch and resync carrying on until the
+// requests in flight are finished
+func (i *ImportLocationServer) Run(ctx context.Context) error {
+	bgCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go i.watchStorage(bgCtx)
+	if i.resyncSettings.interval > 0 {
+		klog.Infof("resyncing with storage every %s", i.resyncSettings.interval)
+		go wait.JitterUntilWithContext(bgCtx, i.resync, i.resyncSettings.interval, i.resyncSettings.jitter, true)
+	} else {
+		go wait.UntilWithContext(bgCtx, i.pruneRemoved, pruneInterval)
+	}
+	return lifecycle.Serve(ctx, i.port, i.router, i.timeouts, i.probes, i.drain)
+}
+
+// initialLoadReady checks that we have loaded what storage had when we started
+func (i *ImportLocationServer) initialLoadReady() error {
+	if !i.loaded.Load() {
+		return fmt.Errorf("the models in storage have not been loaded yet")
+	}
+	return nil
+}
+
+// watchStorage keeps our content in line with storage, so that an upsert or remove which storage-rest failed to push
+// to us is still picked up; when the stream has to be restarted, we resume from the last event we got
+func (i *ImportLocationServer) watchStorage(ctx context.Context) {
+	since := int64(0)
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		var err error
+		since, err = i.watchStorageOnce(ctx, since)
+		if err != nil && ctx.Err() == nil {
+			klog.Errorf("error watching storage: %s", err.Error())
+		}
+	}, 5*time.Second)
+}
+
+// watchStorageOnce applies the events from one watch stream, and returns the sequence to resume from
+func (i *ImportLocationServer) watchStorageOnce(ctx context.Context, since int64) (int64, error) {
+	// when the stream starts with every key, the URIs not among them are no longer in storage
+	seen := map[string]struct{}{}
+	err := i.storage.Watch(ctx, since, func(ev types.WatchEvent) error {
+		since = ev.Sequence
+		if ev.Type == types.WatchEventSynced {
+			i.loaded.Store(true)
+			i.lock.Lock()
+			defer i.lock.Unlock()
+			for uri, il := range i.content {
+				if _, ok := seen[uri]; !ok && il.content != nil {
+					klog.Infof("removing URI %s as it is no longer in storage", uri)
+					i.remove(uri, il, time.Now())
 				}
 			}
+			return nil
+		}
+		seg1, seg2, ok := util.KeySegments(ev.Key)
+		if !ok {
+			klog.Errorf("bad format for key from storage watch when splitting with '_': %s", ev.Key)
+			return nil
+		}
+		i.lock.Lock()
+		defer i.lock.Unlock()
+		switch {
+		case ev.Type == types.WatchEventDeleted:
+			i.removeKey(ev.Key, time.Now())
+		case ev.Value != nil:
+			uri := util.BuildSourceURI(types.EffectiveSource(ev.Value.Source, ev.Value.ReconcilerType), seg1, seg2, i.format)
+			seen[uri] = struct{}{}
+			i.setFromStorage(ev.Key, uri, ev.Value, time.Now())
 		}
-	}()
-	<-stopCh
-	close(ch)
+		return nil
+	})
+	return since, err
 }
 
 type ImportLocation struct {
+	// key is the storage key of the model
+	key     string
 	content []byte
+	// digest is the content digest storage-rest recorded, which we answer with as the ETag
+	digest string
+	// tombstone is set while storage keeps a removed model for its grace period, during which we answer with a 410
+	tombstone *types.Tombstone
+	// modelCardKey is the key of the model card upserted with the content
+	modelCardKey string
+	// updatedAt is when the content was last changed, by an upsert, a remove or from storage, so that a resync does
+	// not undo a change made after it listed storage
+	updatedAt time.Time
+	// generation is the catalog generation of the last change to the content, and addedGeneration the one at which the
+	// content was last added, after not being served
+	generation      int64
+	addedGeneration int64
 }
 
 func (i *ImportLocation) handleCatalogInfoGet(c *gin.Context) {
+	i.handleFormatGet(c, "", "")
+}
+
+// handleFormatGet serves the content, stored in one format, in the format asked for, with an ETag for each format; an
+// empty format serves the content as it is stored
+func (i *ImportLocation) handleFormatGet(c *gin.Context, format, stored types.NormalizerFormat) {
 	if i.content == nil {
 		c.Status(http.StatusNotFound)
 		return
 	}
-	c.Data(http.StatusOK, "Content-Type: application/json", i.content)
+	if i.tombstone != nil {
+		content, err := json.Marshal(i.tombstone)
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			c.Error(err)
+			return
+		}
+		c.Data(http.StatusGone, "Content-Type: application/json", content)
+		return
+	}
+	content, rc, err := convert(i.content, format, stored)
+	if err != nil {
+		c.Status(rc)
+		c.Error(err)
+		return
+	}
+	etag := i.etag(format, stored)
+	c.Header("ETag", etag)
+	if ifNoneMatch(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	contentType := "Content-Type: application/json"
+	if len(format) > 0 {
+		contentType = formatMIMETypes[format][0]
+	}
+	c.Data(http.StatusOK, contentType, content)
+}
+
+// etag quotes the digest of the content, with the file name of the format when the content is converted to it
+func (i *ImportLocation) etag(format, stored types.NormalizerFormat) string {
+	digest := i.contentDigest()
+	if format != stored {
+		digest = digest + "+" + util.FormatFileName(format)
+	}
+	return fmt.Sprintf("%q", digest)
+}
+
+// contentDigest is the digest storage-rest recorded for the content, or for content stored before it recorded digests,
+// the digest worked out from the content
+func (i *ImportLocation) contentDigest() string {
+	if len(i.digest) == 0 {
+		return types.ContentDigest(i.content, "", "")
+	}
+	return i.digest
+}
+
+// ifNoneMatch says whether the If-None-Match header lists the ETag, ignoring the weak validator prefix
+func ifNoneMatch(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
 }
 
 type DicoveryResponse struct {
@@ -170,13 +350,10 @@ type DicoveryResponse struct {
 
 func (i *ImportLocationServer) handleCatalogDiscoveryGet(c *gin.Context) {
 	d := &DicoveryResponse{}
-	i.lock.Lock()
-	defer i.lock.Unlock()
+	i.lock.RLock()
+	defer i.lock.RUnlock()
 	for uri, il := range i.content {
-		//TODO normalizer id should be part of the model lookup URI a la "kubeflow/mnist/v1" or "kserve/mnist/v1"
-
-		// since we cannot delete handlers from gin, when we delete a location, rather than removing from the map,
-		// we set the contents field to nil, so we check for that before deciding to in include the URI
+		// removed entries are kept in the index for a while, see prune
 		if il.content != nil {
 			d.Uris = append(d.Uris, uri)
 		}
@@ -190,12 +367,6 @@ func (i *ImportLocationServer) handleCatalogDiscoveryGet(c *gin.Context) {
 	c.Data(http.StatusOK, "Content-Type: application/json", content)
 }
 
-type ModelURI struct {
-	Model   string `uri:"model" binding:"required"`
-	Version string `uri:"version" binding:"required"`
-	Format  string `uri:"format" binding:"required"`
-}
-
 func (u *ImportLocationServer) handleCatalogUpsertPost(c *gin.Context) {
 	key := c.Query("key")
 	if len(key) == 0 {
@@ -212,19 +383,87 @@ func (u *ImportLocationServer) handleCatalogUpsertPost(c *gin.Context) {
 		c.Error(err)
 		return
 	}
-	segs := strings.Split(key, "_")
-	if len(segs) < 2 {
+	err = u.upsertContent(key, c.Query(util.SourceQueryParam), &postBody)
+	if validation.Respond(c, err) {
+		return
+	}
+	if err != nil {
 		c.Status(http.StatusBadRequest)
-		c.Error(fmt.Errorf("bad key format: %s", key))
+		c.Error(err)
 		return
 	}
-	//TODO normalizer id should be part of the model lookup URI
-	_, uriString := util.BuildImportKeyAndURI(segs[0], segs[1], u.format)
-	il := &ImportLocation{}
-	il.content = postBody.Body
+	c.Status(http.StatusCreated)
+}
+
+// handleCatalogUpsertBatchPost upserts every model of the batch, answering with the result for each
+func (u *ImportLocationServer) handleCatalogUpsertBatchPost(c *gin.Context) {
+	var batch rest.BatchUpsertBody
+	err := c.BindJSON(&batch)
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		msg := fmt.Sprintf("error reading POST body: %s", err.Error())
+		klog.Error(msg)
+		c.Error(err)
+		return
+	}
+	resp := &rest.BatchUpsertResponse{Results: make([]rest.BatchUpsertResult, 0, len(batch.Entries))}
+	for _, e := range batch.Entries {
+		result := rest.BatchUpsertResult{Key: e.Key, Status: http.StatusCreated}
+		err = u.upsertContent(e.Key, e.Source, &e.PostBody)
+		var verr *validation.Error
+		if errors.As(err, &verr) {
+			result.Status = http.StatusUnprocessableEntity
+			result.Error = verr.Error()
+			result.Errors = verr.Errors
+		} else if err != nil {
+			result.Status = http.StatusBadRequest
+			result.Error = err.Error()
+		}
+		resp.Results = append(resp.Results, result)
+	}
+	var content []byte
+	content, err = json.Marshal(resp)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		c.Error(err)
+		return
+	}
+	c.Data(http.StatusOK, "Content-Type: application/json", content)
+}
+
+// upsertContent serves the model under the storage key at its URI for the source, see util.BuildSourceKey and
+// util.BuildSourceURI
+func (u *ImportLocationServer) upsertContent(key, source string, postBody *rest.PostBody) error {
+	if len(key) == 0 {
+		return fmt.Errorf("need a 'key' parameter")
+	}
+	seg1, seg2, ok := util.KeySegments(key)
+	if !ok {
+		return fmt.Errorf("bad key format: %s", key)
+	}
+	key = strings.ReplaceAll(key, " ", "")
+	uriString := util.BuildSourceURI(source, seg1, seg2, u.format)
+	if postBody.Tombstone == nil {
+		// in lenient mode storage-rest has already recorded what is wrong, so the content is served all the same
+		_, err := validation.Check(u.validationMode, key, postBody.Body, u.format)
+		if err != nil {
+			return err
+		}
+	}
+	now := time.Now()
 	u.lock.Lock()
 	defer u.lock.Unlock()
-	u.content[uriString] = il
+	il := u.entry(key, uriString, now)
+	added := il.content == nil
+	differs := added || !bytes.Equal(il.content, postBody.Body) || il.digest != postBody.Digest || !sameTombstone(il.tombstone, postBody.Tombstone)
+	il.content = postBody.Body
+	il.digest = postBody.Digest
+	il.tombstone = postBody.Tombstone
+	il.modelCardKey = postBody.ModelCardKey
+	il.updatedAt = now
+	if differs {
+		u.changed(uriString, il, added)
+	}
 	mcm, ok := u.modelcards[postBody.ModelCardKey]
 	if !ok {
 		mcm = modelCardMetadata{
@@ -235,14 +474,16 @@ func (u *ImportLocationServer) handleCatalogUpsertPost(c *gin.Context) {
 		}
 	} else {
 		if mcm.lastUpdateTimeSinceEpoch != postBody.LastUpdateTimeSinceEpoch {
+			mcm.content = postBody.ModelCard
 			mcm.lastUpdateTimeSinceEpoch = postBody.LastUpdateTimeSinceEpoch
 			mcm.needToUpdate = true
 			mcm.updateCount = 0
 		}
 	}
+	mcm.updatedAt = now
 	u.modelcards[postBody.ModelCardKey] = mcm
 	klog.Infof("Upserting URI %s with data of len %d with modelcard key %s and modelcard len %d", uriString, len(postBody.Body), postBody.ModelCardKey, len(postBody.ModelCard))
-	c.Status(http.StatusCreated)
+	return nil
 }
 
 func (u *ImportLocationServer) handleCatalogDelete(c *gin.Context) {
@@ -252,22 +493,16 @@ func (u *ImportLocationServer) handleCatalogDelete(c *gin.Context) {
 		c.Error(fmt.Errorf("need a 'key' parameter"))
 		return
 	}
-	segs := strings.Split(key, "_")
-	if len(segs) < 2 {
+	if _, _, ok := util.KeySegments(key); !ok {
 		c.Status(http.StatusBadRequest)
 		c.Error(fmt.Errorf("bad key format: %s", key))
 		return
 	}
-	//TODO normalizer id should be part of the model lookup URI
-	_, uri := util.BuildImportKeyAndURI(segs[0], segs[1], u.format)
-	klog.Infof("Removing URI %s", uri)
-	// you don't unbind URIs, so we remove its content regardless of removing it from the map so that
-	// when backstage calls, we can return it a not found if the content is now nil
+	key = strings.ReplaceAll(key, " ", "")
 	u.lock.Lock()
 	defer u.lock.Unlock()
-	il, ok := u.content[uri]
-	if ok {
-		il.content = nil
+	if uri := u.removeKey(key, time.Now()); len(uri) > 0 {
+		klog.Infof("Removing URI %s", uri)
 	}
 	c.Status(http.StatusOK)
 }
@@ -1,16 +1,23 @@
 package server
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/cmd/cli/backstage"
 	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/rest"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/types"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/util"
 	"github.com/redhat-ai-dev/model-catalog-bridge/test/stub/common"
 	testgin "github.com/redhat-ai-dev/model-catalog-bridge/test/stub/gin-gonic"
 	"github.com/redhat-ai-dev/model-catalog-bridge/test/stub/storage"
@@ -45,6 +52,368 @@ func TestLoadFromStorage(t *testing.T) {
 	common.AssertNotNil(t, bodyBuf)
 }
 
+func TestWatchStorage(t *testing.T) {
+	callback := &sync.Map{}
+	st := storage.CreateBridgeStorageREST(t, callback)
+	defer st.Close()
+	ils := &ImportLocationServer{
+		content: map[string]*ImportLocation{
+			"/foo/bar/catalog-info.yaml":  {content: []byte("stale")},
+			"/mnist/v1/catalog-info.yaml": {content: []byte("removed from storage")},
+		},
+		storage: storage.SetupBridgeStorageRESTClient(st),
+	}
+	if ils.initialLoadReady() == nil {
+		t.Error("expected not to be ready before hearing from storage")
+	}
+
+	since, err := ils.watchStorageOnce(context.Background(), 5)
+
+	common.AssertError(t, err)
+	// the watch catching up with storage completes the initial load, should the one time load have failed
+	common.AssertError(t, ils.initialLoadReady())
+	common.AssertEqual(t, int64(10), since)
+	sinceParam, ok := callback.Load(util.WatchURI)
+	common.AssertEqual(t, true, ok)
+	common.AssertEqual(t, "5", sinceParam)
+	common.AssertEqual(t, "foo-bar", string(ils.content["/foo/bar/catalog-info.yaml"].content))
+	if ils.content["/mnist/v1/catalog-info.yaml"].content != nil {
+		t.Error("expected the content for a key no longer in storage to be removed")
+	}
+}
+
+func TestResync(t *testing.T) {
+	callback := &sync.Map{}
+	st := storage.CreateBridgeStorageREST(t, callback)
+	defer st.Close()
+	recently := time.Now().Add(time.Hour)
+	ils := &ImportLocationServer{
+		content: map[string]*ImportLocation{
+			"/foo/bar/catalog-info.yaml":     {content: []byte("stale"), digest: "sha256:stale", modelCardKey: "foo_bar"},
+			"/mnist/v1/catalog-info.yaml":    {content: []byte("removed from storage"), modelCardKey: "mnist_v1"},
+			"/upserted/v1/catalog-info.yaml": {content: []byte("upserted while resyncing"), updatedAt: recently},
+		},
+		modelcards: map[string]modelCardMetadata{
+			"mnist_v1":    {content: "# removed from storage"},
+			"upserted_v1": {content: "# upserted while resyncing", updatedAt: recently},
+		},
+		storage:        storage.SetupBridgeStorageRESTClient(st),
+		resyncSettings: resyncSettings{interval: time.Minute},
+	}
+
+	ils.resync(context.Background())
+
+	// both pages of the listing are gone through
+	limit, ok := callback.Load(util.ListMetadataURI + "/")
+	common.AssertEqual(t, true, ok)
+	common.AssertEqual(t, resyncListLimit, limit)
+	_, ok = callback.Load(util.ListMetadataURI + "/mnist_v2")
+	common.AssertEqual(t, true, ok)
+	common.AssertEqual(t, "foo_bar", string(ils.content["/foo/bar/catalog-info.yaml"].content))
+	common.AssertEqual(t, "sha256:foo_bar", ils.content["/foo/bar/catalog-info.yaml"].digest)
+	common.AssertEqual(t, "mnist_v2", string(ils.content["/mnist/v2/catalog-info.yaml"].content))
+	if ils.content["/mnist/v1/catalog-info.yaml"].content != nil {
+		t.Error("expected the content for a key no longer in storage to be removed")
+	}
+	// what was upserted after the resync listed storage is left alone
+	common.AssertEqual(t, "upserted while resyncing", string(ils.content["/upserted/v1/catalog-info.yaml"].content))
+	common.AssertEqual(t, "# foo_bar", ils.modelcards["foo_bar"].content)
+	common.AssertEqual(t, true, ils.modelcards["foo_bar"].needToUpdate)
+	common.AssertEqual(t, "# mnist_v2", ils.modelcards["mnist_v2"].content)
+	_, ok = ils.modelcards["mnist_v1"]
+	common.AssertEqual(t, false, ok)
+	_, ok = ils.modelcards["upserted_v1"]
+	common.AssertEqual(t, true, ok)
+	common.AssertError(t, ils.initialLoadReady())
+
+	// once in sync, nothing is fetched or changed
+	callback.Delete(util.FetchURI)
+	ils.resync(context.Background())
+	_, ok = callback.Load(util.FetchURI)
+	common.AssertEqual(t, false, ok)
+
+	testWriter := testgin.NewTestResponseWriter()
+	ctx, _ := gin.CreateTestContext(testWriter)
+	ils.handleResyncGet(ctx)
+	common.AssertEqual(t, http.StatusOK, ctx.Writer.Status())
+	status := ResyncStatus{}
+	common.AssertError(t, json.Unmarshal(testWriter.ResponseWriter.Body.Bytes(), &status))
+	common.AssertEqual(t, "1m0s", status.Interval)
+	common.AssertNotNil(t, status.LastSync)
+	common.AssertEqual(t, "", status.LastError)
+	common.AssertEqual(t, ResyncCounts{}, status.Last)
+	common.AssertEqual(t, ResyncCounts{Added: 1, Updated: 1, Removed: 1, ModelCardsUpdated: 2, ModelCardsRemoved: 1}, status.Total)
+
+	// a failed resync is recorded, and keeps what we have
+	st.Close()
+	ils.resync(context.Background())
+	common.AssertEqual(t, "mnist_v2", string(ils.content["/mnist/v2/catalog-info.yaml"].content))
+	if len(ils.resyncStatus.LastError) == 0 {
+		t.Error("expected the failed resync to be recorded")
+	}
+	common.AssertEqual(t, true, ils.resyncStatus.LastAttempt.After(*ils.resyncStatus.LastSync))
+}
+
+func TestHandleContentGet(t *testing.T) {
+	ils := &ImportLocationServer{
+		content: map[string]*ImportLocation{
+			"/kubeflow/registry-1/mnist/v1/catalog-info.yaml": {key: "mnist_v1", content: []byte("mnist")},
+			"/granite/v1/catalog-info.yaml":                   {key: "granite_v1", content: []byte("granite")},
+			"/kserve/-/gone/v1/catalog-info.yaml":             {key: "gone_v1"},
+		},
+		uris: map[string]string{
+			"mnist_v1":   "/kubeflow/registry-1/mnist/v1/catalog-info.yaml",
+			"granite_v1": "/granite/v1/catalog-info.yaml",
+			"gone_v1":    "/kserve/-/gone/v1/catalog-info.yaml",
+		},
+	}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET(util.ListURI, ils.handleCatalogDiscoveryGet)
+	r.GET(util.UpsertBatchURI, func(c *gin.Context) { c.Status(http.StatusTeapot) })
+	r.GET(contentRoute, ils.handleContentGet)
+	r.GET(legacyContentRoute, ils.handleContentGet)
+	for _, tc := range []struct {
+		uri              string
+		expectedSC       int
+		expectedBody     string
+		expectedLocation string
+	}{
+		{uri: "/kubeflow/registry-1/mnist/v1/catalog-info.yaml", expectedSC: http.StatusOK, expectedBody: "mnist"},
+		// the URIs from before sources were part of them are redirected
+		{uri: "/mnist/v1/catalog-info.yaml", expectedSC: http.StatusFound, expectedLocation: "/kubeflow/registry-1/mnist/v1/catalog-info.yaml"},
+		// unless they are the URIs of models stored without a source
+		{uri: "/granite/v1/catalog-info.yaml", expectedSC: http.StatusOK, expectedBody: "granite"},
+		// a model with the same names from another source is another model
+		{uri: "/kserve/-/mnist/v1/catalog-info.yaml", expectedSC: http.StatusNotFound},
+		{uri: "/kserve/-/gone/v1/catalog-info.yaml", expectedSC: http.StatusNotFound},
+		{uri: "/gone/v1/catalog-info.yaml", expectedSC: http.StatusNotFound},
+		{uri: util.UpsertBatchURI, expectedSC: http.StatusTeapot},
+		{uri: util.ListURI, expectedSC: http.StatusOK},
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.uri, nil))
+		common.AssertEqual(t, tc.expectedSC, w.Code)
+		if len(tc.expectedBody) > 0 {
+			common.AssertEqual(t, tc.expectedBody, w.Body.String())
+		}
+		common.AssertEqual(t, tc.expectedLocation, w.Header().Get("Location"))
+	}
+}
+
+func TestHandleContentGetMovedModel(t *testing.T) {
+	ils := &ImportLocationServer{
+		content:    map[string]*ImportLocation{},
+		modelcards: map[string]modelCardMetadata{},
+	}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET(contentRoute, ils.handleContentGet)
+	get := func(uri string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, uri, nil))
+		return w
+	}
+	prune := func() {
+		ils.lock.Lock()
+		ils.prune(time.Now().Add(time.Second))
+		ils.lock.Unlock()
+	}
+	old := "/kubeflow/registry-1/mnist/v1/catalog-info.yaml"
+	moved := "/kubeflow/registry-2/mnist/v1/catalog-info.yaml"
+
+	// the model moves to another registry, and the Backstage location with the URI it had is redirected to the new one
+	common.AssertError(t, ils.upsertContent("mnist_v1", "kubeflow/registry-1", &rest.PostBody{Body: []byte("mnist")}))
+	common.AssertError(t, ils.upsertContent("mnist_v1", "kubeflow/registry-2", &rest.PostBody{Body: []byte("mnist")}))
+	w := get(old)
+	common.AssertEqual(t, http.StatusFound, w.Code)
+	common.AssertEqual(t, moved, w.Header().Get("Location"))
+	common.AssertEqual(t, "/kubeflow/registry-2/mnist/v1/model-catalog.json", get("/kubeflow/registry-1/mnist/v1/model-catalog.json").Header().Get("Location"))
+	common.AssertEqual(t, http.StatusOK, get(moved).Code)
+
+	// for as long as the model is served
+	prune()
+	common.AssertEqual(t, http.StatusFound, get(old).Code)
+	ils.lock.Lock()
+	ils.removeKey("mnist_v1", time.Now())
+	ils.lock.Unlock()
+	common.AssertEqual(t, http.StatusNotFound, get(old).Code)
+	common.AssertEqual(t, http.StatusNotFound, get(moved).Code)
+	prune()
+	common.AssertEqual(t, 0, len(ils.content))
+}
+
+func TestHandleContentGetSameModelOfTwoSources(t *testing.T) {
+	ils := &ImportLocationServer{
+		content:    map[string]*ImportLocation{},
+		modelcards: map[string]modelCardMetadata{},
+	}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET(contentRoute, ils.handleContentGet)
+	r.GET(legacyContentRoute, ils.handleContentGet)
+	get := func(uri string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, uri, nil))
+		return w
+	}
+	keyA := util.BuildSourceKey("kubeflow/ns:reg-a", "mnist", "v1")
+	keyB := util.BuildSourceKey("kubeflow/ns:reg-b", "mnist", "v1")
+	uriA := "/kubeflow/ns:reg-a/mnist/v1/catalog-info.yaml"
+	uriB := "/kubeflow/ns:reg-b/mnist/v1/catalog-info.yaml"
+	legacy := "/kubeflow/-/mnist/v1/catalog-info.yaml"
+
+	// the model under the key from before sources were part of keys, with the URI from before registries were part of
+	// them, is taken over by the key of the first registry
+	common.AssertError(t, ils.upsertContent("mnist_v1", "kubeflow", &rest.PostBody{Body: []byte("mnist")}))
+	common.AssertError(t, ils.upsertContent(keyA, "kubeflow/ns:reg-a", &rest.PostBody{Body: []byte("mnist a")}))
+	ils.lock.Lock()
+	ils.removeKey("mnist_v1", time.Now())
+	ils.lock.Unlock()
+	common.AssertEqual(t, uriA, get(legacy).Header().Get("Location"))
+
+	// each registry has its own model
+	common.AssertError(t, ils.upsertContent(keyB, "kubeflow/ns:reg-b", &rest.PostBody{Body: []byte("mnist b")}))
+	w := get(uriA)
+	common.AssertEqual(t, http.StatusOK, w.Code)
+	common.AssertEqual(t, "mnist a", w.Body.String())
+	w = get(uriB)
+	common.AssertEqual(t, http.StatusOK, w.Code)
+	common.AssertEqual(t, "mnist b", w.Body.String())
+	w = get("/mnist/v1/catalog-info.yaml")
+	common.AssertEqual(t, http.StatusFound, w.Code)
+	common.AssertEqual(t, uriA, w.Header().Get("Location"))
+
+	// the old URIs are redirected to the registry which still has the model
+	ils.lock.Lock()
+	ils.removeKey(keyA, time.Now())
+	ils.lock.Unlock()
+	common.AssertEqual(t, http.StatusNotFound, get(uriA).Code)
+	common.AssertEqual(t, http.StatusOK, get(uriB).Code)
+	common.AssertEqual(t, uriB, get(legacy).Header().Get("Location"))
+	common.AssertEqual(t, uriB, get("/mnist/v1/catalog-info.yaml").Header().Get("Location"))
+}
+
+func TestHandleContentGetFormats(t *testing.T) {
+	body := `{"models":[{"name":"mnist-v1","owner":"kubeadmin","lifecycle":"production","description":"mnist"}]}`
+	for _, tc := range []struct {
+		name                string
+		format              types.NormalizerFormat
+		content             string
+		uri                 string
+		accept              string
+		expectedSC          int
+		expectedContentType string
+		expectedETag        string
+		expectedLocation    string
+	}{
+		{
+			name:                "model catalog as stored",
+			format:              types.JsonArrayForamt,
+			content:             body,
+			uri:                 "/kubeflow/registry-1/mnist/v1/model-catalog.json",
+			expectedSC:          http.StatusOK,
+			expectedContentType: "application/json",
+			expectedETag:        `"sha256:mnist"`,
+		},
+		{
+			name:                "model catalog as catalog-info.yaml by file name",
+			format:              types.JsonArrayForamt,
+			content:             body,
+			uri:                 "/kubeflow/registry-1/mnist/v1/catalog-info.yaml",
+			expectedSC:          http.StatusOK,
+			expectedContentType: "application/yaml",
+			expectedETag:        `"sha256:mnist+catalog-info.yaml"`,
+		},
+		{
+			name:                "model catalog as catalog-info.yaml by accept header",
+			format:              types.JsonArrayForamt,
+			content:             body,
+			uri:                 "/kubeflow/registry-1/mnist/v1/model-catalog.json",
+			accept:              "application/x-yaml",
+			expectedSC:          http.StatusOK,
+			expectedContentType: "application/yaml",
+			expectedETag:        `"sha256:mnist+catalog-info.yaml"`,
+		},
+		{
+			name:                "accept header without either format",
+			format:              types.JsonArrayForamt,
+			content:             body,
+			uri:                 "/kubeflow/registry-1/mnist/v1/model-catalog.json",
+			accept:              "text/html, */*",
+			expectedSC:          http.StatusOK,
+			expectedContentType: "application/json",
+			expectedETag:        `"sha256:mnist"`,
+		},
+		{
+			name:             "legacy URI redirected in the format asked for",
+			format:           types.JsonArrayForamt,
+			content:          body,
+			uri:              "/mnist/v1/catalog-info.yaml",
+			expectedSC:       http.StatusFound,
+			expectedLocation: "/kubeflow/registry-1/mnist/v1/catalog-info.yaml",
+		},
+		{
+			name:       "catalog-info.yaml cannot be a model catalog",
+			format:     types.CatalogInfoYamlFormat,
+			content:    "apiVersion: backstage.io/v1alpha1",
+			uri:        "/kubeflow/registry-1/mnist/v1/model-catalog.json",
+			expectedSC: http.StatusNotAcceptable,
+		},
+		{
+			name:       "catalog-info.yaml cannot be a model catalog by accept header",
+			format:     types.CatalogInfoYamlFormat,
+			content:    "apiVersion: backstage.io/v1alpha1",
+			uri:        "/kubeflow/registry-1/mnist/v1/catalog-info.yaml",
+			accept:     "application/json",
+			expectedSC: http.StatusNotAcceptable,
+		},
+		{
+			name:       "unknown file name",
+			format:     types.JsonArrayForamt,
+			content:    body,
+			uri:        "/kubeflow/registry-1/mnist/v1/catalog-info.json",
+			expectedSC: http.StatusNotFound,
+		},
+	} {
+		storedURI := util.BuildSourceURI("kubeflow/registry-1", "mnist", "v1", tc.format)
+		ils := &ImportLocationServer{
+			format:  tc.format,
+			content: map[string]*ImportLocation{storedURI: {key: "mnist_v1", content: []byte(tc.content), digest: "sha256:mnist"}},
+			uris:    map[string]string{"mnist_v1": storedURI},
+		}
+		gin.SetMode(gin.TestMode)
+		r := gin.New()
+		r.GET(contentRoute, ils.handleContentGet)
+		r.GET(legacyContentRoute, ils.handleContentGet)
+		req := httptest.NewRequest(http.MethodGet, tc.uri, nil)
+		if len(tc.accept) > 0 {
+			req.Header.Set("Accept", tc.accept)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tc.expectedSC {
+			t.Errorf("%s: expected %d, got %d: %s", tc.name, tc.expectedSC, w.Code, w.Body.String())
+		}
+		common.AssertEqual(t, tc.expectedETag, w.Header().Get("ETag"))
+		common.AssertEqual(t, tc.expectedLocation, w.Header().Get("Location"))
+		if len(tc.expectedContentType) > 0 {
+			common.AssertEqual(t, tc.expectedContentType, w.Header().Get("Content-Type"))
+		}
+		if tc.expectedSC != http.StatusOK {
+			continue
+		}
+		switch tc.expectedContentType {
+		case "application/json":
+			common.AssertEqual(t, tc.content, w.Body.String())
+		default:
+			refs, err := backstage.EntityRefs(w.Body.Bytes(), types.CatalogInfoYamlFormat)
+			common.AssertError(t, err)
+			common.AssertEqual(t, []string{"resource:default/mnist-v1"}, refs)
+		}
+	}
+}
+
 func TestHandleCatalogDiscoveryGet(t *testing.T) {
 	for _, tc := range []struct {
 		name              string
@@ -152,6 +521,230 @@ func TestHandleCatalogDiscoveryGetModel(t *testing.T) {
 	}
 }
 
+func TestHandleCatalogGet(t *testing.T) {
+	ils := &ImportLocationServer{
+		content:    map[string]*ImportLocation{},
+		modelcards: map[string]modelCardMetadata{},
+		generation: 100,
+		compacted:  100,
+	}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET(util.CatalogURI, ils.handleCatalogGet)
+	get := func(query string) (int, *Catalog) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, util.CatalogURI+query, nil))
+		cat := &Catalog{}
+		if w.Code == http.StatusOK {
+			common.AssertError(t, json.Unmarshal(w.Body.Bytes(), cat))
+		}
+		return w.Code, cat
+	}
+	uris := func(entries []CatalogEntry) []string {
+		u := []string{}
+		for _, e := range entries {
+			u = append(u, e.URI)
+		}
+		return u
+	}
+	upsert := func(key, body string) {
+		common.AssertError(t, ils.upsertContent(key, "kubeflow/registry-1", &rest.PostBody{Body: []byte(body), Digest: "sha256:" + body}))
+	}
+	a := "/kubeflow/registry-1/a/v1/catalog-info.yaml"
+	b := "/kubeflow/registry-1/b/v1/catalog-info.yaml"
+	c := "/kubeflow/registry-1/c/v1/catalog-info.yaml"
+
+	upsert("a_v1", "a")
+	upsert("b_v1", "b")
+	rc, cat := get("")
+	common.AssertEqual(t, http.StatusOK, rc)
+	common.AssertEqual(t, int64(102), cat.Generation)
+	common.AssertEqual(t, true, cat.Full)
+	common.AssertEqual(t, []string{a, b}, uris(cat.Added))
+	common.AssertEqual(t, "a", cat.Added[0].Content)
+	common.AssertEqual(t, "sha256:a", cat.Added[0].Digest)
+	common.AssertEqual(t, int64(101), cat.Added[0].Generation)
+
+	upsert("a_v1", "a2")
+	ils.lock.Lock()
+	ils.removeKey("b_v1", time.Now())
+	ils.lock.Unlock()
+	upsert("c_v1", "c")
+	// upserting what we already serve is not a change
+	upsert("c_v1", "c")
+	rc, cat = get("?since=102")
+	common.AssertEqual(t, http.StatusOK, rc)
+	common.AssertEqual(t, int64(105), cat.Generation)
+	common.AssertEqual(t, false, cat.Full)
+	common.AssertEqual(t, int64(102), *cat.Since)
+	common.AssertEqual(t, []string{c}, uris(cat.Added))
+	common.AssertEqual(t, []string{a}, uris(cat.Changed))
+	common.AssertEqual(t, "a2", cat.Changed[0].Content)
+	common.AssertEqual(t, []string{b}, uris(cat.Removed))
+	common.AssertEqual(t, "", cat.Removed[0].Content)
+
+	_, cat = get("?since=105")
+	common.AssertEqual(t, false, cat.Full)
+	common.AssertEqual(t, 0, len(cat.Added)+len(cat.Changed)+len(cat.Removed))
+
+	// deltas from before the generations we have, or after them, say from before a restart, get every model
+	for _, since := range []string{"99", "106"} {
+		_, cat = get("?since=" + since)
+		common.AssertEqual(t, true, cat.Full)
+		common.AssertEqual(t, []string{a, c}, uris(cat.Added))
+		common.AssertEqual(t, 0, len(cat.Removed))
+	}
+
+	// once the removal is pruned, it can no longer be listed
+	ils.lock.Lock()
+	ils.prune(time.Now().Add(time.Second))
+	ils.lock.Unlock()
+	_, cat = get("?since=103")
+	common.AssertEqual(t, true, cat.Full)
+	_, cat = get("?since=104")
+	common.AssertEqual(t, false, cat.Full)
+	common.AssertEqual(t, []string{c}, uris(cat.Added))
+
+	rc, _ = get("?since=yesterday")
+	common.AssertEqual(t, http.StatusBadRequest, rc)
+}
+
+func TestPruneRemoved(t *testing.T) {
+	now := time.Now()
+	ils := &ImportLocationServer{
+		content: map[string]*ImportLocation{
+			"/kubeflow/registry-1/a/v1/catalog-info.yaml": {key: "a_v1", updatedAt: now.Add(-2 * pruneInterval), generation: 101},
+			"/kubeflow/registry-1/b/v1/catalog-info.yaml": {key: "b_v1", updatedAt: now, generation: 102},
+			"/kubeflow/registry-1/c/v1/catalog-info.yaml": {key: "c_v1", content: []byte("c"), updatedAt: now.Add(-2 * pruneInterval), generation: 100},
+		},
+		uris: map[string]string{
+			"a_v1": "/kubeflow/registry-1/a/v1/catalog-info.yaml",
+			"b_v1": "/kubeflow/registry-1/b/v1/catalog-info.yaml",
+			"c_v1": "/kubeflow/registry-1/c/v1/catalog-info.yaml",
+		},
+		generation: 102,
+	}
+	// with resync off, only the entries removed long enough ago are pruned
+	ils.pruneRemoved(context.Background())
+	common.AssertEqual(t, 2, len(ils.content))
+	if _, ok := ils.content["/kubeflow/registry-1/a/v1/catalog-info.yaml"]; ok {
+		t.Error("expected the entry removed long ago to be pruned")
+	}
+	common.AssertEqual(t, int64(101), ils.compacted)
+	common.AssertEqual(t, map[string]string{"b_v1": "/kubeflow/registry-1/b/v1/catalog-info.yaml", "c_v1": "/kubeflow/registry-1/c/v1/catalog-info.yaml"}, ils.uris)
+}
+
+// readEvent reads the next server-sent event, skipping comments, returning its id and what it has
+func readEvent(t *testing.T, r *bufio.Reader) (string, *LocationEvent) {
+	t.Helper()
+	id, ev := "", &LocationEvent{}
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("error reading event: %s", err.Error())
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case len(line) == 0 && len(ev.Type) > 0:
+			return id, ev
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "data: "):
+			common.AssertError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), ev))
+		}
+	}
+}
+
+func TestHandleEventsGet(t *testing.T) {
+	ils := &ImportLocationServer{
+		content:    map[string]*ImportLocation{},
+		modelcards: map[string]modelCardMetadata{},
+		generation: 100,
+		compacted:  100,
+		draining:   make(chan struct{}),
+	}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET(util.EventsURI, ils.handleEventsGet)
+	r.DELETE(util.RemoveURI, ils.handleCatalogDelete)
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+	connect := func(lastEventID string) (*http.Response, *bufio.Reader) {
+		req, err := http.NewRequest(http.MethodGet, ts.URL+util.EventsURI, nil)
+		common.AssertError(t, err)
+		if len(lastEventID) > 0 {
+			req.Header.Set(lastEventIDHeader, lastEventID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		common.AssertError(t, err)
+		return resp, bufio.NewReader(resp.Body)
+	}
+	upsert := func(key, body string) {
+		common.AssertError(t, ils.upsertContent(key, "kubeflow/registry-1", &rest.PostBody{Body: []byte(body), Digest: "sha256:" + body}))
+	}
+	a := "/kubeflow/registry-1/a/v1/catalog-info.yaml"
+	b := "/kubeflow/registry-1/b/v1/catalog-info.yaml"
+
+	upsert("a_v1", "a")
+	resp, events := connect("")
+	common.AssertEqual(t, http.StatusOK, resp.StatusCode)
+	common.AssertEqual(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	// a new consumer gets every model, without ids so that it does not resume from part way through them
+	id, ev := readEvent(t, events)
+	common.AssertEqual(t, "", id)
+	common.AssertEqual(t, LocationEvent{Type: EventUpsert, URI: a, Digest: "sha256:a", Generation: 101}, *ev)
+	id, ev = readEvent(t, events)
+	common.AssertEqual(t, "101", id)
+	common.AssertEqual(t, EventSynced, ev.Type)
+
+	upsert("b_v1", "b")
+	id, ev = readEvent(t, events)
+	common.AssertEqual(t, "102", id)
+	common.AssertEqual(t, LocationEvent{Type: EventUpsert, URI: b, Digest: "sha256:b", Generation: 102}, *ev)
+	req, err := http.NewRequest(http.MethodDelete, ts.URL+util.RemoveURI+"?key=a_v1", nil)
+	common.AssertError(t, err)
+	del, err := http.DefaultClient.Do(req)
+	common.AssertError(t, err)
+	del.Body.Close()
+	id, ev = readEvent(t, events)
+	common.AssertEqual(t, "103", id)
+	common.AssertEqual(t, LocationEvent{Type: EventRemove, URI: a, Generation: 103}, *ev)
+	// upserting what we already serve is not a change
+	upsert("b_v1", "b")
+	upsert("a_v1", "a")
+	id, ev = readEvent(t, events)
+	common.AssertEqual(t, "104", id)
+	common.AssertEqual(t, LocationEvent{Type: EventUpsert, URI: a, Digest: "sha256:a", Generation: 104}, *ev)
+	resp.Body.Close()
+
+	// resuming gets the events missed
+	resp, events = connect("102")
+	id, ev = readEvent(t, events)
+	common.AssertEqual(t, "104", id)
+	common.AssertEqual(t, a, ev.URI)
+	resp.Body.Close()
+
+	// when the consumer cannot be resumed, it gets every model again
+	resp, events = connect("50")
+	_, ev = readEvent(t, events)
+	common.AssertEqual(t, b, ev.URI)
+	_, ev = readEvent(t, events)
+	common.AssertEqual(t, a, ev.URI)
+	id, ev = readEvent(t, events)
+	common.AssertEqual(t, "104", id)
+	common.AssertEqual(t, EventSynced, ev.Type)
+
+	// shutting down ends the streams
+	ils.drain()
+	_, err = io.ReadAll(events)
+	common.AssertError(t, err)
+	resp.Body.Close()
+
+	resp, _ = connect("yesterday")
+	common.AssertEqual(t, http.StatusBadRequest, resp.StatusCode)
+	resp.Body.Close()
+}
+
 func TestHandleCatalogUpsertPost(t *testing.T) {
 	// define outside of the test loop so we can vet updates vs. creates
 	ils := &ImportLocationServer{content: map[string]*ImportLocation{}, modelcards: map[string]modelCardMetadata{}}
@@ -180,7 +773,7 @@ func TestHandleCatalogUpsertPost(t *testing.T) {
 			body:       rest.PostBody{Body: []byte("create")},
 			expectedSC: http.StatusCreated,
 			expectedContent: map[string]*ImportLocation{
-				"/mnist/v1/catalog-info.yaml": {content: []byte("create")},
+				"/mnist/v1/catalog-info.yaml": {key: "mnist_v1", content: []byte("create")},
 			},
 		},
 		{
@@ -189,7 +782,27 @@ func TestHandleCatalogUpsertPost(t *testing.T) {
 			body:       rest.PostBody{Body: []byte("update")},
 			expectedSC: http.StatusCreated,
 			expectedContent: map[string]*ImportLocation{
-				"/mnist/v1/catalog-info.yaml": {content: []byte("update")},
+				"/mnist/v1/catalog-info.yaml": {key: "mnist_v1", content: []byte("update")},
+			},
+		},
+		{
+			name:       "entry moved to its source",
+			reqURL:     url.URL{RawQuery: "key=mnist_v1&source=kubeflow/registry-1"},
+			body:       rest.PostBody{Body: []byte("update")},
+			expectedSC: http.StatusCreated,
+			expectedContent: map[string]*ImportLocation{
+				"/mnist/v1/catalog-info.yaml":                     {key: "mnist_v1"},
+				"/kubeflow/registry-1/mnist/v1/catalog-info.yaml": {key: "mnist_v1", content: []byte("update")},
+			},
+		},
+		{
+			name:       "entry taken over by the key of its source",
+			reqURL:     url.URL{RawQuery: "key=kubeflow_registry-1_mnist_v1&source=kubeflow/registry-1"},
+			body:       rest.PostBody{Body: []byte("update")},
+			expectedSC: http.StatusCreated,
+			expectedContent: map[string]*ImportLocation{
+				"/mnist/v1/catalog-info.yaml":                     {key: "mnist_v1"},
+				"/kubeflow/registry-1/mnist/v1/catalog-info.yaml": {key: "kubeflow_registry-1_mnist_v1", content: []byte("update")},
 			},
 		},
 	} {
@@ -219,7 +832,13 @@ func TestHandleCatalogUpsertPost(t *testing.T) {
 		for key, val := range tc.expectedContent {
 			v, ok := ils.content[key]
 			common.AssertEqual(t, true, ok)
-			common.AssertEqual(t, val, v)
+			if v.updatedAt.IsZero() || v.generation == 0 {
+				t.Errorf("expected when %s was upserted to be recorded", key)
+			}
+			got := *v
+			got.updatedAt = time.Time{}
+			got.generation, got.addedGeneration = 0, 0
+			common.AssertEqual(t, val, &got)
 		}
 	}
 }
@@ -248,24 +867,24 @@ func TestHandleCatalogDelete(t *testing.T) {
 			name:   "entry does not exist",
 			reqURL: url.URL{RawQuery: "key=mnist_v2"},
 			existingContent: map[string]*ImportLocation{
-				"/mnist/v1/catalog-info.yaml": {content: []byte("create")},
+				"/mnist/v1/catalog-info.yaml": {key: "mnist_v1", content: []byte("create")},
 			},
 			expectedSC: http.StatusOK,
 			expectedContent: map[string]*ImportLocation{
-				"/mnist/v1/catalog-info.yaml": {content: []byte("create")},
+				"/mnist/v1/catalog-info.yaml": {key: "mnist_v1", content: []byte("create")},
 			},
 		},
 		{
 			name:   "entry exists",
 			reqURL: url.URL{RawQuery: "key=mnist_v2"},
 			existingContent: map[string]*ImportLocation{
-				"/mnist/v1/catalog-info.yaml": {content: []byte("create")},
-				"/mnist/v2/catalog-info.yaml": {content: []byte("create")},
+				"/mnist/v1/catalog-info.yaml":          {key: "mnist_v1", content: []byte("create")},
+				"/kserve/-/mnist/v2/catalog-info.yaml": {key: "mnist_v2", content: []byte("create")},
 			},
 			expectedSC: http.StatusOK,
 			expectedContent: map[string]*ImportLocation{
-				"/mnist/v1/catalog-info.yaml": {content: []byte("create")},
-				"/mnist/v2/catalog-info.yaml": {content: nil},
+				"/mnist/v1/catalog-info.yaml":          {key: "mnist_v1", content: []byte("create")},
+				"/kserve/-/mnist/v2/catalog-info.yaml": {key: "mnist_v2", content: nil},
 			},
 		},
 	} {
@@ -273,7 +892,10 @@ func TestHandleCatalogDelete(t *testing.T) {
 
 		ctx, eng := gin.CreateTestContext(testWriter)
 		ctx.Request = &http.Request{URL: &tc.reqURL}
-		ils := &ImportLocationServer{content: tc.existingContent, modelcards: map[string]modelCardMetadata{}}
+		ils := &ImportLocationServer{content: tc.existingContent, uris: map[string]string{}, modelcards: map[string]modelCardMetadata{}}
+		for uri, il := range tc.existingContent {
+			ils.uris[il.key] = uri
+		}
 		ils.router = eng
 
 		ils.handleCatalogDelete(ctx)
@@ -295,7 +917,112 @@ func TestHandleCatalogDelete(t *testing.T) {
 		for key, val := range tc.expectedContent {
 			v, ok := ils.content[key]
 			common.AssertEqual(t, ok, true)
-			common.AssertEqual(t, v, val)
+			// when the content was removed is recorded, so that a resync does not bring it back and the catalog
+			// delta lists it
+			got := *v
+			if got.content == nil && got.generation == 0 {
+				t.Errorf("expected the removal of %s to have a generation", key)
+			}
+			got.updatedAt = time.Time{}
+			got.generation = 0
+			common.AssertEqual(t, &got, val)
+		}
+	}
+}
+
+func TestHandleCatalogInfoGetTombstone(t *testing.T) {
+	removedAt := time.Now().UTC().Truncate(time.Second)
+	for _, tc := range []struct {
+		name       string
+		il         *ImportLocation
+		expectedSC int
+		expected   string
+	}{
+		{
+			name:       "deleted",
+			il:         &ImportLocation{},
+			expectedSC: http.StatusNotFound,
+		},
+		{
+			name:       "live",
+			il:         &ImportLocation{content: []byte("create")},
+			expectedSC: http.StatusOK,
+			expected:   "create",
+		},
+		{
+			name:       "tombstoned",
+			il:         &ImportLocation{content: []byte("create"), tombstone: &types.Tombstone{RemovedAt: removedAt, RemovedBy: "10.0.0.1", Reason: "gone", ExpiresAt: removedAt.Add(time.Hour)}},
+			expectedSC: http.StatusGone,
+			expected:   "gone",
+		},
+	} {
+		testWriter := testgin.NewTestResponseWriter()
+		ctx, _ := gin.CreateTestContext(testWriter)
+		ctx.Request = &http.Request{URL: &url.URL{}}
+
+		tc.il.handleCatalogInfoGet(ctx)
+
+		common.AssertEqual(t, tc.expectedSC, ctx.Writer.Status())
+		common.AssertContains(t, testWriter.ResponseWriter.Body.String(), []string{tc.expected})
+		if tc.il.tombstone != nil {
+			tombstone := &types.Tombstone{}
+			err := json.Unmarshal(testWriter.ResponseWriter.Body.Bytes(), tombstone)
+			common.AssertError(t, err)
+			common.AssertEqual(t, tc.il.tombstone, tombstone)
+		}
+	}
+}
+
+func TestHandleCatalogInfoGetETag(t *testing.T) {
+	body := []byte("apiVersion: backstage.io/v1alpha1")
+	digest := types.ContentDigest(body, "mnist", "# mnist")
+	for _, tc := range []struct {
+		name         string
+		il           *ImportLocation
+		ifNoneMatch  string
+		expectedSC   int
+		expectedETag string
+	}{
+		{
+			name:         "no If-None-Match",
+			il:           &ImportLocation{content: body, digest: digest},
+			expectedSC:   http.StatusOK,
+			expectedETag: `"` + digest + `"`,
+		},
+		{
+			name:         "matching",
+			il:           &ImportLocation{content: body, digest: digest},
+			ifNoneMatch:  `"sha256:other", W/"` + digest + `"`,
+			expectedSC:   http.StatusNotModified,
+			expectedETag: `"` + digest + `"`,
+		},
+		{
+			name:         "not matching",
+			il:           &ImportLocation{content: body, digest: digest},
+			ifNoneMatch:  `"sha256:other"`,
+			expectedSC:   http.StatusOK,
+			expectedETag: `"` + digest + `"`,
+		},
+		{
+			name:         "stored before digests",
+			il:           &ImportLocation{content: body},
+			ifNoneMatch:  `"` + types.ContentDigest(body, "", "") + `"`,
+			expectedSC:   http.StatusNotModified,
+			expectedETag: `"` + types.ContentDigest(body, "", "") + `"`,
+		},
+	} {
+		testWriter := testgin.NewTestResponseWriter()
+		ctx, _ := gin.CreateTestContext(testWriter)
+		ctx.Request = &http.Request{URL: &url.URL{}, Header: http.Header{}}
+		if len(tc.ifNoneMatch) > 0 {
+			ctx.Request.Header.Set("If-None-Match", tc.ifNoneMatch)
+		}
+
+		tc.il.handleCatalogInfoGet(ctx)
+
+		if ctx.Writer.Status() != tc.expectedSC {
+			t.Errorf("test %s expected status %d got %d", tc.name, tc.expectedSC, ctx.Writer.Status())
 		}
+		common.AssertEqual(t, tc.expectedETag, testWriter.Header().Get("ETag"))
 	}
 }
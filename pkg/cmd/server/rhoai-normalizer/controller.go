@@ -9,6 +9,7 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"sort"
 	"strings"
 	"time"
 
@@ -49,6 +50,9 @@ var (
 	controllerLog = ctrl.Log.WithName("controller")
 )
 
+// how many models innerStart upserts to storage per request
+const upsertBatchSize = 50
+
 func NewControllerManager(ctx context.Context, cfg *rest.Config, options ctrl.Options, pprofAddr string) (ctrl.Manager, error) {
 	apiextensionsClient := apiextensionsclient.NewForConfigOrDie(cfg)
 	kserveClient := util.GetKServeClient(cfg)
@@ -358,12 +362,13 @@ func (r *RHOAINormalizerReconcile) Reconcile(ctx context.Context, request reconc
 	lastUpdateTimeSinceEpoch := ""
 	var modelCard *string
 	modelCardKey := ""
+	registry := ""
 
 	klog.V(4).Infof("Reconcile processing/found %s", name.String())
 
 	//TODO fill in lifecycle from kfmr k/v pairs perhaps
 	if len(r.kfmrRegistryRoute) > 0 {
-		importKey, lastUpdateTimeSinceEpoch, modelCardKey, modelCard, err = r.processKFMR(ctx, name, is, bwriter, log)
+		importKey, registry, lastUpdateTimeSinceEpoch, modelCardKey, modelCard, err = r.processKFMR(ctx, name, is, bwriter, log)
 		if err != nil {
 			return reconcile.Result{}, err
 		}
@@ -404,10 +409,10 @@ func (r *RHOAINormalizerReconcile) Reconcile(ctx context.Context, request reconc
 			return reconcile.Result{}, nil
 		}
 
-		importKey, _ = util.BuildImportKeyAndURI(util.SanitizeName(is.Namespace), util.SanitizeName(is.Name), r.format)
+		importKey = util.BuildSourceKey(types2.SourceID(normilzerType, registry), util.SanitizeName(is.Namespace), util.SanitizeName(is.Name))
 	}
 
-	err = r.processBWriter(bwriter, buf, importKey, normilzerType, lastUpdateTimeSinceEpoch, modelCardKey, modelCard)
+	err = r.processBWriter(bwriter, buf, importKey, normilzerType, types2.SourceID(normilzerType, registry), lastUpdateTimeSinceEpoch, modelCardKey, modelCard)
 	if err != nil {
 		return reconcile.Result{}, err
 	}
@@ -415,7 +420,7 @@ func (r *RHOAINormalizerReconcile) Reconcile(ctx context.Context, request reconc
 	return reconcile.Result{}, nil
 }
 
-func (r *RHOAINormalizerReconcile) processBWriter(bwriter *bufio.Writer, buf *bytes.Buffer, importKey, reconcilerType, lastUpdateTimeSinceEpoch, modelCardKey string, modelCard *string) error {
+func (r *RHOAINormalizerReconcile) processBWriter(bwriter *bufio.Writer, buf *bytes.Buffer, importKey, reconcilerType, source, lastUpdateTimeSinceEpoch, modelCardKey string, modelCard *string) error {
 	err := bwriter.Flush()
 	if err != nil {
 		return err
@@ -427,9 +432,9 @@ func (r *RHOAINormalizerReconcile) processBWriter(bwriter *bufio.Writer, buf *by
 	if modelCard != nil {
 		mcardLen = len(*modelCard)
 	}
-	klog.V(4).Infof("processBWriater key %s type %s epoch %s mkey %s len mcard %v len buf %d",
-		importKey, reconcilerType, lastUpdateTimeSinceEpoch, modelCardKey, mcardLen, buf.Len())
-	httpRC, msg, _, err = r.storage.UpsertModel(importKey, reconcilerType, lastUpdateTimeSinceEpoch, modelCardKey, modelCard, buf.Bytes())
+	klog.V(4).Infof("processBWriater key %s type %s source %s epoch %s mkey %s len mcard %v len buf %d",
+		importKey, reconcilerType, source, lastUpdateTimeSinceEpoch, modelCardKey, mcardLen, buf.Len())
+	httpRC, msg, _, err = r.storage.UpsertModel(importKey, reconcilerType, source, lastUpdateTimeSinceEpoch, modelCardKey, modelCard, buf.Bytes())
 	if err != nil {
 		return err
 	}
@@ -439,12 +444,14 @@ func (r *RHOAINormalizerReconcile) processBWriter(bwriter *bufio.Writer, buf *by
 	return nil
 }
 
-func (r *RHOAINormalizerReconcile) processKFMR(ctx context.Context, name types.NamespacedName, is *serverapiv1beta1.InferenceService, bwriter io.Writer, log logr.Logger) (string, string, string, *string, error) {
+// processKFMR looks for the model registry entry behind the KServe inference service, returning its import key along with
+// the key of the registry it came from
+func (r *RHOAINormalizerReconcile) processKFMR(ctx context.Context, name types.NamespacedName, is *serverapiv1beta1.InferenceService, bwriter io.Writer, log logr.Logger) (string, string, string, string, *string, error) {
 	klog.V(4).Infof("processKFMR entry %s", name.String())
 	ready := r.setupKFMR(ctx)
 	if !ready {
 		log.V(4).Info(fmt.Sprintf("reconciling inferenceservice %s, no kmr routes with ingress", name.String()))
-		return "", "", "", nil, nil
+		return "", "", "", "", nil, nil
 	}
 
 	klog.V(4).Infof("processKFMR have kfmr entry %s", name.String())
@@ -509,10 +516,10 @@ func (r *RHOAINormalizerReconcile) processKFMR(ctx context.Context, name types.N
 							r.format)
 
 						if err != nil {
-							return "", "", "", nil, err
+							return "", "", "", "", nil, err
 						}
 
-						importKey, _ := util.BuildImportKeyAndURI(util.SanitizeName(rm.Name), util.SanitizeName(mv.Name), r.format)
+						importKey := util.BuildSourceKey(types2.SourceID(types2.KubeflowNormalizer, k), util.SanitizeName(rm.Name), util.SanitizeName(mv.Name))
 						lastUpdateTimeSinceEpoch := mv.GetLastUpdateTimeSinceEpoch()
 						if rm.GetLastUpdateTimeSinceEpoch() > lastUpdateTimeSinceEpoch {
 							lastUpdateTimeSinceEpoch = rm.GetLastUpdateTimeSinceEpoch()
@@ -531,7 +538,7 @@ func (r *RHOAINormalizerReconcile) processKFMR(ctx context.Context, name types.N
 								break
 							}
 						}
-						return importKey, lastUpdateTimeSinceEpoch, modelCardKey, modelCard, nil
+						return importKey, k, lastUpdateTimeSinceEpoch, modelCardKey, modelCard, nil
 					}
 				}
 			}
@@ -596,10 +603,10 @@ func (r *RHOAINormalizerReconcile) processKFMR(ctx context.Context, name types.N
 							r.format)
 
 						if err != nil {
-							return "", "", "", nil, err
+							return "", "", "", "", nil, err
 						}
 
-						importKey, _ := util.BuildImportKeyAndURI(util.SanitizeName(rm.Name), util.SanitizeName(mv.Name), r.format)
+						importKey := util.BuildSourceKey(types2.SourceID(types2.KubeflowNormalizer, k), util.SanitizeName(rm.Name), util.SanitizeName(mv.Name))
 						lastUpdateTimeSinceEpoch := mv.GetLastUpdateTimeSinceEpoch()
 						if rm.GetLastUpdateTimeSinceEpoch() > lastUpdateTimeSinceEpoch {
 							lastUpdateTimeSinceEpoch = rm.GetLastUpdateTimeSinceEpoch()
@@ -620,7 +627,7 @@ func (r *RHOAINormalizerReconcile) processKFMR(ctx context.Context, name types.N
 						}
 						klog.V(4).Infof("processKFMR kserve infsvc %s returning importKey %s epoc %s mcKey %s mc no nil %v",
 							name.String(), importKey, modelCardKey, modelCardKey, modelCard != nil)
-						return importKey, lastUpdateTimeSinceEpoch, modelCardKey, modelCard, nil
+						return importKey, k, lastUpdateTimeSinceEpoch, modelCardKey, modelCard, nil
 
 					}
 
@@ -630,7 +637,7 @@ func (r *RHOAINormalizerReconcile) processKFMR(ctx context.Context, name types.N
 	}
 
 	// no match to kfmr, but do not return error, as caller can still process this as kserve only
-	return "", "", "", nil, nil
+	return "", "", "", "", nil, nil
 }
 
 // Start - supplement with background polling as controller relist does not duplicate delete events, and we can be more
@@ -653,9 +660,17 @@ func (r *RHOAINormalizerReconcile) innerStart(ctx context.Context, buf *bytes.Bu
 	// we do not punt if there is no kfmr to handle the kserve only scenario
 
 	replacer := strings.NewReplacer(" ", "")
-	keys := []string{}
+	// the keys found in each source, KServe and every model registry, which each get their own current key set so
+	// that one source cannot remove the models of another
+	keys := map[string][]string{}
+	// set when a source could not be listed, so that storage does not remove the keys we could not see
+	incomplete := map[string]bool{}
+	// the models found, which are upserted in batches once every registry has been looked at
+	batch := []bridgerest.BatchUpsertEntry{}
 	klog.V(4).Infof("innerStart len kfmr %d", len(r.kfmr))
-	for _, kfmr := range r.kfmr {
+	for registry, kfmr := range r.kfmr {
+		source := types2.SourceID(types2.KubeflowNormalizer, registry)
+		keys[source] = []string{}
 		var err error
 		var rms []openapi.RegisteredModel
 		var mvs map[string][]openapi.ModelVersion
@@ -664,7 +679,8 @@ func (r *RHOAINormalizerReconcile) innerStart(ctx context.Context, buf *bytes.Bu
 		rms, mvs, mas, err = kubeflowmodelregistry.LoopOverKFMR([]string{}, kfmr)
 		if err != nil {
 			controllerLog.Error(err, "err looping over KFMR")
-			return
+			incomplete[source] = true
+			continue
 		}
 		klog.V(4).Infof("innerStart len rms %d mvs %d mas %d", len(rms), len(mvs), len(mas))
 		for _, rm := range rms {
@@ -681,13 +697,13 @@ func (r *RHOAINormalizerReconcile) innerStart(ctx context.Context, buf *bytes.Bu
 			foundKServe := false
 			for _, mv := range mva {
 
-				importKey, _ := util.BuildImportKeyAndURI(util.SanitizeName(rm.Name), util.SanitizeName(mv.Name), r.format)
+				importKey := util.BuildSourceKey(source, util.SanitizeName(rm.Name), util.SanitizeName(mv.Name))
 				klog.V(4).Infof("innerStart importKey %s from rm %s mv %s format %v", importKey, rm.Name, mv.Name, r.format)
 				lastUpdateTimeSinceEpoch := mv.GetLastUpdateTimeSinceEpoch()
 				if rm.GetLastUpdateTimeSinceEpoch() > lastUpdateTimeSinceEpoch {
 					lastUpdateTimeSinceEpoch = rm.GetLastUpdateTimeSinceEpoch()
 				}
-				keys = append(keys, importKey)
+				keys[source] = append(keys[source], importKey)
 				eb := []byte{}
 				ebuf := bytes.NewBuffer(eb)
 				ewriter := bufio.NewWriter(ebuf)
@@ -715,7 +731,9 @@ func (r *RHOAINormalizerReconcile) innerStart(ctx context.Context, buf *bytes.Bu
 						ewriter,
 						ebuf,
 						importKey,
-						lastUpdateTimeSinceEpoch)
+						source,
+						lastUpdateTimeSinceEpoch,
+						&batch)
 					if err != nil {
 						klog.V(4).Infof("innerStart callBackstage printers len mvISL 0 error %s", err.Error())
 					}
@@ -767,7 +785,9 @@ func (r *RHOAINormalizerReconcile) innerStart(ctx context.Context, buf *bytes.Bu
 						ewriter,
 						ebuf,
 						importKey,
-						lastUpdateTimeSinceEpoch)
+						source,
+						lastUpdateTimeSinceEpoch,
+						&batch)
 					if err != nil {
 						klog.Errorf("innerStart error from call backstage printers %s", err.Error())
 					}
@@ -787,17 +807,24 @@ func (r *RHOAINormalizerReconcile) innerStart(ctx context.Context, buf *bytes.Bu
 						ewriter,
 						ebuf,
 						importKey,
-						lastUpdateTimeSinceEpoch)
+						source,
+						lastUpdateTimeSinceEpoch,
+						&batch)
 				}
 			}
 		}
 	}
 
+	r.upsertBatch(batch)
+
+	kserveSource := types2.SourceID(types2.KServeNormalizer, "")
+	keys[kserveSource] = []string{}
 	isList := &serverapiv1beta1.InferenceServiceList{}
 	listOptions := &client.ListOptions{Namespace: metav1.NamespaceAll}
 	err := r.client.List(ctx, isList, listOptions)
 	if err != nil {
 		controllerLog.Error(err, "error listing kserve inferenceservices")
+		incomplete[kserveSource] = true
 	}
 	for _, is := range isList.Items {
 		skip := false
@@ -816,22 +843,29 @@ func (r *RHOAINormalizerReconcile) innerStart(ctx context.Context, buf *bytes.Bu
 		}
 		if !skip {
 			// we'll let the reconcile loop build the entry; let's just add the key for the current key set call
-			importKey, _ := util.BuildImportKeyAndURI(util.SanitizeName(is.Namespace), util.SanitizeName(is.Name), r.format)
+			importKey := util.BuildSourceKey(kserveSource, util.SanitizeName(is.Namespace), util.SanitizeName(is.Name))
 			klog.V(4).Infof("innerStart importKey %s for kserver infsvc %s:%s format %v",
 				importKey, is.Namespace, is.Name, r.format)
-			keys = append(keys, importKey)
+			keys[kserveSource] = append(keys[kserveSource], importKey)
 		}
 	}
 
-	rc := 0
-	msg := ""
-	rc, msg, err = r.storage.PostCurrentKeySet(keys)
-	if err != nil {
-		controllerLog.Error(err, "error updating current key set")
-		return
+	sources := make([]string, 0, len(keys))
+	for source := range keys {
+		sources = append(sources, source)
 	}
-	if rc != http.StatusCreated && rc != http.StatusOK {
-		controllerLog.Error(fmt.Errorf("post to storage returned rc %d: %s", rc, msg), "bad rc updating current key set")
+	sort.Strings(sources)
+	for _, source := range sources {
+		rc := 0
+		msg := ""
+		rc, msg, err = r.storage.PostCurrentKeySet(keys[source], source, incomplete[source])
+		if err != nil {
+			controllerLog.Error(err, fmt.Sprintf("error updating current key set for source %s", source))
+			continue
+		}
+		if rc != http.StatusCreated && rc != http.StatusOK {
+			controllerLog.Error(fmt.Errorf("post to storage returned rc %d: %s", rc, msg), fmt.Sprintf("bad rc updating current key set for source %s", source))
+		}
 	}
 
 }
@@ -847,7 +881,9 @@ func (r *RHOAINormalizerReconcile) innerStartCallBackstagePrinters(ctx context.C
 	ewriter *bufio.Writer,
 	ebuf *bytes.Buffer,
 	importKey string,
-	lastUpdateTimeSinceEpoch string) error {
+	source string,
+	lastUpdateTimeSinceEpoch string,
+	batch *[]bridgerest.BatchUpsertEntry) error {
 	// only include this model version vs. whole array to line up with our importKey
 	err := kubeflowmodelregistry.CallBackstagePrinters(ctx, r.defaultOwner, r.defaultLifecycle, rm, mv, maa, isl, is, kfmr, r.client, ewriter, r.format)
 	if err != nil {
@@ -869,10 +905,51 @@ func (r *RHOAINormalizerReconcile) innerStartCallBackstagePrinters(ctx context.C
 
 		}
 	}
-	err = r.processBWriter(ewriter, ebuf, importKey, types2.KubeflowNormalizer, lastUpdateTimeSinceEpoch, modelCardKey, modelCard)
+	err = ewriter.Flush()
 	if err != nil {
 		controllerLog.Error(err, "error processing KFMR writer")
 		return err
 	}
+	// the buffer may be reused for the next model, so the entry gets its own copy
+	*batch = append(*batch, storage.NewBatchUpsertEntry(importKey, types2.KubeflowNormalizer, source, lastUpdateTimeSinceEpoch, modelCardKey, modelCard, bytes.Clone(ebuf.Bytes())))
 	return nil
 }
+
+// upsertBatch stores the models innerStart found, upsertBatchSize at a time, falling back to one request per model
+// should storage-rest predate batches
+func (r *RHOAINormalizerReconcile) upsertBatch(entries []bridgerest.BatchUpsertEntry) {
+	for start := 0; start < len(entries); start += upsertBatchSize {
+		chunk := entries[start:min(start+upsertBatchSize, len(entries))]
+		klog.V(4).Infof("upsertBatch posting %d of %d models", len(chunk), len(entries))
+		rc, msg, resp, err := r.storage.UpsertModels(chunk)
+		switch {
+		case err != nil:
+			controllerLog.Error(err, "error upserting batch to storage")
+			continue
+		case rc == http.StatusNotFound || rc == http.StatusMethodNotAllowed:
+			for _, e := range chunk {
+				var modelCard *string
+				if len(e.ModelCardKey) > 0 {
+					modelCard = &e.ModelCard
+				}
+				rc, msg, _, err = r.storage.UpsertModel(e.Key, e.Type, e.Source, e.LastUpdateTimeSinceEpoch, e.ModelCardKey, modelCard, e.Body)
+				if err != nil {
+					controllerLog.Error(err, fmt.Sprintf("error upserting key %s to storage", e.Key))
+					continue
+				}
+				if rc != http.StatusCreated && rc != http.StatusOK {
+					controllerLog.Error(fmt.Errorf("post to storage returned rc %d: %s", rc, msg), fmt.Sprintf("bad rc upserting key %s", e.Key))
+				}
+			}
+			continue
+		case rc != http.StatusOK:
+			controllerLog.Error(fmt.Errorf("post to storage returned rc %d: %s", rc, msg), "bad rc upserting batch")
+			continue
+		}
+		for _, result := range resp.Results {
+			if result.Status != http.StatusCreated && result.Status != http.StatusOK {
+				controllerLog.Error(fmt.Errorf("storage returned rc %d: %s", result.Status, result.Error), fmt.Sprintf("bad rc upserting key %s", result.Key))
+			}
+		}
+	}
+}
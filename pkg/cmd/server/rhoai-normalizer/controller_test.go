@@ -16,6 +16,7 @@ import (
 	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/config"
 	bridgerest "github.com/redhat-ai-dev/model-catalog-bridge/pkg/rest"
 	types2 "github.com/redhat-ai-dev/model-catalog-bridge/pkg/types"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/util"
 	"github.com/redhat-ai-dev/model-catalog-bridge/test/stub/common"
 	"github.com/redhat-ai-dev/model-catalog-bridge/test/stub/kfmr"
 	"github.com/redhat-ai-dev/model-catalog-bridge/test/stub/location"
@@ -478,12 +479,22 @@ func TestStart_JsonArray_MultiVersion(t *testing.T) {
 		bwriter := bufio.NewWriter(buf)
 		r.innerStart(ctx, buf, bwriter)
 
-		data1, ok1 := callback.Load("key=mnist_v1&type=kubeflow")
+		// the registry's models, and its current key set, are marked with the registry's source, which their keys
+		// start with
+		source := types2.SourceID(types2.KubeflowNormalizer, fmt.Sprintf("%s-%d", tc.name, 0))
+		v1Key := util.BuildSourceKey(source, "mnist", "v1")
+		expectedKeys := []string{}
+		for _, k := range strings.Split(tc.expectedKey, ",") {
+			seg1, seg2, _ := util.KeySegments(k)
+			expectedKeys = append(expectedKeys, util.BuildSourceKey(source, seg1, seg2))
+		}
+
+		data1, ok1 := callback.Load("key=" + v1Key + "&type=kubeflow")
 		common.AssertEqual(t, true, ok1)
 		common.AssertEqual(t, false, strings.Contains(fmt.Sprintf("%v", data1), "mnist-v3"))
 		common.AssertEqual(t, true, strings.Contains(fmt.Sprintf("%v", data1), "mnist-v1"))
 		common.AssertEqual(t, true, strings.Contains(fmt.Sprintf("%v", data1), "modelServer"))
-		data2, ok2 := callback.Load("key=mnist_v3&type=kubeflow")
+		data2, ok2 := callback.Load("key=" + util.BuildSourceKey(source, "mnist", "v3") + "&type=kubeflow")
 		common.AssertEqual(t, true, ok2)
 		common.AssertEqual(t, false, strings.Contains(fmt.Sprintf("%v", data2), "mnist-v1"))
 		common.AssertEqual(t, false, strings.Contains(fmt.Sprintf("%v", data2), "modelServer"))
@@ -491,6 +502,18 @@ func TestStart_JsonArray_MultiVersion(t *testing.T) {
 		_, ok := callback.Load("hasModelCard")
 		common.AssertEqual(t, true, ok)
 
+		upsertSource, _ := callback.Load("source/" + v1Key)
+		common.AssertEqual(t, source, upsertSource)
+		keySet, _ := callback.Load("key/" + source)
+		common.AssertEqual(t, strings.Join(expectedKeys, ","), keySet)
+		kserveKeySet, ok := callback.Load("key/" + types2.KServeNormalizer)
+		common.AssertEqual(t, true, ok)
+		common.AssertEqual(t, "", kserveKeySet)
+		// the registry's models went to storage in one request
+		batchKeys, _ := callback.Load(util.UpsertBatchURI)
+		common.AssertEqual(t, true, strings.Contains(fmt.Sprintf("%v", batchKeys), "mnist_v1"))
+		common.AssertEqual(t, true, strings.Contains(fmt.Sprintf("%v", batchKeys), "mnist_v3"))
+
 		// clear out callback for next test
 		callback.Range(func(key, value any) bool {
 			callback.Delete(key)
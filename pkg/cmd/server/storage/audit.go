@@ -0,0 +1,303 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/cmd/server/auth"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/cmd/server/validation"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/types"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/util"
+	"k8s.io/klog/v2"
+)
+
+// the mutations recorded in the audit log
+const (
+	AuditActionUpsert    = "upsert"
+	AuditActionTombstone = "tombstone"
+	AuditActionRemove    = "remove"
+	AuditActionImport    = "import"
+	AuditActionDelete    = "delete"
+)
+
+// how the mutations recorded in the audit log turned out
+const (
+	AuditOutcomeSucceeded = "succeeded"
+	AuditOutcomeFailed    = "failed"
+	// AuditOutcomeRejected is for content turned down by validation, which is not stored
+	AuditOutcomeRejected = "rejected"
+	// AuditOutcomeSkipped is for a removal given up on as the key was updated in the meantime
+	AuditOutcomeSkipped = "skipped"
+)
+
+const (
+	defaultAuditMaxEntries = 100
+	defaultAuditRetention  = 30 * 24 * time.Hour
+	// how often we look for audit log entries past the retention
+	auditPurgeInterval = time.Hour
+	// systemCaller is the caller for what storage-rest does on its own, such as purging tombstones or retrying the
+	// outbox
+	systemCaller = "storage-rest"
+)
+
+// AuditEntry records one mutation of a model, in storage or in Backstage
+type AuditEntry struct {
+	Time time.Time `json:"time"`
+	// RequestID is the ID addRequestId gave the request which made the mutation, and is empty for what storage-rest
+	// does on its own
+	RequestID string `json:"requestId,omitempty"`
+	// Caller is the user auth found for the request, or else its address, or systemCaller
+	Caller     string `json:"caller"`
+	Action     string `json:"action"`
+	Key        string `json:"key"`
+	Reason     string `json:"reason,omitempty"`
+	Outcome    string `json:"outcome"`
+	Error      string `json:"error,omitempty"`
+	Digest     string `json:"digest,omitempty"`
+	LocationId string `json:"locationId,omitempty"`
+}
+
+type AuditResponse struct {
+	// Key is empty when the entries are for every model
+	Key     string       `json:"key,omitempty"`
+	Entries []AuditEntry `json:"entries"`
+}
+
+// auditActor is who a mutation is made for
+type auditActor struct {
+	requestID string
+	caller    string
+}
+
+var systemActor = auditActor{caller: systemCaller}
+
+func requestActor(c *gin.Context) auditActor {
+	return auditActor{requestID: c.GetString("requestId"), caller: callerOf(c)}
+}
+
+// callerOf names the caller of a request, by the user auth found for them, or else by their address
+func callerOf(c *gin.Context) string {
+	if user := c.GetString(auth.UserContextKey); len(user) > 0 {
+		return user
+	}
+	return c.ClientIP()
+}
+
+// auditRetention bounds the audit log; its zero value turns the audit log off
+type auditRetention struct {
+	// maxEntries is how many entries are kept for each model, and 0 means none are
+	maxEntries int
+	// maxAge of 0 means entries are kept until there are more than maxEntries
+	maxAge time.Duration
+}
+
+// auditRetentionFromEnv keeps the audit log on, with bounded retention, unless AuditMaxEntriesEnvVar is set to 0
+func auditRetentionFromEnv() auditRetention {
+	r := strings.NewReplacer("\r", "", "\n", "")
+	a := auditRetention{maxEntries: defaultAuditMaxEntries, maxAge: defaultAuditRetention}
+	if v := r.Replace(os.Getenv(types.AuditMaxEntriesEnvVar)); len(v) > 0 {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			klog.Errorf("ignoring %s setting %q as it is not a positive number", types.AuditMaxEntriesEnvVar, v)
+		} else {
+			a.maxEntries = n
+		}
+	}
+	if v := r.Replace(os.Getenv(types.AuditRetentionEnvVar)); len(v) > 0 {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			klog.Errorf("ignoring %s setting %q as it is not a duration", types.AuditRetentionEnvVar, v)
+		} else {
+			a.maxAge = d
+		}
+	}
+	return a
+}
+
+// isAuditKey says whether the storage key holds the audit log of a model rather than a model
+func isAuditKey(key string) bool {
+	return strings.HasPrefix(key, util.AuditKeyPrefix)
+}
+
+// trim drops the entries past the retention, the oldest first
+func (a auditRetention) trim(entries []AuditEntry, now time.Time) []AuditEntry {
+	if a.maxAge > 0 {
+		cutoff := now.Add(-a.maxAge)
+		i := sort.Search(len(entries), func(i int) bool { return entries[i].Time.After(cutoff) })
+		entries = entries[i:]
+	}
+	if len(entries) > a.maxEntries {
+		entries = entries[len(entries)-a.maxEntries:]
+	}
+	return entries
+}
+
+// audit appends an entry to the audit log of its key, with the outcome set from err unless it is already set.  The
+// audit log is only ever appended to, and trimmed to the retention, so a failure to record an entry is logged but does
+// not fail the mutation.  Entries without a key, such as for a Backstage location no model refers to, are only logged.
+func (s *StorageRESTServer) audit(a auditActor, e AuditEntry, err error) {
+	e.Time = time.Now().UTC()
+	e.RequestID = a.requestID
+	e.Caller = a.caller
+	if len(e.Outcome) == 0 {
+		e.Outcome = AuditOutcomeSucceeded
+		if err != nil {
+			e.Outcome = AuditOutcomeFailed
+		}
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	klog.V(4).Infof("audit: %s of key %s by %s for request %s %s: %s", e.Action, e.Key, e.Caller, e.RequestID, e.Outcome, e.Reason)
+	if s.auditRetention.maxEntries == 0 || len(e.Key) == 0 {
+		return
+	}
+	_, err = s.update(util.AuditKeyPrefix+e.Key, func(sb *types.StorageBody) (bool, error) {
+		entries := []AuditEntry{}
+		if len(sb.Body) > 0 {
+			if err := json.Unmarshal(sb.Body, &entries); err != nil {
+				return false, err
+			}
+		}
+		buf, err := json.Marshal(s.auditRetention.trim(append(entries, e), e.Time))
+		if err != nil {
+			return false, err
+		}
+		sb.Body = buf
+		return true, nil
+	})
+	if err != nil {
+		klog.Errorf("error recording %s of key %s in the audit log: %s", e.Action, e.Key, err.Error())
+	}
+}
+
+// auditUpsert records the upsert in the audit log, unless it left storage as it was
+func (s *StorageRESTServer) auditUpsert(req *upsertRequest, err error) {
+	if err == nil && (req.stale || req.unchanged) {
+		return
+	}
+	e := AuditEntry{Action: AuditActionUpsert, Key: req.key, Digest: req.postBody.Digest, LocationId: req.stored.LocationId}
+	if req.revived {
+		e.Reason = "back after being removed"
+	}
+	s.audit(req.actor, e, err)
+}
+
+// auditRejected records the upsert in the audit log when err is for content validation turned down
+func (s *StorageRESTServer) auditRejected(a auditActor, err error) {
+	var verr *validation.Error
+	if errors.As(err, &verr) {
+		s.audit(a, AuditEntry{Action: AuditActionUpsert, Key: verr.Key, Outcome: AuditOutcomeRejected}, err)
+	}
+}
+
+// fetchAudit returns the audit log stored under the storage key, along with its revision, which is empty if there is
+// none
+func (s *StorageRESTServer) fetchAudit(storageKey string) ([]AuditEntry, string, error) {
+	entries := []AuditEntry{}
+	sb, rev, err := s.st.Fetch(storageKey)
+	if err != nil || len(rev) == 0 || len(sb.Body) == 0 {
+		return entries, rev, err
+	}
+	err = json.Unmarshal(sb.Body, &entries)
+	return entries, rev, err
+}
+
+// purgeAudit drops the audit log entries past the retention age, and the audit logs left without any, which is how the
+// audit log of a model removed long ago goes away
+func (s *StorageRESTServer) purgeAudit(ctx context.Context) {
+	if s.auditRetention.maxAge == 0 {
+		return
+	}
+	keys, err := s.st.List()
+	if err != nil {
+		klog.Errorf("error listing keys to purge the audit log: %s", err.Error())
+		return
+	}
+	now := time.Now().UTC()
+	for _, k := range keys {
+		if ctx.Err() != nil {
+			return
+		}
+		if !isAuditKey(k) {
+			continue
+		}
+		entries, rev, err := s.fetchAudit(k)
+		if err != nil {
+			klog.Errorf("error reading audit log %s: %s", k, err.Error())
+			continue
+		}
+		if len(rev) == 0 {
+			continue
+		}
+		kept := s.auditRetention.trim(entries, now)
+		switch {
+		case len(kept) == len(entries):
+			continue
+		case len(kept) == 0:
+			err = s.st.Remove(k, rev)
+		default:
+			var buf []byte
+			buf, err = json.Marshal(kept)
+			if err == nil {
+				_, err = s.st.Upsert(k, types.StorageBody{Body: buf}, rev)
+			}
+		}
+		// an audit log appended to in the meantime is left for the next pass
+		if err != nil && !types.IsConflict(err) {
+			klog.Errorf("error purging audit log %s: %s", k, err.Error())
+		}
+	}
+}
+
+// handleAuditGet returns the audit log of the model with the 'key' parameter, oldest entry first, or without one the
+// audit logs of every model, including the ones since removed, merged by time
+func (s *StorageRESTServer) handleAuditGet(c *gin.Context) {
+	if s.auditRetention.maxEntries == 0 {
+		c.Status(http.StatusNotImplemented)
+		c.Error(fmt.Errorf("the audit log is turned off"))
+		return
+	}
+	resp := &AuditResponse{Key: c.Query(util.KeyQueryParam), Entries: []AuditEntry{}}
+	var err error
+	if len(resp.Key) > 0 {
+		resp.Entries, _, err = s.fetchAudit(util.AuditKeyPrefix + resp.Key)
+	} else {
+		var keys []string
+		keys, err = s.st.List()
+		for _, k := range keys {
+			if err != nil {
+				break
+			}
+			if !isAuditKey(k) {
+				continue
+			}
+			var entries []AuditEntry
+			entries, _, err = s.fetchAudit(k)
+			resp.Entries = append(resp.Entries, entries...)
+		}
+		sort.SliceStable(resp.Entries, func(i, j int) bool { return resp.Entries[i].Time.Before(resp.Entries[j].Time) })
+	}
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		klog.Errorf("error reading the audit log: %s", err.Error())
+		c.Error(fmt.Errorf("error reading the audit log: %s", err.Error()))
+		return
+	}
+	content, err := json.Marshal(resp)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		c.Error(err)
+		return
+	}
+	c.Data(http.StatusOK, "Content-Type: application/json", content)
+}
@@ -0,0 +1,222 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/types"
+)
+
+const (
+	// FormatVersion is bumped whenever the archive layout changes in a way older versions of Import cannot read
+	FormatVersion = 1
+
+	manifestFileName = "manifest.json"
+	entriesDir       = "entries"
+)
+
+// Manifest is the last file in the archive, and lists every entry file along with the key it holds and its checksum
+type Manifest struct {
+	Version    int                     `json:"version"`
+	SourceType types.BridgeStorageType `json:"sourceType"`
+	CreatedAt  time.Time               `json:"createdAt"`
+	Entries    []ManifestEntry         `json:"entries"`
+}
+
+type ManifestEntry struct {
+	Key    string `json:"key"`
+	File   string `json:"file"`
+	SHA256 string `json:"sha256"`
+	Size   int    `json:"size"`
+}
+
+type ImportOptions struct {
+	// Overwrite replaces keys which are already in the target storage with different content; otherwise the import
+	// fails before anything is written
+	Overwrite bool
+}
+
+type ImportResult struct {
+	Created   []string
+	Updated   []string
+	Unchanged []string
+}
+
+// Export writes every key in st, with the whole StorageBody so the Backstage location ids, reconciler types and
+// epochs carry over, to w as a gzipped tar archive.  Entry files are named by their position rather than their key,
+// so no key has to be a valid file name.
+func Export(st types.BridgeStorage, sourceType types.BridgeStorageType, w io.Writer) (*Manifest, error) {
+	keys, err := st.List()
+	if err != nil {
+		return nil, fmt.Errorf("error listing keys: %s", err.Error())
+	}
+	sort.Strings(keys)
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	manifest := &Manifest{Version: FormatVersion, SourceType: sourceType, CreatedAt: time.Now().UTC()}
+	for i, key := range keys {
+		sb, rev, err := st.Fetch(key)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching key %s: %s", key, err.Error())
+		}
+		if len(rev) == 0 {
+			// removed since we listed the keys
+			continue
+		}
+		buf, err := json.Marshal(sb)
+		if err != nil {
+			return nil, err
+		}
+		entry := ManifestEntry{Key: key, File: fmt.Sprintf("%s/%06d.json", entriesDir, i+1), SHA256: checksum(buf), Size: len(buf)}
+		if err = writeFile(tw, entry.File, buf, manifest.CreatedAt); err != nil {
+			return nil, err
+		}
+		manifest.Entries = append(manifest.Entries, entry)
+	}
+	buf, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err = writeFile(tw, manifestFileName, buf, manifest.CreatedAt); err != nil {
+		return nil, err
+	}
+	if err = tw.Close(); err != nil {
+		return nil, err
+	}
+	return manifest, gz.Close()
+}
+
+// Read reads an archive written by Export and verifies every checksum, returning the entries by key
+func Read(r io.Reader) (*Manifest, map[string]types.StorageBody, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("not a storage backup archive: %s", err.Error())
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	files := map[string][]byte{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("error reading storage backup archive: %s", err.Error())
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		buf, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, nil, err
+		}
+		files[hdr.Name] = buf
+	}
+
+	buf, ok := files[manifestFileName]
+	if !ok {
+		return nil, nil, fmt.Errorf("the storage backup archive has no %s", manifestFileName)
+	}
+	manifest := &Manifest{}
+	if err = json.Unmarshal(buf, manifest); err != nil {
+		return nil, nil, fmt.Errorf("error reading %s: %s", manifestFileName, err.Error())
+	}
+	if manifest.Version > FormatVersion {
+		return nil, nil, fmt.Errorf("the storage backup archive is version %d but only up to version %d is supported", manifest.Version, FormatVersion)
+	}
+
+	entries := map[string]types.StorageBody{}
+	for _, entry := range manifest.Entries {
+		if _, ok = entries[entry.Key]; ok {
+			return nil, nil, fmt.Errorf("key %s is in the storage backup archive more than once", entry.Key)
+		}
+		buf, ok = files[entry.File]
+		if !ok {
+			return nil, nil, fmt.Errorf("file %s for key %s is missing from the storage backup archive", entry.File, entry.Key)
+		}
+		if sum := checksum(buf); sum != entry.SHA256 || len(buf) != entry.Size {
+			return nil, nil, fmt.Errorf("file %s for key %s has checksum %s and size %d but %s and %d were expected", entry.File, entry.Key, sum, len(buf), entry.SHA256, entry.Size)
+		}
+		sb := types.StorageBody{}
+		if err = json.Unmarshal(buf, &sb); err != nil {
+			return nil, nil, fmt.Errorf("error reading file %s for key %s: %s", entry.File, entry.Key, err.Error())
+		}
+		entries[entry.Key] = sb
+	}
+	return manifest, entries, nil
+}
+
+// Import verifies the whole archive, and then checks every key against what is already in st, before writing
+// anything, so a bad archive or an unexpected difference leaves st as it was
+func Import(r io.Reader, st types.BridgeStorage, opts ImportOptions) (*ImportResult, error) {
+	_, entries, err := Read(r)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := &ImportResult{}
+	revisions := map[string]string{}
+	conflicts := []string{}
+	for _, key := range keys {
+		current, rev, err := st.Fetch(key)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching key %s: %s", key, err.Error())
+		}
+		revisions[key] = rev
+		switch {
+		case len(rev) == 0:
+			result.Created = append(result.Created, key)
+		case same(current, entries[key]):
+			result.Unchanged = append(result.Unchanged, key)
+		default:
+			result.Updated = append(result.Updated, key)
+			conflicts = append(conflicts, key)
+		}
+	}
+	if len(conflicts) > 0 && !opts.Overwrite {
+		return nil, fmt.Errorf("these keys are already in storage with different content, and overwrite was not requested: %s", strings.Join(conflicts, ", "))
+	}
+
+	for _, key := range append(append([]string{}, result.Created...), result.Updated...) {
+		// the revision we checked guards against storage-rest changing the key while we import
+		if _, err = st.Upsert(key, entries[key], revisions[key]); err != nil {
+			return result, fmt.Errorf("error importing key %s: %s", key, err.Error())
+		}
+	}
+	return result, nil
+}
+
+func same(a, b types.StorageBody) bool {
+	abuf, _ := json.Marshal(a)
+	bbuf, _ := json.Marshal(b)
+	return bytes.Equal(abuf, bbuf)
+}
+
+func checksum(buf []byte) string {
+	sum := sha256.Sum256(buf)
+	return hex.EncodeToString(sum[:])
+}
+
+func writeFile(tw *tar.Writer, name string, buf []byte, modTime time.Time) error {
+	err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(buf)), ModTime: modTime, Typeflag: tar.TypeReg})
+	if err != nil {
+		return err
+	}
+	_, err = tw.Write(buf)
+	return err
+}
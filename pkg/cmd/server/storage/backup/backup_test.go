@@ -0,0 +1,143 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/cmd/server/storage/configmap"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/cmd/server/storage/sqlite"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/types"
+	"github.com/redhat-ai-dev/model-catalog-bridge/test/stub/common"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+var entries = map[string]types.StorageBody{
+	"mnist_v1": {
+		Body:                     []byte("mnist v1"),
+		LocationId:               "loc-id-1",
+		LocationTarget:           "http://foo.com/mnist/v1/catalog-info.yaml",
+		LocationIDValid:          true,
+		ReconcilerType:           types.KubeflowNormalizer,
+		LastUpdateTimeSinceEpoch: "1000",
+		ModelCardKey:             "mnist_v1_modelcard",
+		LastPushResult:           types.PushResultImported,
+	},
+	"Granite_V2": {
+		Body:                     []byte("granite v2"),
+		ReconcilerType:           types.KServeNormalizer,
+		LastUpdateTimeSinceEpoch: "2000",
+	},
+}
+
+func setupArchive(t *testing.T) []byte {
+	t.Helper()
+	src := configmap.NewConfigMapBridgeStorageForTest(metav1.NamespaceDefault, fake.NewClientset().CoreV1())
+	for key, sb := range entries {
+		_, err := src.Upsert(key, sb, types.AnyRevision)
+		common.AssertError(t, err)
+	}
+	buf := &bytes.Buffer{}
+	manifest, err := Export(src, types.ConfigMapBridgeStorage, buf)
+	common.AssertError(t, err)
+	common.AssertEqual(t, 2, len(manifest.Entries))
+	common.AssertEqual(t, "Granite_V2", manifest.Entries[0].Key)
+	return buf.Bytes()
+}
+
+func setupTarget(t *testing.T) *sqlite.SQLiteBridgeStorage {
+	t.Helper()
+	dest := sqlite.NewSQLiteBridgeStorageForTest(filepath.Join(t.TempDir(), "bridge.db"))
+	err := dest.Initialize(nil)
+	common.AssertError(t, err)
+	t.Cleanup(func() { dest.Close() })
+	return dest
+}
+
+func TestExportImport(t *testing.T) {
+	archive := setupArchive(t)
+	dest := setupTarget(t)
+
+	result, err := Import(bytes.NewReader(archive), dest, ImportOptions{})
+	common.AssertError(t, err)
+	common.AssertEqual(t, []string{"Granite_V2", "mnist_v1"}, result.Created)
+	for key, expected := range entries {
+		sb, _, err := dest.Fetch(key)
+		common.AssertError(t, err)
+		common.AssertEqual(t, expected, sb)
+	}
+
+	// importing again changes nothing
+	result, err = Import(bytes.NewReader(archive), dest, ImportOptions{})
+	common.AssertError(t, err)
+	common.AssertEqual(t, 0, len(result.Created))
+	common.AssertEqual(t, 2, len(result.Unchanged))
+
+	// a key that differs is only replaced when asked to
+	changed := entries["mnist_v1"]
+	changed.LocationId = "loc-id-2"
+	_, err = dest.Upsert("mnist_v1", changed, types.AnyRevision)
+	common.AssertError(t, err)
+	_, err = Import(bytes.NewReader(archive), dest, ImportOptions{})
+	if err == nil || !strings.Contains(err.Error(), "mnist_v1") {
+		t.Errorf("expected an error naming the differing key, got %v", err)
+	}
+	sb, _, err := dest.Fetch("mnist_v1")
+	common.AssertError(t, err)
+	common.AssertEqual(t, "loc-id-2", sb.LocationId)
+	result, err = Import(bytes.NewReader(archive), dest, ImportOptions{Overwrite: true})
+	common.AssertError(t, err)
+	common.AssertEqual(t, []string{"mnist_v1"}, result.Updated)
+	sb, _, err = dest.Fetch("mnist_v1")
+	common.AssertError(t, err)
+	common.AssertEqual(t, entries["mnist_v1"], sb)
+}
+
+func TestImportVerifiesChecksums(t *testing.T) {
+	archive := setupArchive(t)
+
+	// rewrite the archive with one entry file altered
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	common.AssertError(t, err)
+	tr := tar.NewReader(gz)
+	tampered := &bytes.Buffer{}
+	gzw := gzip.NewWriter(tampered)
+	tw := tar.NewWriter(gzw)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		common.AssertError(t, err)
+		buf, err := io.ReadAll(tr)
+		common.AssertError(t, err)
+		if strings.HasPrefix(hdr.Name, entriesDir) {
+			buf = bytes.Replace(buf, []byte(`"reconcilerType":"`), []byte(`"reconcilerType":"x`), 1)
+			hdr.Size = int64(len(buf))
+		}
+		common.AssertError(t, tw.WriteHeader(hdr))
+		_, err = tw.Write(buf)
+		common.AssertError(t, err)
+	}
+	common.AssertError(t, tw.Close())
+	common.AssertError(t, gzw.Close())
+
+	dest := setupTarget(t)
+	_, err = Import(bytes.NewReader(tampered.Bytes()), dest, ImportOptions{})
+	if err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Errorf("expected a checksum error, got %v", err)
+	}
+	keys, err := dest.List()
+	common.AssertError(t, err)
+	common.AssertEqual(t, 0, len(keys))
+
+	_, err = Import(strings.NewReader("not an archive"), dest, ImportOptions{})
+	if err == nil {
+		t.Error("expected an error for something that is not an archive")
+	}
+}
@@ -0,0 +1,172 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/cmd/server/validation"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/rest"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/types"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/util"
+	"k8s.io/klog/v2"
+)
+
+// handleCatalogUpsertBatchPost upserts many models in one request, doing for each what handleCatalogUpsertPost does,
+// but storing them all with one write when the storage backend can update several keys atomically, and handing them
+// to the location service in one request.  The response has the status code handleCatalogUpsertPost would have
+// returned for each model, in the order of the batch.
+func (s *StorageRESTServer) handleCatalogUpsertBatchPost(c *gin.Context) {
+	var batch rest.BatchUpsertBody
+	err := c.BindJSON(&batch)
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		msg := fmt.Sprintf("error reading POST body: %s", err.Error())
+		klog.Error(msg)
+		c.Error(fmt.Errorf("error reading POST body: %s", err.Error()))
+		return
+	}
+	resp := &rest.BatchUpsertResponse{Results: make([]rest.BatchUpsertResult, len(batch.Entries))}
+	reqs := make([]*upsertRequest, len(batch.Entries))
+	valid := []*upsertRequest{}
+	for i, e := range batch.Entries {
+		resp.Results[i].Key = e.Key
+		reqs[i], err = s.newUpsertRequest(e.Key, e.Type, e.Source, e.PostBody)
+		s.auditRejected(requestActor(c), err)
+		var verr *validation.Error
+		if errors.As(err, &verr) {
+			resp.Results[i].Status = http.StatusUnprocessableEntity
+			resp.Results[i].Error = verr.Error()
+			resp.Results[i].Errors = verr.Errors
+			continue
+		}
+		if err != nil {
+			resp.Results[i].Status = http.StatusBadRequest
+			resp.Results[i].Error = err.Error()
+			continue
+		}
+		reqs[i].actor = requestActor(c)
+		valid = append(valid, reqs[i])
+	}
+	klog.Infof("Upserting batch of %d keys", len(valid))
+
+	storeErrs := s.storeUpserts(valid)
+	// located are the positions in the batch of the models to hand to the location service
+	located := []int{}
+	entries := []rest.BatchUpsertEntry{}
+	for i, req := range reqs {
+		if req == nil {
+			continue
+		}
+		err = storeErrs[req]
+		s.auditUpsert(req, err)
+		if err != nil {
+			resp.Results[i].Status = http.StatusInternalServerError
+			resp.Results[i].Error = fmt.Sprintf("error upserting to storage key %s POST body: %s", req.key, err.Error())
+			klog.Error(resp.Results[i].Error)
+			continue
+		}
+		if req.stale {
+			klog.Infof("ignoring upsert for %s because incoming version %s is older than %s", req.key, req.postBody.LastUpdateTimeSinceEpoch, req.stored.LastUpdateTimeSinceEpoch)
+			resp.Results[i].Status = http.StatusOK
+			continue
+		}
+		if s.settled(req) {
+			resp.Results[i].Status = http.StatusOK
+			resp.Results[i].Result = rest.UpsertUnchanged
+			continue
+		}
+		located = append(located, i)
+		entries = append(entries, rest.BatchUpsertEntry{Key: req.key, Source: req.effectiveSource(), PostBody: req.postBody})
+	}
+	if len(entries) == 0 {
+		writeBatchResponse(c, resp)
+		return
+	}
+
+	// push the updates to the bridge locations REST endpoint in one go
+	locationResp, err := s.locations.UpsertModels(entries)
+	for j, i := range located {
+		req := reqs[i]
+		if err != nil {
+			resp.Results[i].Status = http.StatusInternalServerError
+			resp.Results[i].Error = fmt.Sprintf("error upserting to bridge uri %s POST body: error %s", req.uri, err.Error())
+			klog.Error(resp.Results[i].Error)
+			continue
+		}
+		if rc := locationResp.Results[j].Status; rc != http.StatusCreated && rc != http.StatusOK {
+			// as with a single upsert, the location service turning the model down does not stop the Backstage import
+			resp.Results[i].Error = fmt.Sprintf("error upserting to bridge uri %s POST body: rc %d msg %s", req.uri, rc, locationResp.Results[j].Error)
+			klog.Error(resp.Results[i].Error)
+		}
+		var pushErr error
+		resp.Results[i].Status, pushErr = s.pushLocation(req)
+		if pushErr != nil {
+			resp.Results[i].Error = pushErr.Error()
+		}
+	}
+	writeBatchResponse(c, resp)
+}
+
+func writeBatchResponse(c *gin.Context, resp *rest.BatchUpsertResponse) {
+	content, err := json.Marshal(resp)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		c.Error(err)
+		return
+	}
+	c.Data(http.StatusOK, "Content-Type: application/json", content)
+}
+
+// storeUpserts stores the models, in one transaction when the storage backend supports them, returning the error for
+// each model which could not be stored.  Should the transaction fail, each model is stored on its own, so that one bad
+// model does not keep the rest of the batch out of storage.
+func (s *StorageRESTServer) storeUpserts(reqs []*upsertRequest) map[*upsertRequest]error {
+	errs := map[*upsertRequest]error{}
+	if len(reqs) == 0 {
+		return errs
+	}
+	for _, req := range reqs {
+		if err := s.adopt(req); err != nil {
+			klog.Errorf("error moving key %s to key %s: %s", util.LegacyKey(req.key), req.key, err.Error())
+		}
+	}
+	if transactor, ok := s.st.(types.BridgeStorageTransactor); ok {
+		stored := map[*upsertRequest]types.StorageBody{}
+		err := transactor.Transact(func(tx types.BridgeStorageTx) error {
+			for _, req := range reqs {
+				sb, rev, err := tx.Fetch(req.key)
+				if err != nil {
+					return err
+				}
+				if req.apply(&sb) {
+					_, err = tx.Upsert(req.key, sb, rev)
+					if err != nil {
+						return err
+					}
+				}
+				stored[req] = sb
+			}
+			return nil
+		})
+		if err == nil {
+			for req, sb := range stored {
+				req.stored = sb
+			}
+			return errs
+		}
+		klog.Warningf("storing the batch of %d keys in one transaction failed, so storing them one at a time: %s", len(reqs), err.Error())
+	}
+	for _, req := range reqs {
+		var err error
+		req.stored, err = s.update(req.key, func(sb *types.StorageBody) (bool, error) {
+			return req.apply(sb), nil
+		})
+		if err != nil {
+			errs[req] = err
+		}
+	}
+	return errs
+}
@@ -1,143 +1,722 @@
 package configmap
 
 import (
-     "context"
-     "encoding/json"
-     "sync"
-     "time"
-
-     "github.com/redhat-ai-dev/model-catalog-bridge/pkg/types"
-     "github.com/redhat-ai-dev/model-catalog-bridge/pkg/util"
-     corev1 "k8s.io/api/core/v1"
-     "k8s.io/apimachinery/pkg/api/errors"
-     metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-     "k8s.io/apimachinery/pkg/util/wait"
-     corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
-     "k8s.io/klog/v2"
-
-     "k8s.io/client-go/rest"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/cmd/server/storage/watcher"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/types"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/util"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// ConfigMaps cannot exceed 1 MiB; we leave room for the object metadata
+	defaultMaxShardBytes = 768 * 1024
 )
 
+var errShardFull = fmt.Errorf("configmap shard is full")
+
+// ConfigMapBridgeStorage stores each key in the BinaryData of a set of ConfigMaps labelled as storage shards, where the
+// StorageConfigMapName ConfigMap is the first shard (and was the only ConfigMap used prior to sharding).  The
+// StorageIndexConfigMapName ConfigMap maps each key to its shard so a Fetch only has to get two ConfigMaps, though the
+// shards are the source of truth and all of them are searched if the index is stale.
 type ConfigMapBridgeStorage struct {
-     cfg        *rest.Config
-     cl         corev1client.CoreV1Interface
-     ns         string
-     versionMap map[string]string
-     mutex      sync.Mutex
+	cfg           *rest.Config
+	cl            corev1client.CoreV1Interface
+	ns            string
+	maxShardBytes int
+	mutex         sync.Mutex
 }
 
 func NewConfigMapBridgeStorageForTest(ns string, cl corev1client.CoreV1Interface) *ConfigMapBridgeStorage {
-     return &ConfigMapBridgeStorage{
-          cfg:        nil,
-          cl:         cl,
-          ns:         ns,
-          versionMap: map[string]string{},
-          mutex:      sync.Mutex{},
-     }
+	return NewShardedConfigMapBridgeStorageForTest(ns, cl, defaultMaxShardBytes)
+}
+
+func NewShardedConfigMapBridgeStorageForTest(ns string, cl corev1client.CoreV1Interface, maxShardBytes int) *ConfigMapBridgeStorage {
+	return &ConfigMapBridgeStorage{
+		cfg:           nil,
+		cl:            cl,
+		ns:            ns,
+		maxShardBytes: maxShardBytes,
+		mutex:         sync.Mutex{},
+	}
 }
 
 func (c *ConfigMapBridgeStorage) Initialize(cfg *rest.Config) error {
-     c.cfg = cfg
-     c.cl = util.GetCoreClient(c.cfg)
-     c.ns = util.GetCurrentProject()
-     c.versionMap = map[string]string{}
-     c.mutex = sync.Mutex{}
-     klog.Infof("getting cfg map in %s ns", c.ns)
-     _, err := c.cl.ConfigMaps(c.ns).Get(context.Background(), util.StorageConfigMapName, metav1.GetOptions{})
-     klog.Infof("getting cfg map err %#v", err)
-     if err != nil && !errors.IsNotFound(err) {
-          return err
-     }
-     if err != nil {
-          cm := &corev1.ConfigMap{}
-          cm.Name = util.StorageConfigMapName
-          _, err = c.cl.ConfigMaps(c.ns).Create(context.Background(), cm, metav1.CreateOptions{})
-          klog.Infof("create cfg map err %#v", err)
-          if err != nil {
-               return err
-          }
-     }
-     return nil
-}
-
-func (c *ConfigMapBridgeStorage) Upsert(key string, value types.StorageBody) error {
-     c.mutex.Lock()
-     defer c.mutex.Unlock()
-     v, ok := c.versionMap[key]
-     if !ok {
-          c.versionMap[key] = v
-     }
-     if value.LastUpdateTimeSinceEpoch >= v {
-          c.versionMap[key] = v
-     } else {
-          klog.Infof("ignoring upsert for %s because incoming version %s is older than %s", key, value.LastUpdateTimeSinceEpoch, v)
-          return nil
-     }
-     err := wait.PollImmediate(time.Second, 5*time.Second, func() (bool, error) {
-          cm, err := c.cl.ConfigMaps(c.ns).Get(context.Background(), util.StorageConfigMapName, metav1.GetOptions{})
-          if err != nil {
-               return false, nil
-          }
-
-          if cm.BinaryData == nil {
-               cm.BinaryData = map[string][]byte{}
-          }
-          buf := []byte{}
-          buf, err = json.Marshal(value)
-          if err != nil {
-               return false, nil
-          }
-
-          cm.BinaryData[key] = buf
-          _, err = c.cl.ConfigMaps(c.ns).Update(context.Background(), cm, metav1.UpdateOptions{})
-          if err != nil {
-               return false, nil
-          }
-          return true, nil
-     })
-
-     return err
-}
-
-func (c *ConfigMapBridgeStorage) Fetch(key string) (types.StorageBody, error) {
-     cm, err := c.cl.ConfigMaps(c.ns).Get(context.Background(), util.StorageConfigMapName, metav1.GetOptions{})
-     sb := types.StorageBody{}
-     if err != nil {
-          return sb, err
-     }
-     if cm.BinaryData == nil {
-          return sb, nil
-     }
-
-     buf, ok := cm.BinaryData[key]
-     if !ok {
-          return sb, nil
-     }
-     err = json.Unmarshal(buf, &sb)
-     return sb, err
-}
-
-func (c *ConfigMapBridgeStorage) Remove(key string) error {
-     cm, err := c.cl.ConfigMaps(c.ns).Get(context.Background(), util.StorageConfigMapName, metav1.GetOptions{})
-     if err != nil {
-          return err
-     }
-     if cm.BinaryData == nil {
-          return nil
-     }
-     delete(cm.BinaryData, key)
-     _, err = c.cl.ConfigMaps(c.ns).Update(context.Background(), cm, metav1.UpdateOptions{})
-     return err
+	c.cfg = cfg
+	c.cl = util.GetCoreClient(c.cfg)
+	c.ns = util.GetCurrentProject()
+	c.mutex = sync.Mutex{}
+	c.maxShardBytes = defaultMaxShardBytes
+	maxStr := os.Getenv(types.ConfigMapStorageMaxShardBytesEnvVar)
+	max, err := strconv.Atoi(strings.TrimSpace(maxStr))
+	if err == nil && max > 0 {
+		c.maxShardBytes = max
+	}
+	klog.Infof("getting cfg map in %s ns", c.ns)
+	return c.setup()
+}
+
+// setup makes sure the first shard and the index exist and are labelled, which also takes care of upgrading from the
+// single, unlabelled ConfigMap, and then levels out any shard which is over the size limit
+func (c *ConfigMapBridgeStorage) setup() error {
+	err := c.updateShard(util.StorageConfigMapName, true, func(cm *corev1.ConfigMap) error { return nil })
+	klog.Infof("setup of cfg map %s err %#v", util.StorageConfigMapName, err)
+	if err != nil {
+		return err
+	}
+	err = c.updateIndex(func(index map[string]string) bool { return false })
+	if err != nil {
+		return err
+	}
+	return c.Rebalance()
+}
+
+func (c *ConfigMapBridgeStorage) Upsert(key string, value types.StorageBody, expectedRevision string) (string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	buf, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	size := entrySize(key, buf)
+	if size > c.maxShardBytes {
+		return "", fmt.Errorf("the entry for key %s is %d bytes, which exceeds the %d byte configmap shard limit", key, size, c.maxShardBytes)
+	}
+
+	err = c.upsert(key, buf, expectedRevision)
+	if types.IsConflict(err) && expectedRevision == types.AnyRevision {
+		// another replica stored the key in another shard at the same time, so update its copy instead
+		err = c.upsert(key, buf, expectedRevision)
+	}
+	if err != nil {
+		return "", err
+	}
+	return revision(buf), nil
+}
+
+func (c *ConfigMapBridgeStorage) upsert(key string, buf []byte, expectedRevision string) error {
+	size := entrySize(key, buf)
+	current, err := c.findShard(key)
+	if err != nil {
+		return err
+	}
+	if current == nil {
+		return c.moveEntry(key, buf, "", expectedRevision)
+	}
+	prev := ""
+	err = c.updateShard(current.Name, false, func(cm *corev1.ConfigMap) error {
+		// the shard update is itself guarded by the configmap resourceVersion, so the revision check and the write
+		// are atomic
+		prev = revision(cm.BinaryData[key])
+		if err := types.CheckRevision(key, expectedRevision, prev); err != nil {
+			return err
+		}
+		if shardSize(cm)-entrySize(key, cm.BinaryData[key])+size > c.maxShardBytes {
+			return errShardFull
+		}
+		cm.BinaryData[key] = buf
+		return nil
+	})
+	switch {
+	case err == nil:
+		return c.setIndexEntry(key, current.Name)
+	case err != errShardFull:
+		return err
+	}
+	// the entry has grown past what its shard can hold, so move it
+	klog.Infof("moving key %s out of configmap %s as it has grown to %d bytes", key, current.Name, size)
+	return c.moveEntry(key, buf, current.Name, prev)
+}
+
+func (c *ConfigMapBridgeStorage) Fetch(key string) (types.StorageBody, string, error) {
+	sb := types.StorageBody{}
+	cm, err := c.findShard(key)
+	if err != nil {
+		return sb, "", err
+	}
+	if cm == nil {
+		return sb, "", nil
+	}
+	err = json.Unmarshal(cm.BinaryData[key], &sb)
+	return sb, revision(cm.BinaryData[key]), err
+}
+
+func (c *ConfigMapBridgeStorage) Remove(key string, expectedRevision string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	cm, err := c.findShard(key)
+	if err != nil {
+		return err
+	}
+	if cm != nil {
+		err = c.updateShard(cm.Name, false, func(cm *corev1.ConfigMap) error {
+			buf, ok := cm.BinaryData[key]
+			if !ok {
+				return nil
+			}
+			if err := types.CheckRevision(key, expectedRevision, revision(buf)); err != nil {
+				return err
+			}
+			delete(cm.BinaryData, key)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return c.updateIndex(func(index map[string]string) bool {
+		_, ok := index[key]
+		delete(index, key)
+		return ok
+	})
 }
 
 func (c *ConfigMapBridgeStorage) List() ([]string, error) {
-     keys := []string{}
-     cm, err := c.cl.ConfigMaps(c.ns).Get(context.Background(), util.StorageConfigMapName, metav1.GetOptions{})
-     if err != nil {
-          return keys, err
-     }
-     for key := range cm.BinaryData {
-          keys = append(keys, key)
-     }
-     return keys, nil
+	keys := []string{}
+	shards, err := c.listShards()
+	if err != nil {
+		return keys, err
+	}
+	seen := map[string]struct{}{}
+	for _, cm := range shards {
+		for key := range cm.BinaryData {
+			// an entry can briefly live in two shards while it is being moved
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// Watch keeps the shards in an informer, so that neither listing the revisions after a change nor fetching what
+// changed calls the API server
+func (c *ConfigMapBridgeStorage) Watch(ctx context.Context) (<-chan types.WatchEvent, error) {
+	selector := labels.SelectorFromSet(map[string]string{util.StorageLabel: util.StorageShardLabelValue}).String()
+	lw := &cache.ListWatch{
+		ListWithContextFunc: func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+			opts.LabelSelector = selector
+			return c.cl.ConfigMaps(c.ns).List(ctx, opts)
+		},
+		WatchFuncWithContext: func(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+			opts.LabelSelector = selector
+			return c.cl.ConfigMaps(c.ns).Watch(ctx, opts)
+		},
+	}
+	store, trigger, err := watcher.Informer(ctx, lw, &corev1.ConfigMap{})
+	if err != nil {
+		return nil, err
+	}
+	list := func() (map[string]string, error) {
+		revisions := map[string]string{}
+		for _, cm := range cachedShards(store) {
+			for key, buf := range cm.BinaryData {
+				// an entry being moved is in both shards with the same content
+				revisions[key] = revision(buf)
+			}
+		}
+		return revisions, nil
+	}
+	fetch := func(key string) (types.StorageBody, string, error) {
+		sb := types.StorageBody{}
+		for _, cm := range cachedShards(store) {
+			if buf, ok := cm.BinaryData[key]; ok {
+				err := json.Unmarshal(buf, &sb)
+				return sb, revision(buf), err
+			}
+		}
+		return sb, "", nil
+	}
+	return watcher.Start(ctx, list, fetch, trigger)
+}
+
+func cachedShards(store cache.Store) []*corev1.ConfigMap {
+	shards := []*corev1.ConfigMap{}
+	for _, obj := range store.List() {
+		if cm, ok := obj.(*corev1.ConfigMap); ok {
+			shards = append(shards, cm)
+		}
+	}
+	sort.Slice(shards, func(i, j int) bool {
+		return shardNumber(shards[i].Name) < shardNumber(shards[j].Name)
+	})
+	return shards
+}
+
+// Usage reports the size of each shard and how much room is left before a new shard has to be created
+func (c *ConfigMapBridgeStorage) Usage() (types.StorageUsage, error) {
+	usage := types.StorageUsage{Type: types.ConfigMapBridgeStorage}
+	shards, err := c.listShards()
+	if err != nil {
+		return usage, err
+	}
+	for _, cm := range shards {
+		su := types.ShardUsage{
+			Name:     cm.Name,
+			Keys:     len(cm.BinaryData),
+			Bytes:    shardSize(&cm),
+			MaxBytes: c.maxShardBytes,
+		}
+		su.HeadroomBytes = su.MaxBytes - su.Bytes
+		if su.HeadroomBytes < 0 {
+			su.HeadroomBytes = 0
+		}
+		usage.Shards = append(usage.Shards, su)
+		usage.Keys += su.Keys
+		usage.Bytes += su.Bytes
+		usage.HeadroomBytes += su.HeadroomBytes
+	}
+	return usage, nil
+}
+
+// Rebalance moves the largest entries out of any shard over the size limit (i.e. the limit was lowered, or shards
+// were edited by hand), deletes emptied shards other than the first one, and repairs the index
+func (c *ConfigMapBridgeStorage) Rebalance() error {
+	shards, err := c.listShards()
+	if err != nil {
+		return err
+	}
+	for _, cm := range shards {
+		size := shardSize(&cm)
+		if size <= c.maxShardBytes {
+			continue
+		}
+		keys := []string{}
+		for key := range cm.BinaryData {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return entrySize(keys[i], cm.BinaryData[keys[i]]) > entrySize(keys[j], cm.BinaryData[keys[j]])
+		})
+		for _, key := range keys {
+			if size <= c.maxShardBytes {
+				break
+			}
+			klog.Infof("rebalancing key %s out of configmap %s which is %d bytes", key, cm.Name, size)
+			err = c.moveEntry(key, cm.BinaryData[key], cm.Name, revision(cm.BinaryData[key]))
+			if types.IsConflict(err) {
+				klog.Infof("not rebalancing key %s as it was updated while it was being moved", key)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			size -= entrySize(key, cm.BinaryData[key])
+		}
+	}
+
+	shards, err = c.listShards()
+	if err != nil {
+		return err
+	}
+	location := map[string]string{}
+	for _, cm := range shards {
+		if len(cm.BinaryData) == 0 && cm.Name != util.StorageConfigMapName {
+			klog.Infof("deleting empty configmap shard %s", cm.Name)
+			// the precondition keeps us from deleting an entry another replica stored in the shard since we listed it
+			opts := metav1.DeleteOptions{Preconditions: &metav1.Preconditions{ResourceVersion: &cm.ResourceVersion}}
+			err = c.cl.ConfigMaps(c.ns).Delete(context.Background(), cm.Name, opts)
+			switch {
+			case err == nil || errors.IsNotFound(err):
+				continue
+			case errors.IsForbidden(err):
+				// an empty shard does no harm, so it is left in place rather than failing start up
+				klog.Warningf("leaving empty configmap shard %s in place as it could not be deleted: %s", cm.Name, err.Error())
+				continue
+			case !errors.IsConflict(err):
+				return err
+			}
+			klog.Infof("not deleting configmap shard %s as it is no longer empty", cm.Name)
+			var latest *corev1.ConfigMap
+			latest, err = c.cl.ConfigMaps(c.ns).Get(context.Background(), cm.Name, metav1.GetOptions{})
+			if errors.IsNotFound(err) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			cm = *latest
+		}
+		for key := range cm.BinaryData {
+			location[key] = cm.Name
+		}
+	}
+	return c.updateIndex(func(index map[string]string) bool {
+		changed := false
+		for key, shard := range location {
+			if index[key] != shard {
+				index[key] = shard
+				changed = true
+			}
+		}
+		for key := range index {
+			if _, ok := location[key]; !ok {
+				delete(index, key)
+				changed = true
+			}
+		}
+		return changed
+	})
+}
+
+// moveEntry stores the entry in the shard with the most headroom (other than the one it is moving from), creating a
+// new shard if none have room, then points the index at it before deleting it from the shard it is moving from.
+//
+// No single write covers both shards, so the revision is checked in each: the entry must be absent from the target
+// shard, or be at the expected revision there when an interrupted move left it behind, and must still be at the
+// expected revision in the shard it is moving from, else the move is undone.  A new key is only stored once the index
+// points at its shard, so that two replicas creating the same key in different shards cannot both succeed.
+func (c *ConfigMapBridgeStorage) moveEntry(key string, buf []byte, from, expectedRevision string) error {
+	size := entrySize(key, buf)
+	var lastErr error
+	for attempt := 0; attempt < 5; attempt++ {
+		target, err := c.pickShard(size, from)
+		if err != nil {
+			return err
+		}
+		lastErr = c.updateShard(target, true, func(cm *corev1.ConfigMap) error {
+			current := revision(cm.BinaryData[key])
+			if len(from) == 0 || len(current) > 0 {
+				if err := types.CheckRevision(key, expectedRevision, current); err != nil {
+					return err
+				}
+			}
+			if shardSize(cm)-entrySize(key, cm.BinaryData[key])+size > c.maxShardBytes {
+				return errShardFull
+			}
+			cm.BinaryData[key] = buf
+			return nil
+		})
+		if lastErr == errShardFull {
+			// another replica filled it up
+			continue
+		}
+		if lastErr != nil {
+			return lastErr
+		}
+		err = c.claimIndexEntry(key, target, from)
+		if err != nil {
+			return c.undoMove(key, buf, target, from, err)
+		}
+		if len(from) == 0 {
+			return nil
+		}
+		err = c.updateShard(from, false, func(cm *corev1.ConfigMap) error {
+			if err := types.CheckRevision(key, expectedRevision, revision(cm.BinaryData[key])); err != nil {
+				return err
+			}
+			delete(cm.BinaryData, key)
+			return nil
+		})
+		if types.IsConflict(err) {
+			return c.undoMove(key, buf, target, from, err)
+		}
+		return err
+	}
+	return fmt.Errorf("could not find a configmap shard with room for key %s: %v", key, lastErr)
+}
+
+// claimIndexEntry points the index at the target shard for the key, unless it already points at another shard, other
+// than the one the key is moving from, which holds the key
+func (c *ConfigMapBridgeStorage) claimIndexEntry(key, target, from string) error {
+	var claimErr error
+	err := c.updateIndex(func(index map[string]string) bool {
+		claimErr = nil
+		holder := index[key]
+		if len(holder) > 0 && holder != target && holder != from {
+			cm, err := c.cl.ConfigMaps(c.ns).Get(context.Background(), holder, metav1.GetOptions{})
+			switch {
+			case err != nil && !errors.IsNotFound(err):
+				claimErr = err
+				return false
+			case err == nil && cm.BinaryData[key] != nil:
+				claimErr = fmt.Errorf("%w: key %s was stored in configmap %s at the same time", types.ErrConflict, key, holder)
+				return false
+			}
+			// the index is stale
+		}
+		if holder == target {
+			return false
+		}
+		index[key] = target
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	return claimErr
+}
+
+// undoMove deletes the copy of the entry moveEntry stored in the target shard, and points the index back at the shard
+// it was moving from, returning the error that made it give up
+func (c *ConfigMapBridgeStorage) undoMove(key string, buf []byte, target, from string, cause error) error {
+	klog.Infof("undoing the move of key %s to configmap %s: %s", key, target, cause.Error())
+	rev := revision(buf)
+	err := c.updateShard(target, false, func(cm *corev1.ConfigMap) error {
+		if revision(cm.BinaryData[key]) == rev {
+			delete(cm.BinaryData, key)
+		}
+		return nil
+	})
+	if err == nil && len(from) > 0 {
+		err = c.setIndexEntry(key, from)
+	}
+	if err != nil {
+		klog.Errorf("error undoing the move of key %s to configmap %s: %s", key, target, err.Error())
+	}
+	return cause
+}
+
+func (c *ConfigMapBridgeStorage) pickShard(size int, exclude string) (string, error) {
+	shards, err := c.listShards()
+	if err != nil {
+		return "", err
+	}
+	if len(shards) == 0 {
+		return util.StorageConfigMapName, nil
+	}
+	best := ""
+	bestHeadroom := -1
+	highest := 0
+	for _, cm := range shards {
+		if n := shardNumber(cm.Name); n > highest {
+			highest = n
+		}
+		if cm.Name == exclude {
+			continue
+		}
+		headroom := c.maxShardBytes - shardSize(&cm)
+		if headroom >= size && headroom > bestHeadroom {
+			best = cm.Name
+			bestHeadroom = headroom
+		}
+	}
+	if len(best) > 0 {
+		return best, nil
+	}
+	name := fmt.Sprintf("%s-%d", util.StorageConfigMapName, highest+1)
+	klog.Warningf("all configmap shards are full, adding shard %s", name)
+	return name, nil
+}
+
+// findShard returns the shard holding the key, or nil if no shard has it
+func (c *ConfigMapBridgeStorage) findShard(key string) (*corev1.ConfigMap, error) {
+	index, err := c.cl.ConfigMaps(c.ns).Get(context.Background(), util.StorageIndexConfigMapName, metav1.GetOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, err
+	}
+	if err == nil && index.Data != nil {
+		if name, ok := index.Data[key]; ok {
+			var cm *corev1.ConfigMap
+			cm, err = c.cl.ConfigMaps(c.ns).Get(context.Background(), name, metav1.GetOptions{})
+			if err != nil && !errors.IsNotFound(err) {
+				return nil, err
+			}
+			if err == nil {
+				if _, ok = cm.BinaryData[key]; ok {
+					return cm, nil
+				}
+			}
+		}
+	}
+	// the index is missing or stale
+	shards, err := c.listShards()
+	if err != nil {
+		return nil, err
+	}
+	for i := range shards {
+		if _, ok := shards[i].BinaryData[key]; ok {
+			return &shards[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// listShards returns the labelled shards along with the first shard, which may not be labelled yet if it was
+// created prior to sharding, sorted by shard number
+func (c *ConfigMapBridgeStorage) listShards() ([]corev1.ConfigMap, error) {
+	selector := labels.SelectorFromSet(map[string]string{util.StorageLabel: util.StorageShardLabelValue})
+	list, err := c.cl.ConfigMaps(c.ns).List(context.Background(), metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, err
+	}
+	shards := list.Items
+	found := false
+	for _, cm := range shards {
+		if cm.Name == util.StorageConfigMapName {
+			found = true
+			break
+		}
+	}
+	if !found {
+		var cm *corev1.ConfigMap
+		cm, err = c.cl.ConfigMaps(c.ns).Get(context.Background(), util.StorageConfigMapName, metav1.GetOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			return nil, err
+		}
+		if err == nil {
+			shards = append(shards, *cm)
+		}
+	}
+	sort.Slice(shards, func(i, j int) bool {
+		return shardNumber(shards[i].Name) < shardNumber(shards[j].Name)
+	})
+	return shards, nil
+}
+
+func (c *ConfigMapBridgeStorage) setIndexEntry(key, shard string) error {
+	return c.updateIndex(func(index map[string]string) bool {
+		if index[key] == shard {
+			return false
+		}
+		index[key] = shard
+		return true
+	})
+}
+
+// updateIndex applies the change to the index ConfigMap, creating it if needed; the change function returns whether
+// it modified the index, so we can skip needless updates
+func (c *ConfigMapBridgeStorage) updateIndex(change func(index map[string]string) bool) error {
+	return retryOnConflict(func() error {
+		cm, err := c.cl.ConfigMaps(c.ns).Get(context.Background(), util.StorageIndexConfigMapName, metav1.GetOptions{})
+		notFound := errors.IsNotFound(err)
+		if err != nil && !notFound {
+			return err
+		}
+		if notFound {
+			cm = &corev1.ConfigMap{}
+			cm.Name = util.StorageIndexConfigMapName
+			cm.Labels = map[string]string{util.StorageLabel: util.StorageIndexLabelValue}
+		}
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		changed := change(cm.Data)
+		switch {
+		case notFound:
+			_, err = c.cl.ConfigMaps(c.ns).Create(context.Background(), cm, metav1.CreateOptions{})
+		case changed:
+			_, err = c.cl.ConfigMaps(c.ns).Update(context.Background(), cm, metav1.UpdateOptions{})
+		}
+		return err
+	})
+}
+
+// updateShard applies the change to the named shard, optionally creating it, and makes sure it carries the shard
+// label; an error returned by the change function aborts the update
+func (c *ConfigMapBridgeStorage) updateShard(name string, create bool, change func(cm *corev1.ConfigMap) error) error {
+	return retryOnConflict(func() error {
+		cm, err := c.cl.ConfigMaps(c.ns).Get(context.Background(), name, metav1.GetOptions{})
+		notFound := errors.IsNotFound(err)
+		switch {
+		case notFound && !create:
+			return nil
+		case err != nil && !notFound:
+			return err
+		case notFound:
+			cm = &corev1.ConfigMap{}
+			cm.Name = name
+		}
+		if cm.BinaryData == nil {
+			cm.BinaryData = map[string][]byte{}
+		}
+		if cm.Labels == nil {
+			cm.Labels = map[string]string{}
+		}
+		cm.Labels[util.StorageLabel] = util.StorageShardLabelValue
+		err = change(cm)
+		if err != nil {
+			return err
+		}
+		if notFound {
+			_, err = c.cl.ConfigMaps(c.ns).Create(context.Background(), cm, metav1.CreateOptions{})
+			if errors.IsAlreadyExists(err) {
+				// created by another replica; Get it again and retry
+				return errors.NewConflict(corev1.Resource("configmaps"), name, err)
+			}
+			return err
+		}
+		_, err = c.cl.ConfigMaps(c.ns).Update(context.Background(), cm, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+func retryOnConflict(fn func() error) error {
+	var lastErr error
+	err := wait.PollImmediate(100*time.Millisecond, 5*time.Second, func() (bool, error) {
+		lastErr = fn()
+		switch {
+		case lastErr == nil:
+			return true, nil
+		case errors.IsConflict(lastErr):
+			return false, nil
+		default:
+			return false, lastErr
+		}
+	})
+	if wait.Interrupted(err) {
+		return lastErr
+	}
+	return err
+}
+
+// revision is a hash of the stored entry, as the configmap resourceVersion changes whenever any entry in the shard
+// does
+func revision(buf []byte) string {
+	if buf == nil {
+		return ""
+	}
+	sum := sha256.Sum256(buf)
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// shardNumber returns 0 for the first shard and N for the StorageConfigMapName-N shards
+func shardNumber(name string) int {
+	n, err := strconv.Atoi(strings.TrimPrefix(name, util.StorageConfigMapName+"-"))
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+func entrySize(key string, buf []byte) int {
+	if buf == nil {
+		return 0
+	}
+	return len(key) + len(buf)
+}
+
+func shardSize(cm *corev1.ConfigMap) int {
+	size := 0
+	for key, buf := range cm.BinaryData {
+		size += entrySize(key, buf)
+	}
+	for key, val := range cm.Data {
+		size += len(key) + len(val)
+	}
+	return size
 }
@@ -0,0 +1,281 @@
+package configmap
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/types"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/util"
+	"github.com/redhat-ai-dev/model-catalog-bridge/test/stub/common"
+	"github.com/redhat-ai-dev/model-catalog-bridge/test/stub/storage/conformance"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func shardContents(t *testing.T, c *ConfigMapBridgeStorage) map[string][]string {
+	t.Helper()
+	shards, err := c.listShards()
+	common.AssertError(t, err)
+	contents := map[string][]string{}
+	for _, cm := range shards {
+		keys := []string{}
+		for k := range cm.BinaryData {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		contents[cm.Name] = keys
+	}
+	return contents
+}
+
+func index(t *testing.T, c *ConfigMapBridgeStorage) map[string]string {
+	t.Helper()
+	cm, err := c.cl.ConfigMaps(c.ns).Get(context.Background(), util.StorageIndexConfigMapName, metav1.GetOptions{})
+	common.AssertError(t, err)
+	return cm.Data
+}
+
+func testEntrySize(t *testing.T, key string, sb types.StorageBody) int {
+	t.Helper()
+	buf, err := json.Marshal(sb)
+	common.AssertError(t, err)
+	return entrySize(key, buf)
+}
+
+func TestShardedUpsertFetchRemove(t *testing.T) {
+	cmCl := fake.NewClientset().CoreV1()
+	// only two of the entries below fit in a shard
+	max := 2*testEntrySize(t, "mnist_v1", types.StorageBody{Body: []byte("mnist_v1"), ReconcilerType: types.KubeflowNormalizer}) + 10
+	c := NewShardedConfigMapBridgeStorageForTest(metav1.NamespaceDefault, cmCl, max)
+	err := c.setup()
+	common.AssertError(t, err)
+
+	for _, key := range []string{"mnist_v1", "mnist_v2", "mnist_v3", "mnist_v4", "mnist_v5"} {
+		_, err = c.Upsert(key, types.StorageBody{Body: []byte(key), ReconcilerType: types.KubeflowNormalizer}, types.AnyRevision)
+		common.AssertError(t, err)
+	}
+
+	contents := shardContents(t, c)
+	common.AssertEqual(t, 3, len(contents))
+	idx := index(t, c)
+	common.AssertEqual(t, 5, len(idx))
+	for shard, keys := range contents {
+		for _, key := range keys {
+			common.AssertEqual(t, shard, idx[key])
+		}
+	}
+
+	keys, err := c.List()
+	common.AssertError(t, err)
+	common.AssertEqual(t, 5, len(keys))
+
+	sb, _, err := c.Fetch("mnist_v4")
+	common.AssertError(t, err)
+	common.AssertEqual(t, "mnist_v4", string(sb.Body))
+
+	// grow an entry so it no longer fits alongside its neighbor, though it still fits in the shard that only has one entry
+	grown := idx["mnist_v1"]
+	_, err = c.Upsert("mnist_v1", types.StorageBody{Body: []byte(strings.Repeat("x", 60)), ReconcilerType: types.KubeflowNormalizer}, types.AnyRevision)
+	common.AssertError(t, err)
+	idx = index(t, c)
+	if idx["mnist_v1"] == grown {
+		t.Errorf("expected mnist_v1 to move out of %s", grown)
+	}
+	sb, _, err = c.Fetch("mnist_v1")
+	common.AssertError(t, err)
+	common.AssertEqual(t, strings.Repeat("x", 60), string(sb.Body))
+
+	usage, err := c.Usage()
+	common.AssertError(t, err)
+	common.AssertEqual(t, 5, usage.Keys)
+	for _, su := range usage.Shards {
+		common.AssertEqual(t, true, su.Bytes <= max)
+		common.AssertEqual(t, max-su.Bytes, su.HeadroomBytes)
+	}
+
+	for _, key := range []string{"mnist_v1", "mnist_v2", "mnist_v3", "mnist_v4", "mnist_v5"} {
+		err = c.Remove(key, types.AnyRevision)
+		common.AssertError(t, err)
+	}
+	keys, err = c.List()
+	common.AssertError(t, err)
+	common.AssertEqual(t, 0, len(keys))
+	common.AssertEqual(t, 0, len(index(t, c)))
+
+	// too large for any shard
+	_, err = c.Upsert("mnist_v6", types.StorageBody{Body: []byte(strings.Repeat("x", max))}, types.AnyRevision)
+	if err == nil {
+		t.Errorf("expected error for an entry larger than a shard")
+	}
+}
+
+func TestShardedSetupFromSingleConfigMap(t *testing.T) {
+	cmCl := fake.NewClientset().CoreV1()
+	legacy := &corev1.ConfigMap{}
+	legacy.Name = util.StorageConfigMapName
+	legacy.BinaryData = map[string][]byte{}
+	for _, key := range []string{"mnist_v1", "mnist_v2", "mnist_v3"} {
+		buf, err := json.Marshal(types.StorageBody{Body: []byte(key)})
+		common.AssertError(t, err)
+		legacy.BinaryData[key] = buf
+	}
+	_, err := cmCl.ConfigMaps(metav1.NamespaceDefault).Create(context.Background(), legacy, metav1.CreateOptions{})
+	common.AssertError(t, err)
+
+	// the pre-existing configmap is over the limit, so setup has to spread it out
+	c := NewShardedConfigMapBridgeStorageForTest(metav1.NamespaceDefault, cmCl, testEntrySize(t, "mnist_v1", types.StorageBody{Body: []byte("mnist_v1")})+10)
+	err = c.setup()
+	common.AssertError(t, err)
+
+	cm, err := cmCl.ConfigMaps(metav1.NamespaceDefault).Get(context.Background(), util.StorageConfigMapName, metav1.GetOptions{})
+	common.AssertError(t, err)
+	common.AssertEqual(t, util.StorageShardLabelValue, cm.Labels[util.StorageLabel])
+
+	contents := shardContents(t, c)
+	common.AssertEqual(t, 3, len(contents))
+	for _, keys := range contents {
+		common.AssertEqual(t, 1, len(keys))
+	}
+	common.AssertEqual(t, 3, len(index(t, c)))
+	for _, key := range []string{"mnist_v1", "mnist_v2", "mnist_v3"} {
+		sb, _, err := c.Fetch(key)
+		common.AssertError(t, err)
+		common.AssertEqual(t, key, string(sb.Body))
+	}
+}
+
+func TestRebalanceWithoutDeletePermission(t *testing.T) {
+	cs := fake.NewClientset()
+	c := NewShardedConfigMapBridgeStorageForTest(metav1.NamespaceDefault, cs.CoreV1(), 1024*1024)
+	err := c.setup()
+	common.AssertError(t, err)
+	name := util.StorageConfigMapName + "-2"
+	err = c.updateShard(name, true, func(cm *corev1.ConfigMap) error { return nil })
+	common.AssertError(t, err)
+	cs.PrependReactor("delete", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.NewForbidden(corev1.Resource("configmaps"), action.(k8stesting.DeleteAction).GetName(), fmt.Errorf("no delete verb"))
+	})
+
+	// the empty shard is left in place, and set up still repairs the index
+	_, err = c.Upsert("mnist_v1", types.StorageBody{Body: []byte("mnist_v1")}, types.AnyRevision)
+	common.AssertError(t, err)
+	err = c.updateIndex(func(index map[string]string) bool {
+		delete(index, "mnist_v1")
+		return true
+	})
+	common.AssertError(t, err)
+	err = c.setup()
+	common.AssertError(t, err)
+	contents := shardContents(t, c)
+	common.AssertEqual(t, []string{}, contents[name])
+	common.AssertEqual(t, []string{"mnist_v1"}, contents[util.StorageConfigMapName])
+	common.AssertEqual(t, util.StorageConfigMapName, index(t, c)["mnist_v1"])
+}
+
+func TestRebalanceKeepsShardFilledWhileDeleting(t *testing.T) {
+	cs := fake.NewClientset()
+	c := NewShardedConfigMapBridgeStorageForTest(metav1.NamespaceDefault, cs.CoreV1(), 1024*1024)
+	err := c.setup()
+	common.AssertError(t, err)
+	name := util.StorageConfigMapName + "-2"
+	err = c.updateShard(name, true, func(cm *corev1.ConfigMap) error { return nil })
+	common.AssertError(t, err)
+
+	// another replica stores an entry in the empty shard just as we go to delete it; the fake client does not check
+	// preconditions, so the reactor does
+	buf, err := json.Marshal(types.StorageBody{Body: []byte("mnist_v1")})
+	common.AssertError(t, err)
+	cs.PrependReactor("delete", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		del := action.(k8stesting.DeleteAction)
+		if del.GetName() != name {
+			return false, nil, nil
+		}
+		obj, err := cs.Tracker().Get(corev1.SchemeGroupVersion.WithResource("configmaps"), metav1.NamespaceDefault, name)
+		common.AssertError(t, err)
+		cm := obj.(*corev1.ConfigMap).DeepCopy()
+		cm.BinaryData = map[string][]byte{"mnist_v1": buf}
+		cm.ResourceVersion = "filled"
+		err = cs.Tracker().Update(corev1.SchemeGroupVersion.WithResource("configmaps"), cm, metav1.NamespaceDefault)
+		common.AssertError(t, err)
+		pre := del.GetDeleteOptions().Preconditions
+		if pre == nil || pre.ResourceVersion == nil || *pre.ResourceVersion != cm.ResourceVersion {
+			return true, nil, errors.NewConflict(corev1.Resource("configmaps"), name, fmt.Errorf("the resource version does not match"))
+		}
+		return false, nil, nil
+	})
+
+	err = c.Rebalance()
+	common.AssertError(t, err)
+	common.AssertEqual(t, []string{"mnist_v1"}, shardContents(t, c)[name])
+	common.AssertEqual(t, name, index(t, c)["mnist_v1"])
+	sb, _, err := c.Fetch("mnist_v1")
+	common.AssertError(t, err)
+	common.AssertEqual(t, "mnist_v1", string(sb.Body))
+}
+
+func TestConcurrentCreate(t *testing.T) {
+	cmCl := fake.NewClientset().CoreV1()
+	c := NewShardedConfigMapBridgeStorageForTest(metav1.NamespaceDefault, cmCl, 1024*1024)
+	err := c.setup()
+	common.AssertError(t, err)
+	mine, err := json.Marshal(types.StorageBody{Body: []byte("mine")})
+	common.AssertError(t, err)
+	theirs, err := json.Marshal(types.StorageBody{Body: []byte("theirs")})
+	common.AssertError(t, err)
+
+	// both replicas found the key absent, and another replica stored it in the shard we go to store ours in
+	err = c.moveEntry("mnist_v1", theirs, "", "")
+	common.AssertError(t, err)
+	err = c.moveEntry("mnist_v1", mine, "", "")
+	if !types.IsConflict(err) {
+		t.Errorf("expected a conflict creating a key stored at the same time in the same shard, got [%v]", err)
+	}
+
+	// with an emptier shard to store ours in, the index only lets one of them have the key
+	other := util.StorageConfigMapName + "-2"
+	err = c.updateShard(other, true, func(cm *corev1.ConfigMap) error { return nil })
+	common.AssertError(t, err)
+	err = c.moveEntry("mnist_v1", mine, "", "")
+	if !types.IsConflict(err) {
+		t.Errorf("expected a conflict creating a key stored at the same time in another shard, got [%v]", err)
+	}
+	contents := shardContents(t, c)
+	common.AssertEqual(t, []string{"mnist_v1"}, contents[util.StorageConfigMapName])
+	common.AssertEqual(t, []string{}, contents[other])
+	common.AssertEqual(t, util.StorageConfigMapName, index(t, c)["mnist_v1"])
+	sb, _, err := c.Fetch("mnist_v1")
+	common.AssertError(t, err)
+	common.AssertEqual(t, "theirs", string(sb.Body))
+}
+
+func TestRevisions(t *testing.T) {
+	cmCl := fake.NewClientset().CoreV1()
+	c := NewShardedConfigMapBridgeStorageForTest(metav1.NamespaceDefault, cmCl, 1024*1024)
+	err := c.setup()
+	common.AssertError(t, err)
+	conformance.AssertRevisionSemantics(t, c)
+}
+
+func TestRoundTrip(t *testing.T) {
+	cmCl := fake.NewClientset().CoreV1()
+	c := NewShardedConfigMapBridgeStorageForTest(metav1.NamespaceDefault, cmCl, 1024*1024)
+	err := c.setup()
+	common.AssertError(t, err)
+	conformance.AssertRoundTrip(t, c)
+}
+
+func TestWatch(t *testing.T) {
+	cmCl := fake.NewClientset().CoreV1()
+	c := NewShardedConfigMapBridgeStorageForTest(metav1.NamespaceDefault, cmCl, 1024*1024)
+	err := c.setup()
+	common.AssertError(t, err)
+	conformance.AssertWatch(t, c)
+}
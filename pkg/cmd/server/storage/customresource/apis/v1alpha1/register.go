@@ -0,0 +1,32 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	GroupName = "modelcatalogbridge.rhdh.io"
+	Version   = "v1alpha1"
+
+	Kind     = "ModelCatalogEntry"
+	Resource = "modelcatalogentries"
+	CRDName  = Resource + "." + GroupName
+)
+
+var (
+	SchemeGroupVersion = schema.GroupVersion{Group: GroupName, Version: Version}
+
+	SchemeBuilder = runtime.NewSchemeBuilder(addKnownTypes)
+	AddToScheme   = SchemeBuilder.AddToScheme
+)
+
+func addKnownTypes(scheme *runtime.Scheme) error {
+	scheme.AddKnownTypes(SchemeGroupVersion,
+		&ModelCatalogEntry{},
+		&ModelCatalogEntryList{},
+	)
+	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
+	return nil
+}
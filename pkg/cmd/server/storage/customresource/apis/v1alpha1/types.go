@@ -0,0 +1,58 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ModelCatalogEntry is the storage record for a single model version the bridge has imported into Backstage.  The spec
+// holds what the normalizers sent us, and the status holds what we learned from pushing it to Backstage.
+type ModelCatalogEntry struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ModelCatalogEntrySpec   `json:"spec"`
+	Status ModelCatalogEntryStatus `json:"status,omitempty"`
+}
+
+type ModelCatalogEntrySpec struct {
+	// Key is the bridge storage key; the object name is derived from it, but has to be DNS compliant so may differ
+	Key                      string `json:"key"`
+	Body                     string `json:"body,omitempty"`
+	ReconcilerType           string `json:"reconcilerType,omitempty"`
+	Source                   string `json:"source,omitempty"`
+	LastUpdateTimeSinceEpoch string `json:"lastUpdateTimeSinceEpoch,omitempty"`
+	ModelCardKey             string `json:"modelCardKey,omitempty"`
+	// ModelCard is the model card last upserted with Body, and Digest the digest of the two
+	ModelCard string `json:"modelCard,omitempty"`
+	Digest    string `json:"digest,omitempty"`
+	// Invalid is what validation found wrong with Body, when it was stored in lenient validation mode
+	Invalid []ModelCatalogEntryFieldError `json:"invalid,omitempty"`
+}
+
+type ModelCatalogEntryFieldError struct {
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+type ModelCatalogEntryStatus struct {
+	LocationId      string `json:"locationId,omitempty"`
+	LocationTarget  string `json:"locationTarget,omitempty"`
+	LocationIDValid bool   `json:"locationIDValid,omitempty"`
+	LastPushResult  string `json:"lastPushResult,omitempty"`
+	// Tombstone is set once the model is no longer found, until the entry is removed for good
+	Tombstone *ModelCatalogEntryTombstone `json:"tombstone,omitempty"`
+}
+
+type ModelCatalogEntryTombstone struct {
+	RemovedAt metav1.Time `json:"removedAt"`
+	RemovedBy string      `json:"removedBy,omitempty"`
+	Reason    string      `json:"reason,omitempty"`
+	ExpiresAt metav1.Time `json:"expiresAt"`
+}
+
+type ModelCatalogEntryList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []ModelCatalogEntry `json:"items"`
+}
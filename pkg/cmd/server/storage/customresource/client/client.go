@@ -0,0 +1,133 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/cmd/server/storage/customresource/apis/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/gentype"
+	"k8s.io/client-go/rest"
+)
+
+var (
+	scheme         = runtime.NewScheme()
+	codecs         = serializer.NewCodecFactory(scheme)
+	parameterCodec = runtime.NewParameterCodec(scheme)
+)
+
+func init() {
+	metav1.AddToGroupVersion(scheme, metav1.SchemeGroupVersion)
+	if err := v1alpha1.AddToScheme(scheme); err != nil {
+		panic(err)
+	}
+}
+
+type ModelCatalogBridgeV1alpha1Interface interface {
+	RESTClient() rest.Interface
+	ModelCatalogEntriesGetter
+}
+
+// ModelCatalogBridgeV1alpha1Client is used to interact with features provided by the modelcatalogbridge.rhdh.io group.
+type ModelCatalogBridgeV1alpha1Client struct {
+	restClient rest.Interface
+}
+
+func (c *ModelCatalogBridgeV1alpha1Client) ModelCatalogEntries(namespace string) ModelCatalogEntryInterface {
+	return newModelCatalogEntries(c, namespace)
+}
+
+// NewForConfig creates a new ModelCatalogBridgeV1alpha1Client for the given config.
+func NewForConfig(c *rest.Config) (*ModelCatalogBridgeV1alpha1Client, error) {
+	config := *c
+	setConfigDefaults(&config)
+	httpClient, err := rest.HTTPClientFor(&config)
+	if err != nil {
+		return nil, err
+	}
+	return NewForConfigAndClient(&config, httpClient)
+}
+
+// NewForConfigAndClient creates a new ModelCatalogBridgeV1alpha1Client for the given config and http client.
+func NewForConfigAndClient(c *rest.Config, h *http.Client) (*ModelCatalogBridgeV1alpha1Client, error) {
+	config := *c
+	setConfigDefaults(&config)
+	client, err := rest.RESTClientForConfigAndClient(&config, h)
+	if err != nil {
+		return nil, err
+	}
+	return &ModelCatalogBridgeV1alpha1Client{client}, nil
+}
+
+// NewForConfigOrDie creates a new ModelCatalogBridgeV1alpha1Client for the given config and panics if there is an error
+// in the config.
+func NewForConfigOrDie(c *rest.Config) *ModelCatalogBridgeV1alpha1Client {
+	client, err := NewForConfig(c)
+	if err != nil {
+		panic(err)
+	}
+	return client
+}
+
+// New creates a new ModelCatalogBridgeV1alpha1Client for the given RESTClient.
+func New(c rest.Interface) *ModelCatalogBridgeV1alpha1Client {
+	return &ModelCatalogBridgeV1alpha1Client{c}
+}
+
+func setConfigDefaults(config *rest.Config) {
+	gv := v1alpha1.SchemeGroupVersion
+	config.GroupVersion = &gv
+	config.APIPath = "/apis"
+	config.NegotiatedSerializer = codecs.WithoutConversion()
+	if config.UserAgent == "" {
+		config.UserAgent = rest.DefaultKubernetesUserAgent()
+	}
+}
+
+// RESTClient returns a RESTClient that is used to communicate with the API server by this client implementation.
+func (c *ModelCatalogBridgeV1alpha1Client) RESTClient() rest.Interface {
+	if c == nil {
+		return nil
+	}
+	return c.restClient
+}
+
+// ModelCatalogEntriesGetter has a method to return a ModelCatalogEntryInterface.
+type ModelCatalogEntriesGetter interface {
+	ModelCatalogEntries(namespace string) ModelCatalogEntryInterface
+}
+
+// ModelCatalogEntryInterface has methods to work with ModelCatalogEntry resources.
+type ModelCatalogEntryInterface interface {
+	Create(ctx context.Context, modelCatalogEntry *v1alpha1.ModelCatalogEntry, opts metav1.CreateOptions) (*v1alpha1.ModelCatalogEntry, error)
+	Update(ctx context.Context, modelCatalogEntry *v1alpha1.ModelCatalogEntry, opts metav1.UpdateOptions) (*v1alpha1.ModelCatalogEntry, error)
+	UpdateStatus(ctx context.Context, modelCatalogEntry *v1alpha1.ModelCatalogEntry, opts metav1.UpdateOptions) (*v1alpha1.ModelCatalogEntry, error)
+	Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts metav1.DeleteOptions, listOpts metav1.ListOptions) error
+	Get(ctx context.Context, name string, opts metav1.GetOptions) (*v1alpha1.ModelCatalogEntry, error)
+	List(ctx context.Context, opts metav1.ListOptions) (*v1alpha1.ModelCatalogEntryList, error)
+	Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts metav1.PatchOptions, subresources ...string) (result *v1alpha1.ModelCatalogEntry, err error)
+}
+
+// modelCatalogEntries implements ModelCatalogEntryInterface
+type modelCatalogEntries struct {
+	*gentype.ClientWithList[*v1alpha1.ModelCatalogEntry, *v1alpha1.ModelCatalogEntryList]
+}
+
+func newModelCatalogEntries(c *ModelCatalogBridgeV1alpha1Client, namespace string) *modelCatalogEntries {
+	return &modelCatalogEntries{
+		gentype.NewClientWithList[*v1alpha1.ModelCatalogEntry, *v1alpha1.ModelCatalogEntryList](
+			v1alpha1.Resource,
+			c.RESTClient(),
+			parameterCodec,
+			namespace,
+			func() *v1alpha1.ModelCatalogEntry { return &v1alpha1.ModelCatalogEntry{} },
+			func() *v1alpha1.ModelCatalogEntryList { return &v1alpha1.ModelCatalogEntryList{} },
+		),
+	}
+}
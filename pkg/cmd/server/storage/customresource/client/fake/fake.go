@@ -0,0 +1,139 @@
+package fake
+
+import (
+	"strconv"
+
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/cmd/server/storage/customresource/apis/v1alpha1"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/cmd/server/storage/customresource/client"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/gentype"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/testing"
+)
+
+var (
+	scheme = runtime.NewScheme()
+	codecs = serializer.NewCodecFactory(scheme)
+)
+
+func init() {
+	metav1.AddToGroupVersion(scheme, metav1.SchemeGroupVersion)
+	if err := v1alpha1.AddToScheme(scheme); err != nil {
+		panic(err)
+	}
+}
+
+// FakeModelCatalogBridgeV1alpha1 is backed by an object tracker, but unlike the stock client-go fakes it also bumps
+// resourceVersion, rejects updates with a stale resourceVersion, and keeps spec and status updates apart the way the
+// status subresource does on a real API server, as the storage implementation depends on all three.
+type FakeModelCatalogBridgeV1alpha1 struct {
+	*testing.Fake
+	tracker         testing.ObjectTracker
+	resourceVersion int
+}
+
+func NewSimpleClientset(objects ...runtime.Object) *FakeModelCatalogBridgeV1alpha1 {
+	o := testing.NewObjectTracker(scheme, codecs.UniversalDecoder())
+	for _, obj := range objects {
+		if err := o.Add(obj); err != nil {
+			panic(err)
+		}
+	}
+
+	f := &FakeModelCatalogBridgeV1alpha1{Fake: &testing.Fake{}, tracker: o}
+	f.AddReactor("create", v1alpha1.Resource, f.create)
+	f.AddReactor("update", v1alpha1.Resource, f.update)
+	f.AddReactor("*", "*", testing.ObjectReaction(o))
+	f.AddWatchReactor("*", func(action testing.Action) (bool, watch.Interface, error) {
+		w, err := o.Watch(action.GetResource(), action.GetNamespace())
+		return true, w, err
+	})
+	return f
+}
+
+// Tracker is the object tracker the fake is backed by, for tests to change objects behind the client's back
+func (f *FakeModelCatalogBridgeV1alpha1) Tracker() testing.ObjectTracker {
+	return f.tracker
+}
+
+func (f *FakeModelCatalogBridgeV1alpha1) nextResourceVersion() string {
+	f.resourceVersion++
+	return strconv.Itoa(f.resourceVersion)
+}
+
+func (f *FakeModelCatalogBridgeV1alpha1) create(action testing.Action) (bool, runtime.Object, error) {
+	ca := action.(testing.CreateAction)
+	obj := ca.GetObject().(*v1alpha1.ModelCatalogEntry).DeepCopy()
+	obj.ResourceVersion = f.nextResourceVersion()
+	err := f.tracker.Create(ca.GetResource(), obj, ca.GetNamespace())
+	if err != nil {
+		return true, nil, err
+	}
+	return true, obj, nil
+}
+
+func (f *FakeModelCatalogBridgeV1alpha1) update(action testing.Action) (bool, runtime.Object, error) {
+	ua := action.(testing.UpdateAction)
+	obj := ua.GetObject().(*v1alpha1.ModelCatalogEntry).DeepCopy()
+	existing, err := f.tracker.Get(ua.GetResource(), ua.GetNamespace(), obj.Name)
+	if err != nil {
+		return true, nil, err
+	}
+	current := existing.(*v1alpha1.ModelCatalogEntry)
+	if len(obj.ResourceVersion) > 0 && obj.ResourceVersion != current.ResourceVersion {
+		return true, nil, errors.NewConflict(v1alpha1.SchemeGroupVersion.WithResource(v1alpha1.Resource).GroupResource(), obj.Name,
+			errors.NewBadRequest("the object has been modified; please apply your changes to the latest version and try again"))
+	}
+	if ua.GetSubresource() == "status" {
+		updated := current.DeepCopy()
+		updated.Status = obj.Status
+		obj = updated
+	} else {
+		obj.Status = current.Status
+	}
+	obj.ResourceVersion = f.nextResourceVersion()
+	err = f.tracker.Update(ua.GetResource(), obj, ua.GetNamespace())
+	if err != nil {
+		return true, nil, err
+	}
+	return true, obj, nil
+}
+
+func (f *FakeModelCatalogBridgeV1alpha1) ModelCatalogEntries(namespace string) client.ModelCatalogEntryInterface {
+	return newFakeModelCatalogEntries(f, namespace)
+}
+
+// RESTClient returns a RESTClient that is used to communicate with the API server by this client implementation.
+func (f *FakeModelCatalogBridgeV1alpha1) RESTClient() rest.Interface {
+	var ret *rest.RESTClient
+	return ret
+}
+
+// fakeModelCatalogEntries implements ModelCatalogEntryInterface
+type fakeModelCatalogEntries struct {
+	*gentype.FakeClientWithList[*v1alpha1.ModelCatalogEntry, *v1alpha1.ModelCatalogEntryList]
+}
+
+func newFakeModelCatalogEntries(fake *FakeModelCatalogBridgeV1alpha1, namespace string) client.ModelCatalogEntryInterface {
+	return &fakeModelCatalogEntries{
+		gentype.NewFakeClientWithList[*v1alpha1.ModelCatalogEntry, *v1alpha1.ModelCatalogEntryList](
+			fake.Fake,
+			namespace,
+			v1alpha1.SchemeGroupVersion.WithResource(v1alpha1.Resource),
+			v1alpha1.SchemeGroupVersion.WithKind(v1alpha1.Kind),
+			func() *v1alpha1.ModelCatalogEntry { return &v1alpha1.ModelCatalogEntry{} },
+			func() *v1alpha1.ModelCatalogEntryList { return &v1alpha1.ModelCatalogEntryList{} },
+			func(dst, src *v1alpha1.ModelCatalogEntryList) { dst.ListMeta = src.ListMeta },
+			func(list *v1alpha1.ModelCatalogEntryList) []*v1alpha1.ModelCatalogEntry {
+				return gentype.ToPointerSlice(list.Items)
+			},
+			func(list *v1alpha1.ModelCatalogEntryList, items []*v1alpha1.ModelCatalogEntry) {
+				list.Items = gentype.FromPointerSlice(items)
+			},
+		),
+	}
+}
@@ -0,0 +1,369 @@
+package customresource
+
+import (
+	"context"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/cmd/server/storage/customresource/apis/v1alpha1"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/cmd/server/storage/customresource/client"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/cmd/server/storage/watcher"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/types"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/util"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiextensionsclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// etcd rejects objects over 1.5 MiB; we leave room for the rest of the object
+	maxBodyBytes = 1024 * 1024
+	// object names are DNS subdomains, capped at 253 characters, and we need room for the hash suffix
+	maxNamePrefixLength = 200
+)
+
+//go:embed crd/modelcatalogentries.modelcatalogbridge.rhdh.io.yaml
+var crdManifest []byte
+
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9.-]+`)
+
+// CustomResourceBridgeStorage stores each key as a namespaced ModelCatalogEntry, with the content the normalizers send
+// us in the spec and the Backstage location details in the status subresource.
+type CustomResourceBridgeStorage struct {
+	cfg   *rest.Config
+	cl    client.ModelCatalogBridgeV1alpha1Interface
+	ns    string
+	mutex sync.Mutex
+}
+
+func NewCustomResourceBridgeStorageForTest(ns string, cl client.ModelCatalogBridgeV1alpha1Interface) *CustomResourceBridgeStorage {
+	return &CustomResourceBridgeStorage{
+		cl:    cl,
+		ns:    ns,
+		mutex: sync.Mutex{},
+	}
+}
+
+func (c *CustomResourceBridgeStorage) Initialize(cfg *rest.Config) error {
+	var err error
+	c.cfg = cfg
+	c.ns = util.GetCurrentProject()
+	c.mutex = sync.Mutex{}
+	c.cl, err = client.NewForConfig(cfg)
+	if err != nil {
+		return err
+	}
+	klog.Infof("using %s in %s ns for storage", v1alpha1.Resource, c.ns)
+	return c.ensureCRD()
+}
+
+// ensureCRD installs the ModelCatalogEntry CRD if it is not present, or updates it if it is from an older bridge,
+// though typically the bridge's service account will not have permission to do so, in which case the CRD has to be
+// applied by a cluster admin ahead of time
+func (c *CustomResourceBridgeStorage) ensureCRD() error {
+	crdCl := apiextensionsclient.NewForConfigOrDie(c.cfg).ApiextensionsV1().CustomResourceDefinitions()
+	crd := &apiextensionsv1.CustomResourceDefinition{}
+	err := yaml.Unmarshal(crdManifest, crd)
+	if err != nil {
+		return err
+	}
+	installed, err := crdCl.Get(context.TODO(), v1alpha1.CRDName, metav1.GetOptions{})
+	if err == nil {
+		if equality.Semantic.DeepEqual(installed.Spec.Versions, crd.Spec.Versions) {
+			return nil
+		}
+		// the API server prunes the fields an older schema does not have, which would lose them on every write
+		installed.Spec.Versions = crd.Spec.Versions
+		_, err = crdCl.Update(context.TODO(), installed, metav1.UpdateOptions{})
+		if err != nil {
+			klog.Warningf("the installed %s CRD is out of date and could not be updated, so fields it does not have will not be stored until it is applied again from pkg/cmd/server/storage/customresource/crd: %s", v1alpha1.CRDName, err.Error())
+			return nil
+		}
+		klog.Infof("updated the %s CRD", v1alpha1.CRDName)
+		return nil
+	}
+	if !errors.IsNotFound(err) {
+		return err
+	}
+
+	_, err = crdCl.Create(context.TODO(), crd, metav1.CreateOptions{})
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return fmt.Errorf("the %s CRD is not installed and could not be created, so it needs to be applied from pkg/cmd/server/storage/customresource/crd: %s", v1alpha1.CRDName, err.Error())
+	}
+	klog.Infof("created the %s CRD", v1alpha1.CRDName)
+
+	return wait.PollImmediate(time.Second, 30*time.Second, func() (bool, error) {
+		crd, err = crdCl.Get(context.TODO(), v1alpha1.CRDName, metav1.GetOptions{})
+		if err != nil {
+			return false, nil
+		}
+		for _, cond := range crd.Status.Conditions {
+			if cond.Type == apiextensionsv1.Established && cond.Status == apiextensionsv1.ConditionTrue {
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+}
+
+func (c *CustomResourceBridgeStorage) Fetch(key string) (types.StorageBody, string, error) {
+	mce, err := c.cl.ModelCatalogEntries(c.ns).Get(context.TODO(), EntryName(key), metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return types.StorageBody{}, "", nil
+	}
+	if err != nil {
+		return types.StorageBody{}, "", err
+	}
+	return toStorageBody(mce), mce.ResourceVersion, nil
+}
+
+// Upsert uses the resourceVersion as the revision.  As the spec and status are written separately, once the spec is
+// written a conflicting status write is retried against the latest object, for as long as its spec is still ours, so
+// that a failure is not reported for an upsert which is already half made.
+func (c *CustomResourceBridgeStorage) Upsert(key string, value types.StorageBody, expectedRevision string) (string, error) {
+	if size := len(value.Body) + len(value.ModelCard); size > maxBodyBytes {
+		return "", fmt.Errorf("the body and model card for key %s are %d bytes, which exceeds the %d byte limit for a %s", key, size, maxBodyBytes, v1alpha1.Kind)
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	name := EntryName(key)
+	spec, status := fromStorageBody(key, value)
+	var mce *v1alpha1.ModelCatalogEntry
+	err := retryOnConflict(func() error {
+		var err error
+		mce, err = c.cl.ModelCatalogEntries(c.ns).Get(context.TODO(), name, metav1.GetOptions{})
+		switch {
+		case errors.IsNotFound(err):
+			err = types.CheckRevision(key, expectedRevision, "")
+			if err != nil {
+				return err
+			}
+			mce = &v1alpha1.ModelCatalogEntry{}
+			mce.Name = name
+			mce.Namespace = c.ns
+			mce.Spec = spec
+			mce, err = c.cl.ModelCatalogEntries(c.ns).Create(context.TODO(), mce, metav1.CreateOptions{})
+			return err
+		case err != nil:
+			return err
+		}
+		err = types.CheckRevision(key, expectedRevision, mce.ResourceVersion)
+		if err != nil || equality.Semantic.DeepEqual(mce.Spec, spec) {
+			return err
+		}
+		mce.Spec = spec
+		mce, err = c.cl.ModelCatalogEntries(c.ns).Update(context.TODO(), mce, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	err = retryOnConflict(func() error {
+		if equality.Semantic.DeepEqual(mce.Status, status) {
+			return nil
+		}
+		mce.Status = status
+		updated, err := c.cl.ModelCatalogEntries(c.ns).UpdateStatus(context.TODO(), mce, metav1.UpdateOptions{})
+		if errors.IsConflict(err) {
+			latest, getErr := c.cl.ModelCatalogEntries(c.ns).Get(context.TODO(), name, metav1.GetOptions{})
+			switch {
+			case getErr != nil:
+				return getErr
+			case !equality.Semantic.DeepEqual(latest.Spec, spec):
+				return fmt.Errorf("%w: key %s was upserted again while its status was being written", types.ErrConflict, key)
+			}
+			mce = latest
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		mce = updated
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return mce.ResourceVersion, nil
+}
+
+func (c *CustomResourceBridgeStorage) Remove(key string, expectedRevision string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	name := EntryName(key)
+	mce, err := c.cl.ModelCatalogEntries(c.ns).Get(context.TODO(), name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	err = types.CheckRevision(key, expectedRevision, mce.ResourceVersion)
+	if err != nil {
+		return err
+	}
+	opts := metav1.DeleteOptions{}
+	if expectedRevision != types.AnyRevision {
+		// the precondition closes the window between our check and the delete
+		opts.Preconditions = &metav1.Preconditions{ResourceVersion: &mce.ResourceVersion}
+	}
+	err = c.cl.ModelCatalogEntries(c.ns).Delete(context.TODO(), name, opts)
+	switch {
+	case errors.IsNotFound(err):
+		return nil
+	case errors.IsConflict(err):
+		return fmt.Errorf("%w: %s", types.ErrConflict, err.Error())
+	}
+	return err
+}
+
+func (c *CustomResourceBridgeStorage) List() ([]string, error) {
+	list, err := c.cl.ModelCatalogEntries(c.ns).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	keys := []string{}
+	for _, mce := range list.Items {
+		keys = append(keys, mce.Spec.Key)
+	}
+	return keys, nil
+}
+
+// Watch keeps the entries in an informer, so that neither listing the revisions after a change nor fetching what
+// changed calls the API server
+func (c *CustomResourceBridgeStorage) Watch(ctx context.Context) (<-chan types.WatchEvent, error) {
+	lw := &cache.ListWatch{
+		ListWithContextFunc: func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+			return c.cl.ModelCatalogEntries(c.ns).List(ctx, opts)
+		},
+		WatchFuncWithContext: func(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+			return c.cl.ModelCatalogEntries(c.ns).Watch(ctx, opts)
+		},
+	}
+	store, trigger, err := watcher.Informer(ctx, lw, &v1alpha1.ModelCatalogEntry{})
+	if err != nil {
+		return nil, err
+	}
+	list := func() (map[string]string, error) {
+		revisions := map[string]string{}
+		for _, obj := range store.List() {
+			if mce, ok := obj.(*v1alpha1.ModelCatalogEntry); ok {
+				revisions[mce.Spec.Key] = mce.ResourceVersion
+			}
+		}
+		return revisions, nil
+	}
+	fetch := func(key string) (types.StorageBody, string, error) {
+		obj, exists, err := store.GetByKey(c.ns + "/" + EntryName(key))
+		if err != nil || !exists {
+			return types.StorageBody{}, "", err
+		}
+		mce := obj.(*v1alpha1.ModelCatalogEntry)
+		return toStorageBody(mce), mce.ResourceVersion, nil
+	}
+	return watcher.Start(ctx, list, fetch, trigger)
+}
+
+// EntryName maps a storage key to a valid object name; storage keys can have upper case letters and underscores, so
+// we lower case and replace those and then add a hash of the original key so that distinct keys never share a name
+func EntryName(key string) string {
+	prefix := invalidNameChars.ReplaceAllString(strings.ToLower(key), "-")
+	prefix = strings.Trim(prefix, ".-")
+	if len(prefix) > maxNamePrefixLength {
+		prefix = strings.TrimRight(prefix[:maxNamePrefixLength], ".-")
+	}
+	sum := sha256.Sum256([]byte(key))
+	suffix := hex.EncodeToString(sum[:])[:10]
+	if len(prefix) == 0 {
+		return suffix
+	}
+	return prefix + "-" + suffix
+}
+
+func toStorageBody(mce *v1alpha1.ModelCatalogEntry) types.StorageBody {
+	sb := types.StorageBody{
+		LocationId:               mce.Status.LocationId,
+		LocationTarget:           mce.Status.LocationTarget,
+		LocationIDValid:          mce.Status.LocationIDValid,
+		ReconcilerType:           mce.Spec.ReconcilerType,
+		Source:                   mce.Spec.Source,
+		LastUpdateTimeSinceEpoch: mce.Spec.LastUpdateTimeSinceEpoch,
+		ModelCardKey:             mce.Spec.ModelCardKey,
+		ModelCard:                mce.Spec.ModelCard,
+		Digest:                   mce.Spec.Digest,
+		LastPushResult:           mce.Status.LastPushResult,
+	}
+	if len(mce.Spec.Body) > 0 {
+		sb.Body = []byte(mce.Spec.Body)
+	}
+	for _, fe := range mce.Spec.Invalid {
+		sb.Invalid = append(sb.Invalid, types.FieldError{Field: fe.Field, Message: fe.Message})
+	}
+	if ts := mce.Status.Tombstone; ts != nil {
+		sb.Tombstone = &types.Tombstone{RemovedAt: ts.RemovedAt.Time, RemovedBy: ts.RemovedBy, Reason: ts.Reason, ExpiresAt: ts.ExpiresAt.Time}
+	}
+	return sb
+}
+
+func fromStorageBody(key string, sb types.StorageBody) (v1alpha1.ModelCatalogEntrySpec, v1alpha1.ModelCatalogEntryStatus) {
+	var tombstone *v1alpha1.ModelCatalogEntryTombstone
+	if ts := sb.Tombstone; ts != nil {
+		tombstone = &v1alpha1.ModelCatalogEntryTombstone{RemovedAt: metav1.NewTime(ts.RemovedAt), RemovedBy: ts.RemovedBy, Reason: ts.Reason, ExpiresAt: metav1.NewTime(ts.ExpiresAt)}
+	}
+	var invalid []v1alpha1.ModelCatalogEntryFieldError
+	for _, fe := range sb.Invalid {
+		invalid = append(invalid, v1alpha1.ModelCatalogEntryFieldError{Field: fe.Field, Message: fe.Message})
+	}
+	return v1alpha1.ModelCatalogEntrySpec{
+		Key:                      key,
+		Body:                     string(sb.Body),
+		ReconcilerType:           sb.ReconcilerType,
+		Source:                   sb.Source,
+		LastUpdateTimeSinceEpoch: sb.LastUpdateTimeSinceEpoch,
+		ModelCardKey:             sb.ModelCardKey,
+		ModelCard:                sb.ModelCard,
+		Digest:                   sb.Digest,
+		Invalid:                  invalid,
+	}, v1alpha1.ModelCatalogEntryStatus{
+		LocationId:      sb.LocationId,
+		LocationTarget:  sb.LocationTarget,
+		LocationIDValid: sb.LocationIDValid,
+		LastPushResult:  sb.LastPushResult,
+		Tombstone:       tombstone,
+	}
+}
+
+func retryOnConflict(fn func() error) error {
+	var lastErr error
+	err := wait.PollImmediate(100*time.Millisecond, 5*time.Second, func() (bool, error) {
+		lastErr = fn()
+		switch {
+		case lastErr == nil:
+			return true, nil
+		case errors.IsConflict(lastErr):
+			return false, nil
+		default:
+			return false, lastErr
+		}
+	})
+	if wait.Interrupted(err) {
+		return lastErr
+	}
+	return err
+}
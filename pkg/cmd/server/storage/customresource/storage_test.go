@@ -0,0 +1,212 @@
+package customresource
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/cmd/server/storage/customresource/apis/v1alpha1"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/cmd/server/storage/customresource/client/fake"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/types"
+	"github.com/redhat-ai-dev/model-catalog-bridge/test/stub/common"
+	"github.com/redhat-ai-dev/model-catalog-bridge/test/stub/storage/conformance"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestEntryName(t *testing.T) {
+	for _, tc := range []struct {
+		key    string
+		prefix string
+	}{
+		{key: "mnist_v1", prefix: "mnist-v1-"},
+		{key: "Granite-3.1_V2", prefix: "granite-3.1-v2-"},
+		{key: "_weird__key_", prefix: "weird-key-"},
+		{key: strings.Repeat("a", 300) + "_v1", prefix: strings.Repeat("a", maxNamePrefixLength) + "-"},
+	} {
+		name := EntryName(tc.key)
+		if !strings.HasPrefix(name, tc.prefix) {
+			t.Errorf("expected %s to have prefix %s", name, tc.prefix)
+		}
+		if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+			t.Errorf("name %s for key %s is not valid: %v", name, tc.key, errs)
+		}
+	}
+	if EntryName("mnist_v1") == EntryName("mnist-v1") {
+		t.Error("distinct keys should not map to the same name")
+	}
+}
+
+func TestCustomResourceBridgeStorage(t *testing.T) {
+	cl := fake.NewSimpleClientset()
+	st := NewCustomResourceBridgeStorageForTest(metav1.NamespaceDefault, cl)
+
+	sb, _, err := st.Fetch("mnist_v1")
+	common.AssertError(t, err)
+	common.AssertEqual(t, types.StorageBody{}, sb)
+
+	for _, tc := range []struct {
+		name string
+		key  string
+		body types.StorageBody
+	}{
+		{
+			name: "new entry",
+			key:  "mnist_v1",
+			body: types.StorageBody{Body: []byte("create"), ReconcilerType: types.KubeflowNormalizer, LastUpdateTimeSinceEpoch: "1", ModelCardKey: "mnist_v1_modelcard"},
+		},
+		{
+			name: "location from backstage",
+			key:  "mnist_v1",
+			body: types.StorageBody{Body: []byte("create"), LocationId: "loc-id", LocationTarget: "http://foo.com/mnist/v1/catalog-info.yaml", ReconcilerType: types.KubeflowNormalizer, LastUpdateTimeSinceEpoch: "1", ModelCardKey: "mnist_v1_modelcard", LastPushResult: types.PushResultImported},
+		},
+		{
+			name: "updated body keeps the location",
+			key:  "mnist_v1",
+			body: types.StorageBody{Body: []byte("update"), LocationId: "loc-id", LocationTarget: "http://foo.com/mnist/v1/catalog-info.yaml", ReconcilerType: types.KubeflowNormalizer, LastUpdateTimeSinceEpoch: "2", ModelCardKey: "mnist_v1_modelcard", LastPushResult: types.PushResultImported},
+		},
+		{
+			name: "second entry",
+			key:  "Granite_V2",
+			body: types.StorageBody{Body: []byte("create"), ReconcilerType: types.KServeNormalizer, Source: types.KServeNormalizer, LastPushResult: types.PushResultPushDisabled},
+		},
+	} {
+		_, err = st.Upsert(tc.key, tc.body, types.AnyRevision)
+		common.AssertError(t, err)
+		sb, _, err = st.Fetch(tc.key)
+		common.AssertError(t, err)
+		common.AssertEqual(t, tc.body, sb)
+	}
+
+	mce, err := cl.ModelCatalogEntries(metav1.NamespaceDefault).Get(context.Background(), EntryName("mnist_v1"), metav1.GetOptions{})
+	common.AssertError(t, err)
+	common.AssertEqual(t, "mnist_v1", mce.Spec.Key)
+	common.AssertEqual(t, "update", mce.Spec.Body)
+	common.AssertEqual(t, "loc-id", mce.Status.LocationId)
+
+	keys, err := st.List()
+	common.AssertError(t, err)
+	sort.Strings(keys)
+	common.AssertEqual(t, []string{"Granite_V2", "mnist_v1"}, keys)
+
+	err = st.Remove("mnist_v1", types.AnyRevision)
+	common.AssertError(t, err)
+	err = st.Remove("mnist_v1", types.AnyRevision)
+	common.AssertError(t, err)
+	keys, err = st.List()
+	common.AssertError(t, err)
+	common.AssertEqual(t, []string{"Granite_V2"}, keys)
+
+	_, err = st.Upsert("mnist_v3", types.StorageBody{Body: []byte(strings.Repeat("x", maxBodyBytes+1))}, types.AnyRevision)
+	if err == nil {
+		t.Error("expected error for a body over the size limit")
+	}
+}
+
+func TestCustomResourceBridgeStorageStatusConflict(t *testing.T) {
+	cl := fake.NewSimpleClientset()
+	st := NewCustomResourceBridgeStorageForTest(metav1.NamespaceDefault, cl)
+	rev, err := st.Upsert("mnist_v1", types.StorageBody{Body: []byte("v1")}, "")
+	common.AssertError(t, err)
+
+	// another writer updates the status between our spec and status writes
+	gvr := v1alpha1.SchemeGroupVersion.WithResource(v1alpha1.Resource)
+	conflicted := false
+	cl.PrependReactor("update", v1alpha1.Resource, func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "status" || conflicted {
+			return false, nil, nil
+		}
+		conflicted = true
+		obj, err := cl.Tracker().Get(gvr, metav1.NamespaceDefault, EntryName("mnist_v1"))
+		common.AssertError(t, err)
+		mce := obj.(*v1alpha1.ModelCatalogEntry).DeepCopy()
+		mce.Status.LastPushResult = types.PushResultImportFailed
+		mce.ResourceVersion = "status-writer"
+		common.AssertError(t, cl.Tracker().Update(gvr, mce, metav1.NamespaceDefault))
+		return true, nil, errors.NewConflict(gvr.GroupResource(), mce.Name, fmt.Errorf("the object has been modified"))
+	})
+
+	newRev, err := st.Upsert("mnist_v1", types.StorageBody{Body: []byte("v2"), LocationId: "loc-1", LastPushResult: types.PushResultImported}, rev)
+	common.AssertError(t, err)
+	common.AssertEqual(t, true, conflicted)
+	sb, fetchedRev, err := st.Fetch("mnist_v1")
+	common.AssertError(t, err)
+	common.AssertEqual(t, newRev, fetchedRev)
+	common.AssertEqual(t, "v2", string(sb.Body))
+	common.AssertEqual(t, "loc-1", sb.LocationId)
+	common.AssertEqual(t, types.PushResultImported, sb.LastPushResult)
+
+	// but a status write is not retried over another upsert's spec
+	conflicted = false
+	cl.PrependReactor("update", v1alpha1.Resource, func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "status" || conflicted {
+			return false, nil, nil
+		}
+		conflicted = true
+		obj, err := cl.Tracker().Get(gvr, metav1.NamespaceDefault, EntryName("mnist_v1"))
+		common.AssertError(t, err)
+		mce := obj.(*v1alpha1.ModelCatalogEntry).DeepCopy()
+		mce.Spec.Body = "v4"
+		mce.ResourceVersion = "spec-writer"
+		common.AssertError(t, cl.Tracker().Update(gvr, mce, metav1.NamespaceDefault))
+		return true, nil, errors.NewConflict(gvr.GroupResource(), mce.Name, fmt.Errorf("the object has been modified"))
+	})
+	_, err = st.Upsert("mnist_v1", types.StorageBody{Body: []byte("v3")}, newRev)
+	if !types.IsConflict(err) {
+		t.Errorf("expected a conflict when the spec was upserted again, got [%v]", err)
+	}
+	sb, _, err = st.Fetch("mnist_v1")
+	common.AssertError(t, err)
+	common.AssertEqual(t, "v4", string(sb.Body))
+}
+
+func TestCustomResourceBridgeStorageRevisions(t *testing.T) {
+	st := NewCustomResourceBridgeStorageForTest(metav1.NamespaceDefault, fake.NewSimpleClientset())
+	conformance.AssertRevisionSemantics(t, st)
+}
+
+func TestCustomResourceBridgeStorageRoundTrip(t *testing.T) {
+	st := NewCustomResourceBridgeStorageForTest(metav1.NamespaceDefault, fake.NewSimpleClientset())
+	conformance.AssertRoundTrip(t, st)
+}
+
+func TestCustomResourceBridgeStorageWatch(t *testing.T) {
+	st := NewCustomResourceBridgeStorageForTest(metav1.NamespaceDefault, fake.NewSimpleClientset())
+	conformance.AssertWatch(t, st)
+}
+
+func TestCustomResourceBridgeStorageTombstone(t *testing.T) {
+	st := NewCustomResourceBridgeStorageForTest(metav1.NamespaceDefault, fake.NewSimpleClientset())
+	removedAt := time.Now().Truncate(time.Second)
+	body := types.StorageBody{
+		Body:       []byte("create"),
+		LocationId: "loc-id",
+		Tombstone:  &types.Tombstone{RemovedAt: removedAt, RemovedBy: "10.0.0.1", Reason: "gone", ExpiresAt: removedAt.Add(time.Hour)},
+	}
+	_, err := st.Upsert("mnist_v1", body, types.AnyRevision)
+	common.AssertError(t, err)
+	sb, _, err := st.Fetch("mnist_v1")
+	common.AssertError(t, err)
+	common.AssertNotNil(t, sb.Tombstone)
+	common.AssertEqual(t, "10.0.0.1", sb.Tombstone.RemovedBy)
+	common.AssertEqual(t, "gone", sb.Tombstone.Reason)
+	if !sb.Tombstone.RemovedAt.Equal(removedAt) || !sb.Tombstone.ExpiresAt.Equal(removedAt.Add(time.Hour)) {
+		t.Errorf("unexpected tombstone times %v", sb.Tombstone)
+	}
+
+	// clearing the tombstone restores the entry
+	sb.Tombstone = nil
+	_, err = st.Upsert("mnist_v1", sb, types.AnyRevision)
+	common.AssertError(t, err)
+	sb, _, err = st.Fetch("mnist_v1")
+	common.AssertError(t, err)
+	if sb.Tombstone != nil {
+		t.Errorf("expected the tombstone to be cleared but got %v", sb.Tombstone)
+	}
+}
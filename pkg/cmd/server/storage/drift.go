@@ -0,0 +1,504 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/rest"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/types"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/util"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// how often storage is compared with the Backstage catalog, unless BACKSTAGE_RECONCILE_INTERVAL says otherwise
+	defaultDriftInterval = 10 * time.Minute
+
+	// the annotation Backstage puts on each entity with the "<type>:<target>" of the location it came from
+	managedByLocationAnnotation = "backstage.io/managed-by-location"
+)
+
+// DriftReport summarizes one comparison of storage with the Backstage catalog, and what was done, or with a dry run,
+// would have been done, to bring them back in line
+type DriftReport struct {
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+	DryRun     bool      `json:"dryRun"`
+	// Checked is how many models in storage were compared with Backstage
+	Checked int `json:"checked"`
+	// Locations is how many locations Backstage has
+	Locations int `json:"locations"`
+	// Missing are the keys whose location is not in Backstage
+	Missing []string `json:"missing"`
+	// Adopted are the keys which were given the location Backstage already has for their target
+	Adopted []string `json:"adopted"`
+	// Reimported are the keys whose location was imported into Backstage again
+	Reimported []string `json:"reimported"`
+	// Orphaned are the ids of the locations for our location service no key refers to, which were deleted
+	Orphaned []string `json:"orphaned"`
+	// FailedEntities are the entities from our locations Backstage reports errors processing
+	FailedEntities []FailedEntity `json:"failedEntities"`
+	// Errors are the problems which kept part of the drift from being repaired; the repair is tried again on the
+	// next pass, or for imports and deletes, by the outbox
+	Errors []string `json:"errors"`
+}
+
+// FailedEntity is an entity Backstage could not process
+type FailedEntity struct {
+	// Ref is the entity reference, in "kind:namespace/name" form
+	Ref string `json:"ref"`
+	// Key is the storage key of the model whose location the entity came from, if we know it
+	Key      string   `json:"key,omitempty"`
+	Location string   `json:"location"`
+	Errors   []string `json:"errors"`
+}
+
+func driftIntervalFromEnv() time.Duration {
+	v := strings.NewReplacer("\r", "", "\n", "").Replace(os.Getenv(types.BackstageReconcileIntervalEnvVar))
+	if len(v) == 0 {
+		return defaultDriftInterval
+	}
+	interval, err := time.ParseDuration(v)
+	if err != nil || interval < 0 {
+		klog.Errorf("ignoring %s setting %q as it is not a duration", types.BackstageReconcileIntervalEnvVar, v)
+		return defaultDriftInterval
+	}
+	return interval
+}
+
+// locationTarget is the location service URL Backstage imports the model under the key from, along with the URL it
+// was imported from before sources were part of location service URIs, which the location service redirects
+func (s *StorageRESTServer) locationTarget(key string, sb *types.StorageBody) (string, string, bool) {
+	seg1, seg2, ok := util.KeySegments(key)
+	if !ok || len(s.locations.HostURL) == 0 {
+		return "", "", false
+	}
+	_, legacy := util.BuildImportKeyAndURI(seg1, seg2, s.format)
+	uri := util.BuildSourceURI(types.EffectiveSource(sb.Source, sb.ReconcilerType), seg1, seg2, s.format)
+	return s.locations.HostURL + uri, s.locations.HostURL + legacy, true
+}
+
+// ours says whether the location target is served by our location service
+func (s *StorageRESTServer) ours(target string) bool {
+	return len(s.locations.HostURL) > 0 && strings.HasPrefix(target, s.locations.HostURL+"/")
+}
+
+func (s *StorageRESTServer) reconcileBackstageLoop(ctx context.Context) {
+	if !s.driftRunning.TryLock() {
+		return
+	}
+	defer s.driftRunning.Unlock()
+	s.reconcileBackstage(ctx, false, systemActor)
+}
+
+// reconcileBackstage compares the keys and location ids in storage with the locations and entities in Backstage, and
+// unless dryRun is set, repairs the drift between them:
+//   - a key whose location is gone from Backstage is given the location Backstage has for its target, if there is
+//     one, or else, when we import locations directly, has its location imported again
+//   - a location for our location service no key refers to is deleted, provided every key could be fetched and no
+//     key refers to it when storage is checked again just before
+//   - an entity from one of our locations which Backstage could not process is reported
+//
+// Tombstoned keys keep their locations until they are purged, and keys with an import or delete in the outbox are
+// left to it.  The report of the pass is returned and kept for the drift endpoint, and the repairs are recorded in the
+// audit log for a.
+func (s *StorageRESTServer) reconcileBackstage(ctx context.Context, dryRun bool, a auditActor) *DriftReport {
+	report := &DriftReport{StartedAt: time.Now().UTC(), DryRun: dryRun, Missing: []string{}, Adopted: []string{}, Reimported: []string{}, Orphaned: []string{}, FailedEntities: []FailedEntity{}, Errors: []string{}}
+	defer func() {
+		report.FinishedAt = time.Now().UTC()
+		s.mutex.Lock()
+		s.lastDrift = report
+		s.mutex.Unlock()
+		klog.Infof("backstage drift pass checked %d keys against %d locations: %d missing, %d adopted, %d reimported, %d orphaned, %d failed entities, %d errors",
+			report.Checked, report.Locations, len(report.Missing), len(report.Adopted), len(report.Reimported), len(report.Orphaned), len(report.FailedEntities), len(report.Errors))
+	}()
+	fail := func(format string, args ...any) {
+		msg := fmt.Sprintf(format, args...)
+		klog.Error(msg)
+		report.Errors = append(report.Errors, msg)
+	}
+
+	if !s.setupBkstg() {
+		fail("access to Backstage is not available")
+		return report
+	}
+	catalog, ok := s.bkstg.(rest.BackstageCatalog)
+	if !ok {
+		fail("the Backstage client cannot list the catalog")
+		return report
+	}
+
+	keys, err := s.st.List()
+	if err != nil {
+		fail("error listing storage keys: %s", err.Error())
+		return report
+	}
+	entries, _, err := s.listOutbox()
+	if err != nil {
+		fail("error listing the outbox: %s", err.Error())
+		return report
+	}
+	pendingImports := map[string]struct{}{}
+	pendingDeletes := map[string]struct{}{}
+	for _, e := range entries {
+		switch e.Op {
+		case OutboxOpImport:
+			pendingImports[e.Key] = struct{}{}
+		case OutboxOpDelete:
+			pendingDeletes[e.LocationId] = struct{}{}
+		}
+	}
+
+	locations, err := catalog.ListLocationMaps()
+	if err != nil {
+		fail("error listing backstage locations: %s", err.Error())
+		return report
+	}
+	report.Locations = len(locations)
+	locationsByID := map[string]string{}
+	locationsByTarget := map[string]string{}
+	for _, l := range locations {
+		id, target, ok := rest.ParseImportLocationMap(l)
+		if !ok {
+			continue
+		}
+		locationsByID[id] = target
+		locationsByTarget[target] = id
+	}
+
+	// referenced are the location ids some key refers to, and keysByTarget the keys our locations are for
+	referenced := map[string]struct{}{}
+	keysByTarget := map[string]string{}
+	verified := map[string]*types.StorageBody{}
+	// when a key cannot be fetched, we do not know which location it refers to, so no location is taken for an orphan
+	fetchFailed := false
+	keys = modelKeys(keys)
+	sort.Strings(keys)
+	for _, key := range keys {
+		if ctx.Err() != nil {
+			return report
+		}
+		sb, rev, err := s.st.Fetch(key)
+		if err != nil {
+			fail("error fetching key %s: %s", key, err.Error())
+			fetchFailed = true
+			continue
+		}
+		if len(rev) == 0 {
+			continue
+		}
+		report.Checked++
+		target, legacyTarget, hasTarget := s.locationTarget(key, &sb)
+		if hasTarget {
+			keysByTarget[target] = key
+			keysByTarget[legacyTarget] = key
+		}
+		if len(sb.LocationId) > 0 {
+			referenced[sb.LocationId] = struct{}{}
+			if _, ok := locationsByID[sb.LocationId]; ok {
+				verified[key] = &types.StorageBody{LocationId: sb.LocationId, LocationTarget: sb.LocationTarget, LocationIDValid: true}
+				continue
+			}
+		}
+		if sb.Tombstone != nil {
+			continue
+		}
+		if _, ok := pendingImports[key]; ok {
+			continue
+		}
+		if len(sb.LocationId) == 0 && !s.pushToRHDH {
+			// Backstage pulls the model from the location service itself
+			continue
+		}
+		report.Missing = append(report.Missing, key)
+
+		// Backstage may already have a location for the target, say if we stopped before storing the id of one we
+		// imported, in which case we take that one
+		adoptID, adoptTarget, adopt := "", target, false
+		if hasTarget {
+			adoptID, adopt = locationsByTarget[target]
+			if !adopt {
+				adoptID, adopt = locationsByTarget[legacyTarget]
+				adoptTarget = legacyTarget
+			}
+		}
+		if adopt {
+			referenced[adoptID] = struct{}{}
+			report.Adopted = append(report.Adopted, key)
+			if dryRun {
+				continue
+			}
+			err = s.setLocation(key, sb.LocationId, adoptID, adoptTarget, types.PushResultImported)
+			if err != nil {
+				fail("error adopting location %s for key %s: %s", adoptID, key, err.Error())
+				continue
+			}
+			verified[key] = &types.StorageBody{LocationId: adoptID, LocationTarget: adoptTarget, LocationIDValid: true}
+			continue
+		}
+		if !s.pushToRHDH || !hasTarget {
+			if !dryRun {
+				err = s.setLocation(key, sb.LocationId, "", "", sb.LastPushResult)
+				if err != nil {
+					fail("error clearing missing location %s for key %s: %s", sb.LocationId, key, err.Error())
+				}
+			}
+			continue
+		}
+		report.Reimported = append(report.Reimported, key)
+		if dryRun {
+			continue
+		}
+		locID, err := s.reimport(key, sb.LocationId, target, a)
+		if err != nil {
+			fail("error reimporting location %s for key %s: %s", target, key, err.Error())
+			continue
+		}
+		referenced[locID] = struct{}{}
+		verified[key] = &types.StorageBody{LocationId: locID, LocationTarget: target, LocationIDValid: true}
+	}
+
+	ids := make([]string, 0, len(locationsByID))
+	for id := range locationsByID {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	if fetchFailed {
+		klog.Warning("not deleting orphaned locations as not every key could be fetched")
+		ids = nil
+	}
+	for _, id := range ids {
+		target := locationsByID[id]
+		if !s.ours(target) {
+			continue
+		}
+		if _, ok := referenced[id]; ok {
+			continue
+		}
+		if _, ok := pendingDeletes[id]; ok {
+			continue
+		}
+		if key, ok := keysByTarget[target]; ok {
+			// only a duplicate of the location the key has in Backstage is an orphan
+			if _, ok = verified[key]; !ok {
+				continue
+			}
+		}
+		if !dryRun {
+			// the key of the location may have been stored, or given the location, since we listed storage
+			key, err := s.locationInUse(id, target, locationsByID)
+			if err != nil {
+				fail("error checking storage before deleting orphaned location %s: %s", id, err.Error())
+				break
+			}
+			if len(key) > 0 {
+				klog.Infof("not deleting location %s for target %s as key %s is now stored for it", id, target, key)
+				continue
+			}
+		}
+		report.Orphaned = append(report.Orphaned, id)
+		if dryRun {
+			continue
+		}
+		msg, err := s.bkstg.DeleteLocation(id)
+		s.audit(a, AuditEntry{Action: AuditActionDelete, Key: keysByTarget[target], Reason: "orphaned location", LocationId: id}, err)
+		if err != nil {
+			fail("error deleting orphaned location %s for target %s: %s: %s", id, target, msg, err.Error())
+			s.enqueue(OutboxOpDelete, keysByTarget[target], target, id, err)
+			continue
+		}
+		klog.Infof("deleted orphaned location %s for target %s", id, target)
+	}
+
+	s.mutex.Lock()
+	for key, sb := range verified {
+		s.pushedLocations[key] = sb
+	}
+	s.mutex.Unlock()
+
+	entities, err := catalog.QueryEntityMaps("kind=component", "kind=resource", "kind=api")
+	if err != nil {
+		fail("error querying backstage entities: %s", err.Error())
+		return report
+	}
+	for _, entity := range entities {
+		if fe, ok := s.failedEntity(entity, keysByTarget); ok {
+			klog.Warningf("backstage could not process entity %s from %s: %s", fe.Ref, fe.Location, strings.Join(fe.Errors, "; "))
+			report.FailedEntities = append(report.FailedEntities, fe)
+		}
+	}
+	return report
+}
+
+// locationInUse fetches every key again, returning the key which refers to the location, or which is for its target
+// and has no other location in Backstage, if there is one
+func (s *StorageRESTServer) locationInUse(id, target string, locationsByID map[string]string) (string, error) {
+	keys, err := s.st.List()
+	if err != nil {
+		return "", fmt.Errorf("error listing storage keys: %s", err.Error())
+	}
+	for _, key := range modelKeys(keys) {
+		sb, rev, err := s.st.Fetch(key)
+		if err != nil {
+			return "", fmt.Errorf("error fetching key %s: %s", key, err.Error())
+		}
+		if len(rev) == 0 {
+			continue
+		}
+		if sb.LocationId == id {
+			return key, nil
+		}
+		keyTarget, legacyTarget, ok := s.locationTarget(key, &sb)
+		if !ok || (keyTarget != target && legacyTarget != target) {
+			continue
+		}
+		if _, ok = locationsByID[sb.LocationId]; !ok {
+			return key, nil
+		}
+	}
+	return "", nil
+}
+
+// setLocation records the location for the key, provided it still has the location from, which we found missing
+func (s *StorageRESTServer) setLocation(key, from, locID, locTarget, pushResult string) error {
+	_, err := s.update(key, func(sb *types.StorageBody) (bool, error) {
+		if len(sb.Body) == 0 || sb.LocationId != from {
+			return false, nil
+		}
+		sb.LocationId = locID
+		sb.LocationTarget = locTarget
+		sb.LocationIDValid = len(locID) > 0
+		sb.LastPushResult = pushResult
+		return true, nil
+	})
+	return err
+}
+
+// the reason the audit log gives for a drift pass importing a location again
+const reimportReason = "location missing from Backstage"
+
+// reimport imports the location for the key into Backstage again; should that fail, the import is left to the outbox
+func (s *StorageRESTServer) reimport(key, from, target string, a auditActor) (string, error) {
+	impResp, err := s.bkstg.ImportLocation(target)
+	if err != nil {
+		s.audit(a, AuditEntry{Action: AuditActionImport, Key: key, Reason: reimportReason}, err)
+		clearErr := s.setLocation(key, from, "", "", types.PushResultImportFailed)
+		if clearErr != nil {
+			klog.Errorf("error clearing missing location %s for key %s: %s", from, key, clearErr.Error())
+		}
+		s.enqueue(OutboxOpImport, key, target, "", err)
+		return "", err
+	}
+	locID, locTarget, ok := rest.ParseImportLocationMap(impResp)
+	if !ok {
+		err = fmt.Errorf("parsing of import location return had an issue: %#v", impResp)
+		s.audit(a, AuditEntry{Action: AuditActionImport, Key: key, Reason: reimportReason}, err)
+		return "", err
+	}
+	s.audit(a, AuditEntry{Action: AuditActionImport, Key: key, Reason: reimportReason, LocationId: locID}, nil)
+	err = s.setLocation(key, from, locID, locTarget, types.PushResultImported)
+	if err != nil {
+		return locID, err
+	}
+	klog.Infof("reimported location %s for key %s as %s", target, key, locID)
+	return locID, nil
+}
+
+// failedEntity returns the processing errors Backstage reports in the status of an entity from one of our locations
+func (s *StorageRESTServer) failedEntity(entity map[string]any, keysByTarget map[string]string) (FailedEntity, bool) {
+	fe := FailedEntity{}
+	metadata, _ := entity["metadata"].(map[string]any)
+	annotations, _ := metadata["annotations"].(map[string]any)
+	managedBy, _ := annotations[managedByLocationAnnotation].(string)
+	// the annotation is "<type>:<target>", and the target has a ':' of its own
+	segs := strings.SplitN(managedBy, ":", 2)
+	if len(segs) < 2 {
+		return fe, false
+	}
+	fe.Location = segs[1]
+	key, known := keysByTarget[fe.Location]
+	if !known && !s.ours(fe.Location) {
+		return fe, false
+	}
+	fe.Key = key
+	status, _ := entity["status"].(map[string]any)
+	items, _ := status["items"].([]any)
+	for _, i := range items {
+		item, _ := i.(map[string]any)
+		if item["level"] != "error" {
+			continue
+		}
+		msg, _ := item["message"].(string)
+		if errMap, ok := item["error"].(map[string]any); ok {
+			if errMsg, ok := errMap["message"].(string); ok && len(errMsg) > 0 {
+				msg = errMsg
+			}
+		}
+		fe.Errors = append(fe.Errors, msg)
+	}
+	if len(fe.Errors) == 0 {
+		return fe, false
+	}
+	namespace, _ := metadata["namespace"].(string)
+	if len(namespace) == 0 {
+		namespace = rest.DEFAULT_NS
+	}
+	fe.Ref = fmt.Sprintf("%s:%s/%s", strings.ToLower(fmt.Sprintf("%v", entity["kind"])), namespace, metadata["name"])
+	return fe, true
+}
+
+// handleDriftGet returns the report of the last drift pass
+func (s *StorageRESTServer) handleDriftGet(c *gin.Context) {
+	s.mutex.Lock()
+	report := s.lastDrift
+	s.mutex.Unlock()
+	if report == nil {
+		c.Status(http.StatusNotFound)
+		c.Error(fmt.Errorf("no drift pass has run yet"))
+		return
+	}
+	content, err := json.Marshal(report)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		c.Error(err)
+		return
+	}
+	c.Data(http.StatusOK, "Content-Type: application/json", content)
+}
+
+// handleDriftPost runs a drift pass right away, which only reports what it would repair when the 'dryRun' parameter
+// is true, and returns its report
+func (s *StorageRESTServer) handleDriftPost(c *gin.Context) {
+	dryRun := false
+	if v := c.Query(util.DryRunQueryParam); len(v) > 0 {
+		var err error
+		dryRun, err = strconv.ParseBool(v)
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			c.Error(fmt.Errorf("bad '%s' parameter %s: %s", util.DryRunQueryParam, v, err.Error()))
+			return
+		}
+	}
+	if !s.driftRunning.TryLock() {
+		c.Status(http.StatusConflict)
+		c.Error(fmt.Errorf("a drift pass is already running"))
+		return
+	}
+	report := s.reconcileBackstage(c.Request.Context(), dryRun, requestActor(c))
+	s.driftRunning.Unlock()
+	content, err := json.Marshal(report)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		c.Error(err)
+		return
+	}
+	c.Data(http.StatusOK, "Content-Type: application/json", content)
+}
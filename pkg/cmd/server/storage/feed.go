@@ -0,0 +1,182 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// how many of the most recent events a consumer can resume from
+	maxFeedEvents = 1000
+	// how many events a consumer can fall behind by before we end its stream, in which case it reconnects and resumes
+	subscriberBuffer = 256
+)
+
+// changeFeed numbers the events from BridgeStorage.Watch and keeps the most recent of them, so that a consumer of the
+// watch endpoint that reconnects can pick up where it left off instead of starting over with every key.
+//
+// Sequences start at the current time in nanoseconds, so one a consumer got from a prior storage-rest process is
+// never mistaken for one of ours, and that consumer gets every key again.
+type changeFeed struct {
+	st          types.BridgeStorage
+	mutex       sync.Mutex
+	sequence    int64
+	events      []types.WatchEvent
+	current     map[string]types.WatchEvent
+	synced      bool
+	subscribers map[chan types.WatchEvent]struct{}
+}
+
+func newChangeFeed(st types.BridgeStorage) *changeFeed {
+	return &changeFeed{
+		st:          st,
+		mutex:       sync.Mutex{},
+		sequence:    time.Now().UnixNano(),
+		current:     map[string]types.WatchEvent{},
+		subscribers: map[chan types.WatchEvent]struct{}{},
+	}
+}
+
+// run watches storage until ctx is done, starting the watch over whenever it ends
+func (f *changeFeed) run(ctx context.Context) {
+	wait.UntilWithContext(ctx, f.watch, 5*time.Second)
+}
+
+func (f *changeFeed) watch(ctx context.Context) {
+	ch, err := f.st.Watch(ctx)
+	if err != nil {
+		klog.Errorf("error watching storage: %s", err.Error())
+		return
+	}
+	// the watch starts with every key, which after the first watch we only pass on if it changed while we were not
+	// watching
+	seen := map[string]struct{}{}
+	syncing := true
+	for ev := range ch {
+		// the outbox and the audit log are ours alone; consumers only care about the models
+		if !isModelKey(ev.Key) {
+			continue
+		}
+		if !syncing {
+			f.publish(ev)
+			continue
+		}
+		if ev.Type == types.WatchEventSynced {
+			syncing = false
+			f.finishSync(seen)
+			continue
+		}
+		seen[ev.Key] = struct{}{}
+		f.mutex.Lock()
+		cur, ok := f.current[ev.Key]
+		f.mutex.Unlock()
+		if ok {
+			if cur.Revision == ev.Revision {
+				continue
+			}
+			ev.Type = types.WatchEventModified
+		}
+		f.publish(ev)
+	}
+	klog.Info("storage watch ended")
+}
+
+// finishSync removes the keys which were deleted while we were not watching
+func (f *changeFeed) finishSync(seen map[string]struct{}) {
+	f.mutex.Lock()
+	removed := []string{}
+	for key := range f.current {
+		if _, ok := seen[key]; !ok {
+			removed = append(removed, key)
+		}
+	}
+	f.mutex.Unlock()
+	sort.Strings(removed)
+	for _, key := range removed {
+		f.publish(types.WatchEvent{Type: types.WatchEventDeleted, Key: key})
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.synced = true
+}
+
+func (f *changeFeed) publish(ev types.WatchEvent) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.sequence++
+	ev.Sequence = f.sequence
+	f.events = append(f.events, ev)
+	if len(f.events) > maxFeedEvents {
+		f.events = f.events[len(f.events)-maxFeedEvents:]
+	}
+	if ev.Type == types.WatchEventDeleted {
+		delete(f.current, ev.Key)
+	} else {
+		f.current[ev.Key] = ev
+	}
+	for ch := range f.subscribers {
+		select {
+		case ch <- ev:
+		default:
+			klog.Warningf("ending watch for a consumer that fell more than %d events behind", subscriberBuffer)
+			delete(f.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// subscribe returns the events a consumer that last saw the since sequence missed, along with a channel for the
+// events from here on.  When we no longer have all of the events after since, or since is 0, the consumer gets an
+// ADDED event for every key followed by a SYNCED event instead.
+func (f *changeFeed) subscribe(since int64) ([]types.WatchEvent, chan types.WatchEvent, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if !f.synced {
+		return nil, nil, fmt.Errorf("the storage watch has not synced yet")
+	}
+	ch := make(chan types.WatchEvent, subscriberBuffer)
+	f.subscribers[ch] = struct{}{}
+
+	resumable := since > 0 && since <= f.sequence
+	if resumable && len(f.events) > 0 {
+		resumable = since >= f.events[0].Sequence-1
+	}
+	if resumable && len(f.events) == 0 {
+		resumable = since == f.sequence
+	}
+	if resumable {
+		i := sort.Search(len(f.events), func(i int) bool { return f.events[i].Sequence > since })
+		return append([]types.WatchEvent{}, f.events[i:]...), ch, nil
+	}
+
+	keys := make([]string, 0, len(f.current))
+	for key := range f.current {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	replay := make([]types.WatchEvent, 0, len(keys)+1)
+	for _, key := range keys {
+		ev := f.current[key]
+		ev.Type = types.WatchEventAdded
+		ev.Sequence = f.sequence
+		replay = append(replay, ev)
+	}
+	replay = append(replay, types.WatchEvent{Type: types.WatchEventSynced, Sequence: f.sequence})
+	return replay, ch, nil
+}
+
+func (f *changeFeed) unsubscribe(ch chan types.WatchEvent) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if _, ok := f.subscribers[ch]; ok {
+		delete(f.subscribers, ch)
+		close(ch)
+	}
+}
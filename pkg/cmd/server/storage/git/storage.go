@@ -0,0 +1,466 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/cmd/server/storage/watcher"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog/v2"
+)
+
+const (
+	defaultBranch          = "main"
+	defaultPathPrefix      = "model-catalog"
+	defaultAuthorName      = "rhdh-rhoai-bridge"
+	defaultAuthorEmail     = "rhdh-rhoai-bridge@localhost"
+	defaultUser            = "x-access-token"
+	defaultRefreshInterval = 10 * time.Second
+
+	metadataFileName = "metadata.json"
+	bodyFileName     = "body"
+)
+
+// GitBridgeStorage stores each key as a directory in a branch of a git repository, where the directory holds the
+// model's catalog content in a 'body' file and the remaining StorageBody fields in a 'metadata.json' file.  Every
+// mutation is a commit pushed to the remote, so the catalog state can be reviewed with the usual git tooling and
+// survives the cluster the bridge runs on.
+//
+// We shell out to the git CLI vs. pulling in a pure golang git implementation, which means any remote the CLI
+// can reach works (GitHub, GitLab, a local bare repository, etc.).
+type GitBridgeStorage struct {
+	remote          string
+	branch          string
+	pathPrefix      string
+	authorName      string
+	authorEmail     string
+	user            string
+	token           string
+	workDir         string
+	refreshInterval time.Duration
+	lastRefresh     time.Time
+	mutex           sync.Mutex
+}
+
+func NewGitBridgeStorageForTest(remote, branch, pathPrefix, workDir string) *GitBridgeStorage {
+	return &GitBridgeStorage{
+		remote:     remote,
+		branch:     branch,
+		pathPrefix: pathPrefix,
+		workDir:    workDir,
+		mutex:      sync.Mutex{},
+	}
+}
+
+func (g *GitBridgeStorage) Initialize(cfg *rest.Config) error {
+	// no k8s access is needed; settings explicitly provided (i.e. unit tests) take precedence over the env vars
+	r := strings.NewReplacer("\r", "", "\n", "")
+	setting := func(current, envVar, defaultValue string) string {
+		if len(current) > 0 {
+			return current
+		}
+		v := strings.TrimSpace(r.Replace(os.Getenv(envVar)))
+		if len(v) > 0 {
+			return v
+		}
+		return defaultValue
+	}
+	g.remote = setting(g.remote, types.GitStorageRemoteEnvVar, "")
+	g.branch = setting(g.branch, types.GitStorageBranchEnvVar, defaultBranch)
+	g.pathPrefix = strings.Trim(setting(g.pathPrefix, types.GitStoragePathPrefixEnvVar, defaultPathPrefix), "/")
+	g.authorName = setting(g.authorName, types.GitStorageAuthorNameEnvVar, defaultAuthorName)
+	g.authorEmail = setting(g.authorEmail, types.GitStorageAuthorEmailEnvVar, defaultAuthorEmail)
+	g.user = setting(g.user, types.GitStorageUserEnvVar, defaultUser)
+	g.token = setting(g.token, types.GitStorageTokenEnvVar, "")
+	g.workDir = setting(g.workDir, types.GitStorageWorkDirEnvVar, "")
+	if g.refreshInterval == 0 {
+		g.refreshInterval = defaultRefreshInterval
+		interval := os.Getenv(types.GitStorageRefreshIntervalEnvVar)
+		d, err := time.ParseDuration(interval)
+		if err == nil && len(interval) > 0 {
+			g.refreshInterval = d
+		}
+	}
+
+	if len(g.remote) == 0 {
+		return fmt.Errorf("the %s env var must be set when using the git storage type", types.GitStorageRemoteEnvVar)
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		return fmt.Errorf("the git storage type requires the git CLI: %s", err.Error())
+	}
+
+	var err error
+	if len(g.workDir) == 0 {
+		g.workDir, err = os.MkdirTemp("", "model-catalog-bridge-git-")
+		if err != nil {
+			return err
+		}
+	}
+	err = os.MkdirAll(g.workDir, 0o755)
+	if err != nil {
+		return err
+	}
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	_, err = os.Stat(filepath.Join(g.workDir, ".git"))
+	switch {
+	case os.IsNotExist(err):
+		if _, err = g.git("init", "-q"); err != nil {
+			return err
+		}
+		if _, err = g.git("remote", "add", "origin", g.remote); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	default:
+		if _, err = g.git("remote", "set-url", "origin", g.remote); err != nil {
+			return err
+		}
+	}
+	// point HEAD at our branch so the first commit against an empty remote lands on the right branch
+	if _, err = g.git("symbolic-ref", "HEAD", "refs/heads/"+g.branch); err != nil {
+		return err
+	}
+	klog.Infof("git storage using remote %s branch %s path prefix %s work dir %s", g.redactedRemote(), g.branch, g.pathPrefix, g.workDir)
+	return g.sync()
+}
+
+func (g *GitBridgeStorage) Upsert(key string, value types.StorageBody, expectedRevision string) (string, error) {
+	if err := validateKey(key); err != nil {
+		return "", err
+	}
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	err := g.mutate(fmt.Sprintf("upsert %s", key), func() error {
+		current, err := g.revision(key)
+		if err != nil {
+			return err
+		}
+		err = types.CheckRevision(key, expectedRevision, current)
+		if err != nil {
+			return err
+		}
+		dir := g.keyDir(key)
+		err = os.MkdirAll(dir, 0o755)
+		if err != nil {
+			return err
+		}
+		err = os.WriteFile(filepath.Join(dir, bodyFileName), value.Body, 0o644)
+		if err != nil {
+			return err
+		}
+		value.Body = nil
+		var buf []byte
+		buf, err = json.MarshalIndent(value, "", "  ")
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(dir, metadataFileName), append(buf, '\n'), 0o644)
+	})
+	if err != nil {
+		return "", err
+	}
+	return g.revision(key)
+}
+
+func (g *GitBridgeStorage) Fetch(key string) (types.StorageBody, string, error) {
+	sb := types.StorageBody{}
+	if err := validateKey(key); err != nil {
+		return sb, "", err
+	}
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if err := g.refresh(); err != nil {
+		return sb, "", err
+	}
+	sb, err := g.read(key)
+	if err != nil {
+		return sb, "", err
+	}
+	rev, err := g.revision(key)
+	return sb, rev, err
+}
+
+func (g *GitBridgeStorage) Remove(key string, expectedRevision string) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.mutate(fmt.Sprintf("remove %s", key), func() error {
+		current, err := g.revision(key)
+		if err != nil || len(current) == 0 {
+			return err
+		}
+		err = types.CheckRevision(key, expectedRevision, current)
+		if err != nil {
+			return err
+		}
+		return os.RemoveAll(g.keyDir(key))
+	})
+}
+
+// Watch polls the remote at the refresh interval
+func (g *GitBridgeStorage) Watch(ctx context.Context) (<-chan types.WatchEvent, error) {
+	return watcher.Start(ctx, g.revisions, g.Fetch, watcher.Ticker(ctx, g.refreshInterval))
+}
+
+// revisions gets the revision of every key with a single 'ls-tree'
+func (g *GitBridgeStorage) revisions() (map[string]string, error) {
+	revisions := map[string]string{}
+	keys, err := g.List()
+	if err != nil || len(keys) == 0 {
+		// no keys also means there may not be a HEAD for 'ls-tree' yet
+		return revisions, err
+	}
+	present := map[string]struct{}{}
+	for _, key := range keys {
+		present[key] = struct{}{}
+	}
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	// -z so that paths are not quoted
+	out, err := g.git("ls-tree", "-z", "HEAD", "--", g.pathPrefix+"/")
+	if err != nil {
+		return revisions, err
+	}
+	for _, line := range strings.Split(out, "\x00") {
+		// each entry is '<mode> tree <hash>\t<path>'
+		meta, p, ok := strings.Cut(line, "\t")
+		fields := strings.Fields(meta)
+		if !ok || len(fields) < 3 || fields[1] != "tree" {
+			continue
+		}
+		key := path.Base(p)
+		if _, ok = present[key]; ok {
+			revisions[key] = fields[2]
+		}
+	}
+	return revisions, nil
+}
+
+func (g *GitBridgeStorage) List() ([]string, error) {
+	keys := []string{}
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if err := g.refresh(); err != nil {
+		return keys, err
+	}
+	entries, err := os.ReadDir(filepath.Join(g.workDir, g.pathPrefix))
+	if os.IsNotExist(err) {
+		return keys, nil
+	}
+	if err != nil {
+		return keys, err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if _, err = os.Stat(filepath.Join(g.keyDir(entry.Name()), metadataFileName)); err != nil {
+			continue
+		}
+		keys = append(keys, entry.Name())
+	}
+	return keys, nil
+}
+
+func (g *GitBridgeStorage) read(key string) (types.StorageBody, error) {
+	sb := types.StorageBody{}
+	dir := g.keyDir(key)
+	buf, err := os.ReadFile(filepath.Join(dir, metadataFileName))
+	if os.IsNotExist(err) {
+		// same as the ConfigMap storage, a missing key is not an error
+		return sb, nil
+	}
+	if err != nil {
+		return sb, err
+	}
+	err = json.Unmarshal(buf, &sb)
+	if err != nil {
+		return sb, err
+	}
+	sb.Body, err = os.ReadFile(filepath.Join(dir, bodyFileName))
+	if os.IsNotExist(err) {
+		err = nil
+	}
+	return sb, err
+}
+
+// revision is the hash of the key's folder in the last commit, which only changes when the key's files do; it must
+// be called right after a sync or a commit, when the work tree matches HEAD
+func (g *GitBridgeStorage) revision(key string) (string, error) {
+	if _, err := os.Stat(filepath.Join(g.keyDir(key), metadataFileName)); os.IsNotExist(err) {
+		return "", nil
+	}
+	out, err := g.git("ls-tree", "HEAD", "--", path.Join(g.pathPrefix, key))
+	if err != nil {
+		return "", err
+	}
+	// the output is '<mode> tree <hash>\t<path>'
+	fields := strings.Fields(out)
+	if len(fields) < 3 {
+		return "", nil
+	}
+	return fields[2], nil
+}
+
+// mutate applies a change to the work tree on top of the latest remote commit, and then commits and pushes it; if
+// the push is rejected because another replica pushed first, we pull that replica's commit and re-apply our change
+func (g *GitBridgeStorage) mutate(msg string, apply func() error) error {
+	var lastErr error
+	backoff := wait.Backoff{Steps: 5, Duration: 200 * time.Millisecond, Factor: 2.0, Jitter: 0.1}
+	err := wait.ExponentialBackoff(backoff, func() (bool, error) {
+		err := g.sync()
+		if err != nil {
+			return false, err
+		}
+		err = apply()
+		if err != nil {
+			return false, err
+		}
+		changed := false
+		changed, err = g.commit(msg)
+		if err != nil {
+			return false, err
+		}
+		if !changed {
+			return true, nil
+		}
+		_, lastErr = g.git("push", "-q", "origin", "HEAD:refs/heads/"+g.branch)
+		if lastErr != nil {
+			klog.Warningf("git storage push for '%s' failed, will retry: %s", msg, lastErr.Error())
+			return false, nil
+		}
+		return true, nil
+	})
+	if wait.Interrupted(err) && lastErr != nil {
+		return fmt.Errorf("git storage could not push '%s': %s", msg, lastErr.Error())
+	}
+	return err
+}
+
+func (g *GitBridgeStorage) commit(msg string) (bool, error) {
+	var err error
+	if _, serr := os.Stat(filepath.Join(g.workDir, g.pathPrefix)); os.IsNotExist(serr) {
+		// removing the last key removes the prefix dir as well, and 'add' fails on a path spec that matches nothing
+		_, err = g.git("rm", "-r", "-q", "--cached", "--ignore-unmatch", "--", g.pathPrefix)
+	} else {
+		_, err = g.git("add", "-A", "--", g.pathPrefix)
+	}
+	if err != nil {
+		return false, err
+	}
+	// 'diff --cached --quiet' exits with 1 when there are staged changes
+	_, err = g.git("diff", "--cached", "--quiet")
+	if err == nil {
+		return false, nil
+	}
+	author := fmt.Sprintf("%s <%s>", g.authorName, g.authorEmail)
+	_, err = g.git("commit", "-q", "--no-verify", "--author", author, "-m", msg)
+	return err == nil, err
+}
+
+// refresh syncs with the remote if we have not done so within the refresh interval, so that reads see what other
+// replicas have pushed without a network round trip on every read
+func (g *GitBridgeStorage) refresh() error {
+	if time.Since(g.lastRefresh) < g.refreshInterval {
+		return nil
+	}
+	return g.sync()
+}
+
+// sync resets the work tree to the tip of the remote branch, discarding any local commits that failed to push
+func (g *GitBridgeStorage) sync() error {
+	out, err := g.git("ls-remote", "--heads", "origin", "refs/heads/"+g.branch)
+	if err != nil {
+		return err
+	}
+	if len(strings.TrimSpace(out)) == 0 {
+		// nothing has been pushed to the branch yet
+		g.lastRefresh = time.Now()
+		return nil
+	}
+	_, err = g.git("fetch", "-q", "origin", fmt.Sprintf("+refs/heads/%s:refs/remotes/origin/%s", g.branch, g.branch))
+	if err != nil {
+		return err
+	}
+	_, err = g.git("reset", "-q", "--hard", "refs/remotes/origin/"+g.branch)
+	if err != nil {
+		return err
+	}
+	_, err = g.git("clean", "-q", "-fd", "--", g.pathPrefix)
+	if err != nil {
+		return err
+	}
+	g.lastRefresh = time.Now()
+	return nil
+}
+
+func (g *GitBridgeStorage) git(args ...string) (string, error) {
+	cmd := g.command(args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err != nil {
+		return stdout.String(), fmt.Errorf("git %s failed: %s: %s", args[0], err.Error(), strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// command builds the git command; the token is passed as configuration in the environment, rather than with -c, so
+// that it does not show up in the process list
+func (g *GitBridgeStorage) command(args ...string) *exec.Cmd {
+	cfgArgs := []string{
+		"-c", "user.name=" + g.authorName,
+		"-c", "user.email=" + g.authorEmail,
+		"-c", "commit.gpgsign=false",
+	}
+	cmd := exec.Command("git", append(cfgArgs, args...)...)
+	cmd.Dir = g.workDir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if len(g.token) > 0 {
+		creds := base64.StdEncoding.EncodeToString([]byte(g.user + ":" + g.token))
+		cmd.Env = append(cmd.Env,
+			"GIT_CONFIG_COUNT=1",
+			"GIT_CONFIG_KEY_0=http.extraHeader",
+			"GIT_CONFIG_VALUE_0=Authorization: Basic "+creds)
+	}
+	return cmd
+}
+
+func (g *GitBridgeStorage) keyDir(key string) string {
+	return filepath.Join(g.workDir, g.pathPrefix, key)
+}
+
+func (g *GitBridgeStorage) redactedRemote() string {
+	i := strings.Index(g.remote, "@")
+	j := strings.Index(g.remote, "://")
+	if i > 0 && j > 0 && j < i {
+		return g.remote[:j+3] + "***" + g.remote[i:]
+	}
+	return g.remote
+}
+
+// validateKey makes sure a key maps to exactly one directory under the path prefix
+func validateKey(key string) error {
+	if len(key) == 0 || key == "." || key == ".." || strings.ContainsAny(key, `/\`) {
+		return fmt.Errorf("invalid git storage key %q", key)
+	}
+	return nil
+}
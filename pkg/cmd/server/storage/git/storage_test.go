@@ -0,0 +1,157 @@
+package git
+
+import (
+	"encoding/base64"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/types"
+	"github.com/redhat-ai-dev/model-catalog-bridge/test/stub/common"
+	"github.com/redhat-ai-dev/model-catalog-bridge/test/stub/storage/conformance"
+)
+
+func setupBareRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git CLI not available")
+	}
+	remote := filepath.Join(t.TempDir(), "remote.git")
+	out, err := exec.Command("git", "init", "-q", "--bare", remote).CombinedOutput()
+	if err != nil {
+		t.Fatalf("git init --bare failed: %s: %s", err.Error(), string(out))
+	}
+	return remote
+}
+
+func remoteLog(t *testing.T, remote, branch string) []string {
+	t.Helper()
+	out, err := exec.Command("git", "--git-dir", remote, "log", "--format=%an|%s", branch).CombinedOutput()
+	common.AssertError(t, err)
+	return strings.Split(strings.TrimSpace(string(out)), "\n")
+}
+
+func TestGitBridgeStorage(t *testing.T) {
+	remote := setupBareRepo(t)
+
+	st := NewGitBridgeStorageForTest(remote, "catalog", "models", t.TempDir())
+	err := st.Initialize(nil)
+	common.AssertError(t, err)
+
+	// empty remote
+	keys, err := st.List()
+	common.AssertError(t, err)
+	common.AssertEqual(t, 0, len(keys))
+	sb, _, err := st.Fetch("mnist_v1")
+	common.AssertError(t, err)
+	common.AssertEqual(t, 0, len(sb.Body))
+
+	for _, tc := range []struct {
+		name string
+		key  string
+		body types.StorageBody
+	}{
+		{
+			name: "new entry",
+			key:  "mnist_v1",
+			body: types.StorageBody{Body: []byte("create"), ReconcilerType: types.KubeflowNormalizer, LastUpdateTimeSinceEpoch: "1"},
+		},
+		{
+			name: "updated entry",
+			key:  "mnist_v1",
+			body: types.StorageBody{Body: []byte("update"), LocationId: "loc-id", LocationTarget: "http://foo.com/mnist/v1/catalog-info.yaml", ReconcilerType: types.KubeflowNormalizer, LastUpdateTimeSinceEpoch: "2"},
+		},
+		{
+			name: "second entry",
+			key:  "mnist_v2",
+			body: types.StorageBody{Body: []byte("create"), ReconcilerType: types.KServeNormalizer},
+		},
+	} {
+		_, err = st.Upsert(tc.key, tc.body, types.AnyRevision)
+		common.AssertError(t, err)
+		sb, _, err = st.Fetch(tc.key)
+		common.AssertError(t, err)
+		common.AssertEqual(t, tc.body, sb)
+	}
+
+	// an identical upsert does not produce an empty commit
+	_, err = st.Upsert("mnist_v2", types.StorageBody{Body: []byte("create"), ReconcilerType: types.KServeNormalizer}, types.AnyRevision)
+	common.AssertError(t, err)
+	common.AssertEqual(t, []string{
+		"rhdh-rhoai-bridge|upsert mnist_v2",
+		"rhdh-rhoai-bridge|upsert mnist_v1",
+		"rhdh-rhoai-bridge|upsert mnist_v1",
+	}, remoteLog(t, remote, "catalog"))
+
+	// a second replica with its own work dir sees the same content
+	st2 := NewGitBridgeStorageForTest(remote, "catalog", "models", t.TempDir())
+	err = st2.Initialize(nil)
+	common.AssertError(t, err)
+	keys, err = st2.List()
+	common.AssertError(t, err)
+	sort.Strings(keys)
+	common.AssertEqual(t, []string{"mnist_v1", "mnist_v2"}, keys)
+	sb, _, err = st2.Fetch("mnist_v1")
+	common.AssertError(t, err)
+	common.AssertEqual(t, "update", string(sb.Body))
+	common.AssertEqual(t, "loc-id", sb.LocationId)
+
+	// writes from either replica land on top of the other replica's latest commit
+	err = st.Remove("mnist_v1", types.AnyRevision)
+	common.AssertError(t, err)
+	_, err = st2.Upsert("mnist_v3", types.StorageBody{Body: []byte("create")}, types.AnyRevision)
+	common.AssertError(t, err)
+
+	st2.lastRefresh = st2.lastRefresh.Add(-st2.refreshInterval)
+	keys, err = st2.List()
+	common.AssertError(t, err)
+	sort.Strings(keys)
+	common.AssertEqual(t, []string{"mnist_v2", "mnist_v3"}, keys)
+
+	err = st.Remove("bad/key", types.AnyRevision)
+	if err == nil {
+		t.Error("expected error for key with a path separator")
+	}
+}
+
+func TestGitBridgeStorageRevisions(t *testing.T) {
+	st := NewGitBridgeStorageForTest(setupBareRepo(t), "catalog", "models", t.TempDir())
+	err := st.Initialize(nil)
+	common.AssertError(t, err)
+	conformance.AssertRevisionSemantics(t, st)
+}
+
+func TestGitBridgeStorageRoundTrip(t *testing.T) {
+	st := NewGitBridgeStorageForTest(setupBareRepo(t), "catalog", "models", t.TempDir())
+	err := st.Initialize(nil)
+	common.AssertError(t, err)
+	conformance.AssertRoundTrip(t, st)
+}
+
+func TestGitBridgeStorageWatch(t *testing.T) {
+	st := NewGitBridgeStorageForTest(setupBareRepo(t), "catalog", "models", t.TempDir())
+	st.refreshInterval = 50 * time.Millisecond
+	err := st.Initialize(nil)
+	common.AssertError(t, err)
+	conformance.AssertWatch(t, st)
+}
+
+func TestGitBridgeStorageTokenNotOnCommandLine(t *testing.T) {
+	st := NewGitBridgeStorageForTest(setupBareRepo(t), "catalog", "models", t.TempDir())
+	st.user = "bridge"
+	st.token = "s3cr3t"
+	creds := base64.StdEncoding.EncodeToString([]byte("bridge:s3cr3t"))
+	cmd := st.command("config", "--get", "http.extraHeader")
+	for _, arg := range cmd.Args {
+		if strings.Contains(arg, "s3cr3t") || strings.Contains(arg, creds) {
+			t.Errorf("the token is in the git arguments %v", cmd.Args)
+		}
+	}
+	// git still picks the header up from the environment
+	out, err := st.git("config", "--get", "http.extraHeader")
+	common.AssertError(t, err)
+	common.AssertEqual(t, "Authorization: Basic "+creds, strings.TrimSpace(out))
+}
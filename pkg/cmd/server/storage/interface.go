@@ -2,9 +2,13 @@ package storage
 
 import (
 	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/cmd/server/storage/configmap"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/cmd/server/storage/customresource"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/cmd/server/storage/git"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/cmd/server/storage/sqlite"
 	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/config"
 	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/types"
 	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/util"
+	"k8s.io/klog/v2"
 )
 
 func NewBridgeStorage(storageType types.BridgeStorageType) types.BridgeStorage {
@@ -19,7 +23,34 @@ func NewBridgeStorage(storageType types.BridgeStorageType) types.BridgeStorage {
 		}
 		st.Initialize(cfg)
 		return &st
-	case types.GithubBridgeStorage:
+	case types.GithubBridgeStorage, types.GitBridgeStorage:
+		st := &git.GitBridgeStorage{}
+		err := st.Initialize(nil)
+		if err != nil {
+			klog.Errorf("error initializing git storage: %s", err.Error())
+			return nil
+		}
+		return st
+	case types.CustomResourceBridgeStorage:
+		st := &customresource.CustomResourceBridgeStorage{}
+		cfg, err := util.GetK8sConfig(&config.Config{})
+		if err != nil {
+			klog.Errorf("error getting k8s config for custom resource storage: %s", err.Error())
+			return nil
+		}
+		err = st.Initialize(cfg)
+		if err != nil {
+			klog.Errorf("error initializing custom resource storage: %s", err.Error())
+			return nil
+		}
+		return st
+	case types.SQLiteBridgeStorage:
+		st := &sqlite.SQLiteBridgeStorage{}
+		err := st.Initialize(nil)
+		if err != nil {
+			klog.Errorf("error initializing sqlite storage: %s", err.Error())
+			return nil
+		}
+		return st
 	}
-	return nil
 }
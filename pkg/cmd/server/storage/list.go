@@ -0,0 +1,178 @@
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/cmd/cli/backstage"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/types"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/util"
+	"k8s.io/klog/v2"
+)
+
+const (
+	defaultListLimit = 100
+	maxListLimit     = 1000
+)
+
+// KeyMetadata is what storage has for a model, short of its content
+type KeyMetadata struct {
+	Key            string `json:"key"`
+	ReconcilerType string `json:"reconcilerType,omitempty"`
+	Source         string `json:"source,omitempty"`
+	// Registry is the model registry of the source, empty for sources other than a model registry
+	Registry                 string `json:"registry,omitempty"`
+	LastUpdateTimeSinceEpoch string `json:"lastUpdateTimeSinceEpoch,omitempty"`
+	LocationId               string `json:"locationId,omitempty"`
+	LocationTarget           string `json:"locationTarget,omitempty"`
+	LocationIDValid          bool   `json:"locationIDValid"`
+	BodySize                 int    `json:"bodySize"`
+	Digest                   string `json:"digest,omitempty"`
+	LastPushResult           string `json:"lastPushResult,omitempty"`
+	ModelCardKey             string `json:"modelCardKey,omitempty"`
+	// Owners and Lifecycles are those set in the content
+	Owners     []string           `json:"owners,omitempty"`
+	Lifecycles []string           `json:"lifecycles,omitempty"`
+	Invalid    []types.FieldError `json:"invalid,omitempty"`
+	Tombstone  *types.Tombstone   `json:"tombstone,omitempty"`
+}
+
+// MetadataListResponse is a page of KeyMetadata, in key order; Continue, when set, is passed as the 'continue' query
+// parameter to get the next page
+type MetadataListResponse struct {
+	Items    []KeyMetadata `json:"items"`
+	Continue string        `json:"continue,omitempty"`
+}
+
+// metadataFilter holds the query parameters a KeyMetadata has to match, where an empty one matches anything
+type metadataFilter struct {
+	reconcilerType string
+	registry       string
+	owner          string
+	lifecycle      string
+}
+
+func (f metadataFilter) matches(m *KeyMetadata) bool {
+	switch {
+	case len(f.reconcilerType) > 0 && f.reconcilerType != m.ReconcilerType:
+		return false
+	case len(f.registry) > 0 && f.registry != m.Registry:
+		return false
+	case len(f.owner) > 0 && !slices.Contains(m.Owners, f.owner):
+		return false
+	case len(f.lifecycle) > 0 && !slices.Contains(m.Lifecycles, f.lifecycle):
+		return false
+	}
+	return true
+}
+
+func (s *StorageRESTServer) keyMetadata(key string, sb *types.StorageBody) KeyMetadata {
+	m := KeyMetadata{
+		Key:                      key,
+		ReconcilerType:           sb.ReconcilerType,
+		Source:                   sb.Source,
+		LastUpdateTimeSinceEpoch: sb.LastUpdateTimeSinceEpoch,
+		LocationId:               sb.LocationId,
+		LocationTarget:           sb.LocationTarget,
+		LocationIDValid:          sb.LocationIDValid,
+		BodySize:                 len(sb.Body),
+		Digest:                   sb.Digest,
+		LastPushResult:           sb.LastPushResult,
+		ModelCardKey:             sb.ModelCardKey,
+		Invalid:                  sb.Invalid,
+		Tombstone:                sb.Tombstone,
+	}
+	_, m.Registry, _ = strings.Cut(sb.Source, "/")
+	if len(sb.Body) > 0 {
+		var err error
+		m.Owners, m.Lifecycles, err = backstage.OwnersAndLifecycles(sb.Body, s.format)
+		if err != nil {
+			klog.V(4).Infof("could not read the owners and lifecycles of key %s: %s", key, err.Error())
+		}
+	}
+	return m
+}
+
+// handleCatalogListMetadata lists what storage has for each model, short of its content, a page at a time.  The
+// 'type', 'registry', 'owner' and 'lifecycle' query parameters narrow down the models listed, 'limit' sets the most
+// listed per page, and 'continue' picks up after the previous page.
+func (s *StorageRESTServer) handleCatalogListMetadata(c *gin.Context) {
+	limit := defaultListLimit
+	if v := c.Query(util.LimitQueryParam); len(v) > 0 {
+		var err error
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > maxListLimit {
+			c.Status(http.StatusBadRequest)
+			c.Error(fmt.Errorf("the 'limit' parameter %q is not a number between 1 and %d", v, maxListLimit))
+			return
+		}
+	}
+	after := ""
+	if v := c.Query(util.ContinueQueryParam); len(v) > 0 {
+		buf, err := base64.RawURLEncoding.DecodeString(v)
+		if err != nil || len(buf) == 0 {
+			c.Status(http.StatusBadRequest)
+			c.Error(fmt.Errorf("the 'continue' parameter %q was not returned by a previous list", v))
+			return
+		}
+		after = string(buf)
+	}
+	filter := metadataFilter{
+		reconcilerType: c.Query(util.TypeQueryParam),
+		registry:       c.Query(util.RegistryQueryParam),
+		owner:          c.Query(util.OwnerQueryParam),
+		lifecycle:      c.Query(util.LifecycleQueryParam),
+	}
+
+	keys, err := s.st.List()
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		msg := fmt.Sprintf("error listing location keys: %s", err.Error())
+		klog.Error(msg)
+		c.Error(fmt.Errorf("error listing location keys: %s", err.Error()))
+		return
+	}
+	keys = modelKeys(keys)
+	sort.Strings(keys)
+	resp := &MetadataListResponse{Items: []KeyMetadata{}}
+	for i := sort.SearchStrings(keys, after); i < len(keys); i++ {
+		key := keys[i]
+		if key == after {
+			continue
+		}
+		if len(resp.Items) == limit {
+			resp.Continue = base64.RawURLEncoding.EncodeToString([]byte(resp.Items[limit-1].Key))
+			break
+		}
+		sb, rev, err := s.st.Fetch(key)
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			msg := fmt.Sprintf("error fetching key %s: %s", key, err.Error())
+			klog.Error(msg)
+			c.Error(fmt.Errorf("error fetching key %s: %s", key, err.Error()))
+			return
+		}
+		if len(rev) == 0 {
+			// removed since we listed the keys
+			continue
+		}
+		m := s.keyMetadata(key, &sb)
+		if filter.matches(&m) {
+			resp.Items = append(resp.Items, m)
+		}
+	}
+	content, err := json.Marshal(resp)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		c.Error(err)
+		return
+	}
+	c.Data(http.StatusOK, "Content-Type: application/json", content)
+}
@@ -0,0 +1,364 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/rest"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/types"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/util"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// how often we look for outbox entries which are due
+	outboxInterval = 10 * time.Second
+	// the wait before the first retry, which doubles with each attempt up to outboxMaxBackoff
+	outboxInitialBackoff = 10 * time.Second
+	outboxMaxBackoff     = 10 * time.Minute
+	// how many times an operation is tried before it is dead-lettered, unless BACKSTAGE_OUTBOX_MAX_ATTEMPTS says otherwise
+	defaultOutboxMaxAttempts = 10
+)
+
+// values for OutboxEntry.Op
+const (
+	OutboxOpImport = "import"
+	OutboxOpDelete = "delete"
+)
+
+// OutboxEntry is a Backstage location import or delete which failed, and is retried, with exponential backoff, until it
+// succeeds or is dead-lettered.  Each entry is kept in storage under its own key, so that it survives a restart and is
+// retried by whichever replica gets to it first.
+type OutboxEntry struct {
+	ID string `json:"id"`
+	Op string `json:"op"`
+	// Key is the storage key of the model the operation is for
+	Key string `json:"key"`
+	// Target is the location service URL to import
+	Target string `json:"target,omitempty"`
+	// LocationId is the Backstage location to delete
+	LocationId  string    `json:"locationId,omitempty"`
+	Attempts    int       `json:"attempts"`
+	NextAttempt time.Time `json:"nextAttempt"`
+	LastError   string    `json:"lastError,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+	// DeadLettered is set once the operation has failed the maximum number of times, after which it is only tried
+	// again when replayed
+	DeadLettered bool `json:"deadLettered"`
+}
+
+type OutboxResponse struct {
+	Entries []OutboxEntry `json:"entries"`
+}
+
+func outboxMaxAttemptsFromEnv() int {
+	v := strings.NewReplacer("\r", "", "\n", "").Replace(os.Getenv(types.BackstageOutboxMaxAttemptsEnvVar))
+	if len(v) == 0 {
+		return defaultOutboxMaxAttempts
+	}
+	attempts, err := strconv.Atoi(v)
+	if err != nil || attempts < 1 {
+		klog.Errorf("ignoring %s setting %q as it is not a positive number", types.BackstageOutboxMaxAttemptsEnvVar, v)
+		return defaultOutboxMaxAttempts
+	}
+	return attempts
+}
+
+// isOutboxKey says whether the storage key holds an outbox entry rather than a model
+func isOutboxKey(key string) bool {
+	return strings.HasPrefix(key, util.OutboxKeyPrefix)
+}
+
+// isModelKey says whether the storage key holds a model, rather than an outbox entry or an audit log
+func isModelKey(key string) bool {
+	return !isOutboxKey(key) && !isAuditKey(key)
+}
+
+// modelKeys drops the outbox entries and audit logs from the keys in storage
+func modelKeys(keys []string) []string {
+	models := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if isModelKey(k) {
+			models = append(models, k)
+		}
+	}
+	return models
+}
+
+// outboxBackoff is how long to wait after the given number of failed attempts
+func outboxBackoff(attempts int) time.Duration {
+	backoff := outboxInitialBackoff
+	for i := 1; i < attempts && backoff < outboxMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > outboxMaxBackoff {
+		backoff = outboxMaxBackoff
+	}
+	return backoff
+}
+
+func (s *StorageRESTServer) maxOutboxAttempts() int {
+	if s.outboxMaxAttempts > 0 {
+		return s.outboxMaxAttempts
+	}
+	return defaultOutboxMaxAttempts
+}
+
+// enqueue adds the operation to the outbox, unless the same operation is already there, in which case it carries on
+// with the backoff it has
+func (s *StorageRESTServer) enqueue(op, key, target, locationId string, cause error) {
+	id := op + "_" + key
+	if op == OutboxOpDelete {
+		id = op + "_" + locationId
+	}
+	now := time.Now().UTC()
+	e := OutboxEntry{ID: id, Op: op, Key: key, Target: target, LocationId: locationId, Attempts: 1, NextAttempt: now.Add(outboxBackoff(1)), CreatedAt: now}
+	if cause != nil {
+		e.LastError = cause.Error()
+	}
+	_, err := s.storeOutboxEntry(e, "")
+	switch {
+	case types.IsConflict(err):
+		klog.V(4).Infof("backstage %s for key %s is already in the outbox", op, key)
+	case err != nil:
+		klog.Errorf("error adding backstage %s for key %s to the outbox: %s", op, key, err.Error())
+	default:
+		klog.Infof("added backstage %s for key %s to the outbox, to be retried at %s", op, key, e.NextAttempt.Format(time.RFC3339))
+	}
+}
+
+func (s *StorageRESTServer) storeOutboxEntry(e OutboxEntry, rev string) (string, error) {
+	buf, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	return s.st.Upsert(util.OutboxKeyPrefix+e.ID, types.StorageBody{Body: buf}, rev)
+}
+
+// fetchOutboxEntry returns the entry stored under the key along with its revision, which is empty if it is gone
+func (s *StorageRESTServer) fetchOutboxEntry(key string) (OutboxEntry, string, error) {
+	e := OutboxEntry{}
+	sb, rev, err := s.st.Fetch(key)
+	if err != nil || len(rev) == 0 {
+		return e, rev, err
+	}
+	err = json.Unmarshal(sb.Body, &e)
+	return e, rev, err
+}
+
+func (s *StorageRESTServer) listOutbox() ([]OutboxEntry, map[string]string, error) {
+	keys, err := s.st.List()
+	if err != nil {
+		return nil, nil, err
+	}
+	entries := []OutboxEntry{}
+	revs := map[string]string{}
+	for _, k := range keys {
+		if !isOutboxKey(k) {
+			continue
+		}
+		e, rev, err := s.fetchOutboxEntry(k)
+		if err != nil {
+			klog.Errorf("error reading outbox entry %s: %s", k, err.Error())
+			continue
+		}
+		if len(rev) == 0 {
+			continue
+		}
+		entries = append(entries, e)
+		revs[e.ID] = rev
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].CreatedAt.Equal(entries[j].CreatedAt) {
+			return entries[i].ID < entries[j].ID
+		}
+		return entries[i].CreatedAt.Before(entries[j].CreatedAt)
+	})
+	return entries, revs, nil
+}
+
+// processOutbox retries the outbox entries which are due.  An entry is claimed by storing its next attempt before it is
+// tried, so another replica leaves it alone, and it is only removed once the operation succeeds, so an operation is
+// tried at least once more should we stop part way through.
+func (s *StorageRESTServer) processOutbox(ctx context.Context) {
+	if !s.setupBkstg() {
+		return
+	}
+	entries, revs, err := s.listOutbox()
+	if err != nil {
+		klog.Errorf("error listing the outbox: %s", err.Error())
+		return
+	}
+	now := time.Now().UTC()
+	for _, e := range entries {
+		if ctx.Err() != nil {
+			return
+		}
+		if e.DeadLettered || now.Before(e.NextAttempt) {
+			continue
+		}
+		e.Attempts++
+		e.NextAttempt = now.Add(outboxBackoff(e.Attempts))
+		rev, err := s.storeOutboxEntry(e, revs[e.ID])
+		if types.IsConflict(err) {
+			continue
+		}
+		if err != nil {
+			klog.Errorf("error claiming outbox entry %s: %s", e.ID, err.Error())
+			continue
+		}
+
+		err = s.performOutboxEntry(e)
+		if err == nil {
+			klog.Infof("backstage %s for key %s succeeded after %d attempts", e.Op, e.Key, e.Attempts)
+			err = s.st.Remove(util.OutboxKeyPrefix+e.ID, rev)
+			if err != nil && !types.IsConflict(err) {
+				klog.Errorf("error removing outbox entry %s: %s", e.ID, err.Error())
+			}
+			continue
+		}
+		e.LastError = err.Error()
+		if e.Attempts >= s.maxOutboxAttempts() {
+			e.DeadLettered = true
+			klog.Errorf("dead-lettering backstage %s for key %s after %d attempts: %s", e.Op, e.Key, e.Attempts, e.LastError)
+		} else {
+			klog.Warningf("backstage %s for key %s failed on attempt %d, retrying at %s: %s", e.Op, e.Key, e.Attempts, e.NextAttempt.Format(time.RFC3339), e.LastError)
+		}
+		_, err = s.storeOutboxEntry(e, rev)
+		if err != nil && !types.IsConflict(err) {
+			klog.Errorf("error updating outbox entry %s: %s", e.ID, err.Error())
+		}
+	}
+}
+
+// performOutboxEntry tries the operation once; an import which is no longer needed, as the model is gone or has a
+// location by now, succeeds without calling Backstage
+func (s *StorageRESTServer) performOutboxEntry(e OutboxEntry) error {
+	switch e.Op {
+	case OutboxOpDelete:
+		msg, err := s.bkstg.DeleteLocation(e.LocationId)
+		s.audit(systemActor, AuditEntry{Action: AuditActionDelete, Key: e.Key, Reason: outboxReason(e), LocationId: e.LocationId}, err)
+		if err != nil {
+			return fmt.Errorf("error deleting location %s: %s: %s", e.LocationId, msg, err.Error())
+		}
+		s.del(e.Key)
+		return nil
+	case OutboxOpImport:
+		sb, rev, err := s.st.Fetch(e.Key)
+		if err != nil {
+			return err
+		}
+		if len(rev) == 0 || sb.Tombstone != nil || len(sb.LocationId) > 0 {
+			klog.Infof("dropping backstage import for key %s as it is no longer needed", e.Key)
+			return nil
+		}
+		impResp, err := s.bkstg.ImportLocation(e.Target)
+		if err != nil {
+			err = fmt.Errorf("error importing location %s: %s", e.Target, err.Error())
+			s.audit(systemActor, AuditEntry{Action: AuditActionImport, Key: e.Key, Reason: outboxReason(e)}, err)
+			return err
+		}
+		locID, locTarget, ok := rest.ParseImportLocationMap(impResp)
+		if !ok {
+			err = fmt.Errorf("parsing of import location return had an issue: %#v", impResp)
+			s.audit(systemActor, AuditEntry{Action: AuditActionImport, Key: e.Key, Reason: outboxReason(e)}, err)
+			return err
+		}
+		s.audit(systemActor, AuditEntry{Action: AuditActionImport, Key: e.Key, Reason: outboxReason(e), LocationId: locID}, nil)
+		gone := false
+		_, err = s.update(e.Key, func(sb *types.StorageBody) (bool, error) {
+			if len(sb.Body) == 0 {
+				gone = true
+				return false, nil
+			}
+			sb.LocationId = locID
+			sb.LocationTarget = locTarget
+			sb.LastPushResult = types.PushResultImported
+			return true, nil
+		})
+		if gone {
+			// the model was removed while we imported it, so the location we just created has to go as well
+			s.enqueue(OutboxOpDelete, e.Key, locTarget, locID, nil)
+			return nil
+		}
+		return err
+	}
+	return fmt.Errorf("unknown outbox operation %q", e.Op)
+}
+
+// outboxReason is the reason the audit log gives for an attempt at the entry
+func outboxReason(e OutboxEntry) string {
+	return fmt.Sprintf("outbox attempt %d", e.Attempts)
+}
+
+// handleOutboxGet lists the pending and dead-lettered Backstage operations
+func (s *StorageRESTServer) handleOutboxGet(c *gin.Context) {
+	entries, _, err := s.listOutbox()
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		klog.Errorf("error listing the outbox: %s", err.Error())
+		c.Error(err)
+		return
+	}
+	content, err := json.Marshal(&OutboxResponse{Entries: entries})
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		c.Error(err)
+		return
+	}
+	c.Data(http.StatusOK, "Content-Type: application/json", content)
+}
+
+// handleOutboxReplayPost has the entry with the 'id' parameter, or without one every dead-lettered entry, tried again
+// right away, with its attempts starting over
+func (s *StorageRESTServer) handleOutboxReplayPost(c *gin.Context) {
+	id := c.Query(util.IDQueryParam)
+	entries, revs, err := s.listOutbox()
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		klog.Errorf("error listing the outbox: %s", err.Error())
+		c.Error(err)
+		return
+	}
+	replayed := []OutboxEntry{}
+	for _, e := range entries {
+		if (len(id) > 0 && e.ID != id) || (len(id) == 0 && !e.DeadLettered) {
+			continue
+		}
+		e.Attempts = 0
+		e.DeadLettered = false
+		e.NextAttempt = time.Now().UTC()
+		_, err = s.storeOutboxEntry(e, revs[e.ID])
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			if types.IsConflict(err) {
+				c.Status(http.StatusConflict)
+			}
+			klog.Errorf("error replaying outbox entry %s: %s", e.ID, err.Error())
+			c.Error(fmt.Errorf("error replaying outbox entry %s: %s", e.ID, err.Error()))
+			return
+		}
+		klog.Infof("replaying backstage %s for key %s", e.Op, e.Key)
+		replayed = append(replayed, e)
+	}
+	if len(id) > 0 && len(replayed) == 0 {
+		c.Status(http.StatusNotFound)
+		c.Error(fmt.Errorf("no outbox entry %s", id))
+		return
+	}
+	content, err := json.Marshal(&OutboxResponse{Entries: replayed})
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		c.Error(err)
+		return
+	}
+	c.Data(http.StatusOK, "Content-Type: application/json", content)
+}
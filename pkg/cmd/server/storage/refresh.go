@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/cmd/cli/backstage"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/rest"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/types"
+	"k8s.io/client-go/util/flowcontrol"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// how long refreshes are collected before they are sent, unless BACKSTAGE_REFRESH_INTERVAL says otherwise
+	defaultRefreshInterval = 2 * time.Second
+	// how many refreshes are sent per second, unless BACKSTAGE_REFRESH_RATE says otherwise, and how many may be sent
+	// in a burst
+	defaultRefreshRate = 5.0
+	refreshBurst       = 10
+)
+
+// refreshQueue collects the entities to have Backstage process again, so that each is refreshed once per batch
+// however often its model was upserted in the meantime, and sends them no faster than its limiter allows
+type refreshQueue struct {
+	interval time.Duration
+	limiter  flowcontrol.RateLimiter
+	mutex    sync.Mutex
+	pending  map[string]struct{}
+}
+
+func newRefreshQueue(interval time.Duration, rate float32) *refreshQueue {
+	return &refreshQueue{
+		interval: interval,
+		limiter:  flowcontrol.NewTokenBucketRateLimiter(rate, refreshBurst),
+		pending:  map[string]struct{}{},
+	}
+}
+
+// refreshQueueFromEnv returns nil when refreshing entities is turned off
+func refreshQueueFromEnv() *refreshQueue {
+	r := strings.NewReplacer("\r", "", "\n", "")
+	interval := defaultRefreshInterval
+	if v := r.Replace(os.Getenv(types.BackstageRefreshIntervalEnvVar)); len(v) > 0 {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			klog.Errorf("ignoring %s setting %q as it is not a duration", types.BackstageRefreshIntervalEnvVar, v)
+		} else {
+			interval = d
+		}
+	}
+	if interval == 0 {
+		return nil
+	}
+	rate := float32(defaultRefreshRate)
+	if v := r.Replace(os.Getenv(types.BackstageRefreshRateEnvVar)); len(v) > 0 {
+		f, err := strconv.ParseFloat(v, 32)
+		if err != nil || f <= 0 {
+			klog.Errorf("ignoring %s setting %q as it is not a positive number", types.BackstageRefreshRateEnvVar, v)
+		} else {
+			rate = float32(f)
+		}
+	}
+	return newRefreshQueue(interval, rate)
+}
+
+func (q *refreshQueue) add(refs ...string) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	for _, ref := range refs {
+		q.pending[ref] = struct{}{}
+	}
+}
+
+// take empties the queue, returning what was in it
+func (q *refreshQueue) take() []string {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	refs := make([]string, 0, len(q.pending))
+	for ref := range q.pending {
+		refs = append(refs, ref)
+	}
+	q.pending = map[string]struct{}{}
+	sort.Strings(refs)
+	return refs
+}
+
+// queueRefresh has the entities the model under the key becomes in Backstage refreshed with the next batch
+func (s *StorageRESTServer) queueRefresh(key string, body []byte) {
+	if s.refresh == nil {
+		return
+	}
+	refs, err := backstage.EntityRefs(body, s.format)
+	if err != nil {
+		klog.Warningf("not refreshing the backstage entities for key %s as its content could not be parsed: %s", key, err.Error())
+		return
+	}
+	klog.V(4).Infof("queueing refresh of backstage entities %v for key %s", refs, key)
+	s.refresh.add(refs...)
+}
+
+// processRefreshes sends the batch of refreshes collected since the last one to Backstage.  A refresh which fails is
+// not retried, as Backstage's processing loop gets to the entity on its own in time.
+func (s *StorageRESTServer) processRefreshes(ctx context.Context) {
+	if !s.setupBkstg() {
+		return
+	}
+	refresher, ok := s.bkstg.(rest.BackstageRefresh)
+	if !ok {
+		return
+	}
+	refs := s.refresh.take()
+	for i, ref := range refs {
+		err := s.refresh.limiter.Wait(ctx)
+		if err != nil {
+			// we are stopping, so put back what we did not get to
+			s.refresh.add(refs[i:]...)
+			return
+		}
+		err = refresher.RefreshEntity(ref)
+		if err != nil {
+			klog.Warningf("error refreshing backstage entity %s: %s", ref, err.Error())
+			continue
+		}
+		klog.V(4).Infof("refreshed backstage entity %s", ref)
+	}
+	if len(refs) > 0 {
+		klog.Infof("refreshed %d backstage entities", len(refs))
+	}
+}
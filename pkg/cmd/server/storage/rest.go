@@ -1,22 +1,30 @@
 package storage
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/go-resty/resty/v2"
 	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/rest"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/types"
 	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/util"
 )
 
 type BridgeStorageRESTClient struct {
 	RESTClient       *resty.Client
 	UpsertURL        string
+	UpsertBatchURL   string
 	CurrentKeySetURL string
 	ListURL          string
+	ListMetadataURL  string
 	FetchURL         string
+	WatchURL         string
+	HealthzURL       string
 	Token            string
 }
 
@@ -24,15 +32,20 @@ func SetupBridgeStorageRESTClient(hostURL, token string) *BridgeStorageRESTClien
 	b := &BridgeStorageRESTClient{
 		RESTClient:       resty.New(),
 		UpsertURL:        hostURL + util.UpsertURI,
+		UpsertBatchURL:   hostURL + util.UpsertBatchURI,
 		CurrentKeySetURL: hostURL + util.CurrentKeySetURI,
 		ListURL:          hostURL + util.ListURI,
+		ListMetadataURL:  hostURL + util.ListMetadataURI,
 		FetchURL:         hostURL + util.FetchURI,
+		WatchURL:         hostURL + util.WatchURI,
+		HealthzURL:       hostURL + util.HealthzURI,
 		Token:            token,
 	}
 	return b
 }
 
-func (b *BridgeStorageRESTClient) UpsertModel(importKey, normalizerType, lastUpdateTimeSinceEpoch, modelCardKey string, modelCard *string, buf []byte) (int, string, *rest.PostBody, error) {
+// UpsertModel stores the model's content under the import key, recording the normalizer source which owns it
+func (b *BridgeStorageRESTClient) UpsertModel(importKey, normalizerType, source, lastUpdateTimeSinceEpoch, modelCardKey string, modelCard *string, buf []byte) (int, string, *rest.PostBody, error) {
 	var err error
 	var storageResp *resty.Response
 	body := rest.PostBody{
@@ -44,7 +57,8 @@ func (b *BridgeStorageRESTClient) UpsertModel(importKey, normalizerType, lastUpd
 		body.ModelCard = *modelCard
 		body.ModelCardKey = r.Replace(modelCardKey)
 	}
-	storageResp, err = b.RESTClient.R().SetBody(body).SetAuthToken(b.Token).SetQueryParam(util.KeyQueryParam, importKey).SetQueryParam(util.TypeQueryParam, normalizerType).SetHeader("Accept", "application/json").Post(b.UpsertURL)
+	body.Digest = body.ContentDigest()
+	storageResp, err = b.RESTClient.R().SetBody(body).SetAuthToken(b.Token).SetQueryParam(util.KeyQueryParam, importKey).SetQueryParam(util.TypeQueryParam, normalizerType).SetQueryParam(util.SourceQueryParam, source).SetHeader("Accept", "application/json").Post(b.UpsertURL)
 	msg := fmt.Sprintf("%#v", storageResp)
 	if err != nil {
 		return http.StatusInternalServerError, msg, &body, err
@@ -52,12 +66,57 @@ func (b *BridgeStorageRESTClient) UpsertModel(importKey, normalizerType, lastUpd
 	return storageResp.StatusCode(), msg, &body, nil
 }
 
-func (b *BridgeStorageRESTClient) PostCurrentKeySet(keys []string) (int, string, error) {
+// NewBatchUpsertEntry builds the batch entry for what UpsertModel would send on its own
+func NewBatchUpsertEntry(importKey, normalizerType, source, lastUpdateTimeSinceEpoch, modelCardKey string, modelCard *string, buf []byte) rest.BatchUpsertEntry {
+	e := rest.BatchUpsertEntry{
+		Key:    importKey,
+		Type:   normalizerType,
+		Source: source,
+		PostBody: rest.PostBody{
+			Body:                     buf,
+			LastUpdateTimeSinceEpoch: lastUpdateTimeSinceEpoch,
+		},
+	}
+	if modelCard != nil {
+		e.ModelCard = *modelCard
+		e.ModelCardKey = strings.NewReplacer(" ", "").Replace(modelCardKey)
+	}
+	e.Digest = e.ContentDigest()
+	return e
+}
+
+// UpsertModels stores the models in one request, returning the result for each
+func (b *BridgeStorageRESTClient) UpsertModels(entries []rest.BatchUpsertEntry) (int, string, *rest.BatchUpsertResponse, error) {
+	resp := &rest.BatchUpsertResponse{}
+	storageResp, err := b.RESTClient.R().SetBody(&rest.BatchUpsertBody{Entries: entries}).SetAuthToken(b.Token).SetResult(resp).SetHeader("Accept", "application/json").Post(b.UpsertBatchURL)
+	msg := fmt.Sprintf("%#v", storageResp)
+	if err != nil {
+		return http.StatusInternalServerError, msg, nil, err
+	}
+	if storageResp.StatusCode() != http.StatusOK {
+		return storageResp.StatusCode(), msg, nil, nil
+	}
+	if len(resp.Results) != len(entries) {
+		return storageResp.StatusCode(), msg, nil, fmt.Errorf("storage returned %d results for a batch of %d", len(resp.Results), len(entries))
+	}
+	return storageResp.StatusCode(), msg, resp, nil
+}
+
+// PostCurrentKeySet sends the keys the normalizer found in the source, so that storage only removes the missing keys
+// that source owns; incomplete says the source could not be reached, so that storage keeps the keys missing from the set
+func (b *BridgeStorageRESTClient) PostCurrentKeySet(keys []string, source string, incomplete bool) (int, string, error) {
 	var err error
 	var storageResp *resty.Response
 
 	qp := strings.Join(keys, ",")
-	storageResp, err = b.RESTClient.R().SetAuthToken(b.Token).SetQueryParam(util.KeyQueryParam, qp).SetHeader("Accept", "application/json").Post(b.CurrentKeySetURL)
+	req := b.RESTClient.R().SetAuthToken(b.Token).SetQueryParam(util.KeyQueryParam, qp).SetHeader("Accept", "application/json")
+	if len(source) > 0 {
+		req.SetQueryParam(util.SourceQueryParam, source)
+	}
+	if incomplete {
+		req.SetQueryParam(util.IncompleteQueryParam, "true")
+	}
+	storageResp, err = req.Post(b.CurrentKeySetURL)
 	msg := fmt.Sprintf("%#v", storageResp)
 	if err != nil {
 		return http.StatusInternalServerError, msg, err
@@ -85,6 +144,22 @@ func (b *BridgeStorageRESTClient) ListModelsKeys() (int, string, error, []string
 	return storageResp.StatusCode(), msg, nil, d.Keys
 }
 
+// ListModelsMetadata gets a page of what storage has for each model, short of its content; query holds any of the
+// 'type', 'registry', 'owner', 'lifecycle', 'limit' and 'continue' query parameters
+func (b *BridgeStorageRESTClient) ListModelsMetadata(query map[string]string) (int, string, *MetadataListResponse, error) {
+	resp := &MetadataListResponse{}
+	storageResp, err := b.RESTClient.R().SetAuthToken(b.Token).SetQueryParams(query).SetResult(resp).SetHeader("Accept", "application/json").Get(b.ListMetadataURL)
+	msg := fmt.Sprintf("%#v", storageResp)
+	if err != nil {
+		return http.StatusInternalServerError, msg, nil, err
+	}
+	if storageResp.StatusCode() != http.StatusOK {
+		return storageResp.StatusCode(), msg, nil, nil
+	}
+	return storageResp.StatusCode(), msg, resp, nil
+}
+
+// FetchModel gets the entry for the key, where a 404 means storage does not have it
 func (b *BridgeStorageRESTClient) FetchModel(key string) (int, string, error, []byte) {
 	var err error
 	var storageResp *resty.Response
@@ -97,3 +172,45 @@ func (b *BridgeStorageRESTClient) FetchModel(key string) (int, string, error, []
 
 	return storageResp.StatusCode(), msg, nil, storageResp.Body()
 }
+
+// Healthz checks that storage-rest is up
+func (b *BridgeStorageRESTClient) Healthz() error {
+	storageResp, err := b.RESTClient.R().SetHeader("Accept", "application/json").Get(b.HealthzURL)
+	if err != nil {
+		return err
+	}
+	if storageResp.StatusCode() != http.StatusOK {
+		return fmt.Errorf("bad response code from storage-rest liveness %d: %s", storageResp.StatusCode(), storageResp.String())
+	}
+	return nil
+}
+
+// Watch streams the storage change feed, starting after the since sequence, or with every key when since is 0, and
+// calls fn with each event until ctx is done, storage-rest ends the stream, or fn returns an error
+func (b *BridgeStorageRESTClient) Watch(ctx context.Context, since int64, fn func(ev types.WatchEvent) error) error {
+	storageResp, err := b.RESTClient.R().SetContext(ctx).SetDoNotParseResponse(true).SetAuthToken(b.Token).SetQueryParam(util.SinceQueryParam, strconv.FormatInt(since, 10)).SetHeader("Accept", "application/json").Get(b.WatchURL)
+	if err != nil {
+		return err
+	}
+	body := storageResp.RawBody()
+	defer body.Close()
+	if storageResp.StatusCode() != http.StatusOK {
+		return fmt.Errorf("bad response code from storage watch %d", storageResp.StatusCode())
+	}
+
+	dec := json.NewDecoder(body)
+	for {
+		ev := types.WatchEvent{}
+		err = dec.Decode(&ev)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		err = fn(ev)
+		if err != nil {
+			return err
+		}
+	}
+}
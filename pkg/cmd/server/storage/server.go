@@ -1,29 +1,39 @@
 package storage
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/cmd/cli/backstage"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/cmd/server/auth"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/cmd/server/lifecycle"
 	bridgeclient "github.com/redhat-ai-dev/model-catalog-bridge/pkg/cmd/server/location/client"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/cmd/server/validation"
 	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/config"
 	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/rest"
 	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/types"
 	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/util"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
 	k8srest "k8s.io/client-go/rest"
 	"k8s.io/klog/v2"
 	ctrl "sigs.k8s.io/controller-runtime"
 )
 
+// how often we look for tombstones whose grace period is up
+const tombstonePurgeInterval = time.Minute
+
 type StorageRESTServer struct {
 	router          *gin.Engine
 	st              types.BridgeStorage
@@ -35,9 +45,72 @@ type StorageRESTServer struct {
 	format          types.NormalizerFormat
 	pushToRHDH      bool
 	port            string
+	feed            *changeFeed
+	auth            *auth.Authorizer
+	keySetGuard     keySetGuard
+	tombstoneGrace  time.Duration
+	// outboxMaxAttempts of 0 means defaultOutboxMaxAttempts
+	outboxMaxAttempts int
+	// driftInterval of 0 means storage is only compared with Backstage when asked to
+	driftInterval time.Duration
+	driftRunning  sync.Mutex
+	lastDrift     *DriftReport
+	// refresh of nil means entities are left to Backstage's processing loop
+	refresh *refreshQueue
+	// validationMode of "" means upserted content is not validated
+	validationMode types.ValidationMode
+	// auditRetention of its zero value means mutations are not recorded in the audit log
+	auditRetention auditRetention
+	timeouts       lifecycle.Timeouts
+	probes         *lifecycle.Probes
+	// draining is closed as the server shuts down, which ends the watch streams; nil means they are never ended
+	draining     chan struct{}
+	drainingOnce sync.Once
+}
+
+// keySetGuard holds the safeguards against handleCatalogCurrentKeySetPost removing keys a normalizer merely failed to
+// see; its zero value removes every missing key right away, as the current key set always has
+type keySetGuard struct {
+	// maxRemoveRatio of 0 means no limit
+	maxRemoveRatio float64
+	// absentCount of 0 or 1 means a key is removed the first time it is missing
+	absentCount int
+	dryRun      bool
+	// absent is how many current key sets in a row each key has been missing from
+	absent map[string]int
 }
 
-func NewStorageRESTServer(st types.BridgeStorage, port, bridgeURL, bridgeToken, bkstgToken string, nf types.NormalizerFormat) *StorageRESTServer {
+func keySetGuardFromEnv() keySetGuard {
+	r := strings.NewReplacer("\r", "", "\n", "")
+	g := keySetGuard{absent: map[string]int{}}
+	if v := r.Replace(os.Getenv(types.CurrentKeySetMaxRemoveRatioEnvVar)); len(v) > 0 {
+		ratio, err := strconv.ParseFloat(v, 64)
+		if err != nil || ratio < 0 || ratio > 1 {
+			klog.Errorf("ignoring %s setting %q as it is not a number between 0 and 1", types.CurrentKeySetMaxRemoveRatioEnvVar, v)
+		} else {
+			g.maxRemoveRatio = ratio
+		}
+	}
+	if v := r.Replace(os.Getenv(types.CurrentKeySetAbsentCountEnvVar)); len(v) > 0 {
+		count, err := strconv.Atoi(v)
+		if err != nil || count < 0 {
+			klog.Errorf("ignoring %s setting %q as it is not a positive number", types.CurrentKeySetAbsentCountEnvVar, v)
+		} else {
+			g.absentCount = count
+		}
+	}
+	if v := r.Replace(os.Getenv(types.CurrentKeySetDryRunEnvVar)); len(v) > 0 {
+		dryRun, err := strconv.ParseBool(v)
+		if err != nil {
+			klog.Errorf("ignoring %s setting %q as it is not a boolean", types.CurrentKeySetDryRunEnvVar, v)
+		} else {
+			g.dryRun = dryRun
+		}
+	}
+	return g
+}
+
+func NewStorageRESTServer(st types.BridgeStorage, port, bridgeURL, bridgeToken, bkstgToken string, nf types.NormalizerFormat, authz *auth.Authorizer) *StorageRESTServer {
 	gin.SetMode(gin.ReleaseMode)
 	r := gin.Default()
 	pushToRHDH := false
@@ -47,25 +120,61 @@ func NewStorageRESTServer(st types.BridgeStorage, port, bridgeURL, bridgeToken,
 		pushToRHDH = push
 	}
 	s := &StorageRESTServer{
-		router:          r,
-		st:              st,
-		mutex:           sync.Mutex{},
-		pushedLocations: map[string]*types.StorageBody{},
-		locations:       bridgeclient.SetupBridgeLocationRESTClient(bridgeURL, bridgeToken),
-		bkstgToken:      bkstgToken,
-		format:          nf,
-		pushToRHDH:      pushToRHDH,
-		port:            port,
+		router:            r,
+		st:                st,
+		mutex:             sync.Mutex{},
+		pushedLocations:   map[string]*types.StorageBody{},
+		locations:         bridgeclient.SetupBridgeLocationRESTClient(bridgeURL, bridgeToken),
+		bkstgToken:        bkstgToken,
+		format:            nf,
+		pushToRHDH:        pushToRHDH,
+		port:              port,
+		feed:              newChangeFeed(st),
+		auth:              authz,
+		keySetGuard:       keySetGuardFromEnv(),
+		outboxMaxAttempts: outboxMaxAttemptsFromEnv(),
+		driftInterval:     driftIntervalFromEnv(),
+		refresh:           refreshQueueFromEnv(),
+		validationMode:    validation.ModeFromEnv(),
+		auditRetention:    auditRetentionFromEnv(),
+		timeouts:          lifecycle.TimeoutsFromEnv(),
+		draining:          make(chan struct{}),
+	}
+	graceStr := strings.NewReplacer("\r", "", "\n", "").Replace(os.Getenv(types.TombstoneGracePeriodEnvVar))
+	if len(graceStr) > 0 {
+		grace, err := time.ParseDuration(graceStr)
+		if err != nil || grace < 0 {
+			klog.Errorf("ignoring %s setting %q as it is not a duration", types.TombstoneGracePeriodEnvVar, graceStr)
+		} else {
+			s.tombstoneGrace = grace
+		}
 	}
 	s.setupBkstg()
 	klog.Infof("NewStorageRESTServer")
 	r.SetTrustedProxies(nil)
 	r.TrustedPlatform = "X-Forwarded-For"
 	r.Use(addRequestId())
-	r.POST(util.UpsertURI, s.handleCatalogUpsertPost)
-	r.POST(util.CurrentKeySetURI, s.handleCatalogCurrentKeySetPost)
-	r.GET(util.ListURI, s.handleCatalogList)
-	r.GET(util.FetchURI, s.handleCatalogFetch)
+	s.probes = lifecycle.NewProbes(s.timeouts.ReadinessCheck)
+	s.probes.AddReadyzCheck("storage", s.storageReady)
+	if requireBkstg, err := strconv.ParseBool(os.Getenv(types.ReadinessRequiresBackstageEnvVar)); err == nil && requireBkstg {
+		s.probes.AddReadyzCheck("backstage", s.backstageReady)
+	}
+	s.probes.Register(r)
+	// the normalizers write, while the location service and the Backstage entity provider only read
+	r.POST(util.UpsertURI, authz.Require(auth.Write), s.handleCatalogUpsertPost)
+	r.POST(util.UpsertBatchURI, authz.Require(auth.Write), s.handleCatalogUpsertBatchPost)
+	r.POST(util.CurrentKeySetURI, authz.Require(auth.Write), s.handleCatalogCurrentKeySetPost)
+	r.GET(util.ListURI, authz.Require(auth.Read), s.handleCatalogList)
+	r.GET(util.ListMetadataURI, authz.Require(auth.Read), s.handleCatalogListMetadata)
+	r.GET(util.FetchURI, authz.Require(auth.Read), s.handleCatalogFetch)
+	r.GET(util.UsageURI, authz.Require(auth.Read), s.handleStorageUsage)
+	r.GET(util.HistoryURI, authz.Require(auth.Read), s.handleCatalogHistory)
+	r.GET(util.WatchURI, authz.Require(auth.Read), s.handleCatalogWatch)
+	r.GET(util.OutboxURI, authz.Require(auth.Read), s.handleOutboxGet)
+	r.POST(util.OutboxReplayURI, authz.Require(auth.Write), s.handleOutboxReplayPost)
+	r.GET(util.DriftURI, authz.Require(auth.Read), s.handleDriftGet)
+	r.POST(util.DriftURI, authz.Require(auth.Write), s.handleDriftPost)
+	r.GET(util.AuditURI, authz.Require(auth.Read), s.handleAuditGet)
 	return s
 }
 
@@ -79,46 +188,83 @@ func addRequestId() gin.HandlerFunc {
 	}
 }
 
-func (s *StorageRESTServer) Run(stopCh <-chan struct{}) {
-	ch := make(chan int)
-	go func() {
-		for {
-			select {
-			case <-ch:
-				return
-			default:
-				err := s.router.Run(fmt.Sprintf(":%s", s.port))
-				if err != nil {
-					klog.Errorf("ERROR: gin-gonic run error %s", err.Error())
-				}
-			}
+// Run serves until ctx is done, and then drains the server, with the background processing carrying on until the
+// requests in flight are finished
+func (s *StorageRESTServer) Run(ctx context.Context) error {
+	bgCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.feed.run(bgCtx)
+	go wait.UntilWithContext(bgCtx, s.purgeTombstones, tombstonePurgeInterval)
+	go wait.UntilWithContext(bgCtx, s.processOutbox, outboxInterval)
+	if s.driftInterval > 0 {
+		go wait.UntilWithContext(bgCtx, s.reconcileBackstageLoop, s.driftInterval)
+	}
+	if s.refresh != nil {
+		go wait.UntilWithContext(bgCtx, s.processRefreshes, s.refresh.interval)
+	}
+	go wait.UntilWithContext(bgCtx, s.purgeAudit, auditPurgeInterval)
+	return lifecycle.Serve(ctx, s.port, s.router, s.timeouts, s.probes, s.drain)
+}
+
+// drain ends the watch streams, which would otherwise hold up shutting down
+func (s *StorageRESTServer) drain() {
+	s.drainingOnce.Do(func() {
+		if s.draining != nil {
+			close(s.draining)
 		}
-	}()
-	<-stopCh
-	close(ch)
+	})
 }
 
-func (s *StorageRESTServer) sync(key string) (*types.StorageBody, error) {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
-	sb, ok := s.pushedLocations[key]
-	if ok && sb.LocationIDValid {
-		return sb, nil
+// storageReady checks that the storage backend can be read
+func (s *StorageRESTServer) storageReady() error {
+	_, err := s.st.List()
+	if err != nil {
+		return fmt.Errorf("error listing storage keys: %s", err.Error())
 	}
-	ssb, err := s.st.Fetch(key)
-	if err == nil && len(ssb.LocationId) > 0 {
-		_, err = s.bkstg.GetLocation(ssb.LocationId)
-		if err == nil {
-			ssb.LocationIDValid = true
-			s.pushedLocations[key] = &ssb
-			//TODO do we bother updating the storage tier
-		} else {
-			klog.Infof("previously registered location %s:%s is no longer valid, unregistering", ssb.LocationId, ssb.LocationTarget)
-			delete(s.pushedLocations, key)
-			return &types.StorageBody{}, s.st.Remove(key)
+	return nil
+}
+
+// backstageReady checks that Backstage can be reached
+func (s *StorageRESTServer) backstageReady() error {
+	if !s.setupBkstg() {
+		return fmt.Errorf("access to Backstage is not available")
+	}
+	if ping, ok := s.bkstg.(rest.BackstagePing); ok {
+		return ping.Ping()
+	}
+	return nil
+}
+
+// update fetches the latest entry for the key, lets fn change it, and stores it with the revision it was fetched at;
+// if another replica or request updated the key in between, we start over with what they stored, so that no update
+// is lost.  fn returns false if there is nothing to store.
+func (s *StorageRESTServer) update(key string, fn func(sb *types.StorageBody) (bool, error)) (types.StorageBody, error) {
+	sb := types.StorageBody{}
+	var lastErr error
+	backoff := wait.Backoff{Steps: 5, Duration: 50 * time.Millisecond, Factor: 2.0, Jitter: 0.1}
+	err := wait.ExponentialBackoff(backoff, func() (bool, error) {
+		var rev string
+		var err error
+		sb, rev, err = s.st.Fetch(key)
+		if err != nil {
+			return false, err
+		}
+		write := false
+		write, err = fn(&sb)
+		if err != nil || !write {
+			return true, err
+		}
+		_, lastErr = s.st.Upsert(key, sb, rev)
+		if types.IsConflict(lastErr) {
+			klog.Infof("key %s was updated concurrently, retrying: %s", key, lastErr.Error())
+			return false, nil
 		}
+		return true, lastErr
+	})
+	if wait.Interrupted(err) {
+		return sb, lastErr
 	}
-	return &ssb, err
+	return sb, err
 }
 
 func (s *StorageRESTServer) del(key string) {
@@ -127,6 +273,28 @@ func (s *StorageRESTServer) del(key string) {
 	delete(s.pushedLocations, key)
 }
 
+// CurrentKeySetResponse reports what a current key set did, or with a dry run, would have done
+type CurrentKeySetResponse struct {
+	// Removed are the keys removed from storage
+	Removed []string `json:"removed"`
+	// Tombstoned are the keys marked as removed, which are kept until their grace period is up
+	Tombstoned []string `json:"tombstoned"`
+	// WouldRemove are the keys that would have been removed had this not been a dry run, or had removing them not
+	// exceeded the maximum removal ratio
+	WouldRemove []string `json:"wouldRemove"`
+	// Absent are the keys missing from the current key set which are kept until they have been missing enough times in
+	// a row, along with the number of times so far
+	Absent map[string]int `json:"absent"`
+	// Unfetched are the keys missing from the current key set which could not be fetched, and so could not be told
+	// apart from those of other sources; they are neither counted nor removed, and the response is incomplete
+	Unfetched []string `json:"unfetched,omitempty"`
+	// Source is the source the key set was for, and is empty when it was for every key in storage
+	Source     string `json:"source,omitempty"`
+	DryRun     bool   `json:"dryRun"`
+	Incomplete bool   `json:"incomplete"`
+	Blocked    bool   `json:"blocked"`
+}
+
 // handleCatalogCurrentKeySetPost deals with removing model/version entries no longer recognized by our set
 // of metadata normalizers.  It pulls the list of keys in storage and if any of those keys in storage are not
 // in the current key set provided as input, removal processing is initiated.  That removal processing includes:
@@ -138,19 +306,52 @@ func (s *StorageRESTServer) del(key string) {
 //     deleting the location and its related components/resources/apis from the catalog (i.e. a less
 //     aggressive delete) but for a TBD reason deleting the location does not appear to be working form our EntityProvider
 //   - we then remove the entry from the location service
+//
+// With a tombstone grace period, the entry is instead marked as removed and kept, along with its Backstage location,
+// with the location service answering with a 410 for it, until the grace period is up and purgeTombstones does the
+// above; should the model come back before then, handleCatalogUpsertPost carries on with the same location.
+//
+// As one failed call to a model registry would otherwise remove every model from it, and from Backstage, a key is only
+// removed once it has been missing from enough current key sets in a row, and nothing is removed when the normalizer
+// says with the 'incomplete' parameter that some source failed, when more than the maximum ratio of the stored keys
+// would be removed at once, or when this is a dry run.
+//
+// With the 'source' parameter, the key set only covers the entries owned by that source, so that each model registry,
+// and KServe, can post its own key set without removing the models of the others; the ratio is then of the source's
+// keys.  As the keys of the source are those handleCatalogUpsertPost stores its models under, models with the same
+// names from other sources are neither covered nor taken over.
 func (s *StorageRESTServer) handleCatalogCurrentKeySetPost(c *gin.Context) {
 	key := c.Query(util.KeyQueryParam)
 	// no content for the key QP means no models were discovered
+	source := c.Query(util.SourceQueryParam)
 
 	keys := strings.Split(key, ",")
 	keyHash := map[string]struct{}{}
 	if len(key) > 0 {
 		for _, k := range keys {
+			// the keys are stored under the source, as handleCatalogUpsertPost does
+			if seg1, seg2, ok := util.KeySegments(k); ok && len(source) > 0 {
+				k = util.BuildSourceKey(source, seg1, seg2)
+			}
 			keyHash[k] = struct{}{}
 		}
 	}
 
 	var err error
+	resp := &CurrentKeySetResponse{Removed: []string{}, Tombstoned: []string{}, WouldRemove: []string{}, Absent: map[string]int{}, Source: source, DryRun: s.keySetGuard.dryRun}
+	for param, flag := range map[string]*bool{util.IncompleteQueryParam: &resp.Incomplete, util.DryRunQueryParam: &resp.DryRun} {
+		if v := c.Query(param); len(v) > 0 {
+			set := false
+			set, err = strconv.ParseBool(v)
+			if err != nil {
+				c.Status(http.StatusBadRequest)
+				c.Error(fmt.Errorf("the '%s' parameter is not a boolean: %s", param, err.Error()))
+				return
+			}
+			*flag = *flag || set
+		}
+	}
+
 	currentKeys := []string{}
 	currentKeys, err = s.st.List()
 	if err != nil {
@@ -160,61 +361,101 @@ func (s *StorageRESTServer) handleCatalogCurrentKeySetPost(c *gin.Context) {
 		c.Error(err)
 		return
 	}
+	currentKeys = modelKeys(currentKeys)
 
-	var errors []error
+	// keys which are already tombstones, or which belong to another source, are neither missing nor counted among the
+	// keys in storage, and nor are those we cannot fetch, as we cannot tell which source they belong to
+	live := []string{}
 	for _, k := range currentKeys {
-		_, ok := keyHash[k]
-		if !ok {
-			msg := ""
-			//TODO for summit we were not going to "aggressively" inform backstage of deletions by leveraging
-			// the delete location catalog REST API; however, with local testing
-			// https://github.com/redhat-ai-dev/rhdh-plugins/blob/6b0c4a21c1cdfeba4cf2618d4aabadff544c7efc/workspaces/rhdh-ai/plugins/catalog-backend-module-rhdh-ai/src/providers/RHDHRHOAIEntityProvider.ts#L198-L202
-			// is not actually deleting locations as expected.  So we are provisionally (we'll see if it is permananent after diagnosing the situation) using the catalog REST API to delete for now.
-			sb := types.StorageBody{}
-			sb, err = s.st.Fetch(k)
+		if _, ok := keyHash[k]; !ok {
+			sb, _, err := s.st.Fetch(k)
 			if err != nil {
-				// just log error for now
-				klog.Error(err.Error())
+				klog.Errorf("not considering key %s for removal as it could not be fetched: %s", k, err.Error())
+				resp.Unfetched = append(resp.Unfetched, k)
+				continue
 			}
-
-			// initiate removal
-			err = s.st.Remove(k)
-			if err != nil {
-				klog.Errorf("error removing from storage key %s: %s", k, err.Error())
-				errors = append(errors, err)
+			if sb.Tombstone != nil {
 				continue
 			}
-
-			s.del(k)
-			//TODO provisional direct delete of location
-			bkstAvailable := s.setupBkstg()
-			if !bkstAvailable && len(sb.LocationId) > 0 {
-				klog.Warningf("Access to Backstage is not available so will not delete location %s", sb.LocationId)
+			if len(source) > 0 && !sb.OwnedBy(source) {
+				continue
 			}
-			if len(sb.LocationId) > 0 && bkstAvailable {
-				msg, err = s.bkstg.DeleteLocation(sb.LocationId)
-				if err == nil {
-					klog.Infof("deletion of location %s for target %s successful", sb.LocationId, sb.LocationTarget)
-				} else {
-					klog.Errorf("deletions of location %s for target %s had error %s: %s", sb.LocationId, sb.LocationTarget, msg, err.Error())
+			// a key from before sources were part of keys is still there until the next upsert moves it to the key
+			// of the source
+			if seg1, seg2, ok := util.KeySegments(k); ok && len(source) > 0 && util.LegacyKey(k) == k {
+				if _, ok = keyHash[util.BuildSourceKey(source, seg1, seg2)]; ok {
+					keyHash[k] = struct{}{}
 				}
 			}
+		}
+		live = append(live, k)
+	}
 
-			rc := 0
-			rc, msg, err = s.locations.RemoveModel(k)
-			if err != nil {
-				errors = append(errors, err)
-				continue
+	removals := s.keySetAbsences(live, currentKeys, keyHash, resp)
+	currentKeys = live
+	normalizerIncomplete := resp.Incomplete
+	resp.Incomplete = resp.Incomplete || len(resp.Unfetched) > 0
+	switch {
+	case normalizerIncomplete:
+		klog.Infof("not removing any of the %d keys missing from the current key set as the normalizer reported not hearing from all its sources", len(resp.Absent))
+		s.currentKeySetResponse(c, http.StatusOK, resp)
+		return
+	case s.keySetGuard.maxRemoveRatio > 0 && float64(len(removals)) > s.keySetGuard.maxRemoveRatio*float64(len(currentKeys)):
+		resp.Blocked = true
+		resp.WouldRemove = removals
+		err = fmt.Errorf("not removing %d of the %d keys in storage as that is more than the maximum ratio of %v: %s", len(removals), len(currentKeys), s.keySetGuard.maxRemoveRatio, strings.Join(removals, ", "))
+		klog.Error(err.Error())
+		c.Error(err)
+		s.currentKeySetResponse(c, http.StatusConflict, resp)
+		return
+	case resp.DryRun:
+		resp.WouldRemove = removals
+		klog.Infof("dry run of the current key set would remove: %s", strings.Join(removals, ", "))
+		s.currentKeySetResponse(c, http.StatusOK, resp)
+		return
+	}
+
+	var errors []error
+	reason := "missing from the current key set"
+	if len(source) > 0 {
+		reason = fmt.Sprintf("missing from the current key set of source %s", source)
+	}
+	for _, k := range removals {
+		sb := types.StorageBody{}
+		rev := ""
+		sb, rev, err = s.st.Fetch(k)
+		if err != nil {
+			// without the entry we have neither its revision nor its Backstage location, so removing it now would
+			// leave the location behind
+			klog.Errorf("not removing key %s as it could not be fetched: %s", k, err.Error())
+			resp.Unfetched = append(resp.Unfetched, k)
+			continue
+		}
+
+		done := false
+		if s.tombstoneGrace > 0 {
+			done, err = s.tombstone(k, sb, rev, requestActor(c), reason)
+			if done {
+				resp.Tombstoned = append(resp.Tombstoned, k)
 			}
-			if rc != http.StatusOK && rc != http.StatusCreated {
-				err = fmt.Errorf("bad rc removing from storage key %d: %s", rc, msg)
-				klog.Error(err.Error())
-				errors = append(errors, err)
-				continue
+		} else {
+			done, err = s.removeKey(k, sb, rev, requestActor(c), reason)
+			if done {
+				resp.Removed = append(resp.Removed, k)
 			}
 		}
+		if done {
+			s.mutex.Lock()
+			delete(s.keySetGuard.absent, k)
+			delete(resp.Absent, k)
+			s.mutex.Unlock()
+		}
+		if err != nil {
+			errors = append(errors, err)
+		}
 	}
 
+	resp.Incomplete = resp.Incomplete || len(resp.Unfetched) > 0
 	if len(errors) > 0 {
 		c.Status(http.StatusInternalServerError)
 		msg := ""
@@ -224,16 +465,183 @@ func (s *StorageRESTServer) handleCatalogCurrentKeySetPost(c *gin.Context) {
 		c.Error(fmt.Errorf("%d errors: %s", len(errors), msg))
 		return
 	}
-	c.Status(http.StatusOK)
+	s.currentKeySetResponse(c, http.StatusOK, resp)
+}
+
+// removeKey removes the key from storage, provided it is still at rev, and then removes the model from Backstage and
+// the location service, recording why in the audit log; it returns false if the key was updated since rev, in which
+// case it is left alone
+func (s *StorageRESTServer) removeKey(k string, sb types.StorageBody, rev string, a auditActor, reason string) (bool, error) {
+	//TODO for summit we were not going to "aggressively" inform backstage of deletions by leveraging
+	// the delete location catalog REST API; however, with local testing
+	// https://github.com/redhat-ai-dev/rhdh-plugins/blob/6b0c4a21c1cdfeba4cf2618d4aabadff544c7efc/workspaces/rhdh-ai/plugins/catalog-backend-module-rhdh-ai/src/providers/RHDHRHOAIEntityProvider.ts#L198-L202
+	// is not actually deleting locations as expected.  So we are provisionally (we'll see if it is permananent after diagnosing the situation) using the catalog REST API to delete for now.
+
+	// initiate removal; if the key was upserted since we fetched it, it is no longer stale, so leave it be
+	err := s.st.Remove(k, rev)
+	if types.IsConflict(err) {
+		klog.Infof("not removing key %s as it was updated while it was being removed: %s", k, err.Error())
+		s.audit(a, AuditEntry{Action: AuditActionRemove, Key: k, Reason: reason, Outcome: AuditOutcomeSkipped}, err)
+		return false, nil
+	}
+	s.audit(a, AuditEntry{Action: AuditActionRemove, Key: k, Reason: reason, Digest: sb.Digest, LocationId: sb.LocationId}, err)
+	if err != nil {
+		klog.Errorf("error removing from storage key %s: %s", k, err.Error())
+		return false, err
+	}
+
+	s.del(k)
+	msg := ""
+	//TODO provisional direct delete of location
+	bkstAvailable := s.setupBkstg()
+	if !bkstAvailable && len(sb.LocationId) > 0 {
+		klog.Warningf("Access to Backstage is not available so will not delete location %s until it is", sb.LocationId)
+		err = fmt.Errorf("access to Backstage is not available")
+		s.audit(a, AuditEntry{Action: AuditActionDelete, Key: k, Reason: reason, LocationId: sb.LocationId}, err)
+		s.enqueue(OutboxOpDelete, k, sb.LocationTarget, sb.LocationId, err)
+	}
+	if len(sb.LocationId) > 0 && bkstAvailable {
+		msg, err = s.bkstg.DeleteLocation(sb.LocationId)
+		s.audit(a, AuditEntry{Action: AuditActionDelete, Key: k, Reason: reason, LocationId: sb.LocationId}, err)
+		if err == nil {
+			klog.Infof("deletion of location %s for target %s successful", sb.LocationId, sb.LocationTarget)
+		} else {
+			klog.Errorf("deletions of location %s for target %s had error %s: %s", sb.LocationId, sb.LocationTarget, msg, err.Error())
+			s.enqueue(OutboxOpDelete, k, sb.LocationTarget, sb.LocationId, err)
+		}
+	}
+
+	rc := 0
+	rc, msg, err = s.locations.RemoveModel(k)
+	if err != nil {
+		return true, err
+	}
+	if rc != http.StatusOK && rc != http.StatusCreated {
+		err = fmt.Errorf("bad rc removing from storage key %d: %s", rc, msg)
+		klog.Error(err.Error())
+		return true, err
+	}
+	return true, nil
+}
+
+// tombstone marks the key as removed, provided it is still at rev, keeping its body and Backstage location until the
+// grace period is up, and has the location service answer with a 410 for it in the meantime; it returns false if the
+// key was updated since rev, in which case it is left alone
+func (s *StorageRESTServer) tombstone(k string, sb types.StorageBody, rev string, a auditActor, reason string) (bool, error) {
+	now := time.Now().UTC().Truncate(time.Second)
+	sb.Tombstone = &types.Tombstone{RemovedAt: now, RemovedBy: a.caller, Reason: reason, ExpiresAt: now.Add(s.tombstoneGrace)}
+	_, err := s.st.Upsert(k, sb, rev)
+	if types.IsConflict(err) {
+		klog.Infof("not removing key %s as it was updated while it was being removed: %s", k, err.Error())
+		s.audit(a, AuditEntry{Action: AuditActionTombstone, Key: k, Reason: reason, Outcome: AuditOutcomeSkipped}, err)
+		return false, nil
+	}
+	s.audit(a, AuditEntry{Action: AuditActionTombstone, Key: k, Reason: reason, Digest: sb.Digest, LocationId: sb.LocationId}, err)
+	if err != nil {
+		klog.Errorf("error storing tombstone for key %s: %s", k, err.Error())
+		return false, err
+	}
+	klog.Infof("key %s removed by %s as it is %s, and will be kept until %s", k, a.caller, reason, sb.Tombstone.ExpiresAt.Format(time.RFC3339))
+
+	rc, msg, err := s.locations.UpsertModel(k, types.EffectiveSource(sb.Source, sb.ReconcilerType), &rest.PostBody{Body: sb.Body, LastUpdateTimeSinceEpoch: sb.LastUpdateTimeSinceEpoch, ModelCardKey: sb.ModelCardKey, ModelCard: sb.ModelCard, Digest: sb.Digest, Tombstone: sb.Tombstone})
+	if err != nil {
+		return true, err
+	}
+	if rc != http.StatusOK && rc != http.StatusCreated {
+		err = fmt.Errorf("bad rc tombstoning key %s in the location service %d: %s", k, rc, msg)
+		klog.Error(err.Error())
+		return true, err
+	}
+	return true, nil
+}
+
+// purgeTombstones removes the keys whose tombstones have expired, which is when their Backstage locations are deleted
+func (s *StorageRESTServer) purgeTombstones(ctx context.Context) {
+	keys, err := s.st.List()
+	if err != nil {
+		klog.Errorf("error listing keys to purge tombstones: %s", err.Error())
+		return
+	}
+	for _, k := range modelKeys(keys) {
+		if ctx.Err() != nil {
+			return
+		}
+		sb, rev, err := s.st.Fetch(k)
+		if err != nil {
+			klog.Errorf("error fetching key %s to purge tombstones: %s", k, err.Error())
+			continue
+		}
+		if sb.Tombstone == nil || time.Now().Before(sb.Tombstone.ExpiresAt) {
+			continue
+		}
+		klog.Infof("tombstone for key %s expired at %s", k, sb.Tombstone.ExpiresAt.Format(time.RFC3339))
+		_, err = s.removeKey(k, sb, rev, systemActor, "tombstone expired")
+		if err != nil {
+			klog.Errorf("error purging tombstone for key %s: %s", k, err.Error())
+		}
+	}
+}
+
+// keySetAbsences records which of the keys in storage the current key set covers are missing from it, and returns the
+// ones that have now been missing from enough current key sets in a row to be removed.  Keys present in the key set
+// start over, while, as nothing can be told from it, an incomplete key set does not count against the missing ones.
+// storedKeys are all the keys in storage, so that the counts of keys other sources cover are kept.
+func (s *StorageRESTServer) keySetAbsences(currentKeys, storedKeys []string, keyHash map[string]struct{}, resp *CurrentKeySetResponse) []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.keySetGuard.absent == nil {
+		s.keySetGuard.absent = map[string]int{}
+	}
+	stored := map[string]struct{}{}
+	for _, k := range storedKeys {
+		stored[k] = struct{}{}
+	}
+	removals := []string{}
+	for _, k := range currentKeys {
+		if _, ok := keyHash[k]; ok {
+			delete(s.keySetGuard.absent, k)
+			continue
+		}
+		if !resp.Incomplete {
+			s.keySetGuard.absent[k]++
+		}
+		count := s.keySetGuard.absent[k]
+		resp.Absent[k] = count
+		if count >= s.keySetGuard.absentCount && count > 0 {
+			removals = append(removals, k)
+		}
+	}
+	// forget about keys removed some other way
+	for k := range s.keySetGuard.absent {
+		if _, ok := stored[k]; !ok {
+			delete(s.keySetGuard.absent, k)
+		}
+	}
+	sort.Strings(removals)
+	return removals
+}
+
+func (s *StorageRESTServer) currentKeySetResponse(c *gin.Context, status int, resp *CurrentKeySetResponse) {
+	content, err := json.Marshal(resp)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		c.Error(err)
+		return
+	}
+	c.Data(status, "Content-Type: application/json", content)
 }
 
 // handleCatalogUpsertPost deals with either creating or updating new model content in storage, as well as coordinating
 // that content with the location service and backstage.  It pulls the key from the query parameter and then
-//   - fetches the entry if exists in storage, populating our cache and syncing via a golang mutex to make the operation atomic,
-//     regardless if the backend store is transactional
-//   - stores the latest data for the new key in storage
+//   - stores the latest data for the new key in storage, unless storage already has data with a newer
+//     lastUpdateTimeSinceEpoch; all storage updates are compare-and-swap against the revision fetched, so concurrent
+//     requests, whether to this replica or another one, cannot overwrite each other
 //   - updates the location service with the corresponding URI and content
 //   - if importing to backstage was not previously done, it does that, and then stores the ID returned form backstage in storage
+//
+// An upsert of a key which is a tombstone brings it back, with the Backstage location it had.  The 'source' parameter
+// records which source owns the key, for handleCatalogCurrentKeySetPost.  An upsert whose content digest is the one
+// stored, once the location has been dealt with, changes nothing, and is answered with UpsertUnchanged.
 func (s *StorageRESTServer) handleCatalogUpsertPost(c *gin.Context) {
 	key := c.Query(util.KeyQueryParam)
 	if len(key) == 0 {
@@ -241,7 +649,6 @@ func (s *StorageRESTServer) handleCatalogUpsertPost(c *gin.Context) {
 		c.Error(fmt.Errorf("need a 'key' parameter"))
 		return
 	}
-	reconcilerType := c.Query(util.TypeQueryParam)
 	var postBody rest.PostBody
 	err := c.BindJSON(&postBody)
 	if err != nil {
@@ -251,128 +658,277 @@ func (s *StorageRESTServer) handleCatalogUpsertPost(c *gin.Context) {
 		c.Error(fmt.Errorf("error reading POST body: %s", err.Error()))
 		return
 	}
-	//TOOD soon will have the type of normalizer preface the model name and version
-	segs := strings.Split(key, "_")
-	if len(segs) < 2 {
-		c.Status(http.StatusBadRequest)
-		c.Error(fmt.Errorf("bad key format: %s", key))
+	req, err := s.newUpsertRequest(key, c.Query(util.TypeQueryParam), c.Query(util.SourceQueryParam), postBody)
+	s.auditRejected(requestActor(c), err)
+	if validation.Respond(c, err) {
 		return
 	}
-	uri := ""
-	key, uri = util.BuildImportKeyAndURI(segs[0], segs[1], s.format)
-	klog.Infof("Upserting URI %s with key %s with data of len %d and last epoch %s", uri, key, len(postBody.Body), postBody.LastUpdateTimeSinceEpoch)
-
-	sb := &types.StorageBody{}
-    sb.LastUpdateTimeSinceEpoch = postBody.LastUpdateTimeSinceEpoch
-	sb, err = s.sync(key)
 	if err != nil {
-		klog.Error(err.Error())
-		c.Status(http.StatusInternalServerError)
+		c.Status(http.StatusBadRequest)
+		c.Error(err)
 		return
 	}
+	req.actor = requestActor(c)
+	klog.Infof("Upserting URI %s with key %s with data of len %d and last epoch %s", req.uri, req.key, len(postBody.Body), postBody.LastUpdateTimeSinceEpoch)
+	err = s.adopt(req)
+	if err != nil {
+		klog.Errorf("error moving key %s to key %s: %s", util.LegacyKey(req.key), req.key, err.Error())
+	}
 
-	alreadyPushed := len(sb.LocationId) > 0
-	sb.Body = postBody.Body
-	sb.ReconcilerType = reconcilerType
-	err = s.st.Upsert(key, *sb)
+	req.stored, err = s.update(req.key, func(sb *types.StorageBody) (bool, error) {
+		return req.apply(sb), nil
+	})
+	s.auditUpsert(req, err)
 	if err != nil {
 		c.Status(http.StatusInternalServerError)
-		msg := fmt.Sprintf("error upserting to storage key %s POST body: %s", key, err.Error())
+		msg := fmt.Sprintf("error upserting to storage key %s POST body: %s", req.key, err.Error())
 		klog.Error(msg)
-		c.Error(fmt.Errorf("error upserting to storage key %s POST body: %s", key, err.Error()))
+		c.Error(fmt.Errorf("error upserting to storage key %s POST body: %s", req.key, err.Error()))
+		return
+	}
+	if req.stale {
+		klog.Infof("ignoring upsert for %s because incoming version %s is older than %s", req.key, postBody.LastUpdateTimeSinceEpoch, req.stored.LastUpdateTimeSinceEpoch)
+		c.Status(http.StatusOK)
+		return
+	}
+	if s.settled(req) {
+		klog.V(4).Infof("upsert for %s is unchanged from digest %s", req.key, req.stored.Digest)
+		content, err := json.Marshal(rest.UpsertResponse{Key: req.key, Result: rest.UpsertUnchanged, Digest: req.stored.Digest})
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			c.Error(err)
+			return
+		}
+		c.Data(http.StatusOK, "Content-Type: application/json", content)
 		return
 	}
 
 	// push update to bridge locations REST endpoint
 	var rc int
 	var msg string
-	rc, msg, err = s.locations.UpsertModel(key, &postBody)
+	rc, msg, err = s.locations.UpsertModel(req.key, req.effectiveSource(), &req.postBody)
 	if err != nil {
 		c.Status(http.StatusInternalServerError)
-		msg = fmt.Sprintf("error upserting to bridge uri %s POST body: msg %s error %s", uri, msg, err.Error())
+		msg = fmt.Sprintf("error upserting to bridge uri %s POST body: msg %s error %s", req.uri, msg, err.Error())
 		klog.Error(msg)
-		c.Error(fmt.Errorf("error upserting to bridge uri %s POST body: msg %s error %s", uri, msg, err.Error()))
+		c.Error(fmt.Errorf("error upserting to bridge uri %s POST body: msg %s error %s", req.uri, msg, err.Error()))
 		return
 	}
 	if rc != http.StatusCreated && rc != http.StatusOK {
 		c.Status(rc)
-		msg = fmt.Sprintf("error upserting to bridge uri %s POST body: msg %s", uri, msg)
+		msg = fmt.Sprintf("error upserting to bridge uri %s POST body: msg %s", req.uri, msg)
 		klog.Error(msg)
-		c.Error(fmt.Errorf("error upserting to bridge uri %s POST body: msg %s", uri, msg))
+		c.Error(fmt.Errorf("error upserting to bridge uri %s POST body: msg %s", req.uri, msg))
 	}
 
-	if alreadyPushed {
-		// now see if backstage has been recycled to the point where a location we imported in the past
-		// is no longer present
-		var locationMap map[string]any
-		locationMap, err = s.bkstg.GetLocation(sb.LocationId)
-		switch {
-		case err != nil || locationMap == nil || len(locationMap) == 0:
-			klog.Infof("location %s no longer is present in backstage: %v, %v", sb.LocationId, err, locationMap)
-			alreadyPushed = false
-		default:
-			locID, locTarget, locOK := rest.ParseImportLocationMap(locationMap)
-			if !locOK {
-				klog.Infof("location %s no longer is present in backstage: %v, %v", sb.LocationId, err, locationMap)
-				alreadyPushed = false
-			} else {
-				klog.V(4).Infof("location id %s for target %s still present in backstage", locID, locTarget)
-			}
-		}
+	rc, err = s.pushLocation(req)
+	c.Status(rc)
+	if err != nil {
+		c.Error(err)
+	}
+}
+
+// upsertRequest is a model to upsert, which came on its own or as part of a batch
+type upsertRequest struct {
+	key            string
+	uri            string
+	reconcilerType string
+	source         string
+	postBody       rest.PostBody
+	// invalid is what lenient validation found wrong with the content
+	invalid []types.FieldError
+	// actor is who the audit log records the upsert for
+	actor auditActor
+	// stale, unchanged, revived, bodyChanged and stored are what storing the model found
+	stale       bool
+	unchanged   bool
+	revived     bool
+	bodyChanged bool
+	stored      types.StorageBody
+}
+
+func (s *StorageRESTServer) newUpsertRequest(key, reconcilerType, source string, postBody rest.PostBody) (*upsertRequest, error) {
+	if len(key) == 0 {
+		return nil, fmt.Errorf("need a 'key' parameter")
+	}
+	// the key is that of the source, so that models of different sources with the same names are kept apart; entries
+	// stored without a source keep the key from before sources were part of keys
+	seg1, seg2, ok := util.KeySegments(key)
+	if !ok {
+		return nil, fmt.Errorf("bad key format: %s", key)
+	}
+	req := &upsertRequest{reconcilerType: reconcilerType, source: source, postBody: postBody}
+	req.key = util.BuildSourceKey(source, seg1, seg2)
+	req.uri = util.BuildSourceURI(req.effectiveSource(), seg1, seg2, s.format)
+	digest := postBody.ContentDigest()
+	if len(postBody.Digest) > 0 && postBody.Digest != digest {
+		return nil, fmt.Errorf("digest %s sent for key %s does not match the content, whose digest is %s", postBody.Digest, req.key, digest)
+	}
+	req.postBody.Digest = digest
+	var err error
+	req.invalid, err = validation.Check(s.validationMode, req.key, postBody.Body, s.format)
+	if err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// adopt moves the entry the source of the request has under the key from before sources were part of keys, if there
+// is one, to the key of the request, along with its Backstage location, so that the model is not imported again; the
+// location service keeps the old key only to redirect to the new one.  Should the new key already be there, the old
+// entry is just removed, and its location, if it has one of its own, is left to the drift pass to delete.
+func (s *StorageRESTServer) adopt(req *upsertRequest) error {
+	legacy := util.LegacyKey(req.key)
+	if legacy == req.key {
+		return nil
+	}
+	sb, rev, err := s.st.Fetch(legacy)
+	if err != nil || len(rev) == 0 || !sb.OwnedBy(req.source) {
+		return err
+	}
+	_, err = s.st.Upsert(req.key, sb, "")
+	if err != nil && !types.IsConflict(err) {
+		return err
+	}
+	err = s.st.Remove(legacy, rev)
+	if types.IsConflict(err) {
+		klog.Infof("not removing key %s as it was updated while it was being moved to key %s: %s", legacy, req.key, err.Error())
+		return nil
+	}
+	reason := fmt.Sprintf("moved to key %s", req.key)
+	s.audit(req.actor, AuditEntry{Action: AuditActionRemove, Key: legacy, Reason: reason, Digest: sb.Digest, LocationId: sb.LocationId}, err)
+	if err != nil {
+		return err
+	}
+	s.del(legacy)
+	klog.Infof("key %s %s", legacy, reason)
+	return nil
+}
+
+// effectiveSource is the source the location service serves the model under
+func (req *upsertRequest) effectiveSource() string {
+	return types.EffectiveSource(req.source, req.reconcilerType)
+}
+
+// settled says whether an upsert of unchanged content can stop there, which is when the location service already has
+// the content and Backstage the location, or the location is not imported by us; otherwise the upsert carries on, so
+// that an earlier one which did not get that far is finished
+func (s *StorageRESTServer) settled(req *upsertRequest) bool {
+	return req.unchanged && (len(req.stored.LocationId) > 0 || !s.pushToRHDH)
+}
 
+// apply puts the model on top of the stored entry, returning false when the model is older than what is stored, or
+// the same as what is stored
+func (req *upsertRequest) apply(sb *types.StorageBody) bool {
+	req.stale = false
+	req.unchanged = false
+	req.revived = false
+	if types.IsOlderEpoch(req.postBody.LastUpdateTimeSinceEpoch, sb.LastUpdateTimeSinceEpoch) {
+		req.stale = true
+		return false
+	}
+	// entries stored before model cards were kept get theirs with the next upsert
+	if sb.Digest == req.postBody.Digest && sb.ModelCard == req.postBody.ModelCard && sb.Tombstone == nil && sb.ReconcilerType == req.reconcilerType && sb.Source == req.source {
+		// a newer lastUpdateTimeSinceEpoch alone is not worth a write
+		req.unchanged = true
+		return false
+	}
+	req.bodyChanged = !bytes.Equal(sb.Body, req.postBody.Body)
+	if sb.Tombstone != nil {
+		klog.Infof("key %s is back, after being removed by %s at %s, so keeping location %s", req.key, sb.Tombstone.RemovedBy, sb.Tombstone.RemovedAt.Format(time.RFC3339), sb.LocationId)
+		sb.Tombstone = nil
+		req.revived = true
 	}
-	// if we have not previously pushed to backstage, do so now;
-	// we use a sync map here in case our store implementation does not provide atomic updates
+	sb.Body = req.postBody.Body
+	sb.Digest = req.postBody.Digest
+	sb.Invalid = req.invalid
+	sb.ReconcilerType = req.reconcilerType
+	sb.Source = req.source
+	sb.ModelCardKey = req.postBody.ModelCardKey
+	sb.ModelCard = req.postBody.ModelCard
+	sb.LastUpdateTimeSinceEpoch = req.postBody.LastUpdateTimeSinceEpoch
+	return true
+}
+
+// pushLocation imports the location of the stored model into Backstage, unless that was done before, returning the
+// status code for the upsert
+func (s *StorageRESTServer) pushLocation(req *upsertRequest) (int, error) {
+	// whether Backstage still has the location is left to the drift pass, see reconcileBackstage
+	alreadyPushed := len(req.stored.LocationId) > 0
+
+	// if we have not previously pushed to backstage, do so now
 	if alreadyPushed {
-		klog.Info(fmt.Sprintf("%s already provides location %s", s.locations.UpsertURL, uri))
-		c.Status(http.StatusOK)
-		return
+		klog.Info(fmt.Sprintf("%s already provides location %s", s.locations.UpsertURL, req.uri))
+		if req.bodyChanged {
+			// rather than wait for Backstage to notice the new content, have it process the entities again
+			s.queueRefresh(req.key, req.postBody.Body)
+		}
+		return http.StatusOK, nil
 	}
 
+	key := req.key
+	target := s.locations.HostURL + req.uri
+	pushResult := ""
+	locID := ""
+	locTarget := ""
 	switch {
 	case !s.setupBkstg():
-		klog.Warningf("Access to Backstage is not available so will not import location %s", sb.LocationId)
+		klog.Warningf("Access to Backstage is not available so will not import location %s", target)
+		pushResult = types.PushResultBackstageUnavailable
 	case !s.pushToRHDH:
 		klog.V(4).Info("directly importing locations to Backstage has been disabled")
+		pushResult = types.PushResultPushDisabled
 	default:
-		impResp := map[string]any{}
-		impResp, err = s.bkstg.ImportLocation(s.locations.HostURL + uri)
+		impResp, err := s.bkstg.ImportLocation(target)
 		if err != nil {
-			c.Status(http.StatusInternalServerError)
-			msg = fmt.Sprintf("error importing location %s to backstage: %s", s.locations.HostURL+uri, err.Error())
-			klog.Error(msg)
+			klog.Errorf("error importing location %s to backstage: %s", target, err.Error())
+			s.audit(req.actor, AuditEntry{Action: AuditActionImport, Key: key}, err)
+			s.enqueue(OutboxOpImport, key, target, "", err)
+			_, err = s.update(key, func(sb *types.StorageBody) (bool, error) {
+				sb.LastPushResult = types.PushResultImportFailed
+				return true, nil
+			})
+			if err != nil {
+				klog.Errorf("error recording failed import for key %s in storage: %s", key, err.Error())
+			}
 			// let's not error out if backstage is not available for a push / import location ... backstage will pull
-			// when it comes up
-			c.Status(http.StatusCreated)
-			return
+			// when it comes up, and the outbox retries the import
+			return http.StatusCreated, nil
 		}
 		retID, retTarget, rok := rest.ParseImportLocationMap(impResp)
 		if !rok {
 			//TODO perhaps delete location on the backstage side as well as our cache
-			c.Status(http.StatusBadRequest)
-			msg = fmt.Sprintf("parsing of import location return had an issue: %#v", impResp)
-			klog.Error(msg)
-			c.Error(fmt.Errorf("parsing of import location return had an issue: %#v", impResp))
-			return
+			klog.Errorf("parsing of import location return had an issue: %#v", impResp)
+			err = fmt.Errorf("parsing of import location return had an issue: %#v", impResp)
+			s.audit(req.actor, AuditEntry{Action: AuditActionImport, Key: key}, err)
+			return http.StatusBadRequest, err
 		}
+		s.audit(req.actor, AuditEntry{Action: AuditActionImport, Key: key, LocationId: retID}, nil)
 
-		sb.LocationId = retID
-		sb.LocationTarget = retTarget
+		locID = retID
+		locTarget = retTarget
+		pushResult = types.PushResultImported
 
 	}
 
-	// finally store in our storage layer with the id and cross reference location URL from backstage
-	err = s.st.Upsert(key, *sb)
+	// finally store in our storage layer with the id and cross reference location URL from backstage, on top of
+	// whatever is in storage now
+	_, err := s.update(key, func(sb *types.StorageBody) (bool, error) {
+		if len(locID) > 0 {
+			sb.LocationId = locID
+			sb.LocationTarget = locTarget
+		}
+		changed := sb.LastPushResult != pushResult || len(locID) > 0
+		sb.LastPushResult = pushResult
+		return changed, nil
+	})
 	if err != nil {
-		c.Status(http.StatusInternalServerError)
 		//TODO perhaps delete location on the backstage side as well as our cache
-		msg = fmt.Sprintf("error upserting to storage key %s POST body plus backstage ID: %s", key, err.Error())
-		klog.Error(msg)
-		c.Error(fmt.Errorf("error upserting to storage key %s POST body plus backstage ID: %s", key, err.Error()))
-		return
+		klog.Errorf("error upserting to storage key %s POST body plus backstage ID: %s", key, err.Error())
+		return http.StatusInternalServerError, fmt.Errorf("error upserting to storage key %s POST body plus backstage ID: %s", key, err.Error())
 	}
 
-	c.Status(http.StatusCreated)
+	return http.StatusCreated, nil
 }
 
 type DiscoverResponse struct {
@@ -390,6 +946,7 @@ func (s *StorageRESTServer) handleCatalogList(c *gin.Context) {
 		c.Error(fmt.Errorf("error listing location keys: %s", err.Error()))
 		return
 	}
+	d.Keys = modelKeys(d.Keys)
 	var content []byte
 	content, err = json.Marshal(d)
 	if err != nil {
@@ -400,6 +957,7 @@ func (s *StorageRESTServer) handleCatalogList(c *gin.Context) {
 	c.Data(http.StatusOK, "Content-Type: application/json", content)
 }
 
+// handleCatalogFetch returns the entry for the 'key' parameter, or a 404 when storage does not have it
 func (s *StorageRESTServer) handleCatalogFetch(c *gin.Context) {
 	key := c.Query(util.KeyQueryParam)
 	if len(key) == 0 {
@@ -407,9 +965,19 @@ func (s *StorageRESTServer) handleCatalogFetch(c *gin.Context) {
 		c.Error(fmt.Errorf("need a 'key' parameter"))
 		return
 	}
-	var err error
-	sb := types.StorageBody{}
-	sb, err = s.st.Fetch(key)
+	sb, rev, err := s.st.Fetch(key)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		msg := fmt.Sprintf("error fetching key %s: %s", key, err.Error())
+		klog.Error(msg)
+		c.Error(fmt.Errorf("error fetching key %s: %s", key, err.Error()))
+		return
+	}
+	if len(rev) == 0 || !isModelKey(key) {
+		c.Status(http.StatusNotFound)
+		c.Error(fmt.Errorf("key %s not found", key))
+		return
+	}
 	var content []byte
 	content, err = json.Marshal(sb)
 	if err != nil {
@@ -420,6 +988,145 @@ func (s *StorageRESTServer) handleCatalogFetch(c *gin.Context) {
 	c.Data(http.StatusOK, "Content-Type: application/json", content)
 }
 
+// handleStorageUsage reports how much room is left in storage backends that have size limits
+func (s *StorageRESTServer) handleStorageUsage(c *gin.Context) {
+	reporter, ok := s.st.(types.BridgeStorageUsageReporter)
+	if !ok {
+		c.Status(http.StatusNotImplemented)
+		c.Error(fmt.Errorf("the storage backend does not report usage"))
+		return
+	}
+	usage, err := reporter.Usage()
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		msg := fmt.Sprintf("error getting storage usage: %s", err.Error())
+		klog.Error(msg)
+		c.Error(err)
+		return
+	}
+	var content []byte
+	content, err = json.Marshal(usage)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		c.Error(err)
+		return
+	}
+	c.Data(http.StatusOK, "Content-Type: application/json", content)
+}
+
+type HistoryResponse struct {
+	Key       string                  `json:"key"`
+	Revisions []types.StorageRevision `json:"revisions"`
+}
+
+// handleCatalogHistory returns every revision of a key from storage backends which keep them, or with the 'asOf'
+// parameter set to an RFC 3339 time, the entry as it was at that time
+func (s *StorageRESTServer) handleCatalogHistory(c *gin.Context) {
+	key := c.Query(util.KeyQueryParam)
+	if len(key) == 0 {
+		c.Status(http.StatusBadRequest)
+		c.Error(fmt.Errorf("need a 'key' parameter"))
+		return
+	}
+	history, ok := s.st.(types.BridgeStorageHistory)
+	if !ok {
+		c.Status(http.StatusNotImplemented)
+		c.Error(fmt.Errorf("the storage backend does not keep history"))
+		return
+	}
+	var content []byte
+	var err error
+	asOfStr := c.Query(util.AsOfQueryParam)
+	if len(asOfStr) > 0 {
+		var asOf time.Time
+		asOf, err = time.Parse(time.RFC3339, asOfStr)
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			c.Error(fmt.Errorf("bad '%s' parameter %s: %s", util.AsOfQueryParam, asOfStr, err.Error()))
+			return
+		}
+		sb := types.StorageBody{}
+		sb, err = history.FetchAsOf(key, asOf)
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			msg := fmt.Sprintf("error fetching key %s as of %s: %s", key, asOfStr, err.Error())
+			klog.Error(msg)
+			c.Error(fmt.Errorf("error fetching key %s as of %s: %s", key, asOfStr, err.Error()))
+			return
+		}
+		content, err = json.Marshal(sb)
+	} else {
+		h := &HistoryResponse{Key: key}
+		h.Revisions, err = history.History(key)
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			msg := fmt.Sprintf("error getting history for key %s: %s", key, err.Error())
+			klog.Error(msg)
+			c.Error(fmt.Errorf("error getting history for key %s: %s", key, err.Error()))
+			return
+		}
+		content, err = json.Marshal(h)
+	}
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		c.Error(err)
+		return
+	}
+	c.Data(http.StatusOK, "Content-Type: application/json", content)
+}
+
+// handleCatalogWatch streams the storage change feed as one JSON encoded types.WatchEvent per line, starting with the
+// events after the 'since' sequence if we still have them, or else with every key followed by a SYNCED event.  The
+// stream ends if the consumer falls too far behind, and it should then reconnect with the last sequence it got.
+func (s *StorageRESTServer) handleCatalogWatch(c *gin.Context) {
+	since := int64(0)
+	sinceStr := c.Query(util.SinceQueryParam)
+	if len(sinceStr) > 0 {
+		var err error
+		since, err = strconv.ParseInt(sinceStr, 10, 64)
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			c.Error(fmt.Errorf("bad '%s' parameter %s: %s", util.SinceQueryParam, sinceStr, err.Error()))
+			return
+		}
+	}
+	replay, ch, err := s.feed.subscribe(since)
+	if err != nil {
+		c.Status(http.StatusServiceUnavailable)
+		c.Error(err)
+		return
+	}
+	defer s.feed.unsubscribe(ch)
+
+	c.Header("Content-Type", "application/json")
+	c.Status(http.StatusOK)
+	enc := json.NewEncoder(c.Writer)
+	for _, ev := range replay {
+		if err = enc.Encode(ev); err != nil {
+			return
+		}
+	}
+	c.Writer.Flush()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-s.draining:
+			klog.Info("ending watch as we are shutting down")
+			return
+		case ev, ok := <-ch:
+			if !ok {
+				return
+			}
+			if err = enc.Encode(ev); err != nil {
+				klog.Infof("ending watch: %s", err.Error())
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
+
 func GetRESTConfig() (*k8srest.Config, error) {
 	restConfig, err := util.InClusterConfigHackForRHDHSidecars()
 	if restConfig == nil || err != nil {
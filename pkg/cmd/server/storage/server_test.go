@@ -7,7 +7,9 @@ import (
 	"fmt"
 	"github.com/gin-gonic/gin"
 	bkstgclient "github.com/redhat-ai-dev/model-catalog-bridge/pkg/cmd/cli/backstage"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/cmd/server/auth"
 	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/cmd/server/storage/configmap"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/cmd/server/storage/sqlite"
 	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/rest"
 	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/types"
 	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/util"
@@ -18,12 +20,17 @@ import (
 	"io"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes/fake"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 )
 
 func Test_handleCatalogList_handleCatalogFetch_ConfigMap(t *testing.T) {
@@ -252,12 +259,7 @@ func Test_handleCatalogUpsertPost_handleCatalogCurrentKeySetPost_ConfigMap(t *te
 			}
 		}
 		if keys.Has(util.KeyQueryParam) && ctx.Writer.Status() == http.StatusOK {
-			val := keys.Get(util.KeyQueryParam)
-			// storage cache should be populated
-			_, ok := s.pushedLocations[val]
-			common.AssertEqual(t, ok, true)
-
-			// backstage should not be called again
+			// backstage should not be called again, as checking the location is still there is left to the drift pass
 			found := false
 			backstageCallback.Range(func(key, value any) bool {
 				found = true
@@ -312,3 +314,1668 @@ func Test_handleCatalogUpsertPost_handleCatalogCurrentKeySetPost_ConfigMap(t *te
 	common.AssertEqual(t, true, ok)
 
 }
+
+func Test_handleCatalogHistory(t *testing.T) {
+	st := sqlite.NewSQLiteBridgeStorageForTest(filepath.Join(t.TempDir(), "bridge.db"))
+	err := st.Initialize(nil)
+	common.AssertError(t, err)
+	defer st.Close()
+	_, err = st.Upsert("mnist_v1", types.StorageBody{Body: []byte("create")}, types.AnyRevision)
+	common.AssertError(t, err)
+	_, err = st.Upsert("mnist_v1", types.StorageBody{Body: []byte("update")}, types.AnyRevision)
+	common.AssertError(t, err)
+
+	cms := configmap.NewConfigMapBridgeStorageForTest(metav1.NamespaceDefault, fake.NewClientset().CoreV1())
+
+	for _, tc := range []struct {
+		name         string
+		st           types.BridgeStorage
+		query        string
+		expectedSC   int
+		expectedBody string
+		expectedRevs int
+	}{
+		{
+			name:       "no key",
+			st:         st,
+			expectedSC: http.StatusBadRequest,
+		},
+		{
+			name:       "no history kept",
+			st:         cms,
+			query:      "key=mnist_v1",
+			expectedSC: http.StatusNotImplemented,
+		},
+		{
+			name:         "all revisions",
+			st:           st,
+			query:        "key=mnist_v1",
+			expectedSC:   http.StatusOK,
+			expectedRevs: 2,
+		},
+		{
+			name:         "as of now",
+			st:           st,
+			query:        "key=mnist_v1&asOf=" + url.QueryEscape(time.Now().Add(time.Minute).Format(time.RFC3339)),
+			expectedSC:   http.StatusOK,
+			expectedBody: "update",
+		},
+		{
+			name:         "as of before the key existed",
+			st:           st,
+			query:        "key=mnist_v1&asOf=2020-01-01T00:00:00Z",
+			expectedSC:   http.StatusOK,
+			expectedBody: "",
+		},
+		{
+			name:       "bad as of",
+			st:         st,
+			query:      "key=mnist_v1&asOf=yesterday",
+			expectedSC: http.StatusBadRequest,
+		},
+	} {
+		testWriter := testgin.NewTestResponseWriter()
+		ctx, eng := gin.CreateTestContext(testWriter)
+		ctx.Request = &http.Request{URL: &url.URL{RawQuery: tc.query}}
+		s := &StorageRESTServer{
+			router:          eng,
+			st:              tc.st,
+			mutex:           sync.Mutex{},
+			pushedLocations: map[string]*types.StorageBody{},
+		}
+
+		s.handleCatalogHistory(ctx)
+
+		common.AssertEqual(t, tc.expectedSC, ctx.Writer.Status())
+		if tc.expectedSC != http.StatusOK {
+			continue
+		}
+		if strings.Contains(tc.query, util.AsOfQueryParam) {
+			sb := &types.StorageBody{}
+			err = json.Unmarshal(testWriter.ResponseWriter.Body.Bytes(), sb)
+			common.AssertError(t, err)
+			common.AssertEqual(t, tc.expectedBody, string(sb.Body))
+			continue
+		}
+		h := &HistoryResponse{}
+		err = json.Unmarshal(testWriter.ResponseWriter.Body.Bytes(), h)
+		common.AssertError(t, err)
+		common.AssertEqual(t, "mnist_v1", h.Key)
+		common.AssertEqual(t, tc.expectedRevs, len(h.Revisions))
+	}
+}
+
+// racingStorage has another writer sneak in an update the first time a key is upserted
+type racingStorage struct {
+	types.BridgeStorage
+	raced bool
+}
+
+func (r *racingStorage) Upsert(key string, value types.StorageBody, expectedRevision string) (string, error) {
+	if !r.raced {
+		r.raced = true
+		_, err := r.BridgeStorage.Upsert(key, types.StorageBody{Body: []byte("other replica"), LocationId: "loc-id"}, types.AnyRevision)
+		if err != nil {
+			return "", err
+		}
+	}
+	return r.BridgeStorage.Upsert(key, value, expectedRevision)
+}
+
+func Test_update_conflictRetry(t *testing.T) {
+	backing := sqlite.NewSQLiteBridgeStorageForTest(filepath.Join(t.TempDir(), "bridge.db"))
+	err := backing.Initialize(nil)
+	common.AssertError(t, err)
+	defer backing.Close()
+	st := &racingStorage{BridgeStorage: backing}
+	s := &StorageRESTServer{
+		st:              st,
+		mutex:           sync.Mutex{},
+		pushedLocations: map[string]*types.StorageBody{},
+	}
+
+	calls := 0
+	sb, err := s.update("mnist_v1", func(sb *types.StorageBody) (bool, error) {
+		calls++
+		sb.LastPushResult = types.PushResultImported
+		return true, nil
+	})
+	common.AssertError(t, err)
+	common.AssertEqual(t, 2, calls)
+	common.AssertEqual(t, true, st.raced)
+
+	// the retry built on what the other writer stored instead of overwriting it
+	stored, _, err := backing.Fetch("mnist_v1")
+	common.AssertError(t, err)
+	common.AssertEqual(t, sb, stored)
+	common.AssertEqual(t, "other replica", string(stored.Body))
+	common.AssertEqual(t, "loc-id", stored.LocationId)
+	common.AssertEqual(t, types.PushResultImported, stored.LastPushResult)
+}
+
+func Test_handleCatalogUpsertPost_olderEpoch(t *testing.T) {
+	st := sqlite.NewSQLiteBridgeStorageForTest(filepath.Join(t.TempDir(), "bridge.db"))
+	err := st.Initialize(nil)
+	common.AssertError(t, err)
+	defer st.Close()
+	current := types.StorageBody{Body: []byte("newer"), LastUpdateTimeSinceEpoch: "2000"}
+	_, err = st.Upsert("mnist_v1", current, "")
+	common.AssertError(t, err)
+
+	data, err := json.Marshal(rest.PostBody{Body: []byte("older"), LastUpdateTimeSinceEpoch: "1000"})
+	common.AssertError(t, err)
+	testWriter := testgin.NewTestResponseWriter()
+	ctx, eng := gin.CreateTestContext(testWriter)
+	ctx.Request = &http.Request{URL: &url.URL{RawQuery: "key=mnist_v1"}, Body: io.NopCloser(bytes.NewReader(data))}
+	// no location or backstage clients, as neither should be called for an out of date upsert
+	s := &StorageRESTServer{
+		router:          eng,
+		st:              st,
+		mutex:           sync.Mutex{},
+		pushedLocations: map[string]*types.StorageBody{},
+	}
+
+	s.handleCatalogUpsertPost(ctx)
+
+	common.AssertEqual(t, http.StatusOK, ctx.Writer.Status())
+	stored, _, err := st.Fetch("mnist_v1")
+	common.AssertError(t, err)
+	common.AssertEqual(t, current, stored)
+}
+
+func Test_handleCatalogCurrentKeySetPost_safeguards(t *testing.T) {
+	locationCallback := sync.Map{}
+	brts := location.CreateBridgeLocationServerWithCallbackMap(&locationCallback, t)
+	defer brts.Close()
+	backstageCallback := sync.Map{}
+	bks := backstage.CreateBackstageServerWithCallbackMap(&backstageCallback, t)
+	defer bks.Close()
+
+	st := sqlite.NewSQLiteBridgeStorageForTest(filepath.Join(t.TempDir(), "bridge.db"))
+	err := st.Initialize(nil)
+	common.AssertError(t, err)
+	defer st.Close()
+	for _, key := range []string{"a_v1", "b_v1", "c_v1", "d_v1"} {
+		_, err = st.Upsert(key, types.StorageBody{Body: []byte(key)}, types.AnyRevision)
+		common.AssertError(t, err)
+	}
+	s := &StorageRESTServer{
+		st:              st,
+		mutex:           sync.Mutex{},
+		pushedLocations: map[string]*types.StorageBody{},
+		locations:       location.SetupBridgeLocationRESTClient(brts),
+		bkstg:           (&bkstgclient.BackstageRESTClientWrapper{RESTClient: common.DC(), RootURL: bks.URL}),
+		keySetGuard:     keySetGuard{maxRemoveRatio: 0.5, absentCount: 2},
+	}
+
+	for _, tc := range []struct {
+		name     string
+		query    string
+		sc       int
+		expected CurrentKeySetResponse
+		stored   []string
+	}{
+		{
+			name:     "first absence",
+			query:    "key=a_v1,b_v1,c_v1",
+			sc:       http.StatusOK,
+			expected: CurrentKeySetResponse{Removed: []string{}, Tombstoned: []string{}, WouldRemove: []string{}, Absent: map[string]int{"d_v1": 1}},
+			stored:   []string{"a_v1", "b_v1", "c_v1", "d_v1"},
+		},
+		{
+			name:     "incomplete",
+			query:    "key=&incomplete=true",
+			sc:       http.StatusOK,
+			expected: CurrentKeySetResponse{Removed: []string{}, Tombstoned: []string{}, WouldRemove: []string{}, Absent: map[string]int{"a_v1": 0, "b_v1": 0, "c_v1": 0, "d_v1": 1}, Incomplete: true},
+			stored:   []string{"a_v1", "b_v1", "c_v1", "d_v1"},
+		},
+		{
+			name:     "dry run",
+			query:    "key=a_v1,b_v1,c_v1&dryRun=true",
+			sc:       http.StatusOK,
+			expected: CurrentKeySetResponse{Removed: []string{}, Tombstoned: []string{}, WouldRemove: []string{"d_v1"}, Absent: map[string]int{"d_v1": 2}, DryRun: true},
+			stored:   []string{"a_v1", "b_v1", "c_v1", "d_v1"},
+		},
+		{
+			name:     "removed after enough absences",
+			query:    "key=a_v1",
+			sc:       http.StatusOK,
+			expected: CurrentKeySetResponse{Removed: []string{"d_v1"}, Tombstoned: []string{}, WouldRemove: []string{}, Absent: map[string]int{"b_v1": 1, "c_v1": 1}},
+			stored:   []string{"a_v1", "b_v1", "c_v1"},
+		},
+		{
+			name:     "too many removals",
+			query:    "key=a_v1",
+			sc:       http.StatusConflict,
+			expected: CurrentKeySetResponse{Removed: []string{}, Tombstoned: []string{}, WouldRemove: []string{"b_v1", "c_v1"}, Absent: map[string]int{"b_v1": 2, "c_v1": 2}, Blocked: true},
+			stored:   []string{"a_v1", "b_v1", "c_v1"},
+		},
+		{
+			name:     "back again",
+			query:    "key=a_v1,b_v1,c_v1",
+			sc:       http.StatusOK,
+			expected: CurrentKeySetResponse{Removed: []string{}, Tombstoned: []string{}, WouldRemove: []string{}, Absent: map[string]int{}},
+			stored:   []string{"a_v1", "b_v1", "c_v1"},
+		},
+		{
+			name:  "bad flag",
+			query: "key=a_v1&incomplete=maybe",
+			sc:    http.StatusBadRequest,
+			// the earlier key sets are unaffected
+			stored: []string{"a_v1", "b_v1", "c_v1"},
+		},
+	} {
+		testWriter := testgin.NewTestResponseWriter()
+		ctx, _ := gin.CreateTestContext(testWriter)
+		ctx.Request = &http.Request{URL: &url.URL{RawQuery: tc.query}}
+
+		s.handleCatalogCurrentKeySetPost(ctx)
+
+		if ctx.Writer.Status() != tc.sc {
+			t.Errorf("%s: expected %d but got %d: %v", tc.name, tc.sc, ctx.Writer.Status(), ctx.Errors)
+		}
+		if tc.sc != http.StatusBadRequest {
+			resp := CurrentKeySetResponse{}
+			err = json.Unmarshal(testWriter.ResponseWriter.Body.Bytes(), &resp)
+			common.AssertError(t, err)
+			common.AssertEqual(t, tc.expected, resp)
+		}
+		keys, err := st.List()
+		common.AssertError(t, err)
+		sort.Strings(keys)
+		common.AssertEqual(t, tc.stored, keys)
+	}
+	_, ok := locationCallback.Load("delete")
+	common.AssertEqual(t, true, ok)
+}
+
+func Test_handleCatalogCurrentKeySetPost_sources(t *testing.T) {
+	locationCallback := sync.Map{}
+	brts := location.CreateBridgeLocationServerWithCallbackMap(&locationCallback, t)
+	defer brts.Close()
+	backstageCallback := sync.Map{}
+	bks := backstage.CreateBackstageServerWithCallbackMap(&backstageCallback, t)
+	defer bks.Close()
+
+	st := sqlite.NewSQLiteBridgeStorageForTest(filepath.Join(t.TempDir(), "bridge.db"))
+	err := st.Initialize(nil)
+	common.AssertError(t, err)
+	defer st.Close()
+	regA := types.SourceID(types.KubeflowNormalizer, "ns:reg-a")
+	regB := types.SourceID(types.KubeflowNormalizer, "ns:reg-b")
+	for key, sb := range map[string]types.StorageBody{
+		"granite_v1":      {Body: []byte("granite"), ReconcilerType: types.KubeflowNormalizer, Source: regB},
+		"llama_v1":        {Body: []byte("llama"), ReconcilerType: types.KServeNormalizer, Source: types.KServeNormalizer},
+		"legacy_v1":       {Body: []byte("legacy"), ReconcilerType: types.KubeflowNormalizer},
+		"legacykserve_v1": {Body: []byte("legacykserve"), ReconcilerType: types.KServeNormalizer},
+	} {
+		_, err = st.Upsert(key, sb, types.AnyRevision)
+		common.AssertError(t, err)
+	}
+	s := &StorageRESTServer{
+		st:              st,
+		mutex:           sync.Mutex{},
+		pushedLocations: map[string]*types.StorageBody{},
+		locations:       location.SetupBridgeLocationRESTClient(brts),
+		bkstg:           (&bkstgclient.BackstageRESTClientWrapper{RESTClient: common.DC(), RootURL: bks.URL}),
+		keySetGuard:     keySetGuard{absentCount: 2},
+	}
+
+	// the upsert records the source, and stores the model under the key of the source
+	mnistKey := util.BuildSourceKey(regA, "mnist", "v1")
+	data, err := json.Marshal(rest.PostBody{Body: []byte("mnist")})
+	common.AssertError(t, err)
+	testWriter := testgin.NewTestResponseWriter()
+	ctx, _ := gin.CreateTestContext(testWriter)
+	ctx.Request = &http.Request{URL: &url.URL{RawQuery: "key=mnist_v1&type=kubeflow&source=" + url.QueryEscape(regA)}, Body: io.NopCloser(bytes.NewReader(data))}
+	s.handleCatalogUpsertPost(ctx)
+	sb, _, err := st.Fetch(mnistKey)
+	common.AssertError(t, err)
+	common.AssertEqual(t, regA, sb.Source)
+
+	for _, tc := range []struct {
+		name     string
+		query    string
+		expected CurrentKeySetResponse
+		stored   []string
+	}{
+		{
+			name:     "empty registry",
+			query:    "key=&source=" + url.QueryEscape(regA),
+			expected: CurrentKeySetResponse{Removed: []string{}, Tombstoned: []string{}, WouldRemove: []string{}, Absent: map[string]int{"legacy_v1": 1, mnistKey: 1}, Source: regA},
+			stored:   []string{"granite_v1", mnistKey, "legacy_v1", "legacykserve_v1", "llama_v1"},
+		},
+		{
+			name:     "kserve",
+			query:    "key=llama_v1&source=" + types.KServeNormalizer,
+			expected: CurrentKeySetResponse{Removed: []string{}, Tombstoned: []string{}, WouldRemove: []string{}, Absent: map[string]int{"legacykserve_v1": 1}, Source: types.KServeNormalizer},
+			stored:   []string{"granite_v1", mnistKey, "legacy_v1", "legacykserve_v1", "llama_v1"},
+		},
+		{
+			// an entry stored before sources were recorded belongs to every source of its reconciler type
+			name:     "other registry",
+			query:    "key=granite_v1&source=" + url.QueryEscape(regB),
+			expected: CurrentKeySetResponse{Removed: []string{"legacy_v1"}, Tombstoned: []string{}, WouldRemove: []string{}, Absent: map[string]int{}, Source: regB},
+			stored:   []string{"granite_v1", mnistKey, "legacykserve_v1", "llama_v1"},
+		},
+		{
+			// the absences counted for the first registry are kept across the key sets of the other sources
+			name:     "empty registry again",
+			query:    "key=&source=" + url.QueryEscape(regA),
+			expected: CurrentKeySetResponse{Removed: []string{mnistKey}, Tombstoned: []string{}, WouldRemove: []string{}, Absent: map[string]int{}, Source: regA},
+			stored:   []string{"granite_v1", "legacykserve_v1", "llama_v1"},
+		},
+	} {
+		testWriter = testgin.NewTestResponseWriter()
+		ctx, _ = gin.CreateTestContext(testWriter)
+		ctx.Request = &http.Request{URL: &url.URL{RawQuery: tc.query}}
+
+		s.handleCatalogCurrentKeySetPost(ctx)
+
+		if ctx.Writer.Status() != http.StatusOK {
+			t.Errorf("%s: expected %d but got %d: %v", tc.name, http.StatusOK, ctx.Writer.Status(), ctx.Errors)
+		}
+		resp := CurrentKeySetResponse{}
+		err = json.Unmarshal(testWriter.ResponseWriter.Body.Bytes(), &resp)
+		common.AssertError(t, err)
+		common.AssertEqual(t, tc.expected, resp)
+		keys, err := st.List()
+		common.AssertError(t, err)
+		sort.Strings(keys)
+		common.AssertEqual(t, tc.stored, keys)
+	}
+
+	// a key of another source that cannot be fetched is neither counted nor removed, however many times it is missing
+	s.st = &fetchFailingStorage{BridgeStorage: st, failing: "granite_v1"}
+	for i := 0; i < 3; i++ {
+		testWriter = testgin.NewTestResponseWriter()
+		ctx, _ = gin.CreateTestContext(testWriter)
+		ctx.Request = &http.Request{URL: &url.URL{RawQuery: "key=&source=" + url.QueryEscape(regA)}}
+
+		s.handleCatalogCurrentKeySetPost(ctx)
+
+		common.AssertEqual(t, http.StatusOK, ctx.Writer.Status())
+		resp := CurrentKeySetResponse{}
+		err = json.Unmarshal(testWriter.ResponseWriter.Body.Bytes(), &resp)
+		common.AssertError(t, err)
+		common.AssertEqual(t, CurrentKeySetResponse{Removed: []string{}, Tombstoned: []string{}, WouldRemove: []string{}, Absent: map[string]int{}, Unfetched: []string{"granite_v1"}, Source: regA, Incomplete: true}, resp)
+	}
+	keys, err := st.List()
+	common.AssertError(t, err)
+	sort.Strings(keys)
+	common.AssertEqual(t, []string{"granite_v1", "legacykserve_v1", "llama_v1"}, keys)
+}
+
+func Test_handleCatalogCurrentKeySetPost_overlappingSources(t *testing.T) {
+	locationCallback := sync.Map{}
+	brts := location.CreateBridgeLocationServerWithCallbackMap(&locationCallback, t)
+	defer brts.Close()
+	backstageCallback := sync.Map{}
+	bks := backstage.CreateBackstageServerWithCallbackMap(&backstageCallback, t)
+	defer bks.Close()
+
+	st := sqlite.NewSQLiteBridgeStorageForTest(filepath.Join(t.TempDir(), "bridge.db"))
+	err := st.Initialize(nil)
+	common.AssertError(t, err)
+	defer st.Close()
+	s := &StorageRESTServer{
+		st:              st,
+		mutex:           sync.Mutex{},
+		pushedLocations: map[string]*types.StorageBody{},
+		locations:       location.SetupBridgeLocationRESTClient(brts),
+		bkstg:           (&bkstgclient.BackstageRESTClientWrapper{RESTClient: common.DC(), RootURL: bks.URL}),
+		keySetGuard:     keySetGuard{absentCount: 2},
+	}
+	regA := types.SourceID(types.KubeflowNormalizer, "ns:reg-a")
+	regB := types.SourceID(types.KubeflowNormalizer, "ns:reg-b")
+	keyA := util.BuildSourceKey(regA, "mnist", "v1")
+	keyB := util.BuildSourceKey(regB, "mnist", "v1")
+	keyKServe := util.BuildSourceKey(types.KServeNormalizer, "mnist", "v1")
+
+	// both registries, and KServe, have a model with the same names, which each upsert in turn
+	for _, source := range []string{regA, regB, types.KServeNormalizer, regA} {
+		data, err := json.Marshal(rest.PostBody{Body: []byte("mnist " + source)})
+		common.AssertError(t, err)
+		testWriter := testgin.NewTestResponseWriter()
+		ctx, _ := gin.CreateTestContext(testWriter)
+		reconcilerType, _, _ := strings.Cut(source, "/")
+		ctx.Request = &http.Request{URL: &url.URL{RawQuery: "key=mnist_v1&type=" + reconcilerType + "&source=" + url.QueryEscape(source)}, Body: io.NopCloser(bytes.NewReader(data))}
+		s.handleCatalogUpsertPost(ctx)
+	}
+	for key, source := range map[string]string{keyA: regA, keyB: regB, keyKServe: types.KServeNormalizer} {
+		sb, _, err := st.Fetch(key)
+		common.AssertError(t, err)
+		common.AssertEqual(t, source, sb.Source)
+		common.AssertEqual(t, "mnist "+source, string(sb.Body))
+	}
+
+	postKeySet := func(query string) CurrentKeySetResponse {
+		testWriter := testgin.NewTestResponseWriter()
+		ctx, _ := gin.CreateTestContext(testWriter)
+		ctx.Request = &http.Request{URL: &url.URL{RawQuery: query}}
+		s.handleCatalogCurrentKeySetPost(ctx)
+		common.AssertEqual(t, http.StatusOK, ctx.Writer.Status())
+		resp := CurrentKeySetResponse{}
+		common.AssertError(t, json.Unmarshal(testWriter.ResponseWriter.Body.Bytes(), &resp))
+		return resp
+	}
+
+	// each source only covers its own key, whether the normalizer posts the key of the source or the model and version
+	for i := 0; i < 2; i++ {
+		common.AssertEqual(t, map[string]int{}, postKeySet("key=mnist_v1&source="+url.QueryEscape(regA)).Absent)
+		common.AssertEqual(t, map[string]int{}, postKeySet("key="+keyKServe+"&source="+types.KServeNormalizer).Absent)
+	}
+	keys, err := st.List()
+	common.AssertError(t, err)
+	sort.Strings(keys)
+	common.AssertEqual(t, []string{keyKServe, keyA, keyB}, keys)
+
+	// and the model gone from one registry is removed from that registry only
+	common.AssertEqual(t, map[string]int{keyB: 1}, postKeySet("key=&source="+url.QueryEscape(regB)).Absent)
+	common.AssertEqual(t, []string{keyB}, postKeySet("key=&source="+url.QueryEscape(regB)).Removed)
+	keys, err = st.List()
+	common.AssertError(t, err)
+	sort.Strings(keys)
+	common.AssertEqual(t, []string{keyKServe, keyA}, keys)
+	sb, _, err := st.Fetch(keyA)
+	common.AssertError(t, err)
+	common.AssertEqual(t, regA, sb.Source)
+}
+
+func Test_handleCatalogUpsertPost_legacyKey(t *testing.T) {
+	locationCallback := sync.Map{}
+	brts := location.CreateBridgeLocationServerWithCallbackMap(&locationCallback, t)
+	defer brts.Close()
+	backstageCallback := sync.Map{}
+	bks := backstage.CreateBackstageServerWithCallbackMap(&backstageCallback, t)
+	defer bks.Close()
+
+	st := sqlite.NewSQLiteBridgeStorageForTest(filepath.Join(t.TempDir(), "bridge.db"))
+	err := st.Initialize(nil)
+	common.AssertError(t, err)
+	defer st.Close()
+	regA := types.SourceID(types.KubeflowNormalizer, "ns:reg-a")
+	regB := types.SourceID(types.KubeflowNormalizer, "ns:reg-b")
+	locID := "e83bc2d8-0f1c-49f2-b65b-8bfbbbe29ae2"
+	_, err = st.Upsert("mnist_v1", types.StorageBody{Body: []byte("mnist"), ReconcilerType: types.KubeflowNormalizer, Source: regA, LocationId: locID, LocationTarget: "http://foo.com/kubeflow/ns:reg-a/mnist/v1/catalog-info.yaml", LocationIDValid: true}, types.AnyRevision)
+	common.AssertError(t, err)
+	s := &StorageRESTServer{
+		st:              st,
+		mutex:           sync.Mutex{},
+		pushedLocations: map[string]*types.StorageBody{},
+		locations:       location.SetupBridgeLocationRESTClient(brts),
+		bkstg:           (&bkstgclient.BackstageRESTClientWrapper{RESTClient: common.DC(), RootURL: bks.URL}),
+		pushToRHDH:      true,
+	}
+	upsert := func(source, body string) {
+		data, err := json.Marshal(rest.PostBody{Body: []byte(body)})
+		common.AssertError(t, err)
+		testWriter := testgin.NewTestResponseWriter()
+		ctx, _ := gin.CreateTestContext(testWriter)
+		ctx.Request = &http.Request{URL: &url.URL{RawQuery: "key=mnist_v1&type=kubeflow&source=" + url.QueryEscape(source)}, Body: io.NopCloser(bytes.NewReader(data))}
+		s.handleCatalogUpsertPost(ctx)
+		if ctx.Writer.Status() != http.StatusOK && ctx.Writer.Status() != http.StatusCreated {
+			t.Errorf("upsert for %s got %d: %v", source, ctx.Writer.Status(), ctx.Errors)
+		}
+	}
+
+	// the key from before sources were part of keys moves to the key of its source, keeping its location
+	upsert(regA, "mnist a")
+	keyA := util.BuildSourceKey(regA, "mnist", "v1")
+	keys, err := st.List()
+	common.AssertError(t, err)
+	common.AssertEqual(t, []string{keyA}, keys)
+	sb, _, err := st.Fetch(keyA)
+	common.AssertError(t, err)
+	common.AssertEqual(t, locID, sb.LocationId)
+	common.AssertEqual(t, "mnist a", string(sb.Body))
+	_, ok := backstageCallback.Load("body")
+	common.AssertEqual(t, false, ok)
+	_, ok = backstageCallback.Load("delete")
+	common.AssertEqual(t, false, ok)
+
+	// the same model of another registry gets its own key and location
+	upsert(regB, "mnist b")
+	keyB := util.BuildSourceKey(regB, "mnist", "v1")
+	keys, err = st.List()
+	common.AssertError(t, err)
+	sort.Strings(keys)
+	common.AssertEqual(t, []string{keyA, keyB}, keys)
+	sb, _, err = st.Fetch(keyA)
+	common.AssertError(t, err)
+	common.AssertEqual(t, "mnist a", string(sb.Body))
+	common.AssertEqual(t, regA, sb.Source)
+	sb, _, err = st.Fetch(keyB)
+	common.AssertError(t, err)
+	common.AssertEqual(t, "mnist b", string(sb.Body))
+	common.AssertEqual(t, regB, sb.Source)
+	_, ok = backstageCallback.Load("body")
+	common.AssertEqual(t, true, ok)
+}
+
+func Test_tombstones(t *testing.T) {
+	locationCallback := sync.Map{}
+	brts := location.CreateBridgeLocationServerWithCallbackMap(&locationCallback, t)
+	defer brts.Close()
+	backstageCallback := sync.Map{}
+	bks := backstage.CreateBackstageServerWithCallbackMap(&backstageCallback, t)
+	defer bks.Close()
+
+	st := sqlite.NewSQLiteBridgeStorageForTest(filepath.Join(t.TempDir(), "bridge.db"))
+	err := st.Initialize(nil)
+	common.AssertError(t, err)
+	defer st.Close()
+	// the location id the backstage stub knows about
+	locID := "e83bc2d8-0f1c-49f2-b65b-8bfbbbe29ae2"
+	_, err = st.Upsert("mnist_v1", types.StorageBody{Body: []byte("mnist"), LocationId: locID, LocationTarget: "http://foo.com/mnist/v1/catalog-info.yaml", LocationIDValid: true, LastUpdateTimeSinceEpoch: "1000"}, types.AnyRevision)
+	common.AssertError(t, err)
+	s := &StorageRESTServer{
+		st:              st,
+		mutex:           sync.Mutex{},
+		pushedLocations: map[string]*types.StorageBody{},
+		locations:       location.SetupBridgeLocationRESTClient(brts),
+		bkstg:           (&bkstgclient.BackstageRESTClientWrapper{RESTClient: common.DC(), RootURL: bks.URL}),
+		tombstoneGrace:  time.Hour,
+	}
+	postKeySet := func() CurrentKeySetResponse {
+		testWriter := testgin.NewTestResponseWriter()
+		ctx, _ := gin.CreateTestContext(testWriter)
+		ctx.Request = &http.Request{URL: &url.URL{RawQuery: "key="}}
+		s.handleCatalogCurrentKeySetPost(ctx)
+		common.AssertEqual(t, http.StatusOK, ctx.Writer.Status())
+		resp := CurrentKeySetResponse{}
+		common.AssertError(t, json.Unmarshal(testWriter.ResponseWriter.Body.Bytes(), &resp))
+		return resp
+	}
+
+	// the missing key becomes a tombstone, which the location service is told about, rather than being removed
+	resp := postKeySet()
+	common.AssertEqual(t, []string{"mnist_v1"}, resp.Tombstoned)
+	common.AssertEqual(t, 0, len(resp.Removed))
+	sb, _, err := st.Fetch("mnist_v1")
+	common.AssertError(t, err)
+	common.AssertNotNil(t, sb.Tombstone)
+	common.AssertEqual(t, "missing from the current key set", sb.Tombstone.Reason)
+	common.AssertEqual(t, time.Hour, sb.Tombstone.ExpiresAt.Sub(sb.Tombstone.RemovedAt))
+	common.AssertEqual(t, locID, sb.LocationId)
+	body, ok := locationCallback.Load("body")
+	common.AssertEqual(t, true, ok)
+	common.AssertContains(t, body.(string), []string{`"tombstone":{`})
+	_, ok = backstageCallback.Load("delete")
+	common.AssertEqual(t, false, ok)
+
+	// it is not missing all over again
+	resp = postKeySet()
+	common.AssertEqual(t, 0, len(resp.Tombstoned))
+	common.AssertEqual(t, 0, len(resp.Absent))
+
+	// the model coming back keeps its location
+	data, err := json.Marshal(rest.PostBody{Body: []byte("mnist again"), LastUpdateTimeSinceEpoch: "1000"})
+	common.AssertError(t, err)
+	testWriter := testgin.NewTestResponseWriter()
+	ctx, _ := gin.CreateTestContext(testWriter)
+	ctx.Request = &http.Request{URL: &url.URL{RawQuery: "key=mnist_v1"}, Body: io.NopCloser(bytes.NewReader(data))}
+	s.handleCatalogUpsertPost(ctx)
+	common.AssertEqual(t, http.StatusOK, ctx.Writer.Status())
+	sb, _, err = st.Fetch("mnist_v1")
+	common.AssertError(t, err)
+	common.AssertEqual(t, true, sb.Tombstone == nil)
+	common.AssertEqual(t, locID, sb.LocationId)
+	common.AssertEqual(t, "mnist again", string(sb.Body))
+	_, ok = backstageCallback.Load("body")
+	common.AssertEqual(t, false, ok)
+
+	// once the grace period is up, the entry and its location are removed
+	_ = postKeySet()
+	sb, rev, err := st.Fetch("mnist_v1")
+	common.AssertError(t, err)
+	sb.Tombstone.ExpiresAt = time.Now().Add(-time.Second)
+	_, err = st.Upsert("mnist_v1", sb, rev)
+	common.AssertError(t, err)
+	s.purgeTombstones(context.Background())
+	keys, err := st.List()
+	common.AssertError(t, err)
+	common.AssertEqual(t, 0, len(keys))
+	_, ok = backstageCallback.Load("delete")
+	common.AssertEqual(t, true, ok)
+	_, ok = locationCallback.Load("delete")
+	common.AssertEqual(t, true, ok)
+}
+
+// watchStorage hands out the channels the test fills in as the storage watch
+type watchStorage struct {
+	types.BridgeStorage
+	watches chan chan types.WatchEvent
+}
+
+func (w *watchStorage) Watch(ctx context.Context) (<-chan types.WatchEvent, error) {
+	return <-w.watches, nil
+}
+
+func watchEvents(events ...types.WatchEvent) chan types.WatchEvent {
+	ch := make(chan types.WatchEvent, len(events))
+	for _, ev := range events {
+		ch <- ev
+	}
+	close(ch)
+	return ch
+}
+
+func Test_changeFeed(t *testing.T) {
+	st := &watchStorage{watches: make(chan chan types.WatchEvent, 2)}
+	f := newChangeFeed(st)
+	start := f.sequence
+
+	_, _, err := f.subscribe(0)
+	if err == nil {
+		t.Error("expected an error subscribing before the feed synced")
+	}
+
+	st.watches <- watchEvents(
+		types.WatchEvent{Type: types.WatchEventAdded, Key: "mnist_v1", Revision: "1"},
+		types.WatchEvent{Type: types.WatchEventAdded, Key: "mnist_v2", Revision: "1"},
+		types.WatchEvent{Type: types.WatchEventSynced},
+		types.WatchEvent{Type: types.WatchEventModified, Key: "mnist_v1", Revision: "2"},
+	)
+	f.watch(context.Background())
+	common.AssertEqual(t, start+3, f.sequence)
+
+	replay, ch, err := f.subscribe(0)
+	common.AssertError(t, err)
+	common.AssertEqual(t, 3, len(replay))
+	common.AssertEqual(t, types.WatchEventAdded, replay[0].Type)
+	common.AssertEqual(t, "2", replay[0].Revision)
+	common.AssertEqual(t, types.WatchEventSynced, replay[2].Type)
+	common.AssertEqual(t, f.sequence, replay[2].Sequence)
+	f.unsubscribe(ch)
+
+	// resume after the second ADDED
+	replay, ch, err = f.subscribe(start + 2)
+	common.AssertError(t, err)
+	common.AssertEqual(t, 1, len(replay))
+	common.AssertEqual(t, types.WatchEventModified, replay[0].Type)
+	common.AssertEqual(t, start+3, replay[0].Sequence)
+
+	// a restarted watch only passes on what changed while we were not watching
+	st.watches <- watchEvents(
+		types.WatchEvent{Type: types.WatchEventAdded, Key: "mnist_v1", Revision: "2"},
+		types.WatchEvent{Type: types.WatchEventAdded, Key: "mnist_v3", Revision: "1"},
+		types.WatchEvent{Type: types.WatchEventSynced},
+	)
+	f.watch(context.Background())
+	for _, expected := range []types.WatchEvent{
+		{Type: types.WatchEventAdded, Key: "mnist_v3", Revision: "1", Sequence: start + 4},
+		{Type: types.WatchEventDeleted, Key: "mnist_v2", Sequence: start + 5},
+	} {
+		common.AssertEqual(t, expected, <-ch)
+	}
+	f.unsubscribe(ch)
+
+	// sequences we do not have get the full set of keys
+	for _, since := range []int64{1, start + 100} {
+		replay, ch, err = f.subscribe(since)
+		common.AssertError(t, err)
+		common.AssertEqual(t, 3, len(replay))
+		common.AssertEqual(t, types.WatchEventSynced, replay[2].Type)
+		f.unsubscribe(ch)
+	}
+}
+
+func Test_handleCatalogWatch(t *testing.T) {
+	st := &watchStorage{watches: make(chan chan types.WatchEvent, 1)}
+	live := make(chan types.WatchEvent, 4)
+	live <- types.WatchEvent{Type: types.WatchEventAdded, Key: "mnist_v1", Revision: "1", Value: &types.StorageBody{Body: []byte("create")}}
+	live <- types.WatchEvent{Type: types.WatchEventSynced}
+	st.watches <- live
+	gin.SetMode(gin.TestMode)
+	eng := gin.New()
+	s := &StorageRESTServer{
+		router:          eng,
+		st:              st,
+		mutex:           sync.Mutex{},
+		pushedLocations: map[string]*types.StorageBody{},
+		feed:            newChangeFeed(st),
+	}
+	eng.GET(util.WatchURI, s.handleCatalogWatch)
+	ts := httptest.NewServer(eng)
+	defer ts.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.feed.watch(ctx)
+	err := wait.PollUntilContextTimeout(ctx, 10*time.Millisecond, 10*time.Second, true, func(ctx context.Context) (bool, error) {
+		s.feed.mutex.Lock()
+		defer s.feed.mutex.Unlock()
+		return s.feed.synced, nil
+	})
+	common.AssertError(t, err)
+
+	cl := SetupBridgeStorageRESTClient(ts.URL, "")
+	events := []types.WatchEvent{}
+	err = cl.Watch(ctx, 0, func(ev types.WatchEvent) error {
+		events = append(events, ev)
+		switch len(events) {
+		case 2:
+			live <- types.WatchEvent{Type: types.WatchEventDeleted, Key: "mnist_v1"}
+		case 3:
+			return fmt.Errorf("done")
+		}
+		return nil
+	})
+	common.AssertEqual(t, "done", err.Error())
+	common.AssertEqual(t, 3, len(events))
+	common.AssertEqual(t, "create", string(events[0].Value.Body))
+	common.AssertEqual(t, types.WatchEventSynced, events[1].Type)
+	common.AssertEqual(t, types.WatchEventDeleted, events[2].Type)
+	common.AssertEqual(t, events[1].Sequence+1, events[2].Sequence)
+
+	for _, tc := range []struct {
+		since      string
+		expectedSC int
+	}{
+		{since: "yesterday", expectedSC: http.StatusBadRequest},
+	} {
+		resp, err := http.Get(ts.URL + util.WatchURI + "?since=" + tc.since)
+		common.AssertError(t, err)
+		common.AssertEqual(t, tc.expectedSC, resp.StatusCode)
+		resp.Body.Close()
+	}
+}
+
+// failingBackstage fails the given number of imports and deletes before they start to succeed
+type failingBackstage struct {
+	failures int
+	imports  []string
+	deletes  []string
+}
+
+func (f *failingBackstage) ImportLocation(url string) (map[string]any, error) {
+	if f.failures > 0 {
+		f.failures--
+		return nil, fmt.Errorf("backstage is down")
+	}
+	f.imports = append(f.imports, url)
+	return map[string]any{"location": map[string]any{"id": fmt.Sprintf("loc-%d", len(f.imports)), "target": url}}, nil
+}
+
+func (f *failingBackstage) DeleteLocation(id string) (string, error) {
+	if f.failures > 0 {
+		f.failures--
+		return "", fmt.Errorf("backstage is down")
+	}
+	f.deletes = append(f.deletes, id)
+	return "", nil
+}
+
+func (f *failingBackstage) GetLocation(id string) (map[string]any, error) {
+	return map[string]any{"id": id, "target": "http://foo.com"}, nil
+}
+
+func Test_outbox(t *testing.T) {
+	locationCallback := sync.Map{}
+	brts := location.CreateBridgeLocationServerWithCallbackMap(&locationCallback, t)
+	defer brts.Close()
+
+	st := sqlite.NewSQLiteBridgeStorageForTest(filepath.Join(t.TempDir(), "bridge.db"))
+	err := st.Initialize(nil)
+	common.AssertError(t, err)
+	defer st.Close()
+	bkstg := &failingBackstage{failures: 1}
+	s := &StorageRESTServer{
+		st:                st,
+		mutex:             sync.Mutex{},
+		pushedLocations:   map[string]*types.StorageBody{},
+		locations:         location.SetupBridgeLocationRESTClient(brts),
+		bkstg:             bkstg,
+		pushToRHDH:        true,
+		outboxMaxAttempts: 2,
+	}
+	outbox := func() []OutboxEntry {
+		testWriter := testgin.NewTestResponseWriter()
+		ctx, _ := gin.CreateTestContext(testWriter)
+		ctx.Request = &http.Request{URL: &url.URL{}}
+		s.handleOutboxGet(ctx)
+		common.AssertEqual(t, http.StatusOK, ctx.Writer.Status())
+		resp := OutboxResponse{}
+		err = json.Unmarshal(testWriter.ResponseWriter.Body.Bytes(), &resp)
+		common.AssertError(t, err)
+		return resp.Entries
+	}
+	replay := func(query string, sc int) {
+		testWriter := testgin.NewTestResponseWriter()
+		ctx, _ := gin.CreateTestContext(testWriter)
+		ctx.Request = &http.Request{URL: &url.URL{RawQuery: query}}
+		s.handleOutboxReplayPost(ctx)
+		common.AssertEqual(t, sc, ctx.Writer.Status())
+	}
+
+	// a failed import still succeeds the upsert, and is queued
+	data, err := json.Marshal(rest.PostBody{Body: []byte("mnist")})
+	common.AssertError(t, err)
+	testWriter := testgin.NewTestResponseWriter()
+	ctx, _ := gin.CreateTestContext(testWriter)
+	ctx.Request = &http.Request{URL: &url.URL{RawQuery: "key=mnist_v1"}, Body: io.NopCloser(bytes.NewReader(data))}
+	s.handleCatalogUpsertPost(ctx)
+	common.AssertEqual(t, http.StatusCreated, ctx.Writer.Status())
+	entries := outbox()
+	common.AssertEqual(t, 1, len(entries))
+	common.AssertEqual(t, OutboxOpImport, entries[0].Op)
+	common.AssertEqual(t, "mnist_v1", entries[0].Key)
+	common.AssertEqual(t, "backstage is down", entries[0].LastError)
+
+	// the outbox is not among the models
+	testWriter = testgin.NewTestResponseWriter()
+	ctx, _ = gin.CreateTestContext(testWriter)
+	ctx.Request = &http.Request{URL: &url.URL{}}
+	s.handleCatalogList(ctx)
+	d := DiscoverResponse{}
+	err = json.Unmarshal(testWriter.ResponseWriter.Body.Bytes(), &d)
+	common.AssertError(t, err)
+	common.AssertEqual(t, []string{"mnist_v1"}, d.Keys)
+
+	// nothing is retried before it is due
+	s.processOutbox(context.Background())
+	common.AssertEqual(t, 0, len(bkstg.imports))
+
+	// a replica started later retries it, and records the location
+	replay("id="+entries[0].ID, http.StatusOK)
+	replay("id=bogus", http.StatusNotFound)
+	restarted := &StorageRESTServer{st: st, mutex: sync.Mutex{}, pushedLocations: map[string]*types.StorageBody{}, locations: s.locations, bkstg: bkstg}
+	restarted.processOutbox(context.Background())
+	common.AssertEqual(t, 1, len(bkstg.imports))
+	common.AssertEqual(t, 0, len(outbox()))
+	sb, _, err := st.Fetch("mnist_v1")
+	common.AssertError(t, err)
+	common.AssertEqual(t, "loc-1", sb.LocationId)
+	common.AssertEqual(t, types.PushResultImported, sb.LastPushResult)
+
+	// a failed delete is queued, and dead-lettered once it runs out of attempts
+	due := func() {
+		pending, revs, err := s.listOutbox()
+		common.AssertError(t, err)
+		for _, e := range pending {
+			e.NextAttempt = time.Time{}
+			_, err = s.storeOutboxEntry(e, revs[e.ID])
+			common.AssertError(t, err)
+		}
+	}
+	sb, rev, err := st.Fetch("mnist_v1")
+	common.AssertError(t, err)
+	bkstg.failures = 2
+	done, err := s.removeKey("mnist_v1", sb, rev, systemActor, "tombstone expired")
+	common.AssertError(t, err)
+	common.AssertEqual(t, true, done)
+	entries = outbox()
+	common.AssertEqual(t, 1, len(entries))
+	common.AssertEqual(t, OutboxOpDelete, entries[0].Op)
+	common.AssertEqual(t, "loc-1", entries[0].LocationId)
+	common.AssertEqual(t, 1, entries[0].Attempts)
+	due()
+	s.processOutbox(context.Background())
+	entries = outbox()
+	common.AssertEqual(t, 1, len(entries))
+	common.AssertEqual(t, true, entries[0].DeadLettered)
+	common.AssertEqual(t, 2, entries[0].Attempts)
+	due()
+	s.processOutbox(context.Background())
+	common.AssertEqual(t, 0, len(bkstg.deletes))
+
+	// replaying every dead-lettered entry gets it through
+	replay("", http.StatusOK)
+	s.processOutbox(context.Background())
+	common.AssertEqual(t, []string{"loc-1"}, bkstg.deletes)
+	common.AssertEqual(t, 0, len(outbox()))
+}
+
+// catalogBackstage is a Backstage which can list its locations and entities, and refresh entities
+type catalogBackstage struct {
+	failingBackstage
+	locations []map[string]any
+	entities  []map[string]any
+	refreshes []string
+}
+
+func (b *catalogBackstage) ListLocationMaps() ([]map[string]any, error) {
+	return b.locations, nil
+}
+
+func (b *catalogBackstage) QueryEntityMaps(filters ...string) ([]map[string]any, error) {
+	return b.entities, nil
+}
+
+func (b *catalogBackstage) RefreshEntity(entityRef string) error {
+	b.refreshes = append(b.refreshes, entityRef)
+	return nil
+}
+
+func Test_reconcileBackstage(t *testing.T) {
+	locationCallback := sync.Map{}
+	brts := location.CreateBridgeLocationServerWithCallbackMap(&locationCallback, t)
+	defer brts.Close()
+
+	st := sqlite.NewSQLiteBridgeStorageForTest(filepath.Join(t.TempDir(), "bridge.db"))
+	err := st.Initialize(nil)
+	common.AssertError(t, err)
+	defer st.Close()
+	locations := location.SetupBridgeLocationRESTClient(brts)
+	locations.HostURL = "http://bridge.svc"
+	target := func(name string) string {
+		return fmt.Sprintf("http://bridge.svc/%s/v1/catalog-info.yaml", name)
+	}
+	entity := func(name, target string, errs ...string) map[string]any {
+		items := []any{}
+		for _, e := range errs {
+			items = append(items, map[string]any{"type": "backstage.io/catalog-processing", "level": "error", "message": "InputError", "error": map[string]any{"message": e}})
+		}
+		return map[string]any{
+			"kind":     "Component",
+			"metadata": map[string]any{"name": name, "namespace": "default", "annotations": map[string]any{managedByLocationAnnotation: "rhdh-rhoai-bridge:" + target}},
+			"status":   map[string]any{"items": items},
+		}
+	}
+	bkstg := &catalogBackstage{
+		locations: []map[string]any{
+			{"id": "loc-granite", "type": "rhdh-rhoai-bridge", "target": target("granite")},
+			{"id": "loc-llama", "type": "rhdh-rhoai-bridge", "target": target("llama")},
+			{"id": "loc-llama-dup", "type": "rhdh-rhoai-bridge", "target": target("llama")},
+			{"id": "loc-old", "type": "rhdh-rhoai-bridge", "target": target("old")},
+			{"id": "loc-orphan", "type": "rhdh-rhoai-bridge", "target": target("gone")},
+			{"id": "loc-github", "type": "url", "target": "https://github.com/foo/bar/catalog-info.yaml"},
+		},
+		entities: []map[string]any{
+			entity("llama", target("llama"), "spec.owner is missing"),
+			entity("granite", target("granite")),
+			entity("bar", "https://github.com/foo/bar/catalog-info.yaml", "not ours"),
+		},
+	}
+	// mnist lost its location, granite never recorded the one it has, and old is tombstoned
+	for key, sb := range map[string]types.StorageBody{
+		"mnist_v1":   {Body: []byte("mnist"), LocationId: "loc-gone", LocationTarget: target("mnist")},
+		"granite_v1": {Body: []byte("granite"), LastPushResult: types.PushResultBackstageUnavailable},
+		"llama_v1":   {Body: []byte("llama"), LocationId: "loc-llama", LocationTarget: target("llama")},
+		"old_v1":     {Body: []byte("old"), LocationId: "loc-old", LocationTarget: target("old"), Tombstone: &types.Tombstone{RemovedBy: "test", RemovedAt: time.Now()}},
+	} {
+		_, err = st.Upsert(key, sb, "")
+		common.AssertError(t, err)
+	}
+	s := &StorageRESTServer{
+		st:              st,
+		mutex:           sync.Mutex{},
+		pushedLocations: map[string]*types.StorageBody{},
+		locations:       locations,
+		bkstg:           bkstg,
+		pushToRHDH:      true,
+	}
+	drift := func(handler gin.HandlerFunc, query string, sc int) *DriftReport {
+		testWriter := testgin.NewTestResponseWriter()
+		ctx, _ := gin.CreateTestContext(testWriter)
+		ctx.Request = &http.Request{URL: &url.URL{RawQuery: query}}
+		handler(ctx)
+		if ctx.Writer.Status() != sc {
+			t.Fatalf("expected status %d, got %d", sc, ctx.Writer.Status())
+		}
+		if sc != http.StatusOK {
+			return nil
+		}
+		report := &DriftReport{}
+		err = json.Unmarshal(testWriter.ResponseWriter.Body.Bytes(), report)
+		common.AssertError(t, err)
+		return report
+	}
+	check := func(report *DriftReport) {
+		if report.Checked != 4 || report.Locations != 6 {
+			t.Errorf("expected 4 keys checked against 6 locations, got %d and %d", report.Checked, report.Locations)
+		}
+		if fmt.Sprintf("%v", report.Missing) != "[granite_v1 mnist_v1]" {
+			t.Errorf("unexpected missing %v", report.Missing)
+		}
+		if fmt.Sprintf("%v", report.Adopted) != "[granite_v1]" {
+			t.Errorf("unexpected adopted %v", report.Adopted)
+		}
+		if fmt.Sprintf("%v", report.Reimported) != "[mnist_v1]" {
+			t.Errorf("unexpected reimported %v", report.Reimported)
+		}
+		if fmt.Sprintf("%v", report.Orphaned) != "[loc-llama-dup loc-orphan]" {
+			t.Errorf("unexpected orphaned %v", report.Orphaned)
+		}
+		if len(report.FailedEntities) != 1 || report.FailedEntities[0].Ref != "component:default/llama" || report.FailedEntities[0].Key != "llama_v1" ||
+			fmt.Sprintf("%v", report.FailedEntities[0].Errors) != "[spec.owner is missing]" {
+			t.Errorf("unexpected failed entities %#v", report.FailedEntities)
+		}
+		if len(report.Errors) != 0 {
+			t.Errorf("unexpected errors %v", report.Errors)
+		}
+	}
+
+	drift(s.handleDriftGet, "", http.StatusNotFound)
+	drift(s.handleDriftPost, "dryRun=maybe", http.StatusBadRequest)
+
+	// a dry run only reports
+	report := drift(s.handleDriftPost, "dryRun=true", http.StatusOK)
+	check(report)
+	if !report.DryRun || len(bkstg.imports) != 0 || len(bkstg.deletes) != 0 {
+		t.Errorf("dry run changed backstage: %v %v", bkstg.imports, bkstg.deletes)
+	}
+	sb, _, err := st.Fetch("mnist_v1")
+	common.AssertError(t, err)
+	if sb.LocationId != "loc-gone" {
+		t.Errorf("dry run changed storage: %#v", sb)
+	}
+
+	// a real pass repairs
+	report = drift(s.handleDriftPost, "", http.StatusOK)
+	check(report)
+	if fmt.Sprintf("%v", bkstg.imports) != "["+target("mnist")+"]" {
+		t.Errorf("unexpected imports %v", bkstg.imports)
+	}
+	if fmt.Sprintf("%v", bkstg.deletes) != "[loc-llama-dup loc-orphan]" {
+		t.Errorf("unexpected deletes %v", bkstg.deletes)
+	}
+	for key, locID := range map[string]string{"mnist_v1": "loc-1", "granite_v1": "loc-granite", "llama_v1": "loc-llama", "old_v1": "loc-old"} {
+		sb, _, err = st.Fetch(key)
+		common.AssertError(t, err)
+		if sb.LocationId != locID {
+			t.Errorf("expected key %s to have location %s, got %s", key, locID, sb.LocationId)
+		}
+		if (key == "mnist_v1" || key == "granite_v1") && sb.LastPushResult != types.PushResultImported {
+			t.Errorf("expected key %s to be imported, got %s", key, sb.LastPushResult)
+		}
+	}
+	last := drift(s.handleDriftGet, "", http.StatusOK)
+	if last.DryRun || !last.StartedAt.Equal(report.StartedAt) {
+		t.Errorf("expected the last report, got %#v", last)
+	}
+}
+
+// fetchFailingStorage fails to fetch the key
+type fetchFailingStorage struct {
+	types.BridgeStorage
+	failing string
+}
+
+func (f *fetchFailingStorage) Fetch(key string) (types.StorageBody, string, error) {
+	if key == f.failing {
+		return types.StorageBody{}, "", fmt.Errorf("storage unavailable")
+	}
+	return f.BridgeStorage.Fetch(key)
+}
+
+// listHookBackstage calls onList as Backstage lists its locations
+type listHookBackstage struct {
+	*catalogBackstage
+	onList func()
+}
+
+func (b *listHookBackstage) ListLocationMaps() ([]map[string]any, error) {
+	b.onList()
+	return b.catalogBackstage.ListLocationMaps()
+}
+
+func Test_reconcileBackstageKeepsLocationsInUse(t *testing.T) {
+	locationCallback := sync.Map{}
+	brts := location.CreateBridgeLocationServerWithCallbackMap(&locationCallback, t)
+	defer brts.Close()
+	target := func(name string) string {
+		return fmt.Sprintf("http://bridge.svc/%s/v1/catalog-info.yaml", name)
+	}
+	setup := func() (types.BridgeStorage, *catalogBackstage, *StorageRESTServer) {
+		st := sqlite.NewSQLiteBridgeStorageForTest(filepath.Join(t.TempDir(), "bridge.db"))
+		common.AssertError(t, st.Initialize(nil))
+		t.Cleanup(func() { st.Close() })
+		_, err := st.Upsert("granite_v1", types.StorageBody{Body: []byte("granite"), LocationId: "loc-granite", LocationTarget: target("granite")}, "")
+		common.AssertError(t, err)
+		locations := location.SetupBridgeLocationRESTClient(brts)
+		locations.HostURL = "http://bridge.svc"
+		bkstg := &catalogBackstage{
+			locations: []map[string]any{
+				{"id": "loc-granite", "type": "rhdh-rhoai-bridge", "target": target("granite")},
+				{"id": "loc-mnist", "type": "rhdh-rhoai-bridge", "target": target("mnist")},
+				{"id": "loc-orphan", "type": "rhdh-rhoai-bridge", "target": target("gone")},
+			},
+		}
+		s := &StorageRESTServer{
+			st:              st,
+			mutex:           sync.Mutex{},
+			pushedLocations: map[string]*types.StorageBody{},
+			locations:       locations,
+			bkstg:           bkstg,
+			pushToRHDH:      true,
+		}
+		return st, bkstg, s
+	}
+
+	// the location of a key which cannot be fetched is not taken for an orphan, nor is any other location
+	st, bkstg, s := setup()
+	_, err := st.Upsert("mnist_v1", types.StorageBody{Body: []byte("mnist"), LocationId: "loc-mnist", LocationTarget: target("mnist")}, "")
+	common.AssertError(t, err)
+	s.st = &fetchFailingStorage{BridgeStorage: st, failing: "mnist_v1"}
+	report := s.reconcileBackstage(context.Background(), false, systemActor)
+	common.AssertEqual(t, 0, len(bkstg.deletes))
+	common.AssertEqual(t, 0, len(report.Orphaned))
+	if len(report.Errors) == 0 {
+		t.Error("expected the fetch error to be reported")
+	}
+
+	// nor is the location of a key stored after storage was listed, while its location was being imported
+	st, bkstg, s = setup()
+	s.bkstg = &listHookBackstage{catalogBackstage: bkstg, onList: func() {
+		_, err := st.Upsert("mnist_v1", types.StorageBody{Body: []byte("mnist")}, "")
+		common.AssertError(t, err)
+	}}
+	report = s.reconcileBackstage(context.Background(), false, systemActor)
+	common.AssertEqual(t, []string{"loc-orphan"}, bkstg.deletes)
+	common.AssertEqual(t, []string{"loc-orphan"}, report.Orphaned)
+}
+
+func Test_refreshEntities(t *testing.T) {
+	locationCallback := sync.Map{}
+	brts := location.CreateBridgeLocationServerWithCallbackMap(&locationCallback, t)
+	defer brts.Close()
+
+	st := sqlite.NewSQLiteBridgeStorageForTest(filepath.Join(t.TempDir(), "bridge.db"))
+	err := st.Initialize(nil)
+	common.AssertError(t, err)
+	defer st.Close()
+	bkstg := &catalogBackstage{}
+	s := &StorageRESTServer{
+		st:              st,
+		mutex:           sync.Mutex{},
+		pushedLocations: map[string]*types.StorageBody{},
+		locations:       location.SetupBridgeLocationRESTClient(brts),
+		bkstg:           bkstg,
+		pushToRHDH:      true,
+		format:          types.CatalogInfoYamlFormat,
+		refresh:         newRefreshQueue(time.Second, 100),
+	}
+	upsert := func(description, epoch string, sc int) {
+		body := fmt.Sprintf("apiVersion: backstage.io/v1alpha1\nkind: Component\nmetadata:\n  name: mnist-v1\n  description: %s\n---\napiVersion: backstage.io/v1alpha1\nkind: Resource\nmetadata:\n  name: mnist-v1\n", description)
+		data, err := json.Marshal(rest.PostBody{Body: []byte(body), LastUpdateTimeSinceEpoch: epoch})
+		common.AssertError(t, err)
+		testWriter := testgin.NewTestResponseWriter()
+		ctx, _ := gin.CreateTestContext(testWriter)
+		ctx.Request = &http.Request{URL: &url.URL{RawQuery: "key=mnist_v1"}, Body: io.NopCloser(bytes.NewReader(data))}
+		s.handleCatalogUpsertPost(ctx)
+		if ctx.Writer.Status() != sc {
+			t.Fatalf("expected status %d, got %d", sc, ctx.Writer.Status())
+		}
+	}
+
+	// a newly imported location is processed by Backstage anyway
+	upsert("first", "1", http.StatusCreated)
+	s.processRefreshes(context.Background())
+	common.AssertEqual(t, 1, len(bkstg.imports))
+	common.AssertEqual(t, 0, len(bkstg.refreshes))
+
+	// as is content which did not change
+	upsert("first", "2", http.StatusOK)
+	s.processRefreshes(context.Background())
+	common.AssertEqual(t, 0, len(bkstg.refreshes))
+
+	// changes are batched, so each entity is refreshed once
+	upsert("second", "3", http.StatusOK)
+	upsert("third", "4", http.StatusOK)
+	s.processRefreshes(context.Background())
+	if fmt.Sprintf("%v", bkstg.refreshes) != "[component:default/mnist-v1 resource:default/mnist-v1]" {
+		t.Errorf("unexpected refreshes %v", bkstg.refreshes)
+	}
+	s.processRefreshes(context.Background())
+	common.AssertEqual(t, 2, len(bkstg.refreshes))
+
+	// refreshes we did not get to before stopping are kept
+	upsert("fourth", "5", http.StatusOK)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.processRefreshes(ctx)
+	common.AssertEqual(t, 2, len(bkstg.refreshes))
+	common.AssertEqual(t, []string{"component:default/mnist-v1", "resource:default/mnist-v1"}, s.refresh.take())
+}
+
+func Test_handleCatalogUpsertBatchPost(t *testing.T) {
+	locationCallback := sync.Map{}
+	brts := location.CreateBridgeLocationServerWithCallbackMap(&locationCallback, t)
+	defer brts.Close()
+
+	st := sqlite.NewSQLiteBridgeStorageForTest(filepath.Join(t.TempDir(), "bridge.db"))
+	err := st.Initialize(nil)
+	common.AssertError(t, err)
+	defer st.Close()
+	current := types.StorageBody{Body: []byte("newer"), LastUpdateTimeSinceEpoch: "2000"}
+	_, err = st.Upsert("granite_v1", current, "")
+	common.AssertError(t, err)
+
+	bkstg := &failingBackstage{}
+	s := &StorageRESTServer{
+		st:              st,
+		mutex:           sync.Mutex{},
+		pushedLocations: map[string]*types.StorageBody{},
+		locations:       location.SetupBridgeLocationRESTClient(brts),
+		bkstg:           bkstg,
+		pushToRHDH:      true,
+		format:          types.CatalogInfoYamlFormat,
+	}
+	batch := rest.BatchUpsertBody{Entries: []rest.BatchUpsertEntry{
+		{Key: "mnist_v1", Type: types.KubeflowNormalizer, PostBody: rest.PostBody{Body: []byte("mnist"), LastUpdateTimeSinceEpoch: "1000"}},
+		{Key: "nounderscore", PostBody: rest.PostBody{Body: []byte("bad")}},
+		{Key: "granite_v1", PostBody: rest.PostBody{Body: []byte("older"), LastUpdateTimeSinceEpoch: "1000"}},
+		{Key: "llama_v1", Type: types.KubeflowNormalizer, PostBody: rest.PostBody{Body: []byte("llama"), LastUpdateTimeSinceEpoch: "1000"}},
+	}}
+	data, err := json.Marshal(batch)
+	common.AssertError(t, err)
+	testWriter := testgin.NewTestResponseWriter()
+	ctx, _ := gin.CreateTestContext(testWriter)
+	ctx.Request = &http.Request{URL: &url.URL{}, Body: io.NopCloser(bytes.NewReader(data))}
+
+	s.handleCatalogUpsertBatchPost(ctx)
+
+	common.AssertEqual(t, http.StatusOK, ctx.Writer.Status())
+	resp := rest.BatchUpsertResponse{}
+	err = json.Unmarshal(testWriter.ResponseWriter.Body.Bytes(), &resp)
+	common.AssertError(t, err)
+	statuses := []int{}
+	for _, r := range resp.Results {
+		statuses = append(statuses, r.Status)
+	}
+	common.AssertEqual(t, []int{http.StatusCreated, http.StatusBadRequest, http.StatusOK, http.StatusCreated}, statuses)
+
+	// the stale upsert leaves storage alone and is not handed to the location service
+	stored, _, err := st.Fetch("granite_v1")
+	common.AssertError(t, err)
+	common.AssertEqual(t, current, stored)
+	_, ok := locationCallback.Load("batch/granite_v1")
+	common.AssertEqual(t, false, ok)
+
+	for _, key := range []string{"mnist_v1", "llama_v1"} {
+		stored, _, err = st.Fetch(key)
+		common.AssertError(t, err)
+		common.AssertEqual(t, key[:strings.Index(key, "_")], string(stored.Body))
+		common.AssertEqual(t, types.KubeflowNormalizer, stored.ReconcilerType)
+		_, ok = locationCallback.Load("batch/" + key)
+		common.AssertEqual(t, true, ok)
+	}
+	common.AssertEqual(t, 2, len(bkstg.imports))
+}
+
+func Test_handleCatalogUpsertPost_validation(t *testing.T) {
+	locationCallback := sync.Map{}
+	brts := location.CreateBridgeLocationServerWithCallbackMap(&locationCallback, t)
+	defer brts.Close()
+
+	st := sqlite.NewSQLiteBridgeStorageForTest(filepath.Join(t.TempDir(), "bridge.db"))
+	err := st.Initialize(nil)
+	common.AssertError(t, err)
+	defer st.Close()
+	s := &StorageRESTServer{
+		st:              st,
+		mutex:           sync.Mutex{},
+		pushedLocations: map[string]*types.StorageBody{},
+		locations:       location.SetupBridgeLocationRESTClient(brts),
+		bkstg:           &failingBackstage{},
+		format:          types.JsonArrayForamt,
+		validationMode:  types.ValidationStrict,
+	}
+	invalid := []byte(`{"models": [{"name": "mnist", "owner": "user", "lifecycle": "production"}]}`)
+	upsert := func(body []byte) (int, []byte) {
+		data, err := json.Marshal(rest.PostBody{Body: body, LastUpdateTimeSinceEpoch: "1000"})
+		common.AssertError(t, err)
+		testWriter := testgin.NewTestResponseWriter()
+		ctx, _ := gin.CreateTestContext(testWriter)
+		ctx.Request = &http.Request{URL: &url.URL{RawQuery: "key=mnist_v1"}, Body: io.NopCloser(bytes.NewReader(data))}
+		s.handleCatalogUpsertPost(ctx)
+		return ctx.Writer.Status(), testWriter.ResponseWriter.Body.Bytes()
+	}
+
+	// strict mode rejects the content, saying which fields are at fault, and stores nothing
+	sc, body := upsert(invalid)
+	common.AssertEqual(t, http.StatusUnprocessableEntity, sc)
+	resp := rest.ValidationErrorResponse{}
+	err = json.Unmarshal(body, &resp)
+	common.AssertError(t, err)
+	common.AssertEqual(t, []types.FieldError{{Field: "models[0].description", Message: "is required"}}, resp.Errors)
+	keys, err := st.List()
+	common.AssertError(t, err)
+	common.AssertEqual(t, 0, len(keys))
+
+	// lenient mode stores it, marked invalid
+	s.validationMode = types.ValidationLenient
+	sc, _ = upsert(invalid)
+	common.AssertEqual(t, http.StatusCreated, sc)
+	stored, _, err := st.Fetch("mnist_v1")
+	common.AssertError(t, err)
+	common.AssertEqual(t, resp.Errors, stored.Invalid)
+
+	// and clears the mark once the content is fixed
+	sc, _ = upsert([]byte(`{"models": [{"name": "mnist", "owner": "user", "lifecycle": "production", "description": "mnist"}]}`))
+	common.AssertEqual(t, http.StatusCreated, sc)
+	stored, _, err = st.Fetch("mnist_v1")
+	common.AssertError(t, err)
+	common.AssertEqual(t, 0, len(stored.Invalid))
+}
+
+func Test_handleCatalogUpsertPost_unchanged(t *testing.T) {
+	locationCallback := sync.Map{}
+	brts := location.CreateBridgeLocationServerWithCallbackMap(&locationCallback, t)
+	defer brts.Close()
+
+	st := sqlite.NewSQLiteBridgeStorageForTest(filepath.Join(t.TempDir(), "bridge.db"))
+	err := st.Initialize(nil)
+	common.AssertError(t, err)
+	defer st.Close()
+	bkstg := &failingBackstage{}
+	s := &StorageRESTServer{
+		st:              st,
+		mutex:           sync.Mutex{},
+		pushedLocations: map[string]*types.StorageBody{},
+		locations:       location.SetupBridgeLocationRESTClient(brts),
+		bkstg:           bkstg,
+		pushToRHDH:      true,
+		format:          types.CatalogInfoYamlFormat,
+	}
+	upsert := func(postBody rest.PostBody) (int, []byte) {
+		data, err := json.Marshal(postBody)
+		common.AssertError(t, err)
+		testWriter := testgin.NewTestResponseWriter()
+		ctx, _ := gin.CreateTestContext(testWriter)
+		ctx.Request = &http.Request{URL: &url.URL{RawQuery: "key=mnist_v1&type=kubeflow"}, Body: io.NopCloser(bytes.NewReader(data))}
+		s.handleCatalogUpsertPost(ctx)
+		return ctx.Writer.Status(), testWriter.ResponseWriter.Body.Bytes()
+	}
+	postBody := rest.PostBody{Body: []byte("mnist"), LastUpdateTimeSinceEpoch: "1000", ModelCardKey: "mnist", ModelCard: "# mnist"}
+	postBody.Digest = postBody.ContentDigest()
+
+	sc, _ := upsert(postBody)
+	common.AssertEqual(t, http.StatusCreated, sc)
+	stored, rev, err := st.Fetch("mnist_v1")
+	common.AssertError(t, err)
+	common.AssertEqual(t, postBody.Digest, stored.Digest)
+	// the model card is kept, for the location service to get back from storage
+	common.AssertEqual(t, "# mnist", stored.ModelCard)
+	_, ok := locationCallback.LoadAndDelete("body")
+	common.AssertEqual(t, true, ok)
+
+	// the same content, even with a newer lastUpdateTimeSinceEpoch, is neither stored nor pushed again
+	postBody.LastUpdateTimeSinceEpoch = "2000"
+	sc, body := upsert(postBody)
+	common.AssertEqual(t, http.StatusOK, sc)
+	resp := rest.UpsertResponse{}
+	err = json.Unmarshal(body, &resp)
+	common.AssertError(t, err)
+	common.AssertEqual(t, rest.UpsertResponse{Key: "mnist_v1", Result: rest.UpsertUnchanged, Digest: postBody.Digest}, resp)
+	_, unchangedRev, err := st.Fetch("mnist_v1")
+	common.AssertError(t, err)
+	common.AssertEqual(t, rev, unchangedRev)
+	_, ok = locationCallback.Load("body")
+	common.AssertEqual(t, false, ok)
+	common.AssertEqual(t, 1, len(bkstg.imports))
+	// the location is imported from the URI of the model under its source
+	common.AssertEqual(t, "/kubeflow/-/mnist/v1/catalog-info.yaml", bkstg.imports[0])
+
+	// as is the same content in a batch
+	data, err := json.Marshal(rest.BatchUpsertBody{Entries: []rest.BatchUpsertEntry{{Key: "mnist_v1", Type: types.KubeflowNormalizer, PostBody: postBody}}})
+	common.AssertError(t, err)
+	testWriter := testgin.NewTestResponseWriter()
+	ctx, _ := gin.CreateTestContext(testWriter)
+	ctx.Request = &http.Request{URL: &url.URL{}, Body: io.NopCloser(bytes.NewReader(data))}
+	s.handleCatalogUpsertBatchPost(ctx)
+	batchResp := rest.BatchUpsertResponse{}
+	err = json.Unmarshal(testWriter.ResponseWriter.Body.Bytes(), &batchResp)
+	common.AssertError(t, err)
+	common.AssertEqual(t, []rest.BatchUpsertResult{{Key: "mnist_v1", Status: http.StatusOK, Result: rest.UpsertUnchanged}}, batchResp.Results)
+
+	// a changed model card is a change
+	postBody.ModelCard = "# mnist v1"
+	postBody.Digest = postBody.ContentDigest()
+	sc, _ = upsert(postBody)
+	common.AssertEqual(t, http.StatusOK, sc)
+	_, ok = locationCallback.Load("body")
+	common.AssertEqual(t, true, ok)
+	stored, _, err = st.Fetch("mnist_v1")
+	common.AssertError(t, err)
+	common.AssertEqual(t, postBody.Digest, stored.Digest)
+	common.AssertEqual(t, "# mnist v1", stored.ModelCard)
+
+	// and a digest which does not match the content is turned away
+	postBody.Body = []byte("mnist v1")
+	sc, _ = upsert(postBody)
+	common.AssertEqual(t, http.StatusBadRequest, sc)
+}
+
+func Test_handleCatalogListMetadata_handleCatalogFetch(t *testing.T) {
+	st := sqlite.NewSQLiteBridgeStorageForTest(filepath.Join(t.TempDir(), "bridge.db"))
+	err := st.Initialize(nil)
+	common.AssertError(t, err)
+	defer st.Close()
+	entity := "apiVersion: backstage.io/v1alpha1\nkind: Resource\nmetadata:\n  name: %s\nspec:\n  owner: %s\n  lifecycle: %s\n"
+	for _, e := range []struct {
+		key    string
+		source string
+		owner  string
+	}{
+		{key: "a_v1", source: "kubeflow/registry-1", owner: "alice"},
+		{key: "b_v1", source: "kubeflow/registry-2", owner: "bob"},
+		{key: "c_v1", source: "kserve", owner: "alice"},
+		{key: "d_v1", source: "kubeflow/registry-1", owner: "bob"},
+		{key: "e_v1", source: "kubeflow/registry-1", owner: "alice"},
+	} {
+		reconcilerType, _, _ := strings.Cut(e.source, "/")
+		body := []byte(fmt.Sprintf(entity, strings.ReplaceAll(e.key, "_", "-"), e.owner, "production"))
+		_, err = st.Upsert(e.key, types.StorageBody{Body: body, ReconcilerType: reconcilerType, Source: e.source, LocationId: "loc-" + e.key, Digest: types.ContentDigest(body, "", "")}, "")
+		common.AssertError(t, err)
+	}
+	s := &StorageRESTServer{
+		st:              st,
+		mutex:           sync.Mutex{},
+		pushedLocations: map[string]*types.StorageBody{},
+		format:          types.CatalogInfoYamlFormat,
+	}
+	list := func(query string) (int, *MetadataListResponse) {
+		testWriter := testgin.NewTestResponseWriter()
+		ctx, _ := gin.CreateTestContext(testWriter)
+		ctx.Request = &http.Request{URL: &url.URL{RawQuery: query}}
+		s.handleCatalogListMetadata(ctx)
+		resp := &MetadataListResponse{}
+		if ctx.Writer.Status() == http.StatusOK {
+			err := json.Unmarshal(testWriter.ResponseWriter.Body.Bytes(), resp)
+			common.AssertError(t, err)
+		}
+		return ctx.Writer.Status(), resp
+	}
+	keys := func(resp *MetadataListResponse) string {
+		k := []string{}
+		for _, m := range resp.Items {
+			k = append(k, m.Key)
+		}
+		return strings.Join(k, ",")
+	}
+
+	sc, resp := list("")
+	common.AssertEqual(t, http.StatusOK, sc)
+	common.AssertEqual(t, "a_v1,b_v1,c_v1,d_v1,e_v1", keys(resp))
+	common.AssertEqual(t, "", resp.Continue)
+	a := resp.Items[0]
+	common.AssertEqual(t, "registry-1", a.Registry)
+	common.AssertEqual(t, types.KubeflowNormalizer, a.ReconcilerType)
+	common.AssertEqual(t, "loc-a_v1", a.LocationId)
+	common.AssertEqual(t, []string{"alice"}, a.Owners)
+	common.AssertEqual(t, []string{"production"}, a.Lifecycles)
+	if a.BodySize == 0 || len(a.Digest) == 0 {
+		t.Errorf("expected the body size and digest, got %#v", a)
+	}
+
+	// filters
+	_, resp = list("type=kubeflow&registry=registry-1&owner=alice")
+	common.AssertEqual(t, "a_v1,e_v1", keys(resp))
+	_, resp = list("type=kserve")
+	common.AssertEqual(t, "c_v1", keys(resp))
+	_, resp = list("lifecycle=experimental")
+	common.AssertEqual(t, "", keys(resp))
+
+	// pages, which carry on past a key removed in between
+	_, resp = list("limit=2&owner=bob")
+	common.AssertEqual(t, "b_v1,d_v1", keys(resp))
+	_, resp = list("limit=2")
+	common.AssertEqual(t, "a_v1,b_v1", keys(resp))
+	err = st.Remove("c_v1", types.AnyRevision)
+	common.AssertError(t, err)
+	_, resp = list("limit=2&continue=" + resp.Continue)
+	common.AssertEqual(t, "d_v1,e_v1", keys(resp))
+	common.AssertEqual(t, "", resp.Continue)
+
+	sc, _ = list("limit=0")
+	common.AssertEqual(t, http.StatusBadRequest, sc)
+	sc, _ = list("continue=%21")
+	common.AssertEqual(t, http.StatusBadRequest, sc)
+
+	// fetch has the entry, or a 404 for a key storage does not have
+	for key, expectedSC := range map[string]int{"a_v1": http.StatusOK, "c_v1": http.StatusNotFound} {
+		testWriter := testgin.NewTestResponseWriter()
+		ctx, _ := gin.CreateTestContext(testWriter)
+		ctx.Request = &http.Request{URL: &url.URL{RawQuery: "key=" + key}}
+		s.handleCatalogFetch(ctx)
+		common.AssertEqual(t, expectedSC, ctx.Writer.Status())
+	}
+}
+
+func Test_audit(t *testing.T) {
+	locationCallback := sync.Map{}
+	brts := location.CreateBridgeLocationServerWithCallbackMap(&locationCallback, t)
+	defer brts.Close()
+
+	st := sqlite.NewSQLiteBridgeStorageForTest(filepath.Join(t.TempDir(), "bridge.db"))
+	err := st.Initialize(nil)
+	common.AssertError(t, err)
+	defer st.Close()
+	bkstg := &failingBackstage{}
+	s := &StorageRESTServer{
+		st:              st,
+		mutex:           sync.Mutex{},
+		pushedLocations: map[string]*types.StorageBody{},
+		locations:       location.SetupBridgeLocationRESTClient(brts),
+		bkstg:           bkstg,
+		pushToRHDH:      true,
+		auditRetention:  auditRetention{maxEntries: 4},
+	}
+	call := func(handler func(c *gin.Context), query, requestID string, body []byte) (int, []byte) {
+		testWriter := testgin.NewTestResponseWriter()
+		ctx, _ := gin.CreateTestContext(testWriter)
+		ctx.Request = &http.Request{URL: &url.URL{RawQuery: query}, Body: io.NopCloser(bytes.NewReader(body))}
+		ctx.Set("requestId", requestID)
+		ctx.Set(auth.UserContextKey, "system:serviceaccount:bridge:normalizer")
+		handler(ctx)
+		return ctx.Writer.Status(), testWriter.ResponseWriter.Body.Bytes()
+	}
+	postBody := rest.PostBody{Body: []byte("mnist"), LastUpdateTimeSinceEpoch: "1000"}
+	data, err := json.Marshal(postBody)
+	common.AssertError(t, err)
+	auditLog := func(query string) []AuditEntry {
+		sc, body := call(s.handleAuditGet, query, "", nil)
+		common.AssertEqual(t, http.StatusOK, sc)
+		resp := AuditResponse{}
+		common.AssertError(t, json.Unmarshal(body, &resp))
+		return resp.Entries
+	}
+	summary := func(entries []AuditEntry) []string {
+		s := []string{}
+		for _, e := range entries {
+			s = append(s, strings.Join([]string{e.RequestID, e.Action, e.Key, e.Outcome, e.LocationId, e.Reason}, " "))
+		}
+		return s
+	}
+
+	// the upsert and the import of its location are recorded, while an upsert which changes nothing is not
+	sc, _ := call(s.handleCatalogUpsertPost, "key=mnist_v1", "req-1", data)
+	common.AssertEqual(t, http.StatusCreated, sc)
+	sc, _ = call(s.handleCatalogUpsertPost, "key=mnist_v1", "req-2", data)
+	common.AssertEqual(t, http.StatusOK, sc)
+	entries := auditLog("key=mnist_v1")
+	common.AssertEqual(t, []string{"req-1 upsert mnist_v1 succeeded  ", "req-1 import mnist_v1 succeeded loc-1 "}, summary(entries))
+	common.AssertEqual(t, "system:serviceaccount:bridge:normalizer", entries[0].Caller)
+	common.AssertEqual(t, postBody.ContentDigest(), entries[0].Digest)
+
+	// as is the key set removing the key, and deleting its location, along with why
+	sc, _ = call(s.handleCatalogCurrentKeySetPost, "key=", "req-3", nil)
+	common.AssertEqual(t, http.StatusOK, sc)
+	common.AssertEqual(t, []string{"loc-1"}, bkstg.deletes)
+	common.AssertEqual(t, []string{
+		"req-1 upsert mnist_v1 succeeded  ",
+		"req-1 import mnist_v1 succeeded loc-1 ",
+		"req-3 remove mnist_v1 succeeded loc-1 missing from the current key set",
+		"req-3 delete mnist_v1 succeeded loc-1 missing from the current key set",
+	}, summary(auditLog("key=mnist_v1")))
+
+	// the audit log outlives the model, but is neither listed nor fetched as one
+	sc, body := call(s.handleCatalogList, "", "", nil)
+	common.AssertEqual(t, http.StatusOK, sc)
+	common.AssertEqual(t, `{"keys":[]}`, string(body))
+	sc, _ = call(s.handleCatalogFetch, "key="+util.AuditKeyPrefix+"mnist_v1", "", nil)
+	common.AssertEqual(t, http.StatusNotFound, sc)
+
+	// only the latest entries are kept
+	sc, _ = call(s.handleCatalogUpsertPost, "key=mnist_v1", "req-4", data)
+	common.AssertEqual(t, http.StatusCreated, sc)
+	common.AssertEqual(t, []string{
+		"req-3 remove mnist_v1 succeeded loc-1 missing from the current key set",
+		"req-3 delete mnist_v1 succeeded loc-1 missing from the current key set",
+		"req-4 upsert mnist_v1 succeeded  ",
+		"req-4 import mnist_v1 succeeded loc-2 ",
+	}, summary(auditLog("key=mnist_v1")))
+
+	// without a key, the entries of every model come merged by time
+	sc, _ = call(s.handleCatalogUpsertPost, "key=fashion_v1", "req-5", data)
+	common.AssertEqual(t, http.StatusCreated, sc)
+	entries = auditLog("")
+	common.AssertEqual(t, 6, len(entries))
+	common.AssertEqual(t, "req-5 import fashion_v1 succeeded loc-3 ", summary(entries)[5])
+	common.AssertEqual(t, 0, len(auditLog("key=unknown_v1")))
+
+	// entries past the retention age are purged, along with audit logs left empty
+	s.auditRetention.maxAge = time.Nanosecond
+	s.purgeAudit(context.Background())
+	keys, err := st.List()
+	common.AssertError(t, err)
+	sort.Strings(keys)
+	common.AssertEqual(t, []string{"fashion_v1", "mnist_v1"}, keys)
+	common.AssertEqual(t, 0, len(auditLog("")))
+
+	// and with the audit log turned off, there is nothing to return
+	s.auditRetention = auditRetention{}
+	sc, _ = call(s.handleAuditGet, "key=mnist_v1", "", nil)
+	common.AssertEqual(t, http.StatusNotImplemented, sc)
+}
+
+func Test_readiness(t *testing.T) {
+	st := sqlite.NewSQLiteBridgeStorageForTest(filepath.Join(t.TempDir(), "bridge.db"))
+	err := st.Initialize(nil)
+	common.AssertError(t, err)
+	defer st.Close()
+	bks := backstage.CreateServer(t)
+	s := &StorageRESTServer{
+		st:    st,
+		mutex: sync.Mutex{},
+		bkstg: (&bkstgclient.BackstageRESTClientWrapper{RESTClient: common.DC(), RootURL: bks.URL}),
+	}
+	common.AssertError(t, s.storageReady())
+	common.AssertError(t, s.backstageReady())
+
+	// once Backstage is gone, we are not ready where that matters
+	bks.Close()
+	if s.backstageReady() == nil {
+		t.Error("expected backstage not to be ready once it is gone")
+	}
+
+	// and once storage is gone, we are not ready at all
+	st.Close()
+	if s.storageReady() == nil {
+		t.Error("expected storage not to be ready once it is closed")
+	}
+}
+
+func Test_auditRetentionFromEnv(t *testing.T) {
+	common.AssertEqual(t, auditRetention{maxEntries: defaultAuditMaxEntries, maxAge: defaultAuditRetention}, auditRetentionFromEnv())
+	t.Setenv(types.AuditMaxEntriesEnvVar, "20")
+	t.Setenv(types.AuditRetentionEnvVar, "24h")
+	common.AssertEqual(t, auditRetention{maxEntries: 20, maxAge: 24 * time.Hour}, auditRetentionFromEnv())
+	t.Setenv(types.AuditMaxEntriesEnvVar, "-1")
+	common.AssertEqual(t, auditRetention{maxEntries: defaultAuditMaxEntries, maxAge: 24 * time.Hour}, auditRetentionFromEnv())
+	// 0 turns the audit log off
+	t.Setenv(types.AuditMaxEntriesEnvVar, "0")
+	common.AssertEqual(t, auditRetention{maxAge: 24 * time.Hour}, auditRetentionFromEnv())
+}
@@ -0,0 +1,330 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/cmd/server/storage/watcher"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/types"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog/v2"
+
+	_ "github.com/ncruces/go-sqlite3/driver"
+	_ "github.com/ncruces/go-sqlite3/embed"
+)
+
+const (
+	defaultPath = "model-catalog-bridge.db"
+	// how often Watch checks for changes made by other processes sharing the file
+	defaultWatchInterval = 2 * time.Second
+)
+
+// the revisions table is append only; every upsert that changes an entry and every remove adds a row, and the
+// revision numbers for a key keep increasing even if the key is removed and then added again
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS entries (
+		key TEXT PRIMARY KEY,
+		revision INTEGER NOT NULL,
+		value BLOB NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS revisions (
+		key TEXT NOT NULL,
+		revision INTEGER NOT NULL,
+		operation TEXT NOT NULL,
+		recorded_at INTEGER NOT NULL,
+		value BLOB,
+		PRIMARY KEY (key, revision)
+	)`,
+	`CREATE INDEX IF NOT EXISTS revisions_recorded_at ON revisions (key, recorded_at)`,
+	`CREATE TRIGGER IF NOT EXISTS revisions_no_update BEFORE UPDATE ON revisions
+	BEGIN SELECT RAISE(ABORT, 'revisions are append only'); END`,
+	`CREATE TRIGGER IF NOT EXISTS revisions_no_delete BEFORE DELETE ON revisions
+	BEGIN SELECT RAISE(ABORT, 'revisions are append only'); END`,
+}
+
+// SQLiteBridgeStorage keeps the current entries, plus every prior revision of them, in an embedded SQLite database
+// file, which is meant for laptops and single replica deployments with the file on a PVC.
+type SQLiteBridgeStorage struct {
+	path          string
+	db            *sql.DB
+	now           func() time.Time
+	watchInterval time.Duration
+}
+
+func NewSQLiteBridgeStorageForTest(path string) *SQLiteBridgeStorage {
+	return &SQLiteBridgeStorage{path: path}
+}
+
+func (s *SQLiteBridgeStorage) Initialize(cfg *rest.Config) error {
+	if len(s.path) == 0 {
+		s.path = strings.TrimSpace(os.Getenv(types.SQLiteStoragePathEnvVar))
+	}
+	if len(s.path) == 0 {
+		s.path = defaultPath
+	}
+	if s.now == nil {
+		s.now = time.Now
+	}
+	if s.watchInterval == 0 {
+		s.watchInterval = defaultWatchInterval
+	}
+
+	// immediate transactions take the write lock up front, so concurrent read-then-write transactions wait on the busy
+	// timeout instead of failing when they try to upgrade their lock
+	dsn := fmt.Sprintf("file:%s?_pragma=busy_timeout(10000)&_pragma=journal_mode(wal)&_txlock=immediate", s.path)
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return err
+	}
+	db.SetMaxOpenConns(1)
+	for _, stmt := range schema {
+		_, err = db.Exec(stmt)
+		if err != nil {
+			db.Close()
+			return fmt.Errorf("error setting up sqlite storage schema in %s: %s", s.path, err.Error())
+		}
+	}
+	s.db = db
+	klog.Infof("using sqlite storage in %s", s.path)
+	return nil
+}
+
+func (s *SQLiteBridgeStorage) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLiteBridgeStorage) Fetch(key string) (types.StorageBody, string, error) {
+	return s.tx(s.db).Fetch(key)
+}
+
+func (s *SQLiteBridgeStorage) Upsert(key string, value types.StorageBody, expectedRevision string) (string, error) {
+	rev := ""
+	err := s.Transact(func(tx types.BridgeStorageTx) error {
+		var err error
+		rev, err = tx.Upsert(key, value, expectedRevision)
+		return err
+	})
+	return rev, err
+}
+
+func (s *SQLiteBridgeStorage) Remove(key string, expectedRevision string) error {
+	return s.Transact(func(tx types.BridgeStorageTx) error {
+		return tx.Remove(key, expectedRevision)
+	})
+}
+
+func (s *SQLiteBridgeStorage) List() ([]string, error) {
+	return s.tx(s.db).List()
+}
+
+// Watch polls the entries table, which is cheap as only the key and revision columns are read
+func (s *SQLiteBridgeStorage) Watch(ctx context.Context) (<-chan types.WatchEvent, error) {
+	return watcher.Start(ctx, s.revisions, s.Fetch, watcher.Ticker(ctx, s.watchInterval))
+}
+
+func (s *SQLiteBridgeStorage) revisions() (map[string]string, error) {
+	rows, err := s.db.Query(`SELECT key, revision FROM entries`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	revisions := map[string]string{}
+	for rows.Next() {
+		var key string
+		var rev int64
+		err = rows.Scan(&key, &rev)
+		if err != nil {
+			return nil, err
+		}
+		revisions[key] = strconv.FormatInt(rev, 10)
+	}
+	return revisions, rows.Err()
+}
+
+// Transact runs fn in a single SQLite transaction, which is rolled back if fn returns an error
+func (s *SQLiteBridgeStorage) Transact(fn func(tx types.BridgeStorageTx) error) error {
+	sqlTx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	err = fn(s.tx(sqlTx))
+	if err != nil {
+		rerr := sqlTx.Rollback()
+		if rerr != nil {
+			klog.Errorf("error rolling back sqlite transaction: %s", rerr.Error())
+		}
+		return err
+	}
+	return sqlTx.Commit()
+}
+
+func (s *SQLiteBridgeStorage) History(key string) ([]types.StorageRevision, error) {
+	rows, err := s.db.Query(`SELECT revision, operation, recorded_at, value FROM revisions WHERE key = ? ORDER BY revision`, key)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	revisions := []types.StorageRevision{}
+	for rows.Next() {
+		rev := types.StorageRevision{}
+		var recordedAt int64
+		var buf []byte
+		err = rows.Scan(&rev.Revision, &rev.Operation, &recordedAt, &buf)
+		if err != nil {
+			return nil, err
+		}
+		rev.RecordedAt = time.Unix(0, recordedAt).UTC()
+		if len(buf) > 0 {
+			err = json.Unmarshal(buf, &rev.Value)
+			if err != nil {
+				return nil, err
+			}
+		}
+		revisions = append(revisions, rev)
+	}
+	return revisions, rows.Err()
+}
+
+func (s *SQLiteBridgeStorage) FetchAsOf(key string, asOf time.Time) (types.StorageBody, error) {
+	sb := types.StorageBody{}
+	var buf []byte
+	err := s.db.QueryRow(`SELECT value FROM revisions WHERE key = ? AND recorded_at <= ? ORDER BY revision DESC LIMIT 1`,
+		key, asOf.UnixNano()).Scan(&buf)
+	switch {
+	case err == sql.ErrNoRows:
+		return sb, nil
+	case err != nil:
+		return sb, err
+	case len(buf) == 0:
+		// the key had been removed as of then
+		return sb, nil
+	}
+	err = json.Unmarshal(buf, &sb)
+	return sb, err
+}
+
+// queryer is what *sql.DB and *sql.Tx have in common
+type queryer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+type sqliteTx struct {
+	q   queryer
+	now func() time.Time
+}
+
+func (s *SQLiteBridgeStorage) tx(q queryer) *sqliteTx {
+	return &sqliteTx{q: q, now: s.now}
+}
+
+func (t *sqliteTx) Fetch(key string) (types.StorageBody, string, error) {
+	sb := types.StorageBody{}
+	buf, rev, err := t.current(key)
+	if err != nil || buf == nil {
+		return sb, rev, err
+	}
+	err = json.Unmarshal(buf, &sb)
+	return sb, rev, err
+}
+
+// Upsert uses the revision number from the revisions table as the revision
+func (t *sqliteTx) Upsert(key string, value types.StorageBody, expectedRevision string) (string, error) {
+	buf, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	current, currentRev, err := t.current(key)
+	if err != nil {
+		return "", err
+	}
+	err = types.CheckRevision(key, expectedRevision, currentRev)
+	if err != nil {
+		return "", err
+	}
+	if string(current) == string(buf) {
+		// no need for a revision that is identical to the last one
+		return currentRev, nil
+	}
+	rev, err := t.nextRevision(key)
+	if err != nil {
+		return "", err
+	}
+	_, err = t.q.Exec(`INSERT INTO entries (key, revision, value) VALUES (?, ?, ?)
+		ON CONFLICT (key) DO UPDATE SET revision = excluded.revision, value = excluded.value`, key, rev, buf)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(rev, 10), t.record(key, rev, types.StorageOperationUpsert, buf)
+}
+
+func (t *sqliteTx) Remove(key string, expectedRevision string) error {
+	current, currentRev, err := t.current(key)
+	if err != nil || current == nil {
+		return err
+	}
+	err = types.CheckRevision(key, expectedRevision, currentRev)
+	if err != nil {
+		return err
+	}
+	_, err = t.q.Exec(`DELETE FROM entries WHERE key = ?`, key)
+	if err != nil {
+		return err
+	}
+	rev, err := t.nextRevision(key)
+	if err != nil {
+		return err
+	}
+	return t.record(key, rev, types.StorageOperationRemove, nil)
+}
+
+func (t *sqliteTx) List() ([]string, error) {
+	rows, err := t.q.Query(`SELECT key FROM entries ORDER BY key`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	keys := []string{}
+	for rows.Next() {
+		var key string
+		err = rows.Scan(&key)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// current returns the stored JSON for the key and its revision, or nil if the key is not present
+func (t *sqliteTx) current(key string) ([]byte, string, error) {
+	var buf []byte
+	var rev int64
+	err := t.q.QueryRow(`SELECT value, revision FROM entries WHERE key = ?`, key).Scan(&buf, &rev)
+	if err == sql.ErrNoRows {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return buf, strconv.FormatInt(rev, 10), nil
+}
+
+func (t *sqliteTx) nextRevision(key string) (int64, error) {
+	var rev int64
+	err := t.q.QueryRow(`SELECT COALESCE(MAX(revision), 0) + 1 FROM revisions WHERE key = ?`, key).Scan(&rev)
+	return rev, err
+}
+
+func (t *sqliteTx) record(key string, rev int64, operation string, buf []byte) error {
+	_, err := t.q.Exec(`INSERT INTO revisions (key, revision, operation, recorded_at, value) VALUES (?, ?, ?, ?, ?)`,
+		key, rev, operation, t.now().UnixNano(), buf)
+	return err
+}
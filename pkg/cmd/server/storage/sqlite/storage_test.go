@@ -0,0 +1,173 @@
+package sqlite
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/types"
+	"github.com/redhat-ai-dev/model-catalog-bridge/test/stub/common"
+	"github.com/redhat-ai-dev/model-catalog-bridge/test/stub/storage/conformance"
+)
+
+func setupStorage(t *testing.T) (*SQLiteBridgeStorage, *time.Time) {
+	t.Helper()
+	clock := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	st := NewSQLiteBridgeStorageForTest(filepath.Join(t.TempDir(), "bridge.db"))
+	st.now = func() time.Time { return clock }
+	err := st.Initialize(nil)
+	common.AssertError(t, err)
+	t.Cleanup(func() { st.Close() })
+	return st, &clock
+}
+
+func TestSQLiteBridgeStorage(t *testing.T) {
+	st, clock := setupStorage(t)
+
+	keys, err := st.List()
+	common.AssertError(t, err)
+	common.AssertEqual(t, 0, len(keys))
+	sb, _, err := st.Fetch("mnist_v1")
+	common.AssertError(t, err)
+	common.AssertEqual(t, types.StorageBody{}, sb)
+
+	for _, tc := range []struct {
+		name string
+		key  string
+		body types.StorageBody
+	}{
+		{
+			name: "new entry",
+			key:  "mnist_v1",
+			body: types.StorageBody{Body: []byte("create"), ReconcilerType: types.KubeflowNormalizer, LastUpdateTimeSinceEpoch: "1"},
+		},
+		{
+			name: "updated entry",
+			key:  "mnist_v1",
+			body: types.StorageBody{Body: []byte("update"), LocationId: "loc-id", LocationTarget: "http://foo.com/mnist/v1/catalog-info.yaml", ReconcilerType: types.KubeflowNormalizer, LastUpdateTimeSinceEpoch: "2"},
+		},
+		{
+			name: "second entry",
+			key:  "mnist_v2",
+			body: types.StorageBody{Body: []byte("create"), ReconcilerType: types.KServeNormalizer},
+		},
+	} {
+		*clock = clock.Add(24 * time.Hour)
+		_, err = st.Upsert(tc.key, tc.body, types.AnyRevision)
+		common.AssertError(t, err)
+		sb, _, err = st.Fetch(tc.key)
+		common.AssertError(t, err)
+		common.AssertEqual(t, tc.body, sb)
+	}
+
+	// an identical upsert does not add a revision
+	_, err = st.Upsert("mnist_v2", types.StorageBody{Body: []byte("create"), ReconcilerType: types.KServeNormalizer}, types.AnyRevision)
+	common.AssertError(t, err)
+
+	keys, err = st.List()
+	common.AssertError(t, err)
+	common.AssertEqual(t, []string{"mnist_v1", "mnist_v2"}, keys)
+
+	*clock = clock.Add(24 * time.Hour)
+	err = st.Remove("mnist_v1", types.AnyRevision)
+	common.AssertError(t, err)
+	err = st.Remove("mnist_v1", types.AnyRevision)
+	common.AssertError(t, err)
+	*clock = clock.Add(24 * time.Hour)
+	_, err = st.Upsert("mnist_v1", types.StorageBody{Body: []byte("again")}, types.AnyRevision)
+	common.AssertError(t, err)
+
+	history, err := st.History("mnist_v1")
+	common.AssertError(t, err)
+	common.AssertEqual(t, 4, len(history))
+	for i, op := range []string{types.StorageOperationUpsert, types.StorageOperationUpsert, types.StorageOperationRemove, types.StorageOperationUpsert} {
+		common.AssertEqual(t, int64(i+1), history[i].Revision)
+		common.AssertEqual(t, op, history[i].Operation)
+	}
+	common.AssertEqual(t, "update", string(history[1].Value.Body))
+	history, err = st.History("mnist_v2")
+	common.AssertError(t, err)
+	common.AssertEqual(t, 1, len(history))
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		days int
+		body string
+	}{
+		{days: 0, body: ""},
+		{days: 1, body: "create"},
+		{days: 2, body: "update"},
+		{days: 3, body: "update"},
+		{days: 4, body: ""},
+		{days: 5, body: "again"},
+	} {
+		sb, err = st.FetchAsOf("mnist_v1", start.Add(time.Duration(tc.days)*24*time.Hour))
+		common.AssertError(t, err)
+		common.AssertEqual(t, tc.body, string(sb.Body))
+	}
+
+	// the revisions table cannot be rewritten
+	_, err = st.db.Exec(`DELETE FROM revisions WHERE key = ?`, "mnist_v1")
+	if err == nil {
+		t.Error("expected error deleting from the revisions table")
+	}
+}
+
+func TestSQLiteBridgeStorageTransact(t *testing.T) {
+	st, _ := setupStorage(t)
+
+	err := st.Transact(func(tx types.BridgeStorageTx) error {
+		for i := 1; i <= 3; i++ {
+			if _, err := tx.Upsert(fmt.Sprintf("mnist_v%d", i), types.StorageBody{Body: []byte("create")}, types.AnyRevision); err != nil {
+				return err
+			}
+		}
+		keys, err := tx.List()
+		common.AssertEqual(t, 3, len(keys))
+		return err
+	})
+	common.AssertError(t, err)
+
+	// none of the updates in a failed transaction are applied
+	err = st.Transact(func(tx types.BridgeStorageTx) error {
+		if err := tx.Remove("mnist_v1", types.AnyRevision); err != nil {
+			return err
+		}
+		if _, err := tx.Upsert("mnist_v2", types.StorageBody{Body: []byte("update")}, types.AnyRevision); err != nil {
+			return err
+		}
+		return fmt.Errorf("backstage said no")
+	})
+	if err == nil {
+		t.Error("expected the transaction error to be returned")
+	}
+	keys, err := st.List()
+	common.AssertError(t, err)
+	common.AssertEqual(t, []string{"mnist_v1", "mnist_v2", "mnist_v3"}, keys)
+	sb, _, err := st.Fetch("mnist_v2")
+	common.AssertError(t, err)
+	common.AssertEqual(t, "create", string(sb.Body))
+	history, err := st.History("mnist_v2")
+	common.AssertError(t, err)
+	common.AssertEqual(t, 1, len(history))
+}
+
+func TestSQLiteBridgeStorageRevisions(t *testing.T) {
+	st, _ := setupStorage(t)
+	conformance.AssertRevisionSemantics(t, st)
+}
+
+func TestSQLiteBridgeStorageRoundTrip(t *testing.T) {
+	st, _ := setupStorage(t)
+	conformance.AssertRoundTrip(t, st)
+}
+
+func TestSQLiteBridgeStorageWatch(t *testing.T) {
+	st := NewSQLiteBridgeStorageForTest(filepath.Join(t.TempDir(), "bridge.db"))
+	st.watchInterval = 50 * time.Millisecond
+	err := st.Initialize(nil)
+	common.AssertError(t, err)
+	defer st.Close()
+	conformance.AssertWatch(t, st)
+}
@@ -0,0 +1,186 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/types"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+const (
+	retryInterval       = 5 * time.Second
+	informerSyncTimeout = 30 * time.Second
+)
+
+// ListFunc returns the current revision of every key in storage
+type ListFunc func() (map[string]string, error)
+
+// FetchFunc is BridgeStorage.Fetch
+type FetchFunc func(key string) (types.StorageBody, string, error)
+
+// Start implements BridgeStorage.Watch for backends that can cheaply list the revision of every key; each time the
+// trigger fires the keys are listed again, and the difference from the previous listing is sent as events.  The
+// backends fire the trigger off of an informer when they are backed by k8s, and off of a Ticker otherwise.
+//
+// The first listing is done before Start returns, so that an error reaching storage is returned to the caller; errors
+// from later listings are logged and we wait for the next trigger.
+func Start(ctx context.Context, list ListFunc, fetch FetchFunc, trigger <-chan struct{}) (<-chan types.WatchEvent, error) {
+	current, err := list()
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan types.WatchEvent, 100)
+	go func() {
+		defer close(ch)
+		known := map[string]string{}
+		events, complete := diff(known, current, fetch)
+		if !send(ctx, ch, append(events, types.WatchEvent{Type: types.WatchEventSynced})) {
+			return
+		}
+		for {
+			// an informer trigger only fires when storage changes again, so we have our own timer for when a key
+			// could not be fetched
+			var retry <-chan time.Time
+			if !complete {
+				retry = time.After(retryInterval)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-trigger:
+			case <-retry:
+			}
+			current, err = list()
+			if err != nil {
+				klog.Errorf("error listing storage revisions for watch: %s", err.Error())
+				complete = false
+				continue
+			}
+			events, complete = diff(known, current, fetch)
+			if !send(ctx, ch, events) {
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// Ticker fires a trigger for Start every interval until ctx is done
+func Ticker(ctx context.Context, interval time.Duration) <-chan struct{} {
+	trigger := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				select {
+				case trigger <- struct{}{}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return trigger
+}
+
+// Informer runs an informer for the backends stored in k8s objects, and returns its store, which their ListFunc and
+// FetchFunc can read instead of calling the API server, and a trigger which fires whenever an object changes
+func Informer(ctx context.Context, lw *cache.ListWatch, exampleObject runtime.Object) (cache.Store, <-chan struct{}, error) {
+	informer := cache.NewSharedIndexInformer(lw, exampleObject, 0, cache.Indexers{})
+	trigger := make(chan struct{}, 1)
+	_, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { Poke(trigger) },
+		UpdateFunc: func(oldObj, newObj interface{}) { Poke(trigger) },
+		DeleteFunc: func(obj interface{}) { Poke(trigger) },
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	go informer.RunWithContext(ctx)
+	syncCtx, cancel := context.WithTimeout(ctx, informerSyncTimeout)
+	defer cancel()
+	if !cache.WaitForCacheSync(syncCtx.Done(), informer.HasSynced) {
+		return nil, nil, fmt.Errorf("timed out waiting for the storage informer to sync")
+	}
+	return informer.GetStore(), trigger, nil
+}
+
+// Poke fires a trigger for Start without blocking, where a trigger that is already pending covers this one; the
+// trigger channel needs a buffer of one
+func Poke(trigger chan struct{}) {
+	select {
+	case trigger <- struct{}{}:
+	default:
+	}
+}
+
+// diff updates known to match current and returns the events for what changed, along with false if any changed key
+// could not be fetched
+func diff(known, current map[string]string, fetch FetchFunc) ([]types.WatchEvent, bool) {
+	events := []types.WatchEvent{}
+	complete := true
+	keys := make([]string, 0, len(current))
+	for key := range current {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		rev := current[key]
+		prev, ok := known[key]
+		if ok && prev == rev {
+			continue
+		}
+		sb, fetchedRev, err := fetch(key)
+		if err != nil {
+			// we'll pick it up on the retry
+			klog.Errorf("error fetching key %s for watch: %s", key, err.Error())
+			complete = false
+			continue
+		}
+		if len(fetchedRev) == 0 {
+			// removed since it was listed, and if we knew about it, the next listing produces the delete
+			continue
+		}
+		ev := types.WatchEvent{Type: types.WatchEventAdded, Key: key, Revision: fetchedRev, Value: &sb}
+		if ok {
+			if prev == fetchedRev {
+				continue
+			}
+			ev.Type = types.WatchEventModified
+		}
+		known[key] = fetchedRev
+		events = append(events, ev)
+	}
+	removed := []string{}
+	for key := range known {
+		if _, ok := current[key]; !ok {
+			removed = append(removed, key)
+		}
+	}
+	sort.Strings(removed)
+	for _, key := range removed {
+		delete(known, key)
+		events = append(events, types.WatchEvent{Type: types.WatchEventDeleted, Key: key})
+	}
+	return events, complete
+}
+
+func send(ctx context.Context, ch chan<- types.WatchEvent, events []types.WatchEvent) bool {
+	for _, ev := range events {
+		select {
+		case ch <- ev:
+		case <-ctx.Done():
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,147 @@
+package validation
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/cmd/cli/backstage"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/types"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// the rules Backstage's catalog applies to entity fields, see
+// https://github.com/backstage/backstage/blob/master/packages/catalog-model/src/validation/KubernetesValidatorFunctions.ts
+var (
+	objectNameRegexp = regexp.MustCompile(`^([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9]$`)
+	dnsLabelRegexp   = regexp.MustCompile(`^[a-z0-9]+(?:-[a-z0-9]+)*$`)
+	kindRegexp       = regexp.MustCompile(`^[a-zA-Z][a-z0-9A-Z]*$`)
+	tagRegexp        = regexp.MustCompile(`^[a-z0-9:+#]+(\-[a-z0-9:+#]+)*$`)
+)
+
+const maxNameLength = 63
+
+// validateEntities checks each entity of the catalog-info content against the rules Backstage applies to names,
+// tags, links, annotations and labels, since Backstage drops entities which break them with little more than a log
+func validateEntities(body []byte) []types.FieldError {
+	errs := []types.FieldError{}
+	dec := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(body), 4096)
+	count := 0
+	for i := 0; ; i++ {
+		entity := backstage.Entity{}
+		err := dec.Decode(&entity)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		path := fmt.Sprintf("entities[%d]", i)
+		if err != nil {
+			// the decoder cannot go on past a document it could not read
+			errs = append(errs, types.FieldError{Field: path, Message: fmt.Sprintf("is not a valid entity: %s", err.Error())})
+			return errs
+		}
+		if len(entity.ApiVersion) == 0 && len(entity.Kind) == 0 && len(entity.Metadata.Name) == 0 {
+			// an empty document, as between two "---" dividers
+			continue
+		}
+		count++
+		validateEntity(&entity, path, &errs)
+	}
+	if count == 0 && len(errs) == 0 {
+		errs = append(errs, types.FieldError{Message: "has no entities"})
+	}
+	return errs
+}
+
+func validateEntity(entity *backstage.Entity, path string, errs *[]types.FieldError) {
+	add := func(field, format string, args ...any) {
+		*errs = append(*errs, types.FieldError{Field: path + "." + field, Message: fmt.Sprintf(format, args...)})
+	}
+	if !validApiVersion(entity.ApiVersion) {
+		add("apiVersion", "%q is not a valid apiVersion, such as backstage.io/v1alpha1", entity.ApiVersion)
+	}
+	if len(entity.Kind) > maxNameLength || !kindRegexp.MatchString(entity.Kind) {
+		add("kind", "%q is not a valid kind, which is letters and digits, starting with a letter", entity.Kind)
+	}
+	if !validObjectName(entity.Metadata.Name) {
+		add("metadata.name", "%q is not a valid name, which is at most 63 letters, digits and '-', '_' or '.' separators", entity.Metadata.Name)
+	}
+	if len(entity.Metadata.Namespace) > 0 && !validDNSLabel(entity.Metadata.Namespace) {
+		add("metadata.namespace", "%q is not a valid namespace, which is at most 63 lower case letters, digits and '-' separators", entity.Metadata.Namespace)
+	}
+	for i, tag := range entity.Metadata.Tags {
+		if len(tag) > maxNameLength || !tagRegexp.MatchString(tag) {
+			add(fmt.Sprintf("metadata.tags[%d]", i), "%q is not a valid tag, which is at most 63 lower case letters, digits, ':', '+' or '#' and '-' separators", tag)
+		}
+	}
+	for i, link := range entity.Metadata.Links {
+		u, err := url.Parse(link.URL)
+		if len(link.URL) == 0 || err != nil || len(u.Scheme) == 0 {
+			add(fmt.Sprintf("metadata.links[%d].url", i), "%q is not an absolute URL", link.URL)
+		}
+	}
+	for _, key := range sortedKeys(entity.Metadata.Annotations) {
+		if !validPrefixedName(key) {
+			add("metadata.annotations", "%q is not a valid annotation key, which is a name with an optional DNS subdomain prefix", key)
+		}
+	}
+	for _, key := range sortedKeys(entity.Metadata.Labels) {
+		if !validPrefixedName(key) {
+			add("metadata.labels", "%q is not a valid label key, which is a name with an optional DNS subdomain prefix", key)
+		}
+		if value := entity.Metadata.Labels[key]; len(value) > 0 && !validObjectName(value) {
+			add("metadata.labels."+key, "%q is not a valid label value", value)
+		}
+	}
+}
+
+func validObjectName(name string) bool {
+	return len(name) <= maxNameLength && objectNameRegexp.MatchString(name)
+}
+
+func validDNSLabel(label string) bool {
+	return len(label) <= maxNameLength && dnsLabelRegexp.MatchString(label)
+}
+
+func validDNSSubdomain(subdomain string) bool {
+	if len(subdomain) == 0 || len(subdomain) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(subdomain, ".") {
+		if !validDNSLabel(label) {
+			return false
+		}
+	}
+	return true
+}
+
+// validApiVersion accepts a version, or a version prefixed by a DNS subdomain group, as in backstage.io/v1alpha1
+func validApiVersion(apiVersion string) bool {
+	group, version, grouped := strings.Cut(apiVersion, "/")
+	if !grouped {
+		return validDNSLabel(apiVersion)
+	}
+	return validDNSSubdomain(group) && validDNSLabel(version)
+}
+
+// validPrefixedName accepts a name, or a name prefixed by a DNS subdomain, as in backstage.io/techdocs-ref
+func validPrefixedName(key string) bool {
+	prefix, name, prefixed := strings.Cut(key, "/")
+	if !prefixed {
+		return validObjectName(key)
+	}
+	return validDNSSubdomain(prefix) && validObjectName(name)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
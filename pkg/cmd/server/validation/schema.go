@@ -0,0 +1,190 @@
+package validation
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/types"
+	"github.com/redhat-ai-dev/model-catalog-bridge/schema"
+)
+
+// the model catalog schema only uses a handful of JSON schema keywords, namely type, required, properties,
+// additionalProperties, items, enum, anyOf and $ref to $defs, so those are all this validator understands
+
+var (
+	modelCatalogSchema    map[string]any
+	modelCatalogSchemaErr error
+	loadSchema            sync.Once
+)
+
+func validateModelCatalog(body []byte) []types.FieldError {
+	loadSchema.Do(func() {
+		modelCatalogSchemaErr = json.Unmarshal(schema.ModelCatalogSchema, &modelCatalogSchema)
+	})
+	if modelCatalogSchemaErr != nil {
+		return []types.FieldError{{Message: fmt.Sprintf("the model catalog schema could not be read: %s", modelCatalogSchemaErr.Error())}}
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var value any
+	err := dec.Decode(&value)
+	if err != nil {
+		return []types.FieldError{{Message: fmt.Sprintf("is not valid JSON: %s", err.Error())}}
+	}
+	errs := []types.FieldError{}
+	validateValue(modelCatalogSchema, nil, value, "", &errs)
+	return errs
+}
+
+// validateValue checks value against s, where scopes are the $defs of the schemas s is nested in, innermost last,
+// which is where a "#/$defs/" reference is looked up
+func validateValue(s map[string]any, scopes []map[string]any, value any, path string, errs *[]types.FieldError) {
+	if defs, ok := s["$defs"].(map[string]any); ok {
+		scopes = append(scopes, defs)
+	}
+	if ref, ok := s["$ref"].(string); ok {
+		target, found := resolveRef(ref, scopes)
+		if !found {
+			*errs = append(*errs, types.FieldError{Field: path, Message: fmt.Sprintf("refers to %s, which the schema does not define", ref)})
+			return
+		}
+		before := len(*errs)
+		validateValue(target, scopes, value, path, errs)
+		if len(*errs) > before {
+			// the keywords alongside the reference only repeat what it checks
+			return
+		}
+	}
+	if t, ok := s["type"].(string); ok && !isType(value, t) {
+		*errs = append(*errs, types.FieldError{Field: path, Message: fmt.Sprintf("must be of type %s", t)})
+		return
+	}
+	if enum, ok := s["enum"].([]any); ok {
+		allowed := []string{}
+		found := false
+		for _, e := range enum {
+			allowed = append(allowed, fmt.Sprintf("%v", e))
+			if fmt.Sprintf("%v", e) == fmt.Sprintf("%v", value) {
+				found = true
+			}
+		}
+		if !found {
+			*errs = append(*errs, types.FieldError{Field: path, Message: fmt.Sprintf("must be one of %s", strings.Join(allowed, ", "))})
+		}
+	}
+	if anyOf, ok := s["anyOf"].([]any); ok {
+		var closest []types.FieldError
+		for _, alternative := range anyOf {
+			sub, ok := alternative.(map[string]any)
+			if !ok {
+				continue
+			}
+			altErrs := []types.FieldError{}
+			validateValue(sub, scopes, value, path, &altErrs)
+			if len(altErrs) == 0 {
+				closest = nil
+				break
+			}
+			if closest == nil || len(altErrs) < len(closest) {
+				closest = altErrs
+			}
+		}
+		*errs = append(*errs, closest...)
+	}
+	switch v := value.(type) {
+	case map[string]any:
+		validateObject(s, scopes, v, path, errs)
+	case []any:
+		if items, ok := s["items"].(map[string]any); ok {
+			for i, item := range v {
+				validateValue(items, scopes, item, fmt.Sprintf("%s[%d]", path, i), errs)
+			}
+		}
+	}
+}
+
+func validateObject(s map[string]any, scopes []map[string]any, obj map[string]any, path string, errs *[]types.FieldError) {
+	if required, ok := s["required"].([]any); ok {
+		for _, r := range required {
+			name, _ := r.(string)
+			if _, present := obj[name]; !present {
+				*errs = append(*errs, types.FieldError{Field: fieldPath(path, name), Message: "is required"})
+			}
+		}
+	}
+	properties, _ := s["properties"].(map[string]any)
+	// go through the fields in order, so the errors come back the same way every time
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if prop, ok := properties[name].(map[string]any); ok {
+			validateValue(prop, scopes, obj[name], fieldPath(path, name), errs)
+			continue
+		}
+		switch additional := s["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				*errs = append(*errs, types.FieldError{Field: fieldPath(path, name), Message: "is not an allowed field"})
+			}
+		case map[string]any:
+			validateValue(additional, scopes, obj[name], fieldPath(path, name), errs)
+		}
+	}
+}
+
+func resolveRef(ref string, scopes []map[string]any) (map[string]any, bool) {
+	name, ok := strings.CutPrefix(ref, "#/$defs/")
+	if !ok {
+		return nil, false
+	}
+	for i := len(scopes) - 1; i >= 0; i-- {
+		if target, ok := scopes[i][name].(map[string]any); ok {
+			return target, true
+		}
+	}
+	return nil, false
+}
+
+func isType(value any, t string) bool {
+	switch t {
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "number":
+		_, ok := value.(json.Number)
+		return ok
+	case "integer":
+		n, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		_, err := n.Int64()
+		return err == nil
+	case "null":
+		return value == nil
+	}
+	return true
+}
+
+func fieldPath(path, name string) string {
+	if len(path) == 0 {
+		return name
+	}
+	return path + "." + name
+}
@@ -0,0 +1,92 @@
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/rest"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/types"
+	"k8s.io/klog/v2"
+)
+
+// Error is returned when content fails validation in strict mode, carrying what is wrong with it
+type Error struct {
+	Key    string
+	Errors []types.FieldError
+}
+
+func (e *Error) Error() string {
+	msgs := []string{}
+	for _, fe := range e.Errors {
+		if len(fe.Field) == 0 {
+			msgs = append(msgs, fe.Message)
+			continue
+		}
+		msgs = append(msgs, fmt.Sprintf("%s %s", fe.Field, fe.Message))
+	}
+	return fmt.Sprintf("content for key %s is not valid: %s", e.Key, strings.Join(msgs, "; "))
+}
+
+// ModeFromEnv reads the validation mode, which is strict unless set otherwise
+func ModeFromEnv() types.ValidationMode {
+	v := strings.TrimSpace(strings.NewReplacer("\r", "", "\n", "").Replace(os.Getenv(types.ValidationModeEnvVar)))
+	switch mode := types.ValidationMode(strings.ToLower(v)); mode {
+	case types.ValidationStrict, types.ValidationLenient, types.ValidationOff:
+		return mode
+	case "":
+	default:
+		klog.Errorf("ignoring %s setting %q as it is not one of %s, %s or %s", types.ValidationModeEnvVar, v, types.ValidationStrict, types.ValidationLenient, types.ValidationOff)
+	}
+	return types.ValidationStrict
+}
+
+// Validate returns what is wrong with content in the given format: for JsonArrayFormat whatever does not match the
+// model catalog schema, and otherwise whatever in the catalog-info entities Backstage would refuse
+func Validate(body []byte, format types.NormalizerFormat) []types.FieldError {
+	switch format {
+	case types.JsonArrayForamt:
+		return validateModelCatalog(body)
+	default:
+		return validateEntities(body)
+	}
+}
+
+// Check validates the content upserted for key according to mode; in strict mode invalid content is an *Error, while
+// in lenient mode what is wrong is returned for the caller to record.  An empty mode, as for servers not built from
+// the environment, does not validate.
+func Check(mode types.ValidationMode, key string, body []byte, format types.NormalizerFormat) ([]types.FieldError, error) {
+	if mode != types.ValidationStrict && mode != types.ValidationLenient {
+		return nil, nil
+	}
+	errs := Validate(body, format)
+	if len(errs) == 0 {
+		return nil, nil
+	}
+	if mode == types.ValidationStrict {
+		return nil, &Error{Key: key, Errors: errs}
+	}
+	klog.Warningf("storing key %s, even though its content is not valid: %s", key, (&Error{Key: key, Errors: errs}).Error())
+	return errs, nil
+}
+
+// Respond answers with a 422 listing the fields at fault when err is an *Error, returning false for any other error
+func Respond(c *gin.Context, err error) bool {
+	var verr *Error
+	if !errors.As(err, &verr) {
+		return false
+	}
+	klog.Error(verr.Error())
+	c.Error(verr)
+	content, err := json.Marshal(rest.ValidationErrorResponse{Error: verr.Error(), Errors: verr.Errors})
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return true
+	}
+	c.Data(http.StatusUnprocessableEntity, "Content-Type: application/json", content)
+	return true
+}
@@ -0,0 +1,171 @@
+package validation
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/types"
+	"github.com/redhat-ai-dev/model-catalog-bridge/test/stub/common"
+)
+
+const validEntities = `apiVersion: backstage.io/v1alpha1
+kind: Component
+metadata:
+  name: mnist-v1
+  namespace: default
+  tags:
+  - pytorch
+  - llm:7b
+  links:
+  - url: https://huggingface.co/mnist
+    title: mnist
+  annotations:
+    backstage.io/techdocs-ref: ./
+spec:
+  type: model-server
+---
+apiVersion: backstage.io/v1alpha1
+kind: Resource
+metadata:
+  name: mnist_v1.0
+`
+
+func TestValidateModelCatalog(t *testing.T) {
+	examples, err := filepath.Glob(filepath.Join("..", "..", "..", "..", "schema", "tests", "*.json"))
+	common.AssertError(t, err)
+	if len(examples) == 0 {
+		t.Fatal("found none of the schema examples")
+	}
+	for _, example := range examples {
+		body, err := os.ReadFile(example)
+		common.AssertError(t, err)
+		if errs := Validate(body, types.JsonArrayForamt); len(errs) > 0 {
+			t.Errorf("expected %s to be valid, got %v", example, errs)
+		}
+	}
+
+	for _, tc := range []struct {
+		name     string
+		body     string
+		expected []types.FieldError
+	}{
+		{
+			name:     "not JSON",
+			body:     "name: mnist",
+			expected: []types.FieldError{{Message: "is not valid JSON: invalid character 'a' in literal null (expecting 'u')"}},
+		},
+		{
+			name:     "no models",
+			body:     `{"modelServer": {"name": "vllm", "owner": "user", "lifecycle": "production", "description": "vllm"}}`,
+			expected: []types.FieldError{{Field: "models", Message: "is required"}},
+		},
+		{
+			name: "model missing fields",
+			body: `{"models": [{"name": "mnist", "owner": "user", "lifecycle": "production", "description": "mnist"}, {"name": "granite", "tags": "llm", "size": 7}]}`,
+			expected: []types.FieldError{
+				{Field: "models[1].owner", Message: "is required"},
+				{Field: "models[1].description", Message: "is required"},
+				{Field: "models[1].lifecycle", Message: "is required"},
+				{Field: "models[1].size", Message: "is not an allowed field"},
+				{Field: "models[1].tags", Message: "must be of type array"},
+			},
+		},
+		{
+			name: "bad API type",
+			body: `{"modelServer": {"name": "vllm", "owner": "user", "lifecycle": "production", "description": "vllm", "API": {"url": "https://vllm", "type": "rest", "spec": "spec", "annotations": {"a": 1}}}, "models": []}`,
+			expected: []types.FieldError{
+				{Field: "modelServer.API.annotations.a", Message: "must be of type string"},
+				{Field: "modelServer.API.type", Message: "must be one of openapi, asyncapi, graphql, grpc"},
+			},
+		},
+	} {
+		errs := Validate([]byte(tc.body), types.JsonArrayForamt)
+		if len(errs) != len(tc.expected) {
+			t.Errorf("test %s expected %v got %v", tc.name, tc.expected, errs)
+			continue
+		}
+		common.AssertEqual(t, tc.expected, errs)
+	}
+}
+
+func TestValidateEntities(t *testing.T) {
+	if errs := Validate([]byte(validEntities), types.CatalogInfoYamlFormat); len(errs) > 0 {
+		t.Errorf("expected the entities to be valid, got %v", errs)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		body     string
+		expected []string
+	}{
+		{
+			name:     "empty",
+			body:     "---\n",
+			expected: []string{""},
+		},
+		{
+			name:     "bad name and namespace",
+			body:     "apiVersion: backstage.io/v1alpha1\nkind: Component\nmetadata:\n  name: mnist v1\n  namespace: Default\n",
+			expected: []string{"entities[0].metadata.name", "entities[0].metadata.namespace"},
+		},
+		{
+			name:     "bad tags and links in the second entity",
+			body:     validEntities + "---\napiVersion: backstage.io/v1alpha1\nkind: API\nmetadata:\n  name: mnist-v1\n  tags:\n  - ok\n  - Not_OK\n  links:\n  - url: /relative\n  - title: no url\n",
+			expected: []string{"entities[2].metadata.tags[1]", "entities[2].metadata.links[0].url", "entities[2].metadata.links[1].url"},
+		},
+		{
+			name:     "bad kind, apiVersion and annotation",
+			body:     "apiVersion: backstage.io/\nkind: 1Component\nmetadata:\n  name: mnist\n  annotations:\n    bad key: x\n",
+			expected: []string{"entities[0].apiVersion", "entities[0].kind", "entities[0].metadata.annotations"},
+		},
+		{
+			name:     "tags not a list",
+			body:     "apiVersion: backstage.io/v1alpha1\nkind: Component\nmetadata:\n  name: mnist\n  tags: llm\n",
+			expected: []string{"entities[0]"},
+		},
+	} {
+		errs := Validate([]byte(tc.body), types.CatalogInfoYamlFormat)
+		fields := []string{}
+		for _, fe := range errs {
+			fields = append(fields, fe.Field)
+		}
+		if len(fields) != len(tc.expected) {
+			t.Errorf("test %s expected %v got %v", tc.name, tc.expected, errs)
+			continue
+		}
+		common.AssertEqual(t, tc.expected, fields)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	invalid := []byte(`{"models": [{"name": "mnist"}]}`)
+
+	errs, err := Check("", "mnist_v1", invalid, types.JsonArrayForamt)
+	common.AssertError(t, err)
+	common.AssertEqual(t, 0, len(errs))
+	errs, err = Check(types.ValidationOff, "mnist_v1", invalid, types.JsonArrayForamt)
+	common.AssertError(t, err)
+	common.AssertEqual(t, 0, len(errs))
+
+	errs, err = Check(types.ValidationLenient, "mnist_v1", invalid, types.JsonArrayForamt)
+	common.AssertError(t, err)
+	common.AssertEqual(t, 3, len(errs))
+
+	_, err = Check(types.ValidationStrict, "mnist_v1", invalid, types.JsonArrayForamt)
+	var verr *Error
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+	common.AssertEqual(t, "mnist_v1", verr.Key)
+	common.AssertEqual(t, 3, len(verr.Errors))
+}
+
+func TestModeFromEnv(t *testing.T) {
+	common.AssertEqual(t, types.ValidationStrict, ModeFromEnv())
+	t.Setenv(types.ValidationModeEnvVar, "Lenient")
+	common.AssertEqual(t, types.ValidationLenient, ModeFromEnv())
+	t.Setenv(types.ValidationModeEnvVar, "sometimes")
+	common.AssertEqual(t, types.ValidationStrict, ModeFromEnv())
+}
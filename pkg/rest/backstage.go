@@ -10,6 +10,7 @@ const (
 	RESOURCE_URI  = "/entities/by-name/resource/%s/%s"
 	API_URI       = "/entities/by-name/api/%s/%s"
 	QUERY_URI     = "/entities/by-query"
+	REFRESH_URI   = "/refresh"
 	DEFAULT_NS    = "default"
 )
 
@@ -19,6 +20,27 @@ type BackstageImport interface {
 	GetLocation(id string) (map[string]any, error)
 }
 
+// BackstageCatalog is optionally implemented by BackstageImport clients which can list what the Backstage catalog
+// currently holds, so the bridge can reconcile it against storage.
+type BackstageCatalog interface {
+	// ListLocationMaps returns the id, type and target of every location registered with Backstage
+	ListLocationMaps() ([]map[string]any, error)
+	// QueryEntityMaps returns every entity matching any of the supplied filters, following pagination
+	QueryEntityMaps(filters ...string) ([]map[string]any, error)
+}
+
+// BackstageRefresh is optionally implemented by BackstageImport clients which can have Backstage process an entity
+// again right away, rather than when its processing loop gets to it
+type BackstageRefresh interface {
+	RefreshEntity(entityRef string) error
+}
+
+// BackstagePing is optionally implemented by BackstageImport clients which can check that Backstage answers catalog
+// requests, without the cost of listing the catalog
+type BackstagePing interface {
+	Ping() error
+}
+
 func ParseImportLocationMap(retJSON map[string]any) (id string, target string, ok bool) {
 	var location interface{}
 	location, ok = retJSON["location"]
@@ -1,8 +1,65 @@
 package rest
 
+import "github.com/redhat-ai-dev/model-catalog-bridge/pkg/types"
+
 type PostBody struct {
 	Body                     []byte `json:"body"`
 	LastUpdateTimeSinceEpoch string `json:"lastUpdateTimeSinceEpoch"`
 	ModelCardKey             string `json:"modelCardKey"`
 	ModelCard                string `json:"modelCard"`
+	// Tombstone tells the location service to answer with a 410 for the model until it is removed for good
+	Tombstone *types.Tombstone `json:"tombstone,omitempty"`
+	// Digest is the types.ContentDigest of the body and model card; when sent it has to match them
+	Digest string `json:"digest,omitempty"`
+}
+
+// ContentDigest is the types.ContentDigest of the body and model card
+func (p *PostBody) ContentDigest() string {
+	return types.ContentDigest(p.Body, p.ModelCardKey, p.ModelCard)
+}
+
+// UpsertUnchanged is the result of an upsert of the same content as is stored, which is neither stored nor pushed to
+// the location service again
+const UpsertUnchanged = "unchanged"
+
+// UpsertResponse is the body of the 200 answer to an upsert of unchanged content
+type UpsertResponse struct {
+	Key    string `json:"key"`
+	Result string `json:"result"`
+	Digest string `json:"digest"`
+}
+
+// BatchUpsertEntry is one model of a batch upsert, carrying the query parameters of a single upsert along with its body
+type BatchUpsertEntry struct {
+	Key    string `json:"key"`
+	Type   string `json:"type,omitempty"`
+	Source string `json:"source,omitempty"`
+	PostBody
+}
+
+type BatchUpsertBody struct {
+	Entries []BatchUpsertEntry `json:"entries"`
+}
+
+// BatchUpsertResult is what upserting one model of a batch did, with the status code a single upsert would have
+// returned for it
+type BatchUpsertResult struct {
+	Key    string `json:"key"`
+	Status int    `json:"status"`
+	Error  string `json:"error,omitempty"`
+	// Result is UpsertUnchanged when the model's content is the same as is stored
+	Result string `json:"result,omitempty"`
+	// Errors is what is wrong with the model's content, when it was rejected by validation
+	Errors []types.FieldError `json:"errors,omitempty"`
+}
+
+// ValidationErrorResponse is the body of a 422 answer to an upsert whose content failed validation
+type ValidationErrorResponse struct {
+	Error  string             `json:"error"`
+	Errors []types.FieldError `json:"errors"`
+}
+
+// BatchUpsertResponse has a result for each entry of the batch, in the same order
+type BatchUpsertResponse struct {
+	Results []BatchUpsertResult `json:"results"`
 }
@@ -0,0 +1,34 @@
+package types
+
+// AuthMode picks how storage-rest and the location service check the bearer token their callers send
+type AuthMode string
+
+const (
+	// AuthModeNone accepts every caller, which is what both services did before authentication was added
+	AuthModeNone AuthMode = "None"
+	// AuthModeKubernetes validates tokens with a TokenReview and checks access with a SubjectAccessReview
+	AuthModeKubernetes AuthMode = "Kubernetes"
+	// AuthModeStatic compares tokens against fixed read and write tokens, for running outside of a cluster
+	AuthModeStatic AuthMode = "Static"
+)
+
+// settings for authenticating and authorizing callers
+const (
+	AuthModeEnvVar = "BRIDGE_AUTH_MODE"
+	// AuthStaticReadTokensEnvVar and AuthStaticWriteTokensEnvVar are comma separated
+	AuthStaticReadTokensEnvVar  = "BRIDGE_AUTH_STATIC_READ_TOKENS"
+	AuthStaticWriteTokensEnvVar = "BRIDGE_AUTH_STATIC_WRITE_TOKENS"
+	// the SubjectAccessReview attributes; the namespace defaults to the pod's namespace
+	AuthNamespaceEnvVar = "BRIDGE_AUTH_NAMESPACE"
+	AuthGroupEnvVar     = "BRIDGE_AUTH_GROUP"
+	AuthResourceEnvVar  = "BRIDGE_AUTH_RESOURCE"
+	AuthReadVerbEnvVar  = "BRIDGE_AUTH_READ_VERB"
+	AuthWriteVerbEnvVar = "BRIDGE_AUTH_WRITE_VERB"
+	// AuthRouteVerbsEnvVar overrides the verb for individual routes, as comma separated "METHOD /path=verb" settings
+	AuthRouteVerbsEnvVar = "BRIDGE_AUTH_ROUTE_VERBS"
+	// AuthAnonymousContentEnvVar lets callers without a token read catalog-info and model card content from the
+	// location service, since the Backstage URL reader does not send one
+	AuthAnonymousContentEnvVar = "BRIDGE_AUTH_ANONYMOUS_CONTENT"
+	// AuthCacheTTLEnvVar is how long TokenReview and SubjectAccessReview results are reused, as a Go duration
+	AuthCacheTTLEnvVar = "BRIDGE_AUTH_CACHE_TTL"
+)
@@ -0,0 +1,33 @@
+package types
+
+// settings for the HTTP servers of storage-rest and the location service, which are Go durations where 0 means no
+// limit
+const (
+	HTTPReadHeaderTimeoutEnvVar = "HTTP_READ_HEADER_TIMEOUT"
+	HTTPReadTimeoutEnvVar       = "HTTP_READ_TIMEOUT"
+	// HTTPWriteTimeoutEnvVar also bounds streaming responses, such as the storage watch and the location events, so it
+	// is best left at 0
+	HTTPWriteTimeoutEnvVar = "HTTP_WRITE_TIMEOUT"
+	HTTPIdleTimeoutEnvVar  = "HTTP_IDLE_TIMEOUT"
+	// ShutdownDrainDelayEnvVar is how long readiness fails before the server stops taking requests, so that Kubernetes
+	// stops sending them first
+	ShutdownDrainDelayEnvVar = "SHUTDOWN_DRAIN_DELAY"
+	// ShutdownTimeoutEnvVar is how long the requests in flight are given to finish once the server stops taking
+	// requests
+	ShutdownTimeoutEnvVar = "SHUTDOWN_TIMEOUT"
+	// ReadinessCheckTimeoutEnvVar is how long each readiness check is given
+	ReadinessCheckTimeoutEnvVar = "READINESS_CHECK_TIMEOUT"
+	// ReadinessRequiresBackstageEnvVar has storage-rest only report ready when Backstage is reachable, which with
+	// storage-rest running as a sidecar of Backstage would keep the pod from ever being ready
+	ReadinessRequiresBackstageEnvVar = "READINESS_REQUIRES_BACKSTAGE"
+)
+
+// settings for the location service
+const (
+	// LocationResyncIntervalEnvVar is how often, as a Go duration, the location service compares what it serves with
+	// storage and repairs any difference, such as from an upsert storage-rest failed to push; 0 turns resync off
+	LocationResyncIntervalEnvVar = "LOCATION_RESYNC_INTERVAL"
+	// LocationResyncJitterEnvVar is the largest fraction of the resync interval added to it at random, so that replicas
+	// of the location service do not all list storage at once
+	LocationResyncJitterEnvVar = "LOCATION_RESYNC_JITTER"
+)
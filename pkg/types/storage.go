@@ -1,24 +1,229 @@
 package types
 
 import (
+     "context"
+     "crypto/sha256"
+     "encoding/hex"
+     "errors"
+     "fmt"
+     "strconv"
+     "strings"
+     "time"
+
      "k8s.io/client-go/rest"
 )
 
+// BridgeStorage revisions are opaque tokens, and each backend derives them from whatever it already has (a resource
+// version, a content hash, a row counter).  Callers pass the revision they got from Fetch back to Upsert or Remove,
+// so that they only change the entry they actually looked at.
 type BridgeStorage interface {
 	Initialize(cfg *rest.Config) error
-	Fetch(key string) (StorageBody, error)
-	Upsert(key string, value StorageBody) error
-	Remove(key string) error
+	// Fetch returns the entry for the key and its revision, where both are empty if the key is not present
+	Fetch(key string) (StorageBody, string, error)
+	// Upsert stores the entry and returns its new revision, provided the current revision of the key is
+	// expectedRevision; an empty expectedRevision means the key must not be present yet, and AnyRevision skips the
+	// check.  An error wrapping ErrConflict is returned when the revisions do not match.
+	Upsert(key string, value StorageBody, expectedRevision string) (string, error)
+	// Remove deletes the entry, with the same expectedRevision checks as Upsert; removing a key which is not present is
+	// not an error
+	Remove(key string, expectedRevision string) error
 	List() ([]string, error)
+	// Watch sends an ADDED event for every key present, then a SYNCED event, and from then on an event for every
+	// change until ctx is done, when the channel is closed.  Changes made in quick succession to the same key may
+	// arrive as a single event.
+	Watch(ctx context.Context) (<-chan WatchEvent, error)
+}
+
+// AnyRevision can be passed to Upsert or Remove for the last writer wins behavior
+const AnyRevision = "*"
+
+var ErrConflict = errors.New("storage revision conflict")
+
+func IsConflict(err error) bool {
+	return errors.Is(err, ErrConflict)
+}
+
+// CheckRevision returns an error wrapping ErrConflict if the key's actual revision is not the expected one
+func CheckRevision(key, expected, actual string) error {
+	if expected == AnyRevision || expected == actual {
+		return nil
+	}
+	return fmt.Errorf("%w: key %s is at revision %q but revision %q was expected", ErrConflict, key, actual, expected)
+}
+
+// IsOlderEpoch returns true if the incoming lastUpdateTimeSinceEpoch is older than the stored one; the values are
+// milliseconds since the epoch, and if either is empty or not a number we cannot tell, so it is not considered older
+func IsOlderEpoch(incoming, stored string) bool {
+	in, err := strconv.ParseInt(incoming, 10, 64)
+	if err != nil {
+		return false
+	}
+	cur, err := strconv.ParseInt(stored, 10, 64)
+	if err != nil {
+		return false
+	}
+	return in < cur
+}
+
+// ContentDigest is the digest of what an upsert stores and the location service serves for a model, its body along
+// with its model card, so that an upsert of the same content can be recognized without comparing the content itself
+func ContentDigest(body []byte, modelCardKey, modelCard string) string {
+	h := sha256.New()
+	// the lengths keep content moving between the fields from hashing the same
+	fmt.Fprintf(h, "%d:", len(body))
+	h.Write(body)
+	fmt.Fprintf(h, "%d:%s%d:%s", len(modelCardKey), modelCardKey, len(modelCard), modelCard)
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))
+}
+
+// WatchEventType follows the Kubernetes watch event types, plus SYNCED
+type WatchEventType string
+
+const (
+	WatchEventAdded    WatchEventType = "ADDED"
+	WatchEventModified WatchEventType = "MODIFIED"
+	WatchEventDeleted  WatchEventType = "DELETED"
+	// WatchEventSynced follows the ADDED events for the keys which were present when the watch started, so a consumer
+	// knows it can drop any key it has that it has not received since
+	WatchEventSynced WatchEventType = "SYNCED"
+)
+
+type WatchEvent struct {
+	Type WatchEventType `json:"type"`
+	Key  string         `json:"key,omitempty"`
+	// Revision is the key's revision after the change
+	Revision string       `json:"revision,omitempty"`
+	Value    *StorageBody `json:"value,omitempty"`
+	// Sequence is set by the storage-rest change feed, and a consumer passes the last one it received as the 'since'
+	// query parameter to resume from there
+	Sequence int64 `json:"sequence,omitempty"`
 }
 
 type StorageBody struct {
-	Body                     []byte `json:"body"`
-	LocationId               string `json:"locationId"`
-	LocationTarget           string `json:"locationTarget"`
-	LocationIDValid          bool   `json:"locationIDValid"`
-	ReconcilerType           string `json:"reconcilerType"`
+	Body            []byte `json:"body,omitempty"`
+	LocationId      string `json:"locationId"`
+	LocationTarget  string `json:"locationTarget"`
+	LocationIDValid bool   `json:"locationIDValid"`
+	ReconcilerType  string `json:"reconcilerType"`
+	// Source is the normalizer source which owns the entry, as built by SourceID; only a current key set for the same
+	// source removes the entry
+	Source                   string `json:"source,omitempty"`
 	LastUpdateTimeSinceEpoch string `json:"lastUpdateTimeSinceEpoch"`
+	ModelCardKey             string `json:"modelCardKey,omitempty"`
+	// ModelCard is the model card last upserted with Body, kept so that the location service can get it back from
+	// storage
+	ModelCard      string `json:"modelCard,omitempty"`
+	LastPushResult string `json:"lastPushResult,omitempty"`
+	// Tombstone is set once the model is no longer found, and the entry is kept, with its last body and Backstage
+	// location, until the tombstone expires, so that the model coming back carries on with the same location
+	Tombstone *Tombstone `json:"tombstone,omitempty"`
+	// Digest is the ContentDigest of Body and the model card last upserted with it
+	Digest string `json:"digest,omitempty"`
+	// Invalid is what validation found wrong with Body, when it was stored in lenient validation mode
+	Invalid []FieldError `json:"invalid,omitempty"`
+}
+
+// SourceID names a source of models, the reconciler type along with, for a model registry, which registry, so that
+// the current key set of one source does not remove the models of another
+func SourceID(reconcilerType, registry string) string {
+	if len(registry) == 0 {
+		return reconcilerType
+	}
+	return reconcilerType + "/" + registry
+}
+
+// EffectiveSource is the source of an entry, which for entries stored before sources were recorded is their reconciler
+// type
+func EffectiveSource(source, reconcilerType string) string {
+	if len(source) > 0 {
+		return source
+	}
+	return reconcilerType
+}
+
+// OwnedBy says whether the entry belongs to the source; entries stored before sources were recorded belong to any
+// source with the same reconciler type
+func (sb *StorageBody) OwnedBy(source string) bool {
+	if len(sb.Source) > 0 {
+		return sb.Source == source
+	}
+	reconcilerType, _, _ := strings.Cut(source, "/")
+	return sb.ReconcilerType == reconcilerType
+}
+
+// Tombstone records who removed an entry, when and why
+type Tombstone struct {
+	RemovedAt time.Time `json:"removedAt"`
+	RemovedBy string    `json:"removedBy,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	// ExpiresAt is when the entry is removed for good, along with its Backstage location and location service content
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// values for StorageBody.LastPushResult
+const (
+	PushResultImported             = "Imported"
+	PushResultImportFailed         = "ImportFailed"
+	PushResultBackstageUnavailable = "BackstageUnavailable"
+	PushResultPushDisabled         = "PushDisabled"
+)
+
+// BridgeStorageUsageReporter is optionally implemented by BridgeStorage backends which have hard size limits, so
+// operators can see how much room is left before upserts start failing
+type BridgeStorageUsageReporter interface {
+	Usage() (StorageUsage, error)
+}
+
+// BridgeStorageHistory is optionally implemented by BridgeStorage backends which keep every revision of an entry, so
+// storage-rest can show how an entry has changed over time
+type BridgeStorageHistory interface {
+	// History returns the revisions of the key, oldest first
+	History(key string) ([]StorageRevision, error)
+	// FetchAsOf returns the entry for the key as it was at the given time, which is empty if the key did not exist then
+	FetchAsOf(key string, asOf time.Time) (StorageBody, error)
+}
+
+type StorageRevision struct {
+	Revision   int64       `json:"revision"`
+	Operation  string      `json:"operation"`
+	RecordedAt time.Time   `json:"recordedAt"`
+	Value      StorageBody `json:"value"`
+}
+
+// values for StorageRevision.Operation
+const (
+	StorageOperationUpsert = "upsert"
+	StorageOperationRemove = "remove"
+)
+
+// BridgeStorageTransactor is optionally implemented by BridgeStorage backends which can update several keys atomically;
+// if fn returns an error none of its updates are applied
+type BridgeStorageTransactor interface {
+	Transact(fn func(tx BridgeStorageTx) error) error
+}
+
+// BridgeStorageTx has the same revision semantics as BridgeStorage
+type BridgeStorageTx interface {
+	Fetch(key string) (StorageBody, string, error)
+	Upsert(key string, value StorageBody, expectedRevision string) (string, error)
+	Remove(key string, expectedRevision string) error
+	List() ([]string, error)
+}
+
+type StorageUsage struct {
+	Type          BridgeStorageType `json:"type"`
+	Keys          int               `json:"keys"`
+	Bytes         int               `json:"bytes"`
+	HeadroomBytes int               `json:"headroomBytes"`
+	Shards        []ShardUsage      `json:"shards,omitempty"`
+}
+
+type ShardUsage struct {
+	Name          string `json:"name"`
+	Keys          int    `json:"keys"`
+	Bytes         int    `json:"bytes"`
+	MaxBytes      int    `json:"maxBytes"`
+	HeadroomBytes int    `json:"headroomBytes"`
 }
 
 type BridgeStorageType string
@@ -26,9 +231,68 @@ type BridgeStorageType string
 const (
 	ConfigMapBridgeStorage BridgeStorageType = "ConfigMap"
 	GithubBridgeStorage    BridgeStorageType = "Github"
+	// GitBridgeStorage is an alias for GithubBridgeStorage, as the git backend works with any git remote
+	GitBridgeStorage BridgeStorageType = "Git"
+	// CustomResourceBridgeStorage stores each key as a ModelCatalogEntry custom resource
+	CustomResourceBridgeStorage BridgeStorageType = "CustomResource"
+	// SQLiteBridgeStorage stores entries, along with their revision history, in an embedded SQLite database file
+	SQLiteBridgeStorage BridgeStorageType = "SQLite"
 
 	StorageUrlEnvVar  = "STORAGE_URL"
 	StorageTypeEnvVar = "STORAGE_TYPE"
 
 	PushToRHDHEnvVar = "PUSH_TO_RHDH"
+
+	ConfigMapStorageMaxShardBytesEnvVar = "CONFIGMAP_STORAGE_MAX_SHARD_BYTES"
+
+	SQLiteStoragePathEnvVar = "SQLITE_STORAGE_PATH"
+
+	// TombstoneGracePeriodEnvVar is how long, as a Go duration, the entry for a model no longer found is kept as a
+	// tombstone before it is removed; when not set entries are removed right away
+	TombstoneGracePeriodEnvVar = "TOMBSTONE_GRACE_PERIOD"
+
+	// BackstageOutboxMaxAttemptsEnvVar is how many times a failed Backstage location import or delete is tried before
+	// it is dead-lettered
+	BackstageOutboxMaxAttemptsEnvVar = "BACKSTAGE_OUTBOX_MAX_ATTEMPTS"
+
+	// BackstageReconcileIntervalEnvVar is how often, as a Go duration, storage is compared with the Backstage catalog
+	// and any drift between them repaired; 0 turns the periodic pass off
+	BackstageReconcileIntervalEnvVar = "BACKSTAGE_RECONCILE_INTERVAL"
+
+	// BackstageRefreshIntervalEnvVar is how long, as a Go duration, entity refreshes are collected before they are sent
+	// to Backstage as a batch; 0 turns refreshing entities off
+	BackstageRefreshIntervalEnvVar = "BACKSTAGE_REFRESH_INTERVAL"
+	// BackstageRefreshRateEnvVar is the most entity refreshes sent to Backstage per second
+	BackstageRefreshRateEnvVar = "BACKSTAGE_REFRESH_RATE"
+
+	// AuditMaxEntriesEnvVar is how many audit log entries are kept for each model; 0 turns the audit log off
+	AuditMaxEntriesEnvVar = "AUDIT_MAX_ENTRIES"
+	// AuditRetentionEnvVar is how long, as a Go duration, audit log entries are kept; 0 keeps them until there are
+	// more than AuditMaxEntriesEnvVar for the model
+	AuditRetentionEnvVar = "AUDIT_RETENTION"
+)
+
+// safeguards against the current key set removing keys a normalizer merely failed to see
+const (
+	// CurrentKeySetMaxRemoveRatioEnvVar is the largest fraction, between 0 and 1, of the stored keys one current key
+	// set may remove; when more would be removed, none are
+	CurrentKeySetMaxRemoveRatioEnvVar = "CURRENT_KEY_SET_MAX_REMOVE_RATIO"
+	// CurrentKeySetAbsentCountEnvVar is how many current key sets in a row a key has to be missing from before it is
+	// removed
+	CurrentKeySetAbsentCountEnvVar = "CURRENT_KEY_SET_ABSENT_COUNT"
+	// CurrentKeySetDryRunEnvVar reports the keys the current key set would remove instead of removing them
+	CurrentKeySetDryRunEnvVar = "CURRENT_KEY_SET_DRY_RUN"
+)
+
+// settings for the git storage backend
+const (
+	GitStorageRemoteEnvVar          = "GIT_STORAGE_REMOTE"
+	GitStorageBranchEnvVar          = "GIT_STORAGE_BRANCH"
+	GitStoragePathPrefixEnvVar      = "GIT_STORAGE_PATH_PREFIX"
+	GitStorageAuthorNameEnvVar      = "GIT_STORAGE_AUTHOR_NAME"
+	GitStorageAuthorEmailEnvVar     = "GIT_STORAGE_AUTHOR_EMAIL"
+	GitStorageUserEnvVar            = "GIT_STORAGE_USER"
+	GitStorageTokenEnvVar           = "GIT_STORAGE_TOKEN"
+	GitStorageWorkDirEnvVar         = "GIT_STORAGE_WORKDIR"
+	GitStorageRefreshIntervalEnvVar = "GIT_STORAGE_REFRESH_INTERVAL"
 )
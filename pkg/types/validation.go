@@ -0,0 +1,24 @@
+package types
+
+// ValidationMode picks what storage-rest and the location service do with upserted content which does not pass
+// validation, against the model catalog schema for JsonArrayFormat, or the Backstage entity rules for
+// CatalogInfoYamlFormat
+type ValidationMode string
+
+const (
+	// ValidationStrict rejects invalid content, answering with the fields at fault
+	ValidationStrict ValidationMode = "strict"
+	// ValidationLenient stores invalid content, but marks the entry with the fields at fault
+	ValidationLenient ValidationMode = "lenient"
+	// ValidationOff does not validate content, which is what both services did before validation was added
+	ValidationOff ValidationMode = "off"
+
+	ValidationModeEnvVar = "BRIDGE_VALIDATION_MODE"
+)
+
+// FieldError is one problem validation found with upserted content; Field is the path to the value at fault, for
+// example "models[0].name" or "entities[1].metadata.tags[0]"
+type FieldError struct {
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
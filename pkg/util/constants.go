@@ -4,13 +4,56 @@ const (
 	DefaultOwner         = "rhdh-rhoai-bridge"
 	DefaultLifecycle     = "development"
 	StorageConfigMapName = "bac-import-model"
-	KeyQueryParam        = "key"
-	TypeQueryParam       = "type"
-	UpsertURI            = "/upsert"
-	CurrentKeySetURI     = "/currentkeyset"
-	RemoveURI            = "/remove"
-	ListURI              = "/list"
-	FetchURI             = "/fetch"
-	ModelCardURI         = "/modelcard"
+	// StorageIndexConfigMapName maps each key to the StorageConfigMapName shard holding it
+	StorageIndexConfigMapName = "bac-import-model-index"
+	StorageLabel              = "modelcatalogbridge.rhdh.io/storage"
+	StorageShardLabelValue    = "shard"
+	StorageIndexLabelValue    = "index"
+	KeyQueryParam             = "key"
+	TypeQueryParam            = "type"
+	AsOfQueryParam            = "asOf"
+	SinceQueryParam           = "since"
+	IncompleteQueryParam      = "incomplete"
+	DryRunQueryParam          = "dryRun"
+	SourceQueryParam          = "source"
+	IDQueryParam              = "id"
+	RegistryQueryParam        = "registry"
+	OwnerQueryParam           = "owner"
+	LifecycleQueryParam       = "lifecycle"
+	LimitQueryParam           = "limit"
+	ContinueQueryParam        = "continue"
+	UpsertURI                 = "/upsert"
+	UpsertBatchURI            = "/upsert/batch"
+	CurrentKeySetURI          = "/currentkeyset"
+	RemoveURI                 = "/remove"
+	ListURI                   = "/list"
+	ListMetadataURI           = "/list/metadata"
+	FetchURI                  = "/fetch"
+	ModelCardURI              = "/modelcard"
+	UsageURI                  = "/usage"
+	HistoryURI                = "/history"
+	WatchURI                  = "/watch"
+	OutboxURI                 = "/outbox"
+	OutboxReplayURI           = "/outbox/replay"
+	DriftURI                  = "/drift"
+	AuditURI                  = "/audit"
+	HealthzURI                = "/healthz"
+	ReadyzURI                 = "/readyz"
+	ResyncURI                 = "/resync"
+	CatalogURI                = "/catalog"
+	EventsURI                 = "/events"
 
+	// NoRegistrySegment is the registry segment of the location service URIs of models from a source other than a
+	// model registry, which cannot be the name of a registry
+	NoRegistrySegment = "-"
+
+	// CatalogInfoYamlFileName and ModelCatalogJsonFileName end the location service URIs of each NormalizerFormat
+	CatalogInfoYamlFileName  = "catalog-info.yaml"
+	ModelCatalogJsonFileName = "model-catalog.json"
 )
+
+// OutboxKeyPrefix starts the storage keys of pending Backstage operations, which are not models
+const OutboxKeyPrefix = "__bridge_outbox_"
+
+// AuditKeyPrefix starts the storage keys of the audit log of each model, which are not models
+const AuditKeyPrefix = "__bridge_audit_"
@@ -24,6 +24,10 @@ const (
 	NameInvalidCharRegexp = `[^a-zA-Z0-9\-_]`
 
 	NameNoDuplicateSpecialCharRegexp = `[-_.]{2,}`
+
+	// KeyInvalidCharRegexp matches what cannot be in a segment of a storage key, which has to be a valid ConfigMap key
+	// and is split with '_'
+	KeyInvalidCharRegexp = `[^a-zA-Z0-9\-.]`
 )
 
 func PrintYaml(obj interface{}, addDivider bool, w io.Writer) error {
@@ -85,11 +89,84 @@ func BuildImportKeyAndURI(seg1, seg2 string, format types.NormalizerFormat) (str
 	// no spaces in keys
 	seg1 = strings.ReplaceAll(seg1, " ", "")
 	seg2 = strings.ReplaceAll(seg2, " ", "")
-	fn := "catalog-info.yaml"
+	return fmt.Sprintf("%s_%s", seg1, seg2), fmt.Sprintf("/%s/%s/%s", seg1, seg2, FormatFileName(format))
+}
+
+// FormatFileName is the file name which ends the location service URIs of content in the format
+func FormatFileName(format types.NormalizerFormat) string {
 	if format == types.JsonArrayForamt {
-		fn = "model-catalog.json"
+		return ModelCatalogJsonFileName
+	}
+	return CatalogInfoYamlFileName
+}
+
+// FileNameFormat is the format of the content of the file name which ends a location service URI
+func FileNameFormat(fileName string) (types.NormalizerFormat, bool) {
+	switch fileName {
+	case CatalogInfoYamlFileName:
+		return types.CatalogInfoYamlFormat, true
+	case ModelCatalogJsonFileName:
+		return types.JsonArrayForamt, true
+	}
+	return "", false
+}
+
+// BuildSourceURI is the location service URI of the model with the import key from seg1 and seg2, under the source
+// which provides it, as built by types.SourceID, so that models of different sources with the same names get different
+// URIs: /{source}/{registry}/{seg1}/{seg2}/{file}, with NoRegistrySegment for a source other than a model registry.
+// Without a source, it is the URI from BuildImportKeyAndURI, which is what URIs were before sources were part of them.
+func BuildSourceURI(source, seg1, seg2 string, format types.NormalizerFormat) string {
+	_, uri := BuildImportKeyAndURI(seg1, seg2, format)
+	if len(source) == 0 {
+		return uri
+	}
+	reconcilerType, registry, _ := strings.Cut(strings.ReplaceAll(source, " ", ""), "/")
+	if len(registry) == 0 {
+		registry = NoRegistrySegment
+	}
+	return fmt.Sprintf("/%s/%s%s", reconcilerType, registry, uri)
+}
+
+// BuildSourceKey is the storage key of the model with the import key from seg1 and seg2, under the source which
+// provides it, as built by types.SourceID, so that models of different sources with the same names get different keys:
+// {source}_{registry}_{seg1}_{seg2}, the segments of the URI from BuildSourceURI, with '.' for what a storage key cannot
+// have in the registry.  Without a source, it is the key from BuildImportKeyAndURI, which is what keys were before
+// sources were part of them.
+func BuildSourceKey(source, seg1, seg2 string) string {
+	key, _ := BuildImportKeyAndURI(seg1, seg2, "")
+	if len(source) == 0 {
+		return key
+	}
+	reconcilerType, registry, _ := strings.Cut(strings.ReplaceAll(source, " ", ""), "/")
+	if len(registry) == 0 {
+		registry = NoRegistrySegment
+	}
+	registry = regexp.MustCompile(KeyInvalidCharRegexp).ReplaceAllString(registry, ".")
+	return fmt.Sprintf("%s_%s_%s", reconcilerType, registry, key)
+}
+
+// KeySegments returns the seg1 and seg2 the storage key was built from, by either BuildSourceKey or
+// BuildImportKeyAndURI
+func KeySegments(key string) (string, string, bool) {
+	segs := strings.Split(key, "_")
+	switch {
+	case len(segs) >= 4:
+		return segs[2], segs[3], true
+	case len(segs) >= 2:
+		return segs[0], segs[1], true
+	}
+	return "", "", false
+}
+
+// LegacyKey is the key the model under the storage key had before sources were part of keys, which is the key itself
+// for a key from before then
+func LegacyKey(key string) string {
+	seg1, seg2, ok := KeySegments(key)
+	if !ok {
+		return key
 	}
-	return fmt.Sprintf("%s_%s", seg1, seg2), fmt.Sprintf("/%s/%s/%s", seg1, seg2, fn)
+	legacy, _ := BuildImportKeyAndURI(seg1, seg2, "")
+	return legacy
 }
 
 func SanitizeModelVersion(mv string) string {
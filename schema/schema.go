@@ -0,0 +1,7 @@
+// Package schema embeds the model catalog schema, so that content in the JSON array format can be validated against it
+package schema
+
+import _ "embed"
+
+//go:embed model-catalog.schema.json
+var ModelCatalogSchema []byte
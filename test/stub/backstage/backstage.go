@@ -89,6 +89,19 @@ func CreateBackstageServerWithCallbackMap(callback *sync.Map, t *testing.T) *htt
 			}
 		case common.MethodPost:
 			switch r.URL.Path {
+			case rest.REFRESH_URI:
+				data := map[string]string{}
+				bodyBuf, err := io.ReadAll(r.Body)
+				if err == nil {
+					err = json.Unmarshal(bodyBuf, &data)
+				}
+				if err != nil || len(data["entityRef"]) == 0 {
+					w.WriteHeader(400)
+					return
+				}
+				callback.Store("refresh/"+data["entityRef"], data["entityRef"])
+				w.WriteHeader(200)
+				return
 			case rest.LOCATION_URI:
 				w.Header().Set("Content-Type", "application/json")
 				bodyBuf, err := io.ReadAll(r.Body)
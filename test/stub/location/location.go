@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	bridgeclient "github.com/redhat-ai-dev/model-catalog-bridge/pkg/cmd/server/location/client"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/rest"
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/util"
 	"github.com/redhat-ai-dev/model-catalog-bridge/test/stub/backstage"
 	"github.com/redhat-ai-dev/model-catalog-bridge/test/stub/common"
 	"io"
@@ -18,6 +20,7 @@ func SetupBridgeLocationRESTClient(ts *httptest.Server) *bridgeclient.BridgeLoca
 	bkstgTC := &bridgeclient.BridgeLocationRESTClient{}
 	bkstgTC.RESTClient = common.DC()
 	bkstgTC.UpsertURL = ts.URL
+	bkstgTC.UpsertBatchURL = ts.URL + util.UpsertBatchURI
 	bkstgTC.RemoveURL = ts.URL
 	return bkstgTC
 }
@@ -32,6 +35,21 @@ func CreateBridgeLocationServerWithCallbackMap(callback *sync.Map, t *testing.T)
 			callback.Store("delete", "delete")
 		case common.MethodPost:
 			switch r.URL.Path {
+			case util.UpsertBatchURI:
+				batch := rest.BatchUpsertBody{}
+				err := json.NewDecoder(r.Body).Decode(&batch)
+				if err != nil {
+					w.WriteHeader(500)
+					return
+				}
+				resp := rest.BatchUpsertResponse{}
+				for _, e := range batch.Entries {
+					callback.Store("batch/"+e.Key, string(e.Body))
+					resp.Results = append(resp.Results, rest.BatchUpsertResult{Key: e.Key, Status: http.StatusCreated})
+				}
+				w.Header().Set("Content-Type", "application/json")
+				buf, _ := json.Marshal(&resp)
+				_, _ = w.Write(buf)
 			default:
 				w.Header().Set("Content-Type", "application/json")
 				bodyBuf, err := io.ReadAll(r.Body)
@@ -0,0 +1,204 @@
+// Package conformance has the checks every types.BridgeStorage implementation is expected to pass; it lives apart
+// from the other storage stubs so the backend packages can use it without an import cycle
+package conformance
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/redhat-ai-dev/model-catalog-bridge/pkg/types"
+	"github.com/redhat-ai-dev/model-catalog-bridge/test/stub/common"
+)
+
+// AssertRevisionSemantics runs the same compare-and-swap scenario against any types.BridgeStorage implementation
+func AssertRevisionSemantics(t *testing.T, st types.BridgeStorage) {
+	t.Helper()
+	key := "revision_v1"
+
+	sb, rev, err := st.Fetch(key)
+	common.AssertError(t, err)
+	common.AssertEqual(t, types.StorageBody{}, sb)
+	common.AssertEqual(t, "", rev)
+
+	_, err = st.Upsert(key, types.StorageBody{Body: []byte("create")}, "bogus")
+	assertConflict(t, err, "upsert of an absent key with a revision")
+
+	rev1, err := st.Upsert(key, types.StorageBody{Body: []byte("create")}, "")
+	common.AssertError(t, err)
+	if len(rev1) == 0 {
+		t.Error("expected a revision from a successful upsert")
+	}
+	_, err = st.Upsert(key, types.StorageBody{Body: []byte("create again")}, "")
+	assertConflict(t, err, "create of a key which is present")
+
+	sb, rev, err = st.Fetch(key)
+	common.AssertError(t, err)
+	common.AssertEqual(t, "create", string(sb.Body))
+	common.AssertEqual(t, rev1, rev)
+
+	rev2, err := st.Upsert(key, types.StorageBody{Body: []byte("update")}, rev1)
+	common.AssertError(t, err)
+	if rev2 == rev1 {
+		t.Errorf("expected the revision to change from %s after an update", rev1)
+	}
+
+	// a writer that fetched before the update must not clobber it
+	_, err = st.Upsert(key, types.StorageBody{Body: []byte("stale")}, rev1)
+	assertConflict(t, err, "upsert with a stale revision")
+	sb, rev, err = st.Fetch(key)
+	common.AssertError(t, err)
+	common.AssertEqual(t, "update", string(sb.Body))
+	common.AssertEqual(t, rev2, rev)
+
+	err = st.Remove(key, rev1)
+	assertConflict(t, err, "remove with a stale revision")
+	err = st.Remove(key, "")
+	assertConflict(t, err, "remove of a present key with no revision")
+
+	err = st.Remove(key, rev2)
+	common.AssertError(t, err)
+	sb, rev, err = st.Fetch(key)
+	common.AssertError(t, err)
+	common.AssertEqual(t, types.StorageBody{}, sb)
+	common.AssertEqual(t, "", rev)
+	err = st.Remove(key, rev2)
+	common.AssertError(t, err)
+
+	_, err = st.Upsert(key, types.StorageBody{Body: []byte("any")}, types.AnyRevision)
+	common.AssertError(t, err)
+	_, err = st.Upsert(key, types.StorageBody{Body: []byte("any again")}, types.AnyRevision)
+	common.AssertError(t, err)
+	err = st.Remove(key, types.AnyRevision)
+	common.AssertError(t, err)
+	keys, err := st.List()
+	common.AssertError(t, err)
+	for _, k := range keys {
+		if k == key {
+			t.Errorf("key %s still listed after remove", key)
+		}
+	}
+}
+
+// AssertRoundTrip checks that every field of a types.StorageBody is stored and fetched back as it was; the sample sets
+// every field, so a field added to types.StorageBody fails here until it is added to the sample and to each backend
+func AssertRoundTrip(t *testing.T, st types.BridgeStorage) {
+	t.Helper()
+	removedAt := time.Now().UTC().Truncate(time.Second)
+	sb := types.StorageBody{
+		Body:                     []byte("round trip body"),
+		LocationId:               "loc-round-trip",
+		LocationTarget:           "http://location:9090/round/trip/catalog-info.yaml",
+		LocationIDValid:          true,
+		ReconcilerType:           "kubeflow",
+		Source:                   "kubeflow/registry",
+		LastUpdateTimeSinceEpoch: "1700000000",
+		ModelCardKey:             "round_trip_v1_modelcard",
+		ModelCard:                "# round trip",
+		LastPushResult:           types.PushResultImported,
+		Tombstone: &types.Tombstone{
+			RemovedAt: removedAt,
+			RemovedBy: "kubeflow",
+			Reason:    "model deleted",
+			ExpiresAt: removedAt.Add(time.Hour),
+		},
+		Digest:  "sha256:0123456789abcdef",
+		Invalid: []types.FieldError{{Field: "owner", Message: "is required"}, {Message: "no models"}},
+	}
+	v := reflect.ValueOf(sb)
+	for i := 0; i < v.NumField(); i++ {
+		if v.Field(i).IsZero() {
+			t.Fatalf("the round trip sample does not set StorageBody.%s", v.Type().Field(i).Name)
+		}
+	}
+
+	key := "round_trip_v1"
+	_, err := st.Upsert(key, sb, types.AnyRevision)
+	common.AssertError(t, err)
+	got, rev, err := st.Fetch(key)
+	common.AssertError(t, err)
+	if len(rev) == 0 {
+		t.Errorf("no revision for key %s", key)
+	}
+	if got.Tombstone != nil {
+		// backends keep times to the second, in whichever location they parse them into
+		got.Tombstone.RemovedAt = got.Tombstone.RemovedAt.UTC()
+		got.Tombstone.ExpiresAt = got.Tombstone.ExpiresAt.UTC()
+	}
+	common.AssertEqual(t, sb, got)
+}
+
+func assertConflict(t *testing.T, err error, desc string) {
+	t.Helper()
+	if !types.IsConflict(err) {
+		t.Errorf("expected a conflict for %s, got [%v]", desc, err)
+	}
+}
+
+// AssertWatch checks the initial state and the add, update and delete events from BridgeStorage.Watch; backends that
+// poll need a short poll interval for this to finish quickly
+func AssertWatch(t *testing.T, st types.BridgeStorage) {
+	t.Helper()
+	_, err := st.Upsert("watch_v1", types.StorageBody{Body: []byte("create")}, types.AnyRevision)
+	common.AssertError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch, err := st.Watch(ctx)
+	common.AssertError(t, err)
+	if err != nil {
+		return
+	}
+
+	ev := nextEvent(t, ch)
+	common.AssertEqual(t, types.WatchEventAdded, ev.Type)
+	common.AssertEqual(t, "watch_v1", ev.Key)
+	if ev.Value != nil {
+		common.AssertEqual(t, "create", string(ev.Value.Body))
+	} else {
+		t.Error("expected the ADDED event to have the value")
+	}
+	common.AssertEqual(t, types.WatchEventSynced, nextEvent(t, ch).Type)
+
+	rev, err := st.Upsert("watch_v2", types.StorageBody{Body: []byte("create")}, "")
+	common.AssertError(t, err)
+	ev = nextEvent(t, ch)
+	common.AssertEqual(t, types.WatchEventAdded, ev.Type)
+	common.AssertEqual(t, "watch_v2", ev.Key)
+	common.AssertEqual(t, rev, ev.Revision)
+
+	_, err = st.Upsert("watch_v1", types.StorageBody{Body: []byte("update")}, types.AnyRevision)
+	common.AssertError(t, err)
+	ev = nextEvent(t, ch)
+	common.AssertEqual(t, types.WatchEventModified, ev.Type)
+	common.AssertEqual(t, "watch_v1", ev.Key)
+	if ev.Value != nil {
+		common.AssertEqual(t, "update", string(ev.Value.Body))
+	}
+
+	err = st.Remove("watch_v2", types.AnyRevision)
+	common.AssertError(t, err)
+	ev = nextEvent(t, ch)
+	common.AssertEqual(t, types.WatchEventDeleted, ev.Type)
+	common.AssertEqual(t, "watch_v2", ev.Key)
+
+	cancel()
+	for range ch {
+		// the channel is closed once the watch stops
+	}
+}
+
+func nextEvent(t *testing.T, ch <-chan types.WatchEvent) types.WatchEvent {
+	t.Helper()
+	select {
+	case ev, ok := <-ch:
+		if !ok {
+			t.Fatal("watch channel closed unexpectedly")
+		}
+		return ev
+	case <-time.After(30 * time.Second):
+		t.Fatal("timed out waiting for a watch event")
+	}
+	return types.WatchEvent{}
+}
@@ -6,6 +6,7 @@ import (
      "io"
      "net/http"
      "net/http/httptest"
+     "net/url"
      "strings"
      "sync"
      "testing"
@@ -21,9 +22,12 @@ func SetupBridgeStorageRESTClient(ts *httptest.Server) *storage.BridgeStorageRES
 	storageTC := &storage.BridgeStorageRESTClient{}
 	storageTC.RESTClient = common.DC()
 	storageTC.UpsertURL = ts.URL + util.UpsertURI
+	storageTC.UpsertBatchURL = ts.URL + util.UpsertBatchURI
 	storageTC.ListURL = ts.URL + util.ListURI
+	storageTC.ListMetadataURL = ts.URL + util.ListMetadataURI
 	storageTC.FetchURL = ts.URL + util.FetchURI
 	storageTC.CurrentKeySetURL = ts.URL + util.CurrentKeySetURI
+	storageTC.WatchURL = ts.URL + util.WatchURI
 	return storageTC
 }
 
@@ -35,6 +39,17 @@ func CreateBridgeStorageREST(t *testing.T, called *sync.Map) *httptest.Server {
 		switch r.Method {
 		case common.MethodGet:
 			switch {
+			case strings.Contains(r.URL.Path, util.ListMetadataURI):
+				// two pages, so that paging through them is exercised
+				cont := r.URL.Query().Get(util.ContinueQueryParam)
+				called.Store(util.ListMetadataURI+"/"+cont, r.URL.Query().Get(util.LimitQueryParam))
+				w.Header().Set("Content-Type", "application/json")
+				resp := &storage.MetadataListResponse{Items: []storage.KeyMetadata{{Key: "foo_bar", Digest: "sha256:foo_bar", ModelCardKey: "foo_bar"}}, Continue: "mnist_v2"}
+				if cont == "mnist_v2" {
+					resp = &storage.MetadataListResponse{Items: []storage.KeyMetadata{{Key: "mnist_v2", Digest: "sha256:mnist_v2", ModelCardKey: "mnist_v2"}}}
+				}
+				buf, _ := json.Marshal(resp)
+				w.Write(buf)
 			case strings.Contains(r.URL.Path, util.ListURI):
 				called.Store(util.ListURI, util.ListURI)
 				w.Header().Set("Content-Type", "application/json")
@@ -44,30 +59,77 @@ func CreateBridgeStorageREST(t *testing.T, called *sync.Map) *httptest.Server {
 				w.WriteHeader(http.StatusOK)
 			case strings.Contains(r.URL.Path, util.FetchURI):
 				called.Store(util.FetchURI, util.FetchURI)
+				key := r.URL.Query().Get(util.KeyQueryParam)
+				called.Store(util.FetchURI+"/"+key, key)
 				w.Header().Set("Content-Type", "application/json")
 				sb := types.StorageBody{
-					Body:            []byte{},
+					Body:            []byte(key),
 					LocationId:      "foo-id",
 					LocationTarget:  "http://foo.io",
 					LocationIDValid: false,
+					Digest:          "sha256:" + key,
+					ModelCardKey:    key,
+					ModelCard:       "# " + key,
 				}
 				buf, _ := json.Marshal(&sb)
 				w.Write(buf)
 				w.WriteHeader(http.StatusOK)
+			case strings.Contains(r.URL.Path, util.WatchURI):
+				called.Store(util.WatchURI, r.URL.Query().Get(util.SinceQueryParam))
+				w.Header().Set("Content-Type", "application/json")
+				enc := json.NewEncoder(w)
+				enc.Encode(types.WatchEvent{Type: types.WatchEventAdded, Key: "foo_bar", Revision: "1", Value: &types.StorageBody{Body: []byte("foo-bar")}, Sequence: 10})
+				enc.Encode(types.WatchEvent{Type: types.WatchEventSynced, Sequence: 10})
 			}
 		case common.MethodPost:
 			switch r.URL.Path {
 			case util.CurrentKeySetURI:
 				w.Header().Set("Content-Type", "application/json")
 				queryParams := r.URL.Query()
+				// each source posts its own key set, so keep them apart
+				source := queryParams.Get(util.SourceQueryParam)
 				for k, v := range queryParams {
 					for _, vv := range v {
-						t.Logf("query param k %s vv %s", k, vv)
+						t.Logf("query param k %s vv %s source %s", k, vv, source)
+						if k == util.KeyQueryParam && len(source) > 0 {
+							called.Store(k+"/"+source, vv)
+						}
 						called.Store(k, vv)
 					}
 				}
 				_, _ = w.Write([]byte(" "))
 
+			case util.UpsertBatchURI:
+				batch := rest.BatchUpsertBody{}
+				err := json.NewDecoder(r.Body).Decode(&batch)
+				if err != nil {
+					w.WriteHeader(500)
+					return
+				}
+				// store each entry the way a single upsert of it would be, along with the keys of the batch
+				resp := rest.BatchUpsertResponse{}
+				keys := []string{}
+				for _, e := range batch.Entries {
+					keys = append(keys, e.Key)
+					if len(e.ModelCard) > 0 {
+						called.Store("hasModelCard", e.ModelCard)
+					}
+					bodyStr := string(e.Body)
+					called.Store(util.UpsertURI, bodyStr)
+					if len(e.Source) > 0 {
+						called.Store(util.SourceQueryParam+"/"+e.Key, e.Source)
+					}
+					query := url.Values{}
+					query.Set(util.KeyQueryParam, e.Key)
+					query.Set(util.TypeQueryParam, e.Type)
+					called.Store(query.Encode(), bodyStr)
+					resp.Results = append(resp.Results, rest.BatchUpsertResult{Key: e.Key, Status: http.StatusCreated})
+				}
+				called.Store(util.UpsertBatchURI, strings.Join(keys, ","))
+				w.Header().Set("Content-Type", "application/json")
+				buf, _ := json.Marshal(&resp)
+				_, _ = w.Write(buf)
+
 			default:
 				w.Header().Set("Content-Type", "application/json")
 				bodyBuf, err := io.ReadAll(r.Body)
@@ -94,7 +156,13 @@ func CreateBridgeStorageREST(t *testing.T, called *sync.Map) *httptest.Server {
                 bodyStr := string(data.Body)
 				t.Logf("got buf of len %d and storing buf under keys %s and %s with path in body %v rawquery in body %v", len(data.Body), r.URL.Path, r.URL.RawQuery, strings.Contains(bodyStr, r.URL.Path), strings.Contains(bodyStr, r.URL.RawQuery))
 				called.Store(r.URL.Path, bodyStr)
-				called.Store(r.URL.RawQuery, bodyStr)
+				// the source is stored on its own, so that the body is still found under the key and type
+				query := r.URL.Query()
+				if source := query.Get(util.SourceQueryParam); len(source) > 0 {
+					called.Store(util.SourceQueryParam+"/"+query.Get(util.KeyQueryParam), source)
+					query.Del(util.SourceQueryParam)
+				}
+				called.Store(query.Encode(), bodyStr)
 				_, _ = w.Write([]byte(fmt.Sprintf(common.TestPostJSONStringOneLinePlusBody, string(data.Body))))
 
 			}